@@ -40,6 +40,9 @@ func main() {
 		// 初始化 kafka client。
 		providers.KafkaFxModule,
 
+		// 初始化推送提供方。
+		providers.PushFxModule,
+
 		// 初始化 jwt manager。
 		providers.JwtManagerFxModule,
 
@@ -50,11 +53,17 @@ func main() {
 		providers.MiddlewareFxModule,
 
 		// 初始化 sharding。
-		providers.ShardingFxModule,
+		providers.NewShardingFxModule(),
+
+		// 初始化 id 生成器。
+		providers.IDGenFxModule,
 
 		// 初始化 dao。
 		dao.DaoFxModule,
 
+		// 检查分片表结构。
+		providers.ShardSchemaFxModule,
+
 		// 初始化 repo。
 		repo.RepoFxModule,
 
@@ -64,6 +73,9 @@ func main() {
 		// 初始化 web。
 		api.APIFxModule,
 
+		// 初始化 xmq worker。
+		providers.WorkerFxModule,
+
 		// 初始化 app。
 		app.AppFxModule,
 	).Run()
@@ -79,7 +91,7 @@ func loadConfig() error {
 		return fmt.Errorf("failed to read base config: %w", err)
 	}
 
-	subConfigNames := []string{"redis", "db", "mongodb", "kafka", "sharding"}
+	subConfigNames := []string{"redis", "db", "mongodb", "kafka", "push", "sharding"}
 	for _, subConfigName := range subConfigNames {
 		viper.SetConfigName(subConfigName)
 		if err := viper.MergeInConfig(); err != nil {
@@ -0,0 +1,190 @@
+// shardmigrate 将一个虚拟桶的数据在线迁移到新的物理分片。
+//
+// 使用示例：
+//
+//	# 查看虚拟桶 5 在源 / 目标分片上的行数和校验和
+//	go run ./cmd/shardmigrate -table biz_user -bucket 5 -to-db 1 -to-tb 2 -dry-run
+//
+//	# 执行迁移 ( 中断后使用相同的参数重新执行即可从进度继续 )
+//	go run ./cmd/shardmigrate -table biz_user -bucket 5 -to-db 1 -to-tb 2
+//
+// 只支持使用 bucket 分片策略并且在 sharding.yaml 中配置了 bucket_expr 的表，迁移期间业务进程需要正常运行 ( 双写以及切换路由由业务进程执行 )。
+// 迁移完成后需要将新的路由同步到 sharding.yaml 的 buckets 配置中。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jrmarcco/hermet/internal/pkg/providers"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
+	"github.com/jrmarcco/jit/xsync"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/exp/zapslog"
+	"gorm.io/gorm"
+)
+
+type options struct {
+	table     string
+	bucket    uint64
+	toDB      uint64
+	toTB      uint64
+	batchSize int
+	keyColumn string
+	dryRun    bool
+}
+
+type migrateDeps struct {
+	fx.In
+
+	Store       migrate.Store
+	Coordinator *migrate.Coordinator
+	Registry    *sharding.Registry           // 创建时会将虚拟桶分片策略注册到迁移协调器
+	DBs         *xsync.Map[string, *gorm.DB] `name:"db_sharding_clients"`
+	Logger      *zap.Logger
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.table, "table", "", "逻辑表名，与 sharding.yaml 中的名称一致，如 biz_user")
+	flag.Uint64Var(&opts.bucket, "bucket", 0, "需要迁移的虚拟桶 [ 0, 1023 ]")
+	flag.Uint64Var(&opts.toDB, "to-db", 0, "目标库后缀")
+	flag.Uint64Var(&opts.toTB, "to-tb", 0, "目标表后缀")
+	flag.IntVar(&opts.batchSize, "batch", 0, "每批复制的行数，为 0 时使用配置")
+	flag.StringVar(&opts.keyColumn, "key", "id", "主键列")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "只统计源 / 目标分片的数据，不执行迁移")
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		fmt.Fprintf(os.Stderr, "shard migrate failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options) error {
+	if opts.table == "" {
+		return errors.New("table is required")
+	}
+	if opts.bucket >= sharding.BucketCount {
+		return fmt.Errorf("invalid bucket [ %d ]", opts.bucket)
+	}
+
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	var deps migrateDeps
+	app := fx.New(
+		fx.NopLogger,
+
+		providers.ZapLoggerFxModule,
+		providers.RedisFxModule,
+		providers.DBFxModule,
+		providers.NewShardingFxModule(),
+
+		fx.Populate(&deps),
+	)
+	if err := app.Err(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.Start(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		_ = app.Stop(context.Background())
+	}()
+
+	return migrateBucket(ctx, opts, deps)
+}
+
+func migrateBucket(ctx context.Context, opts options, deps migrateDeps) error {
+	cfg, err := providers.LoadShardMigrationConfig()
+	if err != nil {
+		return err
+	}
+
+	// 虚拟桶的计算方式取决于逻辑表的分片键，必须在分片配置中显式声明，不能使用默认值。
+	spec, ok := deps.Registry.Spec(opts.table)
+	if !ok {
+		return fmt.Errorf("table [ %s ] is not configured", opts.table)
+	}
+	if spec.BucketExpr == "" {
+		return fmt.Errorf("bucket_expr of table [ %s ] is not configured in sharding.yaml", opts.table)
+	}
+
+	// 协调器启动时已经应用了之前迁移切换的路由，这里得到的是虚拟桶当前所在的分片。
+	strategy, ok := deps.Coordinator.Strategy(opts.table)
+	if !ok {
+		return fmt.Errorf("table [ %s ] is not using bucket sharding", opts.table)
+	}
+	source, err := strategy.DstFromBucket(opts.bucket)
+	if err != nil {
+		return err
+	}
+
+	task := migrate.Task{
+		Table:  opts.table,
+		Bucket: opts.bucket,
+		Source: source,
+		Target: strategy.DstOf(opts.toDB, opts.toTB),
+	}
+
+	batchSize := cfg.BatchSize
+	if opts.batchSize > 0 {
+		batchSize = opts.batchSize
+	}
+
+	logger := slog.New(zapslog.NewHandler(deps.Logger.Core()))
+	migrator, err := migrate.NewMigrator(deps.Store, deps.DBs, task, migrate.Config{
+		BatchSize:    batchSize,
+		KeyColumn:    opts.keyColumn,
+		BucketExpr:   spec.BucketExpr,
+		Propagation:  2 * cfg.RefreshInterval,
+		VerifyRounds: cfg.VerifyRounds,
+		DryRun:       opts.dryRun,
+	}, logger)
+	if err != nil {
+		return err
+	}
+
+	report, err := migrator.Run(ctx)
+	fmt.Printf("table: %s, bucket: %d, %s -> %s\n", task.Table, task.Bucket, task.Source.FullTable(), task.Target.FullTable())
+	fmt.Printf("phase: %s, copied: %d, repaired: %d\n", report.Phase, report.Copied, report.Repaired)
+	fmt.Printf("source rows: %d, checksum: %s\n", report.Source.RowCount, report.Source.Checksum)
+	fmt.Printf("target rows: %d, checksum: %s\n", report.Target.RowCount, report.Target.Checksum)
+	return err
+}
+
+func loadConfig() error {
+	viper.AddConfigPath("config")
+	viper.SetConfigType("yaml")
+
+	// 读取基础配置
+	viper.SetConfigName("base")
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read base config: %w", err)
+	}
+
+	subConfigNames := []string{"redis", "db", "sharding"}
+	for _, subConfigName := range subConfigNames {
+		viper.SetConfigName(subConfigName)
+		if err := viper.MergeInConfig(); err != nil {
+			return fmt.Errorf("failed to merge %s config: %w", subConfigName, err)
+		}
+	}
+
+	return nil
+}
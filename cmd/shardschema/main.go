@@ -0,0 +1,151 @@
+// shardschema 在分片表的所有物理表上执行版本化迁移 ( 迁移定义在 internal/repo/dao/schema_migrations.go )。
+//
+// 使用示例：
+//
+//	# 查看所有物理表的迁移状态
+//	go run ./cmd/shardschema -action status
+//
+//	# 执行所有逻辑表的迁移
+//	go run ./cmd/shardschema -action up
+//
+//	# 将 biz_user 的所有物理表迁移到版本 3
+//	go run ./cmd/shardschema -action up -table biz_user -version 3
+//
+//	# 回滚 biz_user 版本号大于 2 的迁移
+//	go run ./cmd/shardschema -action down -table biz_user -version 2
+//
+//	# 检查迁移历史与代码是否一致，不一致时退出码为 1
+//	go run ./cmd/shardschema -action check
+//
+// 每个库中的迁移历史记录在 sharding_schema_history 表中。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jrmarcco/hermet/internal/pkg/providers"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/schema"
+	"github.com/jrmarcco/hermet/internal/repo/dao"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+type options struct {
+	action  string
+	table   string
+	version uint64
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.action, "action", "status", "执行的操作: up、down、status 或 check")
+	flag.StringVar(&opts.table, "table", "", "逻辑表名，与 sharding.yaml 中的名称一致，为空时为所有逻辑表 ( down 必须指定 )")
+	flag.Uint64Var(&opts.version, "version", 0, "up: 迁移到的版本 ( 0 为最新 )；down: 回滚版本号大于该值的迁移")
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		fmt.Fprintf(os.Stderr, "shard schema failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	var migrator *schema.Migrator
+	app := fx.New(
+		fx.NopLogger,
+
+		providers.ZapLoggerFxModule,
+		providers.RedisFxModule,
+		providers.DBFxModule,
+		providers.NewShardingFxModule(),
+
+		fx.Provide(
+			dao.SchemaMigrations,
+			fx.Annotate(
+				providers.NewShardSchemaMigrator,
+				fx.ParamTags(`name:"db_sharding_clients"`),
+			),
+		),
+
+		fx.Populate(&migrator),
+	)
+	if err := app.Err(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.Start(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		_ = app.Stop(context.Background())
+	}()
+
+	switch opts.action {
+	case "up":
+		if err := migrator.Up(ctx, opts.table, opts.version); err != nil {
+			return err
+		}
+		return printStatus(ctx, migrator, opts.table)
+	case "down":
+		if err := migrator.Down(ctx, opts.table, opts.version); err != nil {
+			return err
+		}
+		return printStatus(ctx, migrator, opts.table)
+	case "status":
+		return printStatus(ctx, migrator, opts.table)
+	case "check":
+		return migrator.Check(ctx)
+	default:
+		return fmt.Errorf("invalid action [ %s ]", opts.action)
+	}
+}
+
+func printStatus(ctx context.Context, migrator *schema.Migrator, table string) error {
+	statuses, err := migrator.Status(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	drift := 0
+	for _, s := range statuses {
+		fmt.Println(s)
+		if s.Drift() {
+			drift++
+		}
+	}
+	fmt.Printf("tables: %d, drift: %d\n", len(statuses), drift)
+	return nil
+}
+
+func loadConfig() error {
+	viper.AddConfigPath("config")
+	viper.SetConfigType("yaml")
+
+	// 读取基础配置
+	viper.SetConfigName("base")
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read base config: %w", err)
+	}
+
+	subConfigNames := []string{"redis", "db", "sharding"}
+	for _, subConfigName := range subConfigNames {
+		viper.SetConfigName(subConfigName)
+		if err := viper.MergeInConfig(); err != nil {
+			return fmt.Errorf("failed to merge %s config: %w", subConfigName, err)
+		}
+	}
+
+	return nil
+}
@@ -1,11 +1,14 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
 	"github.com/jrmarcco/hermet/internal/pkg/xgin"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/worker"
 	"github.com/jrmarcco/hermet/internal/service"
 	"go.uber.org/zap"
 )
@@ -14,12 +17,14 @@ var _ xgin.RouteRegistry = (*AdminHandler)(nil)
 
 type AdminHandler struct {
 	userService service.UserService
+	redriver    *worker.Redriver
 	logger      *zap.Logger
 }
 
-func NewAdminHandler(userService service.UserService, logger *zap.Logger) *AdminHandler {
+func NewAdminHandler(userService service.UserService, redriver *worker.Redriver, logger *zap.Logger) *AdminHandler {
 	return &AdminHandler{
 		userService: userService,
+		redriver:    redriver,
 		logger:      logger,
 	}
 }
@@ -28,6 +33,7 @@ func (h *AdminHandler) Register(engine *gin.Engine) {
 	adminV1 := engine.Group("api/v1/admin")
 
 	adminV1.Handle(http.MethodPost, "/user", xgin.B(h.AddUser))
+	adminV1.Handle(http.MethodPost, "/xmq/redrive", xgin.B(h.Redrive))
 }
 
 type addUserRequest struct {
@@ -60,3 +66,35 @@ func (h *AdminHandler) AddUser(ctx *gin.Context, req addUserRequest) (xgin.R, er
 		Code: http.StatusOK,
 	}, nil
 }
+
+type redriveRequest struct {
+	DLQ string `json:"dlq"` // 死信 topic
+	Max int    `json:"max"` // 最多重新投递的消息数，<= 0 时不限制
+}
+
+type redriveResponse struct {
+	Count int `json:"count"`
+}
+
+// Redrive 将死信消息重新投递到死信 topic 所属消费者组的重新投递 topic。
+func (h *AdminHandler) Redrive(ctx *gin.Context, req redriveRequest) (xgin.R, error) {
+	if req.DLQ == "" {
+		return xgin.R{}, fmt.Errorf("%w: dlq topic is required", errs.ErrInvalidParam)
+	}
+
+	count, err := h.redriver.Redrive(ctx, req.DLQ, worker.RedriveOptions{Max: req.Max})
+	if err != nil {
+		h.logger.Error(
+			"[hermet-admin-handler] failed to redrive dlq",
+			zap.String("dlq", req.DLQ),
+			zap.Int("count", count),
+			zap.Error(err),
+		)
+		return xgin.R{}, err
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+		Data: redriveResponse{Count: count},
+	}, nil
+}
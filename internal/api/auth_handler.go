@@ -20,15 +20,25 @@ var _ xgin.RouteRegistry = (*AuthHandler)(nil)
 type AuthHandler struct {
 	xsession.Handler
 
-	svc    service.AuthService
-	logger *zap.Logger
+	svc         service.AuthService
+	pushSvc     service.PushService
+	presenceSvc service.PresenceService
+	logger      *zap.Logger
 }
 
-func NewAuthHandler(handler xsession.Handler, svc service.AuthService, logger *zap.Logger) *AuthHandler {
+func NewAuthHandler(
+	handler xsession.Handler,
+	svc service.AuthService,
+	pushSvc service.PushService,
+	presenceSvc service.PresenceService,
+	logger *zap.Logger,
+) *AuthHandler {
 	return &AuthHandler{
-		Handler: handler,
-		svc:     svc,
-		logger:  logger,
+		Handler:     handler,
+		svc:         svc,
+		pushSvc:     pushSvc,
+		presenceSvc: presenceSvc,
+		logger:      logger,
 	}
 }
 
@@ -137,6 +147,13 @@ func (h *AuthHandler) SignOut(ctx *gin.Context) (xgin.R, error) {
 		if err := h.ClearSession(ctx, au.SID); err != nil {
 			h.logger.Error("[hermet-user-handler] failed to clear session", zap.Error(err))
 		}
+		// 退出登录后不再向该会话的设备推送。
+		if err := h.pushSvc.UnregisterDevice(ctx, au.SID); err != nil {
+			h.logger.Error("[hermet-user-handler] failed to unregister push device", zap.Error(err))
+		}
+		if err := h.presenceSvc.Offline(ctx, au.UID, au.SID); err != nil {
+			h.logger.Error("[hermet-user-handler] failed to mark device offline", zap.Error(err))
+		}
 	}()
 	return xgin.R{
 		Code: http.StatusOK,
@@ -27,6 +27,7 @@ func (h *ChannelHandler) Register(engine *gin.Engine) {
 	channelv1 := engine.Group("api/v1/channel")
 
 	channelv1.Handle(http.MethodPost, "/create-group", xgin.BU(h.CreateGroup))
+	channelv1.Handle(http.MethodPost, "/dissolve-group", xgin.BU(h.DissolveGroup))
 }
 
 type createGroupRequest struct {
@@ -40,3 +41,19 @@ func (h *ChannelHandler) CreateGroup(_ *gin.Context, _ createGroupRequest, _ xgi
 	// TODO: not implemented
 	panic("not implemented")
 }
+
+type dissolveGroupRequest struct {
+	CID uint64 `json:"cid"`
+}
+
+// DissolveGroup 解散群组。
+// 仅群主可以解散，解散后群组变为只读，历史消息仍可查询。
+func (h *ChannelHandler) DissolveGroup(ctx *gin.Context, req dissolveGroupRequest, au xgin.ContextUser) (xgin.R, error) {
+	if err := h.svc.DissolveGroup(ctx, req.CID, au.UID); err != nil {
+		return xgin.R{}, err
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+	}, nil
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jrmarcco/hermet/internal/pkg/xgin"
+	"github.com/jrmarcco/hermet/internal/service"
+	"go.uber.org/zap"
+)
+
+var _ xgin.RouteRegistry = (*ConversationHandler)(nil)
+
+// ConversationHandler 会话 HTTP Handler。
+type ConversationHandler struct {
+	svc    service.ConversationService
+	logger *zap.Logger
+}
+
+func NewConversationHandler(svc service.ConversationService, logger *zap.Logger) *ConversationHandler {
+	return &ConversationHandler{
+		svc:    svc,
+		logger: logger,
+	}
+}
+
+func (h *ConversationHandler) Register(engine *gin.Engine) {
+	conversationV1 := engine.Group("api/v1/conversation")
+
+	conversationV1.Handle(http.MethodGet, "/list", xgin.QU(h.List))
+	conversationV1.Handle(http.MethodPost, "/read", xgin.BU(h.MarkRead))
+}
+
+type listConversationReq struct {
+	Offset int `form:"offset"`
+	Limit  int `form:"limit"`
+}
+
+type conversationResp struct {
+	CID                uint64 `json:"cid"`
+	ConversationType   string `json:"conversationType"`
+	ConversationName   string `json:"conversationName"`
+	ConversationAvatar string `json:"conversationAvatar"`
+	PeerUserID         uint64 `json:"peerUserId"`
+	IsMuted            bool   `json:"isMuted"`
+	IsPinned           bool   `json:"isPinned"`
+	LastMessageID      uint64 `json:"lastMessageId"`
+	LastMessageTime    int64  `json:"lastMessageTime"`
+	UnreadCount        int    `json:"unreadCount"`
+	MentionCount       int    `json:"mentionCount"`
+	Mentioned          bool   `json:"mentioned"` // 是否有未读的 @ 消息 ( 展示 "[@me]" )
+}
+
+// List 查询会话列表。
+func (h *ConversationHandler) List(ctx *gin.Context, req listConversationReq, au xgin.ContextUser) (xgin.R, error) {
+	conversations, err := h.svc.List(ctx, au.UID, req.Offset, req.Limit)
+	if err != nil {
+		return xgin.R{}, err
+	}
+
+	responses := make([]conversationResp, 0, len(conversations))
+	for i := range conversations {
+		responses = append(responses, conversationResp{
+			CID:                conversations[i].CID,
+			ConversationType:   string(conversations[i].ConversationType),
+			ConversationName:   conversations[i].ConversationName,
+			ConversationAvatar: conversations[i].ConversationAvatar,
+			PeerUserID:         conversations[i].PeerUserID,
+			IsMuted:            conversations[i].IsMuted,
+			IsPinned:           conversations[i].IsPinned,
+			LastMessageID:      conversations[i].LastMessageID,
+			LastMessageTime:    conversations[i].LastMessageTime,
+			UnreadCount:        conversations[i].UnreadCount,
+			MentionCount:       conversations[i].MentionCount,
+			Mentioned:          conversations[i].IsMentioned(),
+		})
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+		Data: responses,
+	}, nil
+}
+
+type markReadReq struct {
+	CID       uint64 `json:"cid"`
+	MessageID uint64 `json:"messageId"` // 已读到的消息 ID
+}
+
+// MarkRead 标记会话已读。
+func (h *ConversationHandler) MarkRead(ctx *gin.Context, req markReadReq, au xgin.ContextUser) (xgin.R, error) {
+	if err := h.svc.MarkRead(ctx, au.UID, req.CID, req.MessageID); err != nil {
+		return xgin.R{}, err
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+	}, nil
+}
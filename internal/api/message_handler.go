@@ -1 +1,311 @@
 package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/pkg/xgin"
+	"github.com/jrmarcco/hermet/internal/service"
+	commonv1 "github.com/jrmarcco/synp-api/api/go/common/v1"
+	"go.uber.org/zap"
+)
+
+var _ xgin.RouteRegistry = (*MessageHandler)(nil)
+
+// MessageHandler 消息 HTTP Handler。
+type MessageHandler struct {
+	svc    service.MessageService
+	logger *zap.Logger
+}
+
+func NewMessageHandler(svc service.MessageService, logger *zap.Logger) *MessageHandler {
+	return &MessageHandler{
+		svc:    svc,
+		logger: logger,
+	}
+}
+
+func (h *MessageHandler) Register(engine *gin.Engine) {
+	messageV1 := engine.Group("api/v1/message")
+
+	messageV1.Handle(http.MethodGet, "/history", xgin.QU(h.ListHistory))
+	messageV1.Handle(http.MethodGet, "/thread", xgin.QU(h.ListThread))
+	messageV1.Handle(http.MethodGet, "/edits", xgin.QU(h.ListEdits))
+
+	messageV1.Handle(http.MethodPost, "/send", xgin.BU(h.Send))
+	messageV1.Handle(http.MethodPost, "/recall", xgin.BU(h.Recall))
+	messageV1.Handle(http.MethodPost, "/edit", xgin.BU(h.Edit))
+	messageV1.Handle(http.MethodPost, "/delete", xgin.BU(h.DeleteForMe))
+	messageV1.Handle(http.MethodPost, "/reaction/add", xgin.BU(h.AddReaction))
+	messageV1.Handle(http.MethodPost, "/reaction/remove", xgin.BU(h.RemoveReaction))
+}
+
+type listHistoryReq struct {
+	CID      uint64 `form:"cid"`
+	BeforeID uint64 `form:"beforeId"` // 为 0 时从最新消息开始
+	Limit    int    `form:"limit"`
+}
+
+type messageResp struct {
+	ID          uint64          `json:"id"`
+	Seq         uint64          `json:"seq"` // 频道内的消息序列号
+	CID         uint64          `json:"cid"`
+	SID         uint64          `json:"sid"`
+	Content     json.RawMessage `json:"content"`
+	ContentType int32           `json:"contentType"`
+	Mentions    []uint64        `json:"mentions"`
+	MentionAll  bool            `json:"mentionAll"`
+	SendAt      int64           `json:"sendAt"`
+	RecalledAt  int64           `json:"recalledAt"` // 不为 0 时表示消息已撤回
+	EditVer     int             `json:"editVer"`
+	EditedAt    int64           `json:"editedAt"`
+	ReplyTo     *messageRefResp `json:"replyTo"` // 回复 / 引用的父消息快照
+	ThreadID    uint64          `json:"threadId"`
+	ReplyCount  int             `json:"replyCount"`
+	LastReplyAt int64           `json:"lastReplyAt"`
+	Reactions   []reactionResp  `json:"reactions"`
+}
+
+type reactionResp struct {
+	Emoji string   `json:"emoji"`
+	Count int      `json:"count"`
+	UIDs  []uint64 `json:"uids"` // 最近回应的用户
+}
+
+type messageRefResp struct {
+	ID          uint64          `json:"id"`
+	SID         uint64          `json:"sid"`
+	Content     json.RawMessage `json:"content"`
+	ContentType int32           `json:"contentType"`
+	SendAt      int64           `json:"sendAt"`
+	RecalledAt  int64           `json:"recalledAt"` // 不为 0 时表示父消息已撤回 ( 快照内容为空 )
+}
+
+func newMessageResp(message domain.Message) messageResp {
+	var replyTo *messageRefResp
+	if message.ReplyTo != nil {
+		replyTo = &messageRefResp{
+			ID:          message.ReplyTo.ID,
+			SID:         message.ReplyTo.SID,
+			Content:     json.RawMessage(message.ReplyTo.Content),
+			ContentType: int32(message.ReplyTo.ContentType),
+			SendAt:      message.ReplyTo.SendAt,
+			RecalledAt:  message.ReplyTo.RecalledAt,
+		}
+	}
+
+	reactions := make([]reactionResp, 0, len(message.Reactions))
+	for i := range message.Reactions {
+		reactions = append(reactions, reactionResp{
+			Emoji: message.Reactions[i].Emoji,
+			Count: message.Reactions[i].Count,
+			UIDs:  message.Reactions[i].UIDs,
+		})
+	}
+
+	return messageResp{
+		ID:          message.ID,
+		Seq:         message.Seq,
+		CID:         message.CID,
+		SID:         message.SID,
+		Content:     json.RawMessage(message.Content),
+		ContentType: int32(message.ContentType),
+		Mentions:    message.Mentions,
+		MentionAll:  message.MentionAll,
+		SendAt:      message.SendAt,
+		RecalledAt:  message.RecalledAt,
+		EditVer:     message.EditVer,
+		EditedAt:    message.EditedAt,
+		ReplyTo:     replyTo,
+		ThreadID:    message.ThreadID,
+		ReplyCount:  message.ReplyCount,
+		LastReplyAt: message.LastReplyAt,
+		Reactions:   reactions,
+	}
+}
+
+type sendMessageReq struct {
+	CID         uint64          `json:"cid"`
+	MID         uint64          `json:"mid"` // 客户端生成的消息 ID ( 用于去重 )
+	ContentType int32           `json:"contentType"`
+	Content     json.RawMessage `json:"content"`
+	Mentions    []uint64        `json:"mentions"`   // @ 的用户 ID 列表
+	MentionAll  bool            `json:"mentionAll"` // @所有人 ( 仅群主 / 管理员 )
+	ReplyTo     uint64          `json:"replyTo"`    // 回复 / 引用的消息 ID
+	ThreadID    uint64          `json:"threadId"`   // 在话题中回复时为话题根消息 ID
+}
+
+// Send 发送消息。
+func (h *MessageHandler) Send(ctx *gin.Context, req sendMessageReq, au xgin.ContextUser) (xgin.R, error) {
+	message := domain.Message{
+		CID:         req.CID,
+		SID:         au.UID,
+		MID:         req.MID,
+		Content:     req.Content,
+		ContentType: domain.ContentType(req.ContentType),
+		Mentions:    req.Mentions,
+		MentionAll:  req.MentionAll,
+		ThreadID:    req.ThreadID,
+	}
+	if req.ReplyTo != 0 {
+		message.ReplyTo = &domain.MessageRef{ID: req.ReplyTo}
+	}
+
+	message, err := h.svc.Send(ctx, message, commonv1.SerializeType_SERIALIZE_TYPE_JSON)
+	if err != nil {
+		return xgin.R{}, err
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+		Data: newMessageResp(message),
+	}, nil
+}
+
+// ListHistory 查询频道历史消息。
+func (h *MessageHandler) ListHistory(ctx *gin.Context, req listHistoryReq, au xgin.ContextUser) (xgin.R, error) {
+	messages, err := h.svc.ListHistory(ctx, au.UID, req.CID, req.BeforeID, req.Limit)
+	if err != nil {
+		return xgin.R{}, err
+	}
+
+	responses := make([]messageResp, 0, len(messages))
+	for i := range messages {
+		responses = append(responses, newMessageResp(messages[i]))
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+		Data: responses,
+	}, nil
+}
+
+type listThreadReq struct {
+	CID      uint64 `form:"cid"`
+	ThreadID uint64 `form:"threadId"` // 话题根消息 ID
+	AfterID  uint64 `form:"afterId"`  // 为 0 时从第一条回复开始
+	Limit    int    `form:"limit"`
+}
+
+// ListThread 查询话题回复。
+func (h *MessageHandler) ListThread(ctx *gin.Context, req listThreadReq, au xgin.ContextUser) (xgin.R, error) {
+	messages, err := h.svc.ListThread(ctx, au.UID, req.CID, req.ThreadID, req.AfterID, req.Limit)
+	if err != nil {
+		return xgin.R{}, err
+	}
+
+	responses := make([]messageResp, 0, len(messages))
+	for i := range messages {
+		responses = append(responses, newMessageResp(messages[i]))
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+		Data: responses,
+	}, nil
+}
+
+type messageIDReq struct {
+	CID uint64 `json:"cid" form:"cid"`
+	ID  uint64 `json:"id" form:"id"`
+}
+
+// Recall 撤回消息。
+func (h *MessageHandler) Recall(ctx *gin.Context, req messageIDReq, au xgin.ContextUser) (xgin.R, error) {
+	if err := h.svc.Recall(ctx, au.UID, req.CID, req.ID); err != nil {
+		return xgin.R{}, err
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+	}, nil
+}
+
+type editMessageReq struct {
+	CID     uint64          `json:"cid"`
+	ID      uint64          `json:"id"`
+	EditVer int             `json:"editVer"` // 客户端当前看到的消息版本号
+	Content json.RawMessage `json:"content"` // 文本消息内容，如 {"text": "..."}
+}
+
+// Edit 编辑消息。
+func (h *MessageHandler) Edit(ctx *gin.Context, req editMessageReq, au xgin.ContextUser) (xgin.R, error) {
+	message, err := h.svc.Edit(ctx, au.UID, req.CID, req.ID, req.EditVer, req.Content)
+	if err != nil {
+		return xgin.R{}, err
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+		Data: newMessageResp(message),
+	}, nil
+}
+
+type messageEditResp struct {
+	Ver      int             `json:"ver"`
+	Content  json.RawMessage `json:"content"`
+	EditedAt int64           `json:"editedAt"`
+}
+
+// ListEdits 查询消息编辑历史。
+func (h *MessageHandler) ListEdits(ctx *gin.Context, req messageIDReq, au xgin.ContextUser) (xgin.R, error) {
+	edits, err := h.svc.ListEdits(ctx, au.UID, req.CID, req.ID)
+	if err != nil {
+		return xgin.R{}, err
+	}
+
+	responses := make([]messageEditResp, 0, len(edits))
+	for i := range edits {
+		responses = append(responses, messageEditResp{
+			Ver:      edits[i].Ver,
+			Content:  json.RawMessage(edits[i].Content),
+			EditedAt: edits[i].EditedAt,
+		})
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+		Data: responses,
+	}, nil
+}
+
+// DeleteForMe 删除消息 ( 仅对自己不可见 )。
+func (h *MessageHandler) DeleteForMe(ctx *gin.Context, req messageIDReq, au xgin.ContextUser) (xgin.R, error) {
+	if err := h.svc.DeleteForMe(ctx, au.UID, req.CID, req.ID); err != nil {
+		return xgin.R{}, err
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+	}, nil
+}
+
+type reactionReq struct {
+	CID   uint64 `json:"cid"`
+	ID    uint64 `json:"id"`
+	Emoji string `json:"emoji"`
+}
+
+// AddReaction 添加表情回应。
+func (h *MessageHandler) AddReaction(ctx *gin.Context, req reactionReq, au xgin.ContextUser) (xgin.R, error) {
+	if err := h.svc.AddReaction(ctx, au.UID, req.CID, req.ID, req.Emoji); err != nil {
+		return xgin.R{}, err
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+	}, nil
+}
+
+// RemoveReaction 取消表情回应。
+func (h *MessageHandler) RemoveReaction(ctx *gin.Context, req reactionReq, au xgin.ContextUser) (xgin.R, error) {
+	if err := h.svc.RemoveReaction(ctx, au.UID, req.CID, req.ID, req.Emoji); err != nil {
+		return xgin.R{}, err
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+	}, nil
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/pkg/xgin"
+	"github.com/jrmarcco/hermet/internal/service"
+	"go.uber.org/zap"
+)
+
+var _ xgin.RouteRegistry = (*PresenceHandler)(nil)
+
+// PresenceHandler 在线状态 HTTP Handler。
+type PresenceHandler struct {
+	svc    service.PresenceService
+	logger *zap.Logger
+}
+
+func NewPresenceHandler(svc service.PresenceService, logger *zap.Logger) *PresenceHandler {
+	return &PresenceHandler{
+		svc:    svc,
+		logger: logger,
+	}
+}
+
+func (h *PresenceHandler) Register(engine *gin.Engine) {
+	presenceV1 := engine.Group("api/v1/presence")
+
+	presenceV1.Handle(http.MethodPost, "/heartbeat", xgin.U(h.Heartbeat))
+	presenceV1.Handle(http.MethodPost, "/offline", xgin.U(h.Offline))
+	presenceV1.Handle(http.MethodGet, "/query", xgin.QU(h.Query))
+
+	presenceV1.Handle(http.MethodGet, "/setting", xgin.U(h.GetSetting))
+	presenceV1.Handle(http.MethodPost, "/setting", xgin.BU(h.UpdateSetting))
+
+	presenceV1.Handle(http.MethodPost, "/typing", xgin.BU(h.Typing))
+}
+
+// Heartbeat 上报当前会话 ( 设备 ) 的心跳。
+func (h *PresenceHandler) Heartbeat(ctx *gin.Context, au xgin.ContextUser) (xgin.R, error) {
+	if err := h.svc.Heartbeat(ctx, au.UID, au.SID); err != nil {
+		return xgin.R{}, err
+	}
+	return xgin.R{
+		Code: http.StatusOK,
+	}, nil
+}
+
+// Offline 当前会话 ( 设备 ) 主动下线。
+func (h *PresenceHandler) Offline(ctx *gin.Context, au xgin.ContextUser) (xgin.R, error) {
+	if err := h.svc.Offline(ctx, au.UID, au.SID); err != nil {
+		return xgin.R{}, err
+	}
+	return xgin.R{
+		Code: http.StatusOK,
+	}, nil
+}
+
+type queryPresenceReq struct {
+	UIDs []uint64 `form:"uids"`
+}
+
+// Query 批量查询用户在线状态。
+func (h *PresenceHandler) Query(ctx *gin.Context, req queryPresenceReq, au xgin.ContextUser) (xgin.R, error) {
+	presences, err := h.svc.Query(ctx, au.UID, req.UIDs)
+	if err != nil {
+		return xgin.R{}, err
+	}
+	return xgin.R{
+		Code: http.StatusOK,
+		Data: presences,
+	}, nil
+}
+
+type presenceSettingReq struct {
+	Visibility string `json:"visibility"` // everyone / contacts / nobody
+}
+
+type presenceSettingResp struct {
+	Visibility string `json:"visibility"`
+}
+
+// GetSetting 查询在线状态隐私设置。
+func (h *PresenceHandler) GetSetting(ctx *gin.Context, au xgin.ContextUser) (xgin.R, error) {
+	setting, err := h.svc.GetSetting(ctx, au.UID)
+	if err != nil {
+		return xgin.R{}, err
+	}
+	return xgin.R{
+		Code: http.StatusOK,
+		Data: presenceSettingResp{
+			Visibility: string(setting.Visibility),
+		},
+	}, nil
+}
+
+// UpdateSetting 更新在线状态隐私设置。
+func (h *PresenceHandler) UpdateSetting(ctx *gin.Context, req presenceSettingReq, au xgin.ContextUser) (xgin.R, error) {
+	err := h.svc.UpdateSetting(ctx, domain.PresenceSetting{
+		UID:        au.UID,
+		Visibility: domain.PresenceVisibility(req.Visibility),
+	})
+	if err != nil {
+		return xgin.R{}, err
+	}
+	return xgin.R{
+		Code: http.StatusOK,
+		Msg:  "setting updated",
+	}, nil
+}
+
+type typingReq struct {
+	CID uint64 `json:"cid"`
+}
+
+// Typing 上报正在输入状态。
+func (h *PresenceHandler) Typing(ctx *gin.Context, req typingReq, au xgin.ContextUser) (xgin.R, error) {
+	if err := h.svc.Typing(ctx, au.UID, req.CID); err != nil {
+		return xgin.R{}, err
+	}
+	return xgin.R{
+		Code: http.StatusOK,
+	}, nil
+}
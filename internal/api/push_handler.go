@@ -0,0 +1,148 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/pkg/xgin"
+	"github.com/jrmarcco/hermet/internal/service"
+	"go.uber.org/zap"
+)
+
+const dndTimeLayout = "15:04"
+
+var _ xgin.RouteRegistry = (*PushHandler)(nil)
+
+// PushHandler 推送设备与推送设置 HTTP Handler。
+type PushHandler struct {
+	svc    service.PushService
+	logger *zap.Logger
+}
+
+func NewPushHandler(svc service.PushService, logger *zap.Logger) *PushHandler {
+	return &PushHandler{
+		svc:    svc,
+		logger: logger,
+	}
+}
+
+func (h *PushHandler) Register(engine *gin.Engine) {
+	pushV1 := engine.Group("api/v1/push")
+
+	pushV1.Handle(http.MethodPost, "/device/register", xgin.BU(h.RegisterDevice))
+	pushV1.Handle(http.MethodPost, "/device/unregister", xgin.U(h.UnregisterDevice))
+
+	pushV1.Handle(http.MethodGet, "/setting", xgin.U(h.GetSetting))
+	pushV1.Handle(http.MethodPost, "/setting", xgin.BU(h.UpdateSetting))
+}
+
+type registerDeviceReq struct {
+	Platform string `json:"platform"` // apns / fcm
+	Token    string `json:"token"`
+}
+
+// RegisterDevice 注册当前会话的推送设备 token。
+func (h *PushHandler) RegisterDevice(ctx *gin.Context, req registerDeviceReq, au xgin.ContextUser) (xgin.R, error) {
+	err := h.svc.RegisterDevice(ctx, domain.PushDevice{
+		UID:      au.UID,
+		SID:      au.SID,
+		Platform: domain.PushPlatform(req.Platform),
+		Token:    req.Token,
+	})
+	if err != nil {
+		return xgin.R{}, err
+	}
+	return xgin.R{
+		Code: http.StatusOK,
+		Msg:  "device registered",
+	}, nil
+}
+
+// UnregisterDevice 注销当前会话的推送设备。
+func (h *PushHandler) UnregisterDevice(ctx *gin.Context, au xgin.ContextUser) (xgin.R, error) {
+	if err := h.svc.UnregisterDevice(ctx, au.SID); err != nil {
+		return xgin.R{}, err
+	}
+	return xgin.R{
+		Code: http.StatusOK,
+		Msg:  "device unregistered",
+	}, nil
+}
+
+type pushSettingReq struct {
+	DNDEnabled bool   `json:"dndEnabled"`
+	DNDStart   string `json:"dndStart"` // HH:MM
+	DNDEnd     string `json:"dndEnd"`   // HH:MM
+	Timezone   string `json:"timezone"` // IANA 时区，如 Asia/Shanghai
+}
+
+type pushSettingResp struct {
+	DNDEnabled bool   `json:"dndEnabled"`
+	DNDStart   string `json:"dndStart"`
+	DNDEnd     string `json:"dndEnd"`
+	Timezone   string `json:"timezone"`
+}
+
+// GetSetting 查询推送设置。
+func (h *PushHandler) GetSetting(ctx *gin.Context, au xgin.ContextUser) (xgin.R, error) {
+	setting, err := h.svc.GetSetting(ctx, au.UID)
+	if err != nil {
+		return xgin.R{}, err
+	}
+	return xgin.R{
+		Code: http.StatusOK,
+		Data: pushSettingResp{
+			DNDEnabled: setting.DNDEnabled,
+			DNDStart:   formatDNDTime(setting.DNDStart),
+			DNDEnd:     formatDNDTime(setting.DNDEnd),
+			Timezone:   setting.Timezone,
+		},
+	}, nil
+}
+
+// UpdateSetting 更新推送设置 ( 免打扰时间段 )。
+func (h *PushHandler) UpdateSetting(ctx *gin.Context, req pushSettingReq, au xgin.ContextUser) (xgin.R, error) {
+	start, err := parseDNDTime(req.DNDStart)
+	if err != nil {
+		return xgin.R{}, err
+	}
+	end, err := parseDNDTime(req.DNDEnd)
+	if err != nil {
+		return xgin.R{}, err
+	}
+
+	err = h.svc.UpdateSetting(ctx, domain.PushSetting{
+		UID:        au.UID,
+		DNDEnabled: req.DNDEnabled,
+		DNDStart:   start,
+		DNDEnd:     end,
+		Timezone:   req.Timezone,
+	})
+	if err != nil {
+		return xgin.R{}, err
+	}
+	return xgin.R{
+		Code: http.StatusOK,
+		Msg:  "setting updated",
+	}, nil
+}
+
+// parseDNDTime 将 HH:MM 转换为当天的分钟数，为空时返回 0。
+func parseDNDTime(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse(dndTimeLayout, s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid dnd time [ %s ], expected HH:MM", errs.ErrInvalidParam, s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatDNDTime(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jrmarcco/hermet/internal/pkg/xgin"
+	"github.com/jrmarcco/hermet/internal/service"
+	"go.uber.org/zap"
+)
+
+var _ xgin.RouteRegistry = (*StreamHandler)(nil)
+
+// StreamHandler 实时投递 HTTP Handler ( server-sent events )。
+type StreamHandler struct {
+	router *service.MessageRouter
+	logger *zap.Logger
+}
+
+func NewStreamHandler(router *service.MessageRouter, logger *zap.Logger) *StreamHandler {
+	return &StreamHandler{
+		router: router,
+		logger: logger,
+	}
+}
+
+func (h *StreamHandler) Register(engine *gin.Engine) {
+	streamV1 := engine.Group("api/v1/stream")
+
+	streamV1.Handle(http.MethodGet, "", h.Stream)
+}
+
+// Stream 建立当前会话的实时投递连接。
+// 连接期间定时续期路由，连接断开后路由被删除，消息通过离线同步获取。
+func (h *StreamHandler) Stream(ctx *gin.Context) {
+	val, ok := ctx.Get(xgin.ContextKeyAuthUser)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	au, ok := val.(xgin.ContextUser)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	deliveries, disconnect, err := h.router.Connect(ctx, au.UID, au.SID)
+	if err != nil {
+		h.logger.Error("[hermet-stream-handler] failed to connect", zap.Uint64("uid", au.UID), zap.Error(err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer disconnect()
+
+	// 长连接不受 http server 写超时限制。
+	if err := http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Warn("[hermet-stream-handler] failed to clear write deadline", zap.Error(err))
+	}
+
+	keepAlive := time.NewTicker(h.router.RouteTTL() / 3)
+	defer keepAlive.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case <-keepAlive.C:
+			if err := h.router.KeepAlive(ctx, au.UID, au.SID); err != nil {
+				h.logger.Error("[hermet-stream-handler] failed to keep route alive", zap.Uint64("uid", au.UID), zap.Error(err))
+			}
+			ctx.SSEvent("ping", time.Now().UnixMilli())
+			return true
+		case delivery, ok := <-deliveries:
+			if !ok {
+				// 同一会话建立了新的连接。
+				return false
+			}
+			ctx.SSEvent(delivery.EventType, delivery)
+			return true
+		}
+	})
+}
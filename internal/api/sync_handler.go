@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jrmarcco/hermet/internal/pkg/xgin"
+	"github.com/jrmarcco/hermet/internal/service"
+	"go.uber.org/zap"
+)
+
+var _ xgin.RouteRegistry = (*SyncHandler)(nil)
+
+// SyncHandler 离线同步 HTTP Handler。
+type SyncHandler struct {
+	svc    service.SyncService
+	logger *zap.Logger
+}
+
+func NewSyncHandler(svc service.SyncService, logger *zap.Logger) *SyncHandler {
+	return &SyncHandler{
+		svc:    svc,
+		logger: logger,
+	}
+}
+
+func (h *SyncHandler) Register(engine *gin.Engine) {
+	syncV1 := engine.Group("api/v1/sync")
+
+	syncV1.Handle(http.MethodGet, "/messages", xgin.QU(h.SyncMessages))
+}
+
+type syncMessagesReq struct {
+	SinceSeq uint64 `form:"sinceSeq"` // 上一次同步返回的 nextSeq
+	Limit    int    `form:"limit"`
+}
+
+type syncEntryResp struct {
+	Seq     uint64      `json:"seq"`
+	Message messageResp `json:"message"`
+}
+
+type syncMessagesResp struct {
+	Entries    []syncEntryResp `json:"entries"`
+	NextSeq    uint64          `json:"nextSeq"`
+	LatestSeq  uint64          `json:"latestSeq"`
+	HasMore    bool            `json:"hasMore"`
+	FullResync bool            `json:"fullResync"` // 为 true 时客户端需要全量同步
+}
+
+// SyncMessages 离线消息增量同步。
+func (h *SyncHandler) SyncMessages(ctx *gin.Context, req syncMessagesReq, au xgin.ContextUser) (xgin.R, error) {
+	res, err := h.svc.Sync(ctx, au.UID, req.SinceSeq, req.Limit)
+	if err != nil {
+		return xgin.R{}, err
+	}
+
+	entries := make([]syncEntryResp, 0, len(res.Entries))
+	for i := range res.Entries {
+		entries = append(entries, syncEntryResp{
+			Seq:     res.Entries[i].Seq,
+			Message: newMessageResp(res.Entries[i].Message),
+		})
+	}
+
+	return xgin.R{
+		Code: http.StatusOK,
+		Data: syncMessagesResp{
+			Entries:    entries,
+			NextSeq:    res.NextSeq,
+			LatestSeq:  res.LatestSeq,
+			HasMore:    res.HasMore,
+			FullResync: res.FullResync,
+		},
+	}, nil
+}
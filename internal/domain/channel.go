@@ -7,6 +7,28 @@ const (
 	ChannelTypeGroup  = ChannelType("group")
 )
 
+type ChannelStatus string
+
+const (
+	ChannelStatusCreating ChannelStatus = "creating"
+	ChannelStatusActive   ChannelStatus = "active"
+	ChannelStatusFailed   ChannelStatus = "failed"
+	ChannelStatusArchived ChannelStatus = "archived" // 已归档 ( 解散后只读，历史消息仍可查询 )
+)
+
+// IsWritable 判断频道是否允许写入 ( 发送消息 / 变更成员等 )。
+func (cs ChannelStatus) IsWritable() bool {
+	return cs == ChannelStatusActive
+}
+
+type ChannelMemberRole string
+
+const (
+	ChannelMemberRoleOwner  ChannelMemberRole = "owner"
+	ChannelMemberRoleAdmin  ChannelMemberRole = "admin"
+	ChannelMemberRoleMember ChannelMemberRole = "member"
+)
+
 // Channel 是整个 im 的核心抽象，表示一个聊天频道。
 // 频道可以是单聊/群聊。
 // 所有的通信都可以看作是发送到特定的频道，频道中的所有人都会收到消息。
@@ -16,7 +38,17 @@ type Channel struct {
 	ChannelName string      `json:"channelName"`
 	ChannelType ChannelType `json:"channelType"`
 
+	ChannelStatus ChannelStatus `json:"channelStatus"`
+	MemberCount   int           `json:"memberCount"`
+	Creator       uint64        `json:"creator"`
+
+	LastMessageAt     int64 `json:"lastMessageAt"`     // 最后消息时间戳 ( Unix 毫秒值 )
+	MessageArchivedAt int64 `json:"messageArchivedAt"` // 消息归档时间戳 ( Unix 毫秒值，0 表示未归档 )
+
 	Self ChannelMember `json:"self"` // 当前用户在频道中的成员信息
+
+	CreatedAt int64 `json:"createdAt"`
+	UpdatedAt int64 `json:"updatedAt"`
 }
 
 // ChannelMember 是频道中的成员。
@@ -24,8 +56,8 @@ type ChannelMember struct {
 	CID uint64 `json:"cid"`
 	UID uint64 `json:"uid"`
 
-	UserRole       string `json:"userRole"`
-	UserProfileVer int    `json:"userProfileVer"`
+	UserRole       ChannelMemberRole `json:"userRole"`
+	UserProfileVer int               `json:"userProfileVer"`
 
 	Avatar        string `json:"avatar"`
 	Alias         string `json:"alias"`
@@ -34,6 +66,7 @@ type ChannelMember struct {
 
 	Mute   bool  `json:"mute"`
 	JoinAt int64 `json:"joinAt"`
+	LeftAt int64 `json:"leftAt"` // 退出时间戳 ( 0 表示仍在频道中 )
 }
 
 // ChannelReadRecord 是频道中消息阅读记录。
@@ -11,6 +11,8 @@ const (
 	ChannelEventTypeMemberAdded ChannelEventType = "channel.member.added"
 	// ChannelEventTypeMemberRemoved 频道成员移除事件。
 	ChannelEventTypeMemberRemoved ChannelEventType = "channel.member.removed"
+	// ChannelEventTypeDissolved 频道解散事件。
+	ChannelEventTypeDissolved ChannelEventType = "channel.dissolved"
 )
 
 // ChannelCreatedEvent 频道创建事件。
@@ -38,3 +40,13 @@ type ChannelMemberRemovedEvent struct {
 	MemberIDs []uint64 `json:"memberIds"` // 新增的成员 ID 列表
 	Operator  uint64   `json:"operator"`  // 操作者
 }
+
+// ChannelDissolvedEvent 频道解散事件。
+// 解散后频道变为只读 ( archived )，历史消息仍可查询。
+type ChannelDissolvedEvent struct {
+	CID       uint64   `json:"cid"`
+	MemberIDs []uint64 `json:"memberIds"` // 解散时仍在频道中的成员 ID 列表
+	Operator  uint64   `json:"operator"`  // 操作者 ( 群主 )
+
+	DissolvedAt int64 `json:"dissolvedAt"`
+}
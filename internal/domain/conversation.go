@@ -0,0 +1,39 @@
+package domain
+
+// Conversation 是用户的会话 ( 对应读取侧 user_conversation_view )。
+type Conversation struct {
+	ID uint64 `json:"id"`
+
+	UID uint64 `json:"uid"`
+	CID uint64 `json:"cid"`
+
+	ConversationType   ChannelType `json:"conversationType"`
+	ConversationName   string      `json:"conversationName"`
+	ConversationAvatar string      `json:"conversationAvatar"`
+
+	PeerUserID uint64 `json:"peerUserId"` // 单聊对方用户 ID ( 群聊为 0 )
+
+	IsMuted  bool `json:"isMuted"`
+	IsPinned bool `json:"isPinned"`
+	IsHidden bool `json:"isHidden"`
+
+	LastMessageID   uint64 `json:"lastMessageId"`
+	LastMessageTime int64  `json:"lastMessageTime"`
+
+	UnreadCount          int    `json:"unreadCount"`
+	MentionCount         int    `json:"mentionCount"`
+	LastMentionMessageID uint64 `json:"lastMentionMessageId"` // 最后一条 @我的消息 ID ( 0 表示没有未读的 @ 消息 )
+
+	OpenedAt int64 `json:"openedAt"`
+	ClosedAt int64 `json:"closedAt"` // 会话关闭时间戳 ( 0 表示未关闭 )
+}
+
+// IsClosed 判断会话是否已关闭 ( 删除会话 / 频道解散 )。
+func (c Conversation) IsClosed() bool {
+	return c.ClosedAt != 0
+}
+
+// IsMentioned 判断会话中是否有未读的 @ 消息 ( 会话列表展示 "[@me]" )。
+func (c Conversation) IsMentioned() bool {
+	return c.LastMentionMessageID != 0
+}
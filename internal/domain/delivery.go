@@ -0,0 +1,19 @@
+package domain
+
+import "encoding/json"
+
+// Delivery 实时投递信封，由路由层投递到用户连接所在的节点。
+type Delivery struct {
+	EventType string `json:"eventType"` // 事件类型，如 message.sent
+
+	CID uint64 `json:"cid"`
+	// UIDs 接收者，广播投递时为空 ( 由节点根据 CID 查询本地连接的频道成员 )。
+	UIDs []uint64 `json:"uids"`
+
+	Payload json.RawMessage `json:"payload"`
+}
+
+// IsBroadcast 判断是否为广播投递 ( 大群 )。
+func (d Delivery) IsBroadcast() bool {
+	return len(d.UIDs) == 0
+}
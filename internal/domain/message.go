@@ -1,18 +1,88 @@
 package domain
 
+// ContentType 消息内容类型。
+// 每种类型对应一个内容结构体，见 message_content.go。
 type ContentType int32
 
+const (
+	ContentTypeText     ContentType = 1 // 文本
+	ContentTypeImage    ContentType = 2 // 图片
+	ContentTypeFile     ContentType = 3 // 文件
+	ContentTypeVoice    ContentType = 4 // 语音
+	ContentTypeVideo    ContentType = 5 // 视频
+	ContentTypeLocation ContentType = 6 // 位置
+	ContentTypeCard     ContentType = 7 // 名片
+	ContentTypeSystem   ContentType = 8 // 系统消息 ( 仅服务端发送 )
+	ContentTypeCustom   ContentType = 9 // 自定义消息 ( 由业务方约定格式 )
+)
+
 // Message 对应网关消息中的 body 的具体格式。
 type Message struct {
-	ID uint64 `json:"id"`
+	ID  uint64 `json:"id"`
+	Seq uint64 `json:"seq"` // 频道内的消息序列号 ( 从 1 开始递增，可能存在空洞，用于客户端排序和检测缺失的消息 )
 
 	CID uint64 `json:"cid"` // channel id
 	SID uint64 `json:"sid"` // sender id
 
 	MID uint64 `json:"mid"` // 这里指的是网关消息的唯一 id
 
-	Content     []byte      `json:"content"`
+	Content     []byte      `json:"content"` // 消息内容 ( 统一使用 json 编码存储 )
 	ContentType ContentType `json:"contentType"`
 
+	Mentions   []uint64 `json:"mentions"`   // @ 的用户 ID 列表
+	MentionAll bool     `json:"mentionAll"` // 是否 @所有人 ( 仅群主 / 管理员 )
+
 	SendAt int64 `json:"sendAt"` // 消息发送时间 ( 统一使用服务器时间 )
+
+	RecalledAt int64 `json:"recalledAt"` // 撤回时间 ( 0 表示未撤回 )
+	EditVer    int   `json:"editVer"`    // 编辑版本号 ( 0 表示未编辑过 )
+	EditedAt   int64 `json:"editedAt"`   // 最后编辑时间
+
+	ReplyTo *MessageRef `json:"replyTo"` // 回复 / 引用的父消息快照 ( nil 表示不是回复 )
+
+	ThreadID    uint64 `json:"threadId"`    // 所属话题的根消息 ID ( 0 表示不在话题中 )
+	ReplyCount  int    `json:"replyCount"`  // 话题回复数 ( 仅根消息有值 )
+	LastReplyAt int64  `json:"lastReplyAt"` // 话题最后回复时间 ( 仅根消息有值 )
+
+	Reactions []MessageReaction `json:"reactions"` // 表情回应汇总 ( 按回应数倒序 )
+}
+
+// IsRecalled 判断消息是否已撤回。
+func (m Message) IsRecalled() bool {
+	return m.RecalledAt != 0
+}
+
+// IsInThread 判断消息是否为话题中的回复。
+func (m Message) IsInThread() bool {
+	return m.ThreadID != 0
+}
+
+// MessageRef 被回复 / 引用的消息快照。
+// 保存回复时父消息的内容，父消息之后被编辑不会影响快照，父消息撤回后快照内容会被清空。
+type MessageRef struct {
+	ID  uint64 `json:"id"`
+	SID uint64 `json:"sid"`
+
+	Content     []byte      `json:"content"`
+	ContentType ContentType `json:"contentType"`
+
+	SendAt     int64 `json:"sendAt"`
+	RecalledAt int64 `json:"recalledAt"` // 被引用消息的撤回时间 ( 撤回后 Content 为空 )
+}
+
+// MessageReaction 单个表情的回应汇总。
+type MessageReaction struct {
+	Emoji string   `json:"emoji"`
+	Count int      `json:"count"`
+	UIDs  []uint64 `json:"uids"` // 最近回应的用户 ( 数量有上限，不代表全部回应用户 )
+}
+
+// MessageEdit 消息编辑记录 ( 保存被覆盖前的内容 )。
+type MessageEdit struct {
+	MessageID uint64 `json:"messageId"`
+	Ver       int    `json:"ver"` // 被覆盖内容对应的版本号
+
+	Content []byte `json:"content"`
+
+	EditedAt int64 `json:"editedAt"`
 }
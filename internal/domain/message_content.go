@@ -0,0 +1,324 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"unicode/utf8"
+
+	"github.com/jrmarcco/hermet/internal/errs"
+	commonv1 "github.com/jrmarcco/synp-api/api/go/common/v1"
+)
+
+// MessageContent 消息内容，每种 ContentType 对应一个实现。
+type MessageContent interface {
+	ContentType() ContentType
+	// Validate 校验内容是否合法 ( 服务端校验，不信任客户端 )。
+	Validate() error
+}
+
+// ProtoContent 支持 protobuf 编码的消息内容。
+// protobuf 编码是可选的，只有实现了该接口的内容类型才支持 SERIALIZE_TYPE_PROTOBUF，
+// 编码格式需要与 synp-api 中定义的 proto 保持一致。
+type ProtoContent interface {
+	MessageContent
+
+	MarshalProto() ([]byte, error)
+	UnmarshalProto(data []byte) error
+}
+
+const (
+	maxTextLen       = 5000 // 文本消息最大字符数
+	maxVoiceDuration = 60   // 语音消息最大时长 ( 秒 )
+	maxCustomTypeLen = 64   // 自定义消息类型标识的最大长度
+)
+
+type contentSpec struct {
+	maxSize    int // 编码后的最大字节数
+	newContent func() MessageContent
+}
+
+var contentRegistry = map[ContentType]contentSpec{}
+
+// RegisterContentType 注册消息内容类型。
+// 只能在初始化阶段调用 ( 非并发安全 )，重复注册会 panic。
+func RegisterContentType(ct ContentType, maxSize int, newContent func() MessageContent) {
+	if _, ok := contentRegistry[ct]; ok {
+		panic(fmt.Sprintf("content type [ %d ] already registered", ct))
+	}
+	contentRegistry[ct] = contentSpec{
+		maxSize:    maxSize,
+		newContent: newContent,
+	}
+}
+
+func init() {
+	RegisterContentType(ContentTypeText, 16<<10, func() MessageContent { return &TextContent{} })
+	RegisterContentType(ContentTypeImage, 4<<10, func() MessageContent { return &ImageContent{} })
+	RegisterContentType(ContentTypeFile, 4<<10, func() MessageContent { return &FileContent{} })
+	RegisterContentType(ContentTypeVoice, 4<<10, func() MessageContent { return &VoiceContent{} })
+	RegisterContentType(ContentTypeVideo, 4<<10, func() MessageContent { return &VideoContent{} })
+	RegisterContentType(ContentTypeLocation, 4<<10, func() MessageContent { return &LocationContent{} })
+	RegisterContentType(ContentTypeCard, 4<<10, func() MessageContent { return &CardContent{} })
+	RegisterContentType(ContentTypeSystem, 16<<10, func() MessageContent { return &SystemContent{} })
+	RegisterContentType(ContentTypeCustom, 64<<10, func() MessageContent { return &CustomContent{} })
+}
+
+// IsRegistered 判断内容类型是否已注册。
+func (ct ContentType) IsRegistered() bool {
+	_, ok := contentRegistry[ct]
+	return ok
+}
+
+// DecodeContent 按照序列化类型解码消息内容并校验。
+// SERIALIZE_TYPE_UNSPECIFIED 按 json 处理。
+func DecodeContent(ct ContentType, st commonv1.SerializeType, data []byte) (MessageContent, error) {
+	spec, ok := contentRegistry[ct]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown content type [ %d ]", errs.ErrInvalidParam, ct)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: content is empty", errs.ErrInvalidParam)
+	}
+	if len(data) > spec.maxSize {
+		return nil, fmt.Errorf("%w: content size exceeds limit [ %d ]", errs.ErrInvalidParam, spec.maxSize)
+	}
+
+	content := spec.newContent()
+	switch st {
+	case commonv1.SerializeType_SERIALIZE_TYPE_UNSPECIFIED, commonv1.SerializeType_SERIALIZE_TYPE_JSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(content); err != nil {
+			return nil, fmt.Errorf("%w: malformed content: %s", errs.ErrInvalidParam, err.Error())
+		}
+	case commonv1.SerializeType_SERIALIZE_TYPE_PROTOBUF:
+		pc, ok := content.(ProtoContent)
+		if !ok {
+			return nil, fmt.Errorf("%w: content type [ %d ] does not support protobuf", errs.ErrInvalidParam, ct)
+		}
+		if err := pc.UnmarshalProto(data); err != nil {
+			return nil, fmt.Errorf("%w: malformed content: %s", errs.ErrInvalidParam, err.Error())
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown serialize type [ %d ]", errs.ErrInvalidParam, st)
+	}
+
+	if err := content.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %s", errs.ErrInvalidParam, err.Error())
+	}
+	return content, nil
+}
+
+// EncodeContent 按照序列化类型编码消息内容。
+// SERIALIZE_TYPE_UNSPECIFIED 按 json 处理。
+func EncodeContent(content MessageContent, st commonv1.SerializeType) ([]byte, error) {
+	switch st {
+	case commonv1.SerializeType_SERIALIZE_TYPE_UNSPECIFIED, commonv1.SerializeType_SERIALIZE_TYPE_JSON:
+		return json.Marshal(content)
+	case commonv1.SerializeType_SERIALIZE_TYPE_PROTOBUF:
+		pc, ok := content.(ProtoContent)
+		if !ok {
+			return nil, fmt.Errorf("content type [ %d ] does not support protobuf", content.ContentType())
+		}
+		return pc.MarshalProto()
+	default:
+		return nil, fmt.Errorf("unknown serialize type [ %d ]", st)
+	}
+}
+
+// TextContent 文本消息。
+type TextContent struct {
+	Text string `json:"text"`
+}
+
+func (c *TextContent) ContentType() ContentType { return ContentTypeText }
+
+func (c *TextContent) Validate() error {
+	if c.Text == "" {
+		return fmt.Errorf("text is empty")
+	}
+	if !utf8.ValidString(c.Text) {
+		return fmt.Errorf("text is not valid utf-8")
+	}
+	if utf8.RuneCountInString(c.Text) > maxTextLen {
+		return fmt.Errorf("text exceeds [ %d ] characters", maxTextLen)
+	}
+	return nil
+}
+
+// ImageContent 图片消息。
+type ImageContent struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Size         int64  `json:"size"` // 文件大小 ( 字节 )
+}
+
+func (c *ImageContent) ContentType() ContentType { return ContentTypeImage }
+
+func (c *ImageContent) Validate() error {
+	if err := validateURL("url", c.URL, true); err != nil {
+		return err
+	}
+	if err := validateURL("thumbnailUrl", c.ThumbnailURL, false); err != nil {
+		return err
+	}
+	if c.Width <= 0 || c.Height <= 0 {
+		return fmt.Errorf("invalid image size [ %d x %d ]", c.Width, c.Height)
+	}
+	return nil
+}
+
+// FileContent 文件消息。
+type FileContent struct {
+	URL      string `json:"url"`
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+	Size     int64  `json:"size"` // 文件大小 ( 字节 )
+}
+
+func (c *FileContent) ContentType() ContentType { return ContentTypeFile }
+
+func (c *FileContent) Validate() error {
+	if err := validateURL("url", c.URL, true); err != nil {
+		return err
+	}
+	if c.Name == "" {
+		return fmt.Errorf("file name is empty")
+	}
+	if c.Size <= 0 {
+		return fmt.Errorf("invalid file size [ %d ]", c.Size)
+	}
+	return nil
+}
+
+// VoiceContent 语音消息。
+type VoiceContent struct {
+	URL      string `json:"url"`
+	Duration int    `json:"duration"` // 时长 ( 秒 )
+	Size     int64  `json:"size"`     // 文件大小 ( 字节 )
+}
+
+func (c *VoiceContent) ContentType() ContentType { return ContentTypeVoice }
+
+func (c *VoiceContent) Validate() error {
+	if err := validateURL("url", c.URL, true); err != nil {
+		return err
+	}
+	if c.Duration <= 0 || c.Duration > maxVoiceDuration {
+		return fmt.Errorf("voice duration must be in (0, %d]", maxVoiceDuration)
+	}
+	return nil
+}
+
+// VideoContent 视频消息。
+type VideoContent struct {
+	URL      string `json:"url"`
+	CoverURL string `json:"coverUrl"`
+	Duration int    `json:"duration"` // 时长 ( 秒 )
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Size     int64  `json:"size"` // 文件大小 ( 字节 )
+}
+
+func (c *VideoContent) ContentType() ContentType { return ContentTypeVideo }
+
+func (c *VideoContent) Validate() error {
+	if err := validateURL("url", c.URL, true); err != nil {
+		return err
+	}
+	if err := validateURL("coverUrl", c.CoverURL, false); err != nil {
+		return err
+	}
+	if c.Duration <= 0 {
+		return fmt.Errorf("invalid video duration [ %d ]", c.Duration)
+	}
+	return nil
+}
+
+// LocationContent 位置消息。
+type LocationContent struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Name      string  `json:"name"`
+	Address   string  `json:"address"`
+}
+
+func (c *LocationContent) ContentType() ContentType { return ContentTypeLocation }
+
+func (c *LocationContent) Validate() error {
+	if c.Latitude < -90 || c.Latitude > 90 {
+		return fmt.Errorf("invalid latitude [ %f ]", c.Latitude)
+	}
+	if c.Longitude < -180 || c.Longitude > 180 {
+		return fmt.Errorf("invalid longitude [ %f ]", c.Longitude)
+	}
+	return nil
+}
+
+// CardContent 名片消息 ( 分享用户名片 )。
+type CardContent struct {
+	UID      uint64 `json:"uid"`
+	Nickname string `json:"nickname"`
+	Avatar   string `json:"avatar"`
+}
+
+func (c *CardContent) ContentType() ContentType { return ContentTypeCard }
+
+func (c *CardContent) Validate() error {
+	if c.UID == 0 {
+		return fmt.Errorf("card uid is empty")
+	}
+	return nil
+}
+
+// SystemContent 系统消息 ( 如入群 / 退群提示 )。
+type SystemContent struct {
+	Text string            `json:"text"`
+	Args map[string]string `json:"args"` // 模板参数，由客户端渲染
+}
+
+func (c *SystemContent) ContentType() ContentType { return ContentTypeSystem }
+
+func (c *SystemContent) Validate() error {
+	if c.Text == "" {
+		return fmt.Errorf("text is empty")
+	}
+	return nil
+}
+
+// CustomContent 自定义消息，Data 的格式由业务方根据 Type 约定。
+type CustomContent struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+func (c *CustomContent) ContentType() ContentType { return ContentTypeCustom }
+
+func (c *CustomContent) Validate() error {
+	if c.Type == "" || len(c.Type) > maxCustomTypeLen {
+		return fmt.Errorf("custom type length must be in (0, %d]", maxCustomTypeLen)
+	}
+	if len(c.Data) == 0 {
+		return fmt.Errorf("custom data is empty")
+	}
+	return nil
+}
+
+func validateURL(field, raw string, required bool) error {
+	if raw == "" {
+		if required {
+			return fmt.Errorf("%s is empty", field)
+		}
+		return nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s is not a valid http(s) url", field)
+	}
+	return nil
+}
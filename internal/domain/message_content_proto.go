@@ -0,0 +1,351 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 消息内容的 protobuf 编码，字段编号如下 ( synp-api 中定义对应的 proto 时需要保持一致 )：
+//
+//	TextContent     { string text = 1; }
+//	ImageContent    { string url = 1; string thumbnail_url = 2; int32 width = 3; int32 height = 4; int64 size = 5; }
+//	FileContent     { string url = 1; string name = 2; string mime_type = 3; int64 size = 4; }
+//	VoiceContent    { string url = 1; int32 duration = 2; int64 size = 3; }
+//	VideoContent    { string url = 1; string cover_url = 2; int32 duration = 3; int32 width = 4; int32 height = 5; int64 size = 6; }
+//	LocationContent { double latitude = 1; double longitude = 2; string name = 3; string address = 4; }
+//	CardContent     { uint64 uid = 1; string nickname = 2; string avatar = 3; }
+//	CustomContent   { string type = 1; bytes data = 2; } // data 必须是合法的 json
+//
+// 系统消息只由服务端生成，不支持 protobuf 编码。
+
+// 字段编号。
+const (
+	field1 protowire.Number = iota + 1
+	field2
+	field3
+	field4
+	field5
+	field6
+)
+
+var (
+	_ ProtoContent = (*TextContent)(nil)
+	_ ProtoContent = (*ImageContent)(nil)
+	_ ProtoContent = (*FileContent)(nil)
+	_ ProtoContent = (*VoiceContent)(nil)
+	_ ProtoContent = (*VideoContent)(nil)
+	_ ProtoContent = (*LocationContent)(nil)
+	_ ProtoContent = (*CardContent)(nil)
+	_ ProtoContent = (*CustomContent)(nil)
+)
+
+func (c *TextContent) MarshalProto() ([]byte, error) {
+	var b protoBuilder
+	b.string(field1, c.Text)
+	return b, nil
+}
+
+func (c *TextContent) UnmarshalProto(data []byte) error {
+	return parseProto(data, func(num protowire.Number, f protoField) (err error) {
+		if num == field1 {
+			c.Text, err = f.string()
+		}
+		return err
+	})
+}
+
+func (c *ImageContent) MarshalProto() ([]byte, error) {
+	var b protoBuilder
+	b.string(field1, c.URL)
+	b.string(field2, c.ThumbnailURL)
+	b.int(field3, int64(c.Width))
+	b.int(field4, int64(c.Height))
+	b.int(field5, c.Size)
+	return b, nil
+}
+
+func (c *ImageContent) UnmarshalProto(data []byte) error {
+	return parseProto(data, func(num protowire.Number, f protoField) (err error) {
+		switch num {
+		case field1:
+			c.URL, err = f.string()
+		case field2:
+			c.ThumbnailURL, err = f.string()
+		case field3:
+			c.Width, err = f.int()
+		case field4:
+			c.Height, err = f.int()
+		case field5:
+			c.Size, err = f.int64()
+		}
+		return err
+	})
+}
+
+func (c *FileContent) MarshalProto() ([]byte, error) {
+	var b protoBuilder
+	b.string(field1, c.URL)
+	b.string(field2, c.Name)
+	b.string(field3, c.MimeType)
+	b.int(field4, c.Size)
+	return b, nil
+}
+
+func (c *FileContent) UnmarshalProto(data []byte) error {
+	return parseProto(data, func(num protowire.Number, f protoField) (err error) {
+		switch num {
+		case field1:
+			c.URL, err = f.string()
+		case field2:
+			c.Name, err = f.string()
+		case field3:
+			c.MimeType, err = f.string()
+		case field4:
+			c.Size, err = f.int64()
+		}
+		return err
+	})
+}
+
+func (c *VoiceContent) MarshalProto() ([]byte, error) {
+	var b protoBuilder
+	b.string(field1, c.URL)
+	b.int(field2, int64(c.Duration))
+	b.int(field3, c.Size)
+	return b, nil
+}
+
+func (c *VoiceContent) UnmarshalProto(data []byte) error {
+	return parseProto(data, func(num protowire.Number, f protoField) (err error) {
+		switch num {
+		case field1:
+			c.URL, err = f.string()
+		case field2:
+			c.Duration, err = f.int()
+		case field3:
+			c.Size, err = f.int64()
+		}
+		return err
+	})
+}
+
+func (c *VideoContent) MarshalProto() ([]byte, error) {
+	var b protoBuilder
+	b.string(field1, c.URL)
+	b.string(field2, c.CoverURL)
+	b.int(field3, int64(c.Duration))
+	b.int(field4, int64(c.Width))
+	b.int(field5, int64(c.Height))
+	b.int(field6, c.Size)
+	return b, nil
+}
+
+func (c *VideoContent) UnmarshalProto(data []byte) error {
+	return parseProto(data, func(num protowire.Number, f protoField) (err error) {
+		switch num {
+		case field1:
+			c.URL, err = f.string()
+		case field2:
+			c.CoverURL, err = f.string()
+		case field3:
+			c.Duration, err = f.int()
+		case field4:
+			c.Width, err = f.int()
+		case field5:
+			c.Height, err = f.int()
+		case field6:
+			c.Size, err = f.int64()
+		}
+		return err
+	})
+}
+
+func (c *LocationContent) MarshalProto() ([]byte, error) {
+	var b protoBuilder
+	b.double(field1, c.Latitude)
+	b.double(field2, c.Longitude)
+	b.string(field3, c.Name)
+	b.string(field4, c.Address)
+	return b, nil
+}
+
+func (c *LocationContent) UnmarshalProto(data []byte) error {
+	return parseProto(data, func(num protowire.Number, f protoField) (err error) {
+		switch num {
+		case field1:
+			c.Latitude, err = f.double()
+		case field2:
+			c.Longitude, err = f.double()
+		case field3:
+			c.Name, err = f.string()
+		case field4:
+			c.Address, err = f.string()
+		}
+		return err
+	})
+}
+
+func (c *CardContent) MarshalProto() ([]byte, error) {
+	var b protoBuilder
+	b.uint(field1, c.UID)
+	b.string(field2, c.Nickname)
+	b.string(field3, c.Avatar)
+	return b, nil
+}
+
+func (c *CardContent) UnmarshalProto(data []byte) error {
+	return parseProto(data, func(num protowire.Number, f protoField) (err error) {
+		switch num {
+		case field1:
+			c.UID, err = f.uint64()
+		case field2:
+			c.Nickname, err = f.string()
+		case field3:
+			c.Avatar, err = f.string()
+		}
+		return err
+	})
+}
+
+func (c *CustomContent) MarshalProto() ([]byte, error) {
+	var b protoBuilder
+	b.string(field1, c.Type)
+	b.bytes(field2, c.Data)
+	return b, nil
+}
+
+func (c *CustomContent) UnmarshalProto(data []byte) error {
+	err := parseProto(data, func(num protowire.Number, f protoField) (err error) {
+		switch num {
+		case field1:
+			c.Type, err = f.string()
+		case field2:
+			var raw []byte
+			if raw, err = f.bytes(); err == nil {
+				c.Data = json.RawMessage(raw)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// 统一使用 json 编码存储，data 必须是合法的 json。
+	if len(c.Data) > 0 && !json.Valid(c.Data) {
+		return fmt.Errorf("custom data is not valid json")
+	}
+	return nil
+}
+
+// protoBuilder 按 proto3 规则编码字段 ( 零值不编码 )。
+type protoBuilder []byte
+
+func (b *protoBuilder) string(num protowire.Number, v string) {
+	if v == "" {
+		return
+	}
+	*b = protowire.AppendTag(*b, num, protowire.BytesType)
+	*b = protowire.AppendString(*b, v)
+}
+
+func (b *protoBuilder) bytes(num protowire.Number, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	*b = protowire.AppendTag(*b, num, protowire.BytesType)
+	*b = protowire.AppendBytes(*b, v)
+}
+
+func (b *protoBuilder) int(num protowire.Number, v int64) {
+	b.uint(num, uint64(v)) //nolint:gosec // 负数按 proto 的 int64 规则编码为补码
+}
+
+func (b *protoBuilder) uint(num protowire.Number, v uint64) {
+	if v == 0 {
+		return
+	}
+	*b = protowire.AppendTag(*b, num, protowire.VarintType)
+	*b = protowire.AppendVarint(*b, v)
+}
+
+func (b *protoBuilder) double(num protowire.Number, v float64) {
+	if v == 0 {
+		return
+	}
+	*b = protowire.AppendTag(*b, num, protowire.Fixed64Type)
+	*b = protowire.AppendFixed64(*b, math.Float64bits(v))
+}
+
+// protoField 解码出的单个字段。
+type protoField struct {
+	typ protowire.Type
+	num uint64 // varint / fixed64
+	raw []byte // bytes
+}
+
+func (f protoField) expect(typ protowire.Type) error {
+	if f.typ != typ {
+		return fmt.Errorf("unexpected wire type [ %d ]", f.typ)
+	}
+	return nil
+}
+
+func (f protoField) string() (string, error) {
+	raw, err := f.bytes()
+	return string(raw), err
+}
+
+func (f protoField) bytes() ([]byte, error) {
+	return f.raw, f.expect(protowire.BytesType)
+}
+
+func (f protoField) uint64() (uint64, error) {
+	return f.num, f.expect(protowire.VarintType)
+}
+
+func (f protoField) int64() (int64, error) {
+	return int64(f.num), f.expect(protowire.VarintType) //nolint:gosec // proto 的 int64 使用补码编码
+}
+
+func (f protoField) int() (int, error) {
+	return int(int32(f.num)), f.expect(protowire.VarintType) //nolint:gosec // proto 的 int32 取低 32 位
+}
+
+func (f protoField) double() (float64, error) {
+	return math.Float64frombits(f.num), f.expect(protowire.Fixed64Type)
+}
+
+// parseProto 依次解码 data 中的字段，未知字段会被忽略 ( 兼容新版本客户端 )。
+func parseProto(data []byte, field func(num protowire.Number, f protoField) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		f := protoField{typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.num, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			f.num, n = protowire.ConsumeFixed64(data)
+		case protowire.BytesType:
+			f.raw, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if err := field(num, f); err != nil {
+			return fmt.Errorf("field [ %d ]: %w", num, err)
+		}
+	}
+	return nil
+}
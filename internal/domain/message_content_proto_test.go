@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jrmarcco/hermet/internal/errs"
+	commonv1 "github.com/jrmarcco/synp-api/api/go/common/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestContent_ProtoRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		name    string
+		content MessageContent
+	}{
+		{name: "text", content: &TextContent{Text: "你好"}},
+		{name: "image", content: &ImageContent{URL: "https://a.com/1.png", Width: 640, Height: 480, Size: 1024}},
+		{name: "file", content: &FileContent{URL: "https://a.com/1.pdf", Name: "1.pdf", MimeType: "application/pdf", Size: 2048}},
+		{name: "voice", content: &VoiceContent{URL: "https://a.com/1.amr", Duration: 12, Size: 512}},
+		{name: "video", content: &VideoContent{URL: "https://a.com/1.mp4", CoverURL: "https://a.com/1.jpg", Duration: 30, Width: 1280, Height: 720, Size: 1 << 20}},
+		{name: "location", content: &LocationContent{Latitude: 31.23, Longitude: -121.47, Name: "somewhere", Address: "street"}},
+		{name: "card", content: &CardContent{UID: 42, Nickname: "bob", Avatar: "https://a.com/a.png"}},
+		{name: "custom", content: &CustomContent{Type: "vote", Data: json.RawMessage(`{"id":1}`)}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := EncodeContent(tc.content, commonv1.SerializeType_SERIALIZE_TYPE_PROTOBUF)
+			require.NoError(t, err)
+
+			decoded, err := DecodeContent(tc.content.ContentType(), commonv1.SerializeType_SERIALIZE_TYPE_PROTOBUF, data)
+			require.NoError(t, err)
+			require.Equal(t, tc.content, decoded)
+		})
+	}
+}
+
+func TestContent_ProtoMalformed(t *testing.T) {
+	t.Parallel()
+
+	// 字段类型不匹配 ( text 使用 varint 编码 )。
+	data := protowire.AppendTag(nil, 1, protowire.VarintType)
+	data = protowire.AppendVarint(data, 1)
+	_, err := DecodeContent(ContentTypeText, commonv1.SerializeType_SERIALIZE_TYPE_PROTOBUF, data)
+	require.ErrorIs(t, err, errs.ErrInvalidParam)
+
+	// 截断的数据。
+	_, err = DecodeContent(ContentTypeText, commonv1.SerializeType_SERIALIZE_TYPE_PROTOBUF, []byte{0x0a, 0x05, 'a'})
+	require.ErrorIs(t, err, errs.ErrInvalidParam)
+
+	// 自定义消息的 data 不是合法的 json。
+	custom, err := (&CustomContent{Type: "vote", Data: json.RawMessage(`{`)}).MarshalProto()
+	require.NoError(t, err)
+	_, err = DecodeContent(ContentTypeCustom, commonv1.SerializeType_SERIALIZE_TYPE_PROTOBUF, custom)
+	require.ErrorIs(t, err, errs.ErrInvalidParam)
+
+	// 系统消息不支持 protobuf。
+	_, err = DecodeContent(ContentTypeSystem, commonv1.SerializeType_SERIALIZE_TYPE_PROTOBUF, []byte{0x0a, 0x01, 'a'})
+	require.ErrorIs(t, err, errs.ErrInvalidParam)
+}
@@ -0,0 +1,81 @@
+package domain
+
+// MessageEventType 消息事件类型。
+type MessageEventType string
+
+const (
+	// MessageEventTypeSent 消息发送事件。
+	MessageEventTypeSent MessageEventType = "message.sent"
+	// MessageEventTypeRecalled 消息撤回事件。
+	MessageEventTypeRecalled MessageEventType = "message.recalled"
+	// MessageEventTypeEdited 消息编辑事件。
+	MessageEventTypeEdited MessageEventType = "message.edited"
+	// MessageEventTypeThreadReplied 话题回复事件。
+	MessageEventTypeThreadReplied MessageEventType = "message.thread.replied"
+	// MessageEventTypeReactionChanged 表情回应变更事件。
+	MessageEventTypeReactionChanged MessageEventType = "message.reaction.changed"
+)
+
+// MessageSentEvent 消息发送事件，用于离线推送等下游消费者。
+type MessageSentEvent struct {
+	CID uint64 `json:"cid"`
+	ID  uint64 `json:"id"`
+	Seq uint64 `json:"seq"`
+	SID uint64 `json:"sid"`
+
+	Content     []byte      `json:"content"`
+	ContentType ContentType `json:"contentType"`
+
+	Mentions   []uint64 `json:"mentions"`
+	MentionAll bool     `json:"mentionAll"`
+
+	ThreadID uint64 `json:"threadId"`
+
+	SendAt int64 `json:"sendAt"`
+}
+
+// MessageRecalledEvent 消息撤回事件。
+type MessageRecalledEvent struct {
+	CID      uint64 `json:"cid"`
+	ID       uint64 `json:"id"`
+	Operator uint64 `json:"operator"`
+
+	RecalledAt int64 `json:"recalledAt"`
+}
+
+// MessageEditedEvent 消息编辑事件。
+type MessageEditedEvent struct {
+	CID      uint64 `json:"cid"`
+	ID       uint64 `json:"id"`
+	Operator uint64 `json:"operator"`
+
+	Content []byte `json:"content"`
+	EditVer int    `json:"editVer"`
+
+	EditedAt int64 `json:"editedAt"`
+}
+
+// MessageThreadRepliedEvent 话题回复事件，用于通知话题参与者。
+type MessageThreadRepliedEvent struct {
+	CID      uint64 `json:"cid"`
+	ThreadID uint64 `json:"threadId"` // 话题根消息 ID
+	ID       uint64 `json:"id"`       // 回复消息 ID
+	SID      uint64 `json:"sid"`
+
+	ParticipantIDs []uint64 `json:"participantIds"` // 需要通知的话题参与者 ( 不包含回复者本人 )
+
+	RepliedAt int64 `json:"repliedAt"`
+}
+
+// MessageReactionChangedEvent 表情回应变更事件。
+type MessageReactionChangedEvent struct {
+	CID   uint64 `json:"cid"`
+	ID    uint64 `json:"id"`
+	UID   uint64 `json:"uid"`
+	Emoji string `json:"emoji"`
+
+	Added bool `json:"added"` // true 表示添加回应，false 表示取消回应
+	Count int  `json:"count"` // 变更后该表情的回应数
+
+	ChangedAt int64 `json:"changedAt"`
+}
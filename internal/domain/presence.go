@@ -0,0 +1,67 @@
+package domain
+
+// PresenceVisibility 在线状态 ( 在线 / 最后在线时间 ) 的可见范围。
+type PresenceVisibility string
+
+const (
+	PresenceVisibilityEveryone PresenceVisibility = "everyone"
+	PresenceVisibilityContacts PresenceVisibility = "contacts"
+	PresenceVisibilityNobody   PresenceVisibility = "nobody"
+)
+
+func (v PresenceVisibility) IsValid() bool {
+	switch v {
+	case PresenceVisibilityEveryone, PresenceVisibilityContacts, PresenceVisibilityNobody:
+		return true
+	default:
+		return false
+	}
+}
+
+// Presence 用户在线状态。
+type Presence struct {
+	UID uint64 `json:"uid"`
+
+	Online   bool  `json:"online"`
+	LastSeen int64 `json:"lastSeen"` // 最后在线时间戳 ( 0 表示未知或不可见 )
+
+	Hidden bool `json:"hidden"` // 对方设置了隐私，在线状态不可见
+}
+
+// PresenceSetting 用户在线状态隐私设置。
+type PresenceSetting struct {
+	UID        uint64             `json:"uid"`
+	Visibility PresenceVisibility `json:"visibility"`
+
+	UpdatedAt int64 `json:"updatedAt"`
+}
+
+// PresenceEventType 在线状态事件类型。
+type PresenceEventType string
+
+const (
+	// PresenceEventTypeChanged 在线状态变更事件。
+	PresenceEventTypeChanged PresenceEventType = "presence.changed"
+	// PresenceEventTypeTyping 正在输入事件 ( 临时事件，不会持久化 )。
+	PresenceEventTypeTyping PresenceEventType = "presence.typing"
+)
+
+// PresenceChangedEvent 在线状态变更事件，由网关推送给在线的联系人和频道成员。
+type PresenceChangedEvent struct {
+	UID      uint64 `json:"uid"`
+	Online   bool   `json:"online"`
+	LastSeen int64  `json:"lastSeen"`
+
+	ContactIDs []uint64 `json:"contactIds"` // 需要通知的联系人 ( 将 UID 添加为联系人的用户 )
+	CIDs       []uint64 `json:"cids"`       // 需要通知的频道
+
+	ChangedAt int64 `json:"changedAt"`
+}
+
+// TypingEvent 正在输入事件，由网关推送给频道中在线的成员。
+type TypingEvent struct {
+	CID uint64 `json:"cid"`
+	UID uint64 `json:"uid"`
+
+	TypingAt int64 `json:"typingAt"`
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// PushPlatform 推送平台。
+type PushPlatform string
+
+const (
+	PushPlatformAPNs PushPlatform = "apns"
+	PushPlatformFCM  PushPlatform = "fcm"
+)
+
+func (p PushPlatform) IsValid() bool {
+	return p == PushPlatformAPNs || p == PushPlatformFCM
+}
+
+// PushDevice 推送设备，与登录会话绑定 ( 一个会话对应一个设备 token )。
+type PushDevice struct {
+	UID uint64 `json:"uid"`
+	SID string `json:"sid"` // 会话 ID
+
+	Platform PushPlatform `json:"platform"`
+	Token    string       `json:"token"`
+
+	UpdatedAt int64 `json:"updatedAt"`
+}
+
+// PushSetting 用户推送设置。
+type PushSetting struct {
+	UID uint64 `json:"uid"`
+
+	// 免打扰时间段，使用当天的分钟数表示 ( [0, 1440) )。
+	// DNDStart > DNDEnd 表示跨天，如 22:00 - 08:00。
+	DNDEnabled bool   `json:"dndEnabled"`
+	DNDStart   int    `json:"dndStart"`
+	DNDEnd     int    `json:"dndEnd"`
+	Timezone   string `json:"timezone"` // IANA 时区，如 Asia/Shanghai，为空时使用 UTC
+
+	UpdatedAt int64 `json:"updatedAt"`
+}
+
+const minutesPerDay = 24 * 60
+
+// Validate 校验免打扰设置。
+func (s PushSetting) Validate() error {
+	if s.DNDStart < 0 || s.DNDStart >= minutesPerDay || s.DNDEnd < 0 || s.DNDEnd >= minutesPerDay {
+		return fmt.Errorf("dnd time must be in [0, %d)", minutesPerDay)
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone [ %s ]", s.Timezone)
+	}
+	return nil
+}
+
+// InDND 判断 t 是否处于免打扰时间段内。
+func (s PushSetting) InDND(t time.Time) bool {
+	if !s.DNDEnabled || s.DNDStart == s.DNDEnd {
+		return false
+	}
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	minute := t.Hour()*60 + t.Minute()
+
+	if s.DNDStart < s.DNDEnd {
+		return minute >= s.DNDStart && minute < s.DNDEnd
+	}
+	// 跨天。
+	return minute >= s.DNDStart || minute < s.DNDEnd
+}
@@ -0,0 +1,32 @@
+package domain
+
+// SyncLog 用户同步日志，表示用户在序列号 Seq 上收到了一条消息。
+type SyncLog struct {
+	UID uint64 `json:"uid"`
+	Seq uint64 `json:"seq"`
+
+	CID       uint64 `json:"cid"`
+	MessageID uint64 `json:"messageId"`
+
+	CreatedAt int64 `json:"createdAt"` // 写入时间 ( Unix 毫秒值 )
+}
+
+// SyncEntry 同步条目。
+type SyncEntry struct {
+	Seq     uint64  `json:"seq"`
+	Message Message `json:"message"`
+}
+
+// SyncResult 离线同步结果。
+type SyncResult struct {
+	Entries []SyncEntry `json:"entries"`
+
+	// NextSeq 下一次同步时使用的序列号 ( 客户端需要保存 )。
+	// 消息被归档或删除时对应的条目会被跳过，所以 NextSeq 不一定等于最后一个条目的序列号。
+	NextSeq   uint64 `json:"nextSeq"`
+	LatestSeq uint64 `json:"latestSeq"` // 服务端当前最新的序列号
+	HasMore   bool   `json:"hasMore"`
+
+	// FullResync 客户端落后太多 ( 或同步日志已过期 )，需要重新全量同步会话列表和历史消息。
+	FullResync bool `json:"fullResync"`
+}
@@ -3,7 +3,8 @@ package errs
 import "errors"
 
 var (
-	ErrUnauthorized = errors.New("unauthorized")
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrPermissionDenied = errors.New("permission denied")
 
 	ErrInvalidParam = errors.New("invalid param")
 
@@ -11,4 +12,7 @@ var (
 	ErrInvalidAccountType = errors.New("invalid account type")
 
 	ErrRecordNotFound = errors.New("record not found")
+
+	// ErrShardUnavailable 数据所在的分片无法访问 ( 无法路由或者分片数据库没有配置 )。
+	ErrShardUnavailable = errors.New("shard unavailable")
 )
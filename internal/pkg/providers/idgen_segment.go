@@ -0,0 +1,105 @@
+package providers
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen/segment"
+	"github.com/jrmarcco/jit/xsync"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/exp/zapslog"
+	"gorm.io/gorm"
+)
+
+// SegmentStoreType 号段存储类型。
+type SegmentStoreType string
+
+const (
+	SegmentStoreTypePostgres SegmentStoreType = "postgres"
+	SegmentStoreTypeRedis    SegmentStoreType = "redis"
+)
+
+// 按使用场景区分的序列号生成器，每个场景使用 sharding_idgen.segment 下的独立配置，并以 fx 名称 "{场景}_seq_generator" 提供。
+const (
+	SeqUseCaseChannelMessage = "channel_message" // 频道消息序列号
+	SeqUseCaseUserSync       = "user_sync"       // 用户同步序列号
+)
+
+// SegmentIDGenConfig 号段分配器配置。
+type SegmentIDGenConfig struct {
+	Store SegmentStoreType `mapstructure:"store"` // 号段存储: postgres ( 默认 ) 或 redis
+	DB    string           `mapstructure:"db"`    // postgres 存储使用的数据库，与 db.sharding 中的名称一致
+	// Step 每次分配的号段长度，大于 1 时在内存中双 buffer 缓存号段，不同实例之间的序列号不保证有序，
+	// 只能在单实例部署 ( sharding_idgen.worker_bits 为 0 ) 时使用。
+	Step          uint64        `mapstructure:"step"`
+	PrefetchRatio float64       `mapstructure:"prefetch_ratio"`
+	LoadTimeout   time.Duration `mapstructure:"load_timeout"`
+}
+
+// newSegmentAllocator 返回创建 useCase 场景号段分配器的函数。
+// 号段存储在第一次生成序列号时才创建，postgres 存储使用的数据库只有选择 postgres 时才需要配置。
+func newSegmentAllocator(useCase string) func(
+	dbs *xsync.Map[string, *gorm.DB],
+	rdb redis.Cmdable,
+	zapLogger *zap.Logger,
+) (idgen.SeqGenerator, error) {
+	return func(dbs *xsync.Map[string, *gorm.DB], rdb redis.Cmdable, zapLogger *zap.Logger) (idgen.SeqGenerator, error) {
+		cfg := SegmentIDGenConfig{}
+		if err := viper.UnmarshalKey("sharding_idgen.segment."+useCase, &cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Step > 1 && viper.GetUint("sharding_idgen.worker_bits") > 0 {
+			return nil, fmt.Errorf(
+				"segment step of [ %s ] must be 1 when multiple instances are deployed ( sharding_idgen.worker_bits > 0 )",
+				useCase,
+			)
+		}
+
+		var newStore func() (segment.Store, error)
+		switch cfg.Store {
+		case "", SegmentStoreTypePostgres:
+			newStore = func() (segment.Store, error) {
+				db, ok := dbs.Load(cfg.DB)
+				if !ok {
+					return nil, fmt.Errorf("segment store database [ %s ] is not configured", cfg.DB)
+				}
+				return segment.NewPostgresStore(db), nil
+			}
+		case SegmentStoreTypeRedis:
+			newStore = func() (segment.Store, error) {
+				return segment.NewRedisStore(rdb), nil
+			}
+		default:
+			return nil, fmt.Errorf("invalid segment store of [ %s ]: %s", useCase, cfg.Store)
+		}
+
+		return segment.NewLazyAllocator(newStore, segment.Config{
+			Step:          cfg.Step,
+			PrefetchRatio: cfg.PrefetchRatio,
+			LoadTimeout:   cfg.LoadTimeout,
+		}, slog.New(zapslog.NewHandler(zapLogger.Core())).With(slog.String("use_case", useCase))), nil
+	}
+}
+
+// IDGenFxModule 按使用场景提供 idgen.SeqGenerator ( 频道消息序列号和用户同步序列号 )。
+// 分片表的 ID 由 sharding 模块提供的 snowflake 生成器生成，号段分配的序列号不携带分片信息，不能作为 ID。
+var IDGenFxModule = fx.Module(
+	"idgen",
+	fx.Provide(
+		fx.Annotate(
+			newSegmentAllocator(SeqUseCaseChannelMessage),
+			fx.ParamTags(`name:"db_sharding_clients"`),
+			fx.ResultTags(`name:"channel_message_seq_generator"`),
+		),
+		fx.Annotate(
+			newSegmentAllocator(SeqUseCaseUserSync),
+			fx.ParamTags(`name:"db_sharding_clients"`),
+			fx.ResultTags(`name:"user_sync_seq_generator"`),
+		),
+	),
+)
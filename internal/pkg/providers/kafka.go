@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/jrmarcco/hermet/internal/pkg/xmq/consumer"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/memory"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
 	"github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl"
 	"github.com/segmentio/kafka-go/sasl/scram"
@@ -22,9 +24,19 @@ type kafkaFxResult struct {
 
 	Writer           *kafka.Writer                  `name:"ws_kafka_writer"`
 	ReaderCreateFunc consumer.KafkaReaderCreateFunc `name:"ws_kafka_reader_create_func"`
+
+	Producer        produce.Producer
+	AsyncProducer   produce.AsyncProducer
+	ConsumerFactory consumer.ConsumerFactory
+
+	// ManualConsumerFactory 使用手动确认模式的消费者工厂，不受 consumer.ack_mode 配置影响 ( 如死信重新投递 )。
+	ManualConsumerFactory consumer.ConsumerFactory `name:"xmq_manual_consumer_factory"`
 }
 
 type kafkaConfig struct {
+	// Driver 消息队列实现: "kafka" (默认) 或 "memory" ( 内存实现，用于测试和单节点开发环境 )。
+	Driver string `mapstructure:"driver"`
+
 	Brokers []string `mapstructure:"brokers"`
 
 	Producer kafkaProducerConfig `mapstructure:"producer"`
@@ -32,6 +44,13 @@ type kafkaConfig struct {
 
 	TLS  kafkaTLSConfig  `mapstructure:"tls"`
 	SASL kafkaSaslConfig `mapstructure:"sasl"`
+
+	Memory kafkaMemoryConfig `mapstructure:"memory"`
+}
+
+type kafkaMemoryConfig struct {
+	Partitions  int `mapstructure:"partitions"`   // 每个 topic 的分区数
+	MaxMessages int `mapstructure:"max_messages"` // 每个分区最多保留的消息数
 }
 
 type kafkaProducerConfig struct {
@@ -44,6 +63,15 @@ type kafkaProducerConfig struct {
 	BatchTimeout      time.Duration `mapstructure:"batch_timeout"`      // 批量超时时间（毫秒）
 	WriteTimeout      time.Duration `mapstructure:"write_timeout"`      // 写入超时（毫秒）
 	IdempotentEnabled bool          `mapstructure:"idempotent_enabled"` // 是否启用幂等性
+
+	Async kafkaAsyncProducerConfig `mapstructure:"async"`
+}
+
+type kafkaAsyncProducerConfig struct {
+	BufferSize int           `mapstructure:"buffer_size"` // 缓冲区大小，缓冲区满时发送失败
+	BatchSize  int           `mapstructure:"batch_size"`  // 单次批量发送的最大消息数
+	Linger     time.Duration `mapstructure:"linger"`      // 凑批等待时间
+	Workers    int           `mapstructure:"workers"`     // 发送协程数，大于 1 时不保证顺序
 }
 
 type kafkaConsumerConfig struct {
@@ -54,6 +82,9 @@ type kafkaConsumerConfig struct {
 	MinBytes       int           `mapstructure:"min_bytes"`       // 最小字节数
 	MaxBytes       int           `mapstructure:"max_bytes"`       // 最大字节数
 	MaxWait        time.Duration `mapstructure:"max_wait"`        // 最大等待时间（毫秒）
+
+	AckMode         string `mapstructure:"ack_mode"`          // 确认模式: "auto" (默认) 或 "manual"
+	CommitBatchSize int    `mapstructure:"commit_batch_size"` // manual 模式下累计确认的消息数达到该值时立即提交
 }
 
 type kafkaTLSConfig struct {
@@ -84,6 +115,28 @@ func newKafkaClient(zapLogger *zap.Logger, lifecycle fx.Lifecycle) (kafkaFxResul
 		return kafkaFxResult{}, err
 	}
 
+	consumerCfg, err := getKafkaConsumerConfig(cfg.Consumer)
+	if err != nil {
+		return kafkaFxResult{}, err
+	}
+
+	switch cfg.Driver {
+	case "", "kafka":
+		return newKafkaDriver(cfg, consumerCfg, zapLogger, lifecycle)
+	case "memory":
+		return newMemoryDriver(cfg, consumerCfg, zapLogger, lifecycle), nil
+	default:
+		return kafkaFxResult{}, fmt.Errorf("unsupported kafka driver: %s", cfg.Driver)
+	}
+}
+
+// newKafkaDriver 创建基于 kafka 集群的生产者和消费者。
+func newKafkaDriver(
+	cfg *kafkaConfig,
+	consumerCfg consumer.Config,
+	zapLogger *zap.Logger,
+	lifecycle fx.Lifecycle,
+) (kafkaFxResult, error) {
 	// 配置 TLS。
 	tlsConfig, err := configureKafkaTLS(cfg.TLS, zapLogger)
 	if err != nil {
@@ -127,44 +180,62 @@ func newKafkaClient(zapLogger *zap.Logger, lifecycle fx.Lifecycle) (kafkaFxResul
 	)
 
 	// 创建 ReaderFactory，用于按需创建 Reader ( Consumer )。
+	// manual 模式下由消费者自己批量提交，reader 使用同步提交 ( CommitInterval 为 0 ) 以便感知提交失败。
 	const (
 		defaultReadBackoffMin = 100 * time.Millisecond
 		defaultReadBackoffMax = 1 * time.Second
 		defaultDialTimeout    = 10 * time.Second
 	)
-	readerFactory := func(topic string, groupId string) *kafka.Reader {
-		reader := kafka.NewReader(kafka.ReaderConfig{
-			Brokers:        cfg.Brokers,
-			Topic:          topic,
-			GroupID:        groupId,
-			MinBytes:       cfg.Consumer.MinBytes,
-			MaxBytes:       cfg.Consumer.MaxBytes,
-			MaxWait:        cfg.Consumer.MaxWait,
-			ReadBackoffMin: defaultReadBackoffMin,
-			ReadBackoffMax: defaultReadBackoffMax,
-			CommitInterval: cfg.Consumer.CommitInterval,
-			StartOffset:    cfg.Consumer.StartOffset,
-			Dialer: &kafka.Dialer{
-				Timeout:       defaultDialTimeout,
-				DualStack:     true,
-				TLS:           tlsConfig,
-				SASLMechanism: saslMechanism,
-			},
-		})
-
-		zapLogger.Info(
-			"[synp-ioc-kafka] created kafka reader",
-			zap.Strings("brokers", cfg.Brokers),
-			zap.String("topic", topic),
-			zap.String("group_id", groupId),
-		)
-
-		return reader
+	newReaderFactory := func(ackMode consumer.AckMode) consumer.KafkaReaderCreateFunc {
+		commitInterval := cfg.Consumer.CommitInterval
+		if ackMode == consumer.AckModeManual {
+			commitInterval = 0
+		}
+
+		return func(topic string, groupId string) *kafka.Reader {
+			reader := kafka.NewReader(kafka.ReaderConfig{
+				Brokers:        cfg.Brokers,
+				Topic:          topic,
+				GroupID:        groupId,
+				MinBytes:       cfg.Consumer.MinBytes,
+				MaxBytes:       cfg.Consumer.MaxBytes,
+				MaxWait:        cfg.Consumer.MaxWait,
+				ReadBackoffMin: defaultReadBackoffMin,
+				ReadBackoffMax: defaultReadBackoffMax,
+				CommitInterval: commitInterval,
+				StartOffset:    cfg.Consumer.StartOffset,
+				Dialer: &kafka.Dialer{
+					Timeout:       defaultDialTimeout,
+					DualStack:     true,
+					TLS:           tlsConfig,
+					SASLMechanism: saslMechanism,
+				},
+			})
+
+			zapLogger.Info(
+				"[synp-ioc-kafka] created kafka reader",
+				zap.Strings("brokers", cfg.Brokers),
+				zap.String("topic", topic),
+				zap.String("group_id", groupId),
+				zap.String("ack_mode", string(ackMode)),
+			)
+
+			return reader
+		}
 	}
+	readerFactory := newReaderFactory(consumerCfg.AckMode)
+
+	producer := produce.NewKafkaProducer(writer)
+	asyncProducer := newAsyncProducer(cfg, producer)
 
 	// 注册生命周期钩子。
 	lifecycle.Append(fx.Hook{
-		OnStop: func(_ context.Context) error {
+		OnStop: func(ctx context.Context) error {
+			// 先发送异步缓冲区中剩余的消息，再关闭 writer。
+			if err := asyncProducer.Close(ctx); err != nil {
+				zapLogger.Error("[synp-ioc-kafka] failed to close kafka async producer", zap.Error(err))
+			}
+
 			if err := writer.Close(); err != nil {
 				zapLogger.Error("[synp-ioc-kafka] failed to close kafka writer", zap.Error(err))
 				return fmt.Errorf("failed to close kafka writer: %w", err)
@@ -177,9 +248,74 @@ func newKafkaClient(zapLogger *zap.Logger, lifecycle fx.Lifecycle) (kafkaFxResul
 	return kafkaFxResult{
 		Writer:           writer,
 		ReaderCreateFunc: readerFactory,
+
+		Producer:        producer,
+		AsyncProducer:   asyncProducer,
+		ConsumerFactory: consumer.NewKafkaConsumerFactory(readerFactory, consumerCfg),
+
+		ManualConsumerFactory: consumer.NewKafkaConsumerFactory(
+			newReaderFactory(consumer.AckModeManual),
+			consumer.Config{
+				AckMode:         consumer.AckModeManual,
+				CommitInterval:  consumerCfg.CommitInterval,
+				CommitBatchSize: consumerCfg.CommitBatchSize,
+			},
+		),
 	}, nil
 }
 
+// newMemoryDriver 创建基于内存 broker 的生产者和消费者，不需要 kafka 集群。
+// 消息只保存在当前进程中，只能用于测试和单节点开发环境。
+func newMemoryDriver(
+	cfg *kafkaConfig,
+	consumerCfg consumer.Config,
+	zapLogger *zap.Logger,
+	lifecycle fx.Lifecycle,
+) kafkaFxResult {
+	broker := memory.NewBroker(memory.Config{
+		Partitions:  cfg.Memory.Partitions,
+		MaxMessages: cfg.Memory.MaxMessages,
+	})
+	producer := memory.NewProducer(broker)
+	asyncProducer := newAsyncProducer(cfg, producer)
+
+	zapLogger.Warn(
+		"[synp-ioc-kafka] using in-memory message queue, messages will be lost on exit",
+		zap.Int("partitions", cfg.Memory.Partitions),
+		zap.String("ack_mode", string(consumerCfg.AckMode)),
+	)
+
+	lifecycle.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			// 先发送异步缓冲区中剩余的消息，再关闭 broker。
+			if err := asyncProducer.Close(ctx); err != nil {
+				zapLogger.Error("[synp-ioc-kafka] failed to close memory async producer", zap.Error(err))
+			}
+			broker.Close()
+			return nil
+		},
+	})
+
+	return kafkaFxResult{
+		Producer:        producer,
+		AsyncProducer:   asyncProducer,
+		ConsumerFactory: memory.NewConsumerFactory(broker, consumerCfg.AckMode),
+
+		ManualConsumerFactory: memory.NewConsumerFactory(broker, consumer.AckModeManual),
+	}
+}
+
+// newAsyncProducer 基于同步生产者创建异步生产者。
+func newAsyncProducer(cfg *kafkaConfig, producer produce.Producer) *produce.BatchingAsyncProducer {
+	return produce.NewBatchingAsyncProducer(producer, produce.AsyncConfig{
+		BufferSize:   cfg.Producer.Async.BufferSize,
+		BatchSize:    cfg.Producer.Async.BatchSize,
+		Linger:       cfg.Producer.Async.Linger,
+		Workers:      cfg.Producer.Async.Workers,
+		WriteTimeout: cfg.Producer.WriteTimeout,
+	})
+}
+
 // loadKafkaConfig 加载 Kafka 配置。
 func loadKafkaConfig() (*kafkaConfig, error) {
 	cfg := &kafkaConfig{}
@@ -274,6 +410,24 @@ func configureKafkaSaslOAuth(oauthCfg kafkaOAuthConfig, logger *zap.Logger) (sas
 	return mechanism, nil
 }
 
+// getKafkaConsumerConfig 获取消费者确认模式和批量提交配置。
+func getKafkaConsumerConfig(consumerCfg kafkaConsumerConfig) (consumer.Config, error) {
+	ackMode := consumer.AckMode(consumerCfg.AckMode)
+	switch ackMode {
+	case "":
+		ackMode = consumer.AckModeAuto
+	case consumer.AckModeAuto, consumer.AckModeManual:
+	default:
+		return consumer.Config{}, fmt.Errorf("unsupported consumer ack mode: %s", consumerCfg.AckMode)
+	}
+
+	return consumer.Config{
+		AckMode:         ackMode,
+		CommitInterval:  consumerCfg.CommitInterval,
+		CommitBatchSize: consumerCfg.CommitBatchSize,
+	}, nil
+}
+
 // getKafkaCompression 获取压缩算法。
 func getKafkaCompression(compression string) kafka.Compression {
 	switch compression {
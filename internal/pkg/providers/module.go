@@ -3,6 +3,7 @@ package providers
 import (
 	"github.com/jrmarcco/hermet/internal/pkg/xgin"
 	"github.com/jrmarcco/hermet/internal/pkg/xgin/xsession"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/worker"
 	"go.uber.org/fx"
 )
 
@@ -12,6 +13,19 @@ var (
 	MongoFxModule      = fx.Module("mongo", fx.Provide(newMongoClient))
 	KafkaFxModule      = fx.Module("kafka", fx.Provide(newKafkaClient))
 	JwtManagerFxModule = fx.Module("jwt-manager", fx.Provide(newJwtManager))
+	PushFxModule       = fx.Module("push", fx.Provide(newPushProviders))
+)
+
+var WorkerFxModule = fx.Module(
+	"xmq-worker",
+	fx.Provide(
+		newWorkerRuntime,
+		fx.Annotate(
+			newRedriver,
+			fx.ParamTags(`name:"xmq_manual_consumer_factory"`),
+		),
+	),
+	fx.Invoke(func(*worker.Runtime) {}),
 )
 
 var DBFxModule = fx.Module(
@@ -48,28 +62,3 @@ var MiddlewareFxModule = fx.Module(
 		),
 	),
 )
-
-var ShardingFxModule = fx.Module(
-	"sharding",
-	fx.Provide(
-		newIDGen,
-		fx.Annotate(
-			newBizUserShardHelper,
-			fx.ResultTags(`name:"biz_user_shard_helper"`),
-		),
-
-		fx.Annotate(
-			newUserContactShardHelper,
-			fx.ResultTags(`name:"user_contact_shard_helper"`),
-		),
-		fx.Annotate(
-			newContactApplicationShardHelper,
-			fx.ResultTags(`name:"contact_application_shard_helper"`),
-		),
-
-		fx.Annotate(
-			newChannelApplicationShardHelper,
-			fx.ResultTags(`name:"channel_application_shard_helper"`),
-		),
-	),
-)
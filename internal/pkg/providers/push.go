@@ -0,0 +1,114 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xpush"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+type pushConfig struct {
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	APNs pushAPNsConfig `mapstructure:"apns"`
+	FCM  pushFCMConfig  `mapstructure:"fcm"`
+	Log  pushLogConfig  `mapstructure:"log"`
+}
+
+type pushAPNsConfig struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	Endpoint       string `mapstructure:"endpoint"`         // 为空时使用生产环境地址
+	Topic          string `mapstructure:"topic"`            // app bundle id
+	KeyID          string `mapstructure:"key_id"`           // 鉴权私钥的 Key ID
+	TeamID         string `mapstructure:"team_id"`          // 开发者账号的 Team ID
+	PrivateKeyFile string `mapstructure:"private_key_file"` // 鉴权私钥文件 ( .p8 )，用于签发 provider token
+}
+
+type pushFCMConfig struct {
+	Enabled         bool   `mapstructure:"enabled"`
+	Endpoint        string `mapstructure:"endpoint"`         // 为空时使用 https://fcm.googleapis.com
+	ProjectID       string `mapstructure:"project_id"`       // 为空时使用服务账号所属的项目
+	CredentialsFile string `mapstructure:"credentials_file"` // 服务账号密钥文件 ( JSON )，用于换取 OAuth2 access token
+}
+
+type pushLogConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"` // 为空时输出到标准输出
+}
+
+// newPushProviders 根据配置创建各平台的推送提供方。
+// 开启 log 时，未配置真实推送服务的平台会使用本地日志代替 ( 开发 / 测试环境 )。
+func newPushProviders(zapLogger *zap.Logger, lifecycle fx.Lifecycle) (xpush.Providers, error) {
+	cfg := pushConfig{}
+	if err := viper.UnmarshalKey("push", &cfg); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: cfg.Timeout}
+	providers := xpush.Providers{}
+
+	if cfg.APNs.Enabled {
+		privateKey, err := os.ReadFile(cfg.APNs.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read apns private key: %w", err)
+		}
+		tokenSource, err := xpush.NewAPNsTokenSource(cfg.APNs.KeyID, cfg.APNs.TeamID, privateKey)
+		if err != nil {
+			return nil, err
+		}
+		providers[xpush.PlatformAPNs] = xpush.NewAPNsProvider(cfg.APNs.Endpoint, cfg.APNs.Topic, tokenSource, client)
+	}
+	if cfg.FCM.Enabled {
+		credentials, err := os.ReadFile(cfg.FCM.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fcm credentials: %w", err)
+		}
+		tokenSource, err := xpush.NewFCMTokenSource(credentials, client)
+		if err != nil {
+			return nil, err
+		}
+
+		projectID := cfg.FCM.ProjectID
+		if projectID == "" {
+			projectID = tokenSource.ProjectID()
+		}
+		providers[xpush.PlatformFCM] = xpush.NewFCMProvider(cfg.FCM.Endpoint, projectID, tokenSource, client)
+	}
+
+	if cfg.Log.Enabled {
+		var w io.Writer = os.Stdout
+		if cfg.Log.Path != "" {
+			file, err := os.OpenFile(cfg.Log.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				return nil, err
+			}
+			w = file
+
+			lifecycle.Append(fx.Hook{
+				OnStop: func(_ context.Context) error {
+					if err := file.Close(); err != nil {
+						zapLogger.Error("[synp-ioc-push] failed to close push log file", zap.Error(err))
+						return err
+					}
+					return nil
+				},
+			})
+		}
+
+		logProvider := xpush.NewLogProvider(w)
+		for _, platform := range []xpush.Platform{xpush.PlatformAPNs, xpush.PlatformFCM} {
+			if _, ok := providers[platform]; !ok {
+				providers[platform] = logProvider
+			}
+		}
+	}
+
+	return providers, nil
+}
@@ -1,13 +1,25 @@
 package providers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
 
 	"github.com/jrmarcco/hermet/internal/pkg/sharding"
 	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen"
 	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen/snowflake"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen/worker"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/timetable"
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/exp/zapslog"
 )
 
 type idGenFxResult struct {
@@ -17,9 +29,72 @@ type idGenFxResult struct {
 	Extractor sharding.ShardValExtractor
 }
 
-func newIDGen() (idGenFxResult, error) {
-	gen := snowflake.NewGenerator()
+const defaultShardIDGenWorkerLeaseTTL = 30 * time.Second
+
+// ShardIDGenConfig 分片 ID 生成配置。
+type ShardIDGenConfig struct {
+	// WorkerBits snowflake 序列号中 worker ID 的位数，为 0 时不使用 worker ID ( 只能单实例部署 )。
+	WorkerBits uint `mapstructure:"worker_bits"`
+	// WorkerLeaseTTL worker ID 在 redis 中的租约时长。
+	WorkerLeaseTTL time.Duration `mapstructure:"worker_lease_ttl"`
+}
+
+// LoadShardIDGenConfig 加载分片 ID 生成配置。
+func LoadShardIDGenConfig() (ShardIDGenConfig, error) {
+	cfg := ShardIDGenConfig{}
+	if err := viper.UnmarshalKey("sharding_idgen", &cfg); err != nil {
+		return ShardIDGenConfig{}, err
+	}
+	if cfg.WorkerLeaseTTL <= 0 {
+		cfg.WorkerLeaseTTL = defaultShardIDGenWorkerLeaseTTL
+	}
+	return cfg, nil
+}
+
+// newIDGen 创建 snowflake ID 生成器。
+// 配置了 worker_bits 时从 redis 租用 worker ID，启动时获取租约，之后定期续期，保证多个实例生成的 ID 不会重复。
+func newIDGen(rdb redis.Cmdable, zapLogger *zap.Logger, lc fx.Lifecycle) (idGenFxResult, error) {
+	cfg, err := LoadShardIDGenConfig()
+	if err != nil {
+		return idGenFxResult{}, err
+	}
+
 	extractor := snowflake.NewExtractor()
+	if cfg.WorkerBits == 0 {
+		return idGenFxResult{
+			Gen:       snowflake.NewGenerator(),
+			Extractor: extractor,
+		}, nil
+	}
+
+	lease := worker.NewRedisLease(rdb, cfg.WorkerBits, cfg.WorkerLeaseTTL, slog.New(zapslog.NewHandler(zapLogger.Core())))
+	gen, err := snowflake.NewWorkerGenerator(cfg.WorkerBits, lease.WorkerID)
+	if err != nil {
+		return idGenFxResult{}, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(startCtx context.Context) error {
+			if err := lease.Acquire(startCtx); err != nil {
+				cancel()
+				close(done)
+				return err
+			}
+			go func() {
+				defer close(done)
+				lease.Run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			<-done
+			return lease.Release(stopCtx)
+		},
+	})
+
 	return idGenFxResult{
 		Gen:       gen,
 		Extractor: extractor,
@@ -31,59 +106,175 @@ type shardingConfig struct {
 	TBPrefix     string `mapstructure:"tb_prefix"`
 	DBShardCount uint64 `mapstructure:"db_shard_count"`
 	TBShardCount uint64 `mapstructure:"tb_shard_count"`
+
+	// Strategy 分片策略: "modulo" (默认)、"bucket" ( 虚拟桶 ) 或 "time_range" ( 时间范围 )。
+	Strategy string `mapstructure:"strategy"`
+	// BroadcastMode 广播模式: "round_robin" (默认)、"default" 或 "shuffle"。
+	BroadcastMode string `mapstructure:"broadcast_mode"`
+	// Buckets 虚拟桶路由表，为空时使用与取模分片相同的路由 ( 由 db_shard_count / tb_shard_count 计算 )。
+	Buckets []bucketRangeConfig `mapstructure:"buckets"`
+	// BucketExpr 计算行所在虚拟桶的 SQL 表达式，与分片值的计算方式一致，迁移虚拟桶时使用。
+	BucketExpr string `mapstructure:"bucket_expr"`
+
+	// Period time_range 策略的分表周期: "month" 或 "week"。
+	Period string `mapstructure:"period"`
+	// BroadcastPeriods time_range 策略 Broadcast 覆盖的周期数 ( 包含当前周期 )，默认为 1。
+	BroadcastPeriods int `mapstructure:"broadcast_periods"`
 }
 
-func newChannelApplicationShardHelper(gen idgen.Generator, extractor sharding.ShardValExtractor) (*sharding.ShardHelper, error) {
-	cfg := shardingConfig{}
-	if err := viper.UnmarshalKey("sharding.channel_application", &cfg); err != nil {
-		return nil, err
-	}
-	return newShardHelper(gen, extractor, cfg)
+type bucketRangeConfig struct {
+	From uint64 `mapstructure:"from"` // 起始虚拟桶 ( 包含 )
+	To   uint64 `mapstructure:"to"`   // 结束虚拟桶 ( 包含 )
+	DB   uint64 `mapstructure:"db"`   // 库后缀
+	TB   uint64 `mapstructure:"tb"`   // 表后缀
 }
 
-func newBizUserShardHelper(gen idgen.Generator, extractor sharding.ShardValExtractor) (*sharding.ShardHelper, error) {
-	cfg := shardingConfig{}
-	if err := viper.UnmarshalKey("sharding.biz_user", &cfg); err != nil {
-		return nil, err
-	}
-	return newShardHelper(gen, extractor, cfg)
+// shardHelperName 逻辑表分片辅助工具在 fx 中的名称。
+func shardHelperName(table string) string {
+	return table + "_shard_helper"
 }
 
-func newContactApplicationShardHelper(gen idgen.Generator, extractor sharding.ShardValExtractor) (*sharding.ShardHelper, error) {
-	cfg := shardingConfig{}
-	if err := viper.UnmarshalKey("sharding.contact_application", &cfg); err != nil {
-		return nil, err
+// loadShardingTableSpecs 读取 sharding 配置中的所有逻辑表 ( 按名称排序 )。
+func loadShardingTableSpecs() ([]sharding.TableSpec, error) {
+	cfgs := map[string]shardingConfig{}
+	if err := viper.UnmarshalKey("sharding", &cfgs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sharding config: %w", err)
+	}
+	if len(cfgs) == 0 {
+		return nil, errors.New("no sharding table is configured")
 	}
-	return newShardHelper(gen, extractor, cfg)
+
+	names := slices.Sorted(maps.Keys(cfgs))
+	specs := make([]sharding.TableSpec, 0, len(names))
+	for _, name := range names {
+		cfg := cfgs[name]
+
+		buckets := make([]sharding.BucketRange, 0, len(cfg.Buckets))
+		for _, b := range cfg.Buckets {
+			buckets = append(buckets, sharding.BucketRange{
+				From:     b.From,
+				To:       b.To,
+				DBSuffix: b.DB,
+				TBSuffix: b.TB,
+			})
+		}
+
+		specs = append(specs, sharding.TableSpec{
+			Name:          name,
+			DBPrefix:      cfg.DBPrefix,
+			TBPrefix:      cfg.TBPrefix,
+			DBShardCount:  cfg.DBShardCount,
+			TBShardCount:  cfg.TBShardCount,
+			Strategy:      sharding.StrategyType(cfg.Strategy),
+			BroadcastMode: sharding.BroadcastMode(cfg.BroadcastMode),
+			Buckets:       buckets,
+			BucketExpr:    cfg.BucketExpr,
+
+			Period:           sharding.TimePeriod(cfg.Period),
+			BroadcastPeriods: cfg.BroadcastPeriods,
+		})
+	}
+	return specs, nil
 }
 
-func newUserContactShardHelper(gen idgen.Generator, extractor sharding.ShardValExtractor) (*sharding.ShardHelper, error) {
-	cfg := shardingConfig{}
-	if err := viper.UnmarshalKey("sharding.user_contact", &cfg); err != nil {
-		return nil, err
+// loadShardingDBNames 读取 db.sharding 中配置了连接的数据库名称。
+func loadShardingDBNames() ([]string, error) {
+	type dbShardingConfig struct {
+		Name string `mapstructure:"name"`
 	}
-	return newShardHelper(gen, extractor, cfg)
+
+	var cfgs []dbShardingConfig
+	if err := viper.UnmarshalKey("db.sharding", &cfgs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal db sharding config: %w", err)
+	}
+
+	names := make([]string, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		names = append(names, cfg.Name)
+	}
+	return names, nil
 }
 
-func newShardHelper(
+// newShardRegistry 根据 sharding 配置创建所有逻辑表的分片辅助工具，并校验路由到的数据库都在 db.sharding 中配置了连接。
+// 使用虚拟桶分片策略的逻辑表会注册到迁移协调器，迁移工具切换路由后业务进程才能应用新的路由；
+// 使用时间范围分片策略的逻辑表会注册到周期表创建器，自动创建之后周期的表。
+func newShardRegistry(
 	gen idgen.Generator,
 	extractor sharding.ShardValExtractor,
-	cfg shardingConfig,
-) (*sharding.ShardHelper, error) {
-	baseStrategy, err := sharding.NewModuloSharding(extractor, cfg.DBPrefix, cfg.TBPrefix, cfg.DBShardCount, cfg.TBShardCount)
+	coordinator *migrate.Coordinator,
+	creator *timetable.Creator,
+	specs []sharding.TableSpec,
+) (*sharding.Registry, error) {
+	dbNames, err := loadShardingDBNames()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create modulo sharding: %w", err)
+		return nil, err
 	}
 
-	strategy, err := sharding.NewBalancedSharding(baseStrategy, sharding.BroadcastModeRoundRobin)
+	registry, err := sharding.NewRegistry(gen, extractor, specs, dbNames)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create balanced sharding: %w", err)
+		return nil, err
+	}
+
+	for _, name := range registry.Names() {
+		base, _ := registry.Base(name)
+		switch strategy := base.(type) {
+		case *sharding.BucketSharding:
+			coordinator.Register(name, strategy)
+		case *sharding.TimeRangeSharding:
+			creator.Register(name, strategy)
+		}
 	}
+	return registry, nil
+}
 
-	helper, err := sharding.NewShardHelper(gen, strategy)
+// NewShardingFxModule 根据 sharding 配置创建分片模块，需要在加载配置之后调用。
+//
+// 模块提供：
+// - *sharding.Registry: 所有逻辑表的分片辅助工具；
+// - 每个逻辑表的 *sharding.ShardHelper，名称为 `name:"{逻辑表}_shard_helper"`，如 `name:"biz_user_shard_helper"`。
+//
+// 新增逻辑表只需要在 sharding.yaml 中添加配置，不需要再编写 provider。
+func NewShardingFxModule() fx.Option {
+	specs, err := loadShardingTableSpecs()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create shard helper: %w", err)
+		return fx.Error(err)
+	}
+
+	opts := []fx.Option{
+		fx.Provide(
+			newIDGen,
+			newShardMigrationStore,
+			fx.Annotate(
+				newShardMigrationCoordinator,
+				fx.ParamTags(``, `name:"db_sharding_clients"`),
+			),
+			fx.Annotate(
+				newShardTimeTableCreator,
+				fx.ParamTags(`name:"db_sharding_clients"`),
+			),
+			func(
+				gen idgen.Generator,
+				extractor sharding.ShardValExtractor,
+				coordinator *migrate.Coordinator,
+				creator *timetable.Creator,
+			) (*sharding.Registry, error) {
+				return newShardRegistry(gen, extractor, coordinator, creator, specs)
+			},
+		),
+	}
+
+	for _, spec := range specs {
+		name := spec.Name
+		opts = append(opts, fx.Provide(
+			fx.Annotate(
+				func(registry *sharding.Registry) *sharding.ShardHelper {
+					helper, _ := registry.Helper(name)
+					return helper
+				},
+				fx.ResultTags(fmt.Sprintf(`name:"%s"`, shardHelperName(name))),
+			),
+		))
 	}
 
-	return helper, nil
+	return fx.Module("sharding", opts...)
 }
@@ -0,0 +1,82 @@
+package providers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
+	"github.com/jrmarcco/jit/xsync"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/exp/zapslog"
+	"gorm.io/gorm"
+)
+
+const defaultShardMigrationRefreshInterval = 5 * time.Second
+
+// ShardMigrationConfig 虚拟桶迁移配置。
+type ShardMigrationConfig struct {
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	BatchSize       int           `mapstructure:"batch_size"`
+	VerifyRounds    int           `mapstructure:"verify_rounds"`
+}
+
+// LoadShardMigrationConfig 加载虚拟桶迁移配置。
+func LoadShardMigrationConfig() (ShardMigrationConfig, error) {
+	cfg := ShardMigrationConfig{}
+	if err := viper.UnmarshalKey("sharding_migration", &cfg); err != nil {
+		return ShardMigrationConfig{}, err
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = defaultShardMigrationRefreshInterval
+	}
+	return cfg, nil
+}
+
+func newShardMigrationStore(rdb redis.Cmdable) migrate.Store {
+	return migrate.NewRedisStore(rdb)
+}
+
+// newShardMigrationCoordinator 创建虚拟桶迁移协调器。
+// 启动时加载一次迁移状态 ( 应用已经切换的路由 )，之后定期刷新。
+func newShardMigrationCoordinator(
+	store migrate.Store,
+	dbs *xsync.Map[string, *gorm.DB],
+	extractor sharding.ShardValExtractor,
+	zapLogger *zap.Logger,
+	lc fx.Lifecycle,
+) (*migrate.Coordinator, error) {
+	cfg, err := LoadShardMigrationConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	coordinator := migrate.NewCoordinator(store, dbs, extractor, slog.New(zapslog.NewHandler(zapLogger.Core())))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(startCtx context.Context) error {
+			if err := coordinator.Refresh(startCtx); err != nil {
+				cancel()
+				close(done)
+				return err
+			}
+			go func() {
+				defer close(done)
+				coordinator.Run(ctx, cfg.RefreshInterval)
+			}()
+			return nil
+		},
+		OnStop: func(_ context.Context) error {
+			cancel()
+			<-done
+			return nil
+		},
+	})
+	return coordinator, nil
+}
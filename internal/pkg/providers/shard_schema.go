@@ -0,0 +1,98 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/schema"
+	"github.com/jrmarcco/jit/xsync"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/exp/zapslog"
+	"gorm.io/gorm"
+)
+
+// ShardSchemaDriftCheck 启动时检查分片表结构的模式。
+type ShardSchemaDriftCheck string
+
+const (
+	ShardSchemaDriftCheckOff  ShardSchemaDriftCheck = "off"  // 不检查
+	ShardSchemaDriftCheckWarn ShardSchemaDriftCheck = "warn" // 不一致时记录日志 ( 默认 )
+	ShardSchemaDriftCheckFail ShardSchemaDriftCheck = "fail" // 不一致时启动失败
+)
+
+// ShardSchemaConfig 分片表结构迁移配置。
+type ShardSchemaConfig struct {
+	DriftCheck ShardSchemaDriftCheck `mapstructure:"drift_check"`
+}
+
+// LoadShardSchemaConfig 加载分片表结构迁移配置。
+func LoadShardSchemaConfig() (ShardSchemaConfig, error) {
+	cfg := ShardSchemaConfig{}
+	if err := viper.UnmarshalKey("sharding_schema", &cfg); err != nil {
+		return ShardSchemaConfig{}, err
+	}
+	switch cfg.DriftCheck {
+	case "":
+		cfg.DriftCheck = ShardSchemaDriftCheckWarn
+	case ShardSchemaDriftCheckOff, ShardSchemaDriftCheckWarn, ShardSchemaDriftCheckFail:
+	default:
+		return ShardSchemaConfig{}, fmt.Errorf("invalid sharding schema drift check mode: %s", cfg.DriftCheck)
+	}
+	return cfg, nil
+}
+
+// NewShardSchemaMigrator 创建分片表结构迁移执行器，提前创建的周期表也会迁移。
+func NewShardSchemaMigrator(
+	dbs *xsync.Map[string, *gorm.DB],
+	registry *sharding.Registry,
+	migrations []schema.Migration,
+	zapLogger *zap.Logger,
+) (*schema.Migrator, error) {
+	cfg, err := LoadShardTimeRangeConfig()
+	if err != nil {
+		return nil, err
+	}
+	return schema.NewMigrator(dbs, registry, migrations, cfg.PrecreatePeriods, slog.New(zapslog.NewHandler(zapLogger.Core())))
+}
+
+// checkShardSchemaDrift 启动时检查所有分片表的迁移历史与代码中的迁移是否一致。
+func checkShardSchemaDrift(migrator *schema.Migrator, zapLogger *zap.Logger, lc fx.Lifecycle) error {
+	cfg, err := LoadShardSchemaConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.DriftCheck == ShardSchemaDriftCheckOff {
+		return nil
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			err := migrator.Check(ctx)
+			if err == nil {
+				return nil
+			}
+			if cfg.DriftCheck == ShardSchemaDriftCheckFail {
+				return err
+			}
+			zapLogger.Warn("[hermet-ioc-shard-schema] sharding schema drift detected, run cmd/shardschema to migrate", zap.Error(err))
+			return nil
+		},
+	})
+	return nil
+}
+
+// ShardSchemaFxModule 启动时检查分片表结构，需要 dao 模块提供 []schema.Migration。
+var ShardSchemaFxModule = fx.Module(
+	"shard_schema",
+	fx.Provide(
+		fx.Annotate(
+			NewShardSchemaMigrator,
+			fx.ParamTags(`name:"db_sharding_clients"`),
+		),
+	),
+	fx.Invoke(checkShardSchemaDrift),
+)
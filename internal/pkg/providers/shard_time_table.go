@@ -0,0 +1,79 @@
+package providers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/timetable"
+	"github.com/jrmarcco/jit/xsync"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/exp/zapslog"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultShardTimeRangeCheckInterval    = time.Hour
+	defaultShardTimeRangePrecreatePeriods = 2
+)
+
+// ShardTimeRangeConfig 时间范围分片配置。
+type ShardTimeRangeConfig struct {
+	CheckInterval    time.Duration `mapstructure:"check_interval"`
+	PrecreatePeriods int           `mapstructure:"precreate_periods"`
+}
+
+// LoadShardTimeRangeConfig 加载时间范围分片配置。
+func LoadShardTimeRangeConfig() (ShardTimeRangeConfig, error) {
+	cfg := ShardTimeRangeConfig{}
+	if err := viper.UnmarshalKey("sharding_time_range", &cfg); err != nil {
+		return ShardTimeRangeConfig{}, err
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = defaultShardTimeRangeCheckInterval
+	}
+	if cfg.PrecreatePeriods <= 0 {
+		cfg.PrecreatePeriods = defaultShardTimeRangePrecreatePeriods
+	}
+	return cfg, nil
+}
+
+// newShardTimeTableCreator 创建周期表创建器。
+// 启动时创建一次当前周期以及之后的周期表，之后定期检查。
+func newShardTimeTableCreator(
+	dbs *xsync.Map[string, *gorm.DB],
+	zapLogger *zap.Logger,
+	lc fx.Lifecycle,
+) (*timetable.Creator, error) {
+	cfg, err := LoadShardTimeRangeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	creator := timetable.NewCreator(dbs, slog.New(zapslog.NewHandler(zapLogger.Core())))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(startCtx context.Context) error {
+			if err := creator.Ensure(startCtx, cfg.PrecreatePeriods); err != nil {
+				cancel()
+				close(done)
+				return err
+			}
+			go func() {
+				defer close(done)
+				creator.Run(ctx, cfg.CheckInterval, cfg.PrecreatePeriods)
+			}()
+			return nil
+		},
+		OnStop: func(_ context.Context) error {
+			cancel()
+			<-done
+			return nil
+		},
+	})
+	return creator, nil
+}
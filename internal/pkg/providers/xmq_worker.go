@@ -0,0 +1,55 @@
+package providers
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/consumer"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/worker"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/exp/zapslog"
+)
+
+type workerRuntimeFxParams struct {
+	fx.In
+
+	ConsumerFactory consumer.ConsumerFactory
+	Producer        produce.Producer
+	Registrations   []worker.Registration `group:"xmq_workers"`
+
+	Logger    *zap.Logger
+	Lifecycle fx.Lifecycle
+}
+
+// newWorkerRuntime 创建 xmq worker 运行时，注册所有消费者并注册到 fx 生命周期中。
+// 运行时必须在所有消费者依赖的组件之后创建，保证停止时先停止消费再停止依赖组件。
+func newWorkerRuntime(p workerRuntimeFxParams) (*worker.Runtime, error) {
+	runtime := worker.NewRuntime(p.ConsumerFactory, p.Producer, slog.New(zapslog.NewHandler(p.Logger.Core())))
+	for _, reg := range p.Registrations {
+		if err := runtime.Register(reg); err != nil {
+			return nil, err
+		}
+	}
+
+	p.Lifecycle.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			return runtime.Start()
+		},
+		OnStop: func(ctx context.Context) error {
+			return runtime.Stop(ctx)
+		},
+	})
+	return runtime, nil
+}
+
+// newRedriver 创建死信重新投递工具。
+// 无论消费者配置的确认模式是什么，重新投递都使用手动确认模式，避免预读的死信消息被提交而丢失。
+func newRedriver(
+	factory consumer.ConsumerFactory,
+	producer produce.Producer,
+	logger *zap.Logger,
+) *worker.Redriver {
+	return worker.NewRedriver(factory, producer, slog.New(zapslog.NewHandler(logger.Core())))
+}
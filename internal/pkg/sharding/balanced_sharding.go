@@ -3,6 +3,7 @@ package sharding
 import (
 	"errors"
 	"math/rand/v2"
+	"time"
 )
 
 // BroadcastMode 广播模式。
@@ -78,8 +79,24 @@ func (s *BalancedSharding) DstFromShardVal(shardVal uint64) (Dst, error) {
 }
 
 func (s *BalancedSharding) Broadcast() []Dst {
-	dsts := s.base.Broadcast()
+	return s.order(s.base.Broadcast())
+}
+
+// BroadcastRange 返回 [from, to] 时间范围内的分片目标并按照广播模式排序。
+// 基础分片策略不支持按时间范围广播时返回所有分片目标。
+func (s *BalancedSharding) BroadcastRange(from, to time.Time) []Dst {
+	if rb, ok := s.base.(RangeBroadcaster); ok {
+		return s.order(rb.BroadcastRange(from, to))
+	}
+	return s.Broadcast()
+}
+
+// Base 返回基础分片策略。
+func (s *BalancedSharding) Base() Strategy {
+	return s.base
+}
 
+func (s *BalancedSharding) order(dsts []Dst) []Dst {
 	switch s.mode {
 	case BroadcastModeRoundRobin:
 		return s.roundRobinBroadcast(dsts)
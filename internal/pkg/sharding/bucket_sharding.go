@@ -0,0 +1,256 @@
+package sharding
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+)
+
+// BucketCount 虚拟桶数量，与 snowflake ID 中 10 位分片值的取值范围一致。
+const BucketCount = 1024
+
+// BucketOf 计算分片值所在的虚拟桶。
+// 只使用分片值的低 10 位，与从 ID 中提取的分片值得到的桶一致。
+func BucketOf(shardVal uint64) uint64 {
+	return shardVal & (BucketCount - 1)
+}
+
+// BucketRange 一段连续的虚拟桶 ( [From, To] 闭区间 ) 到物理分片的路由。
+type BucketRange struct {
+	From uint64
+	To   uint64
+
+	DBSuffix uint64
+	TBSuffix uint64
+}
+
+// BucketTable 虚拟桶路由表，记录每个虚拟桶所在的物理库表。
+// 路由表创建后不可修改，调整路由时创建新的路由表并通过 BucketSharding.SetTable 整体替换。
+type BucketTable struct {
+	routes [BucketCount]bucketRoute
+}
+
+type bucketRoute struct {
+	dbSuffix uint64
+	tbSuffix uint64
+}
+
+// NewBucketTable 根据路由区间创建路由表。
+// 所有虚拟桶必须被覆盖且只能被覆盖一次。
+func NewBucketTable(ranges []BucketRange) (*BucketTable, error) {
+	table := &BucketTable{}
+	var covered [BucketCount]bool
+
+	for _, r := range ranges {
+		if r.From > r.To || r.To >= BucketCount {
+			return nil, fmt.Errorf("invalid bucket range [ %d, %d ]", r.From, r.To)
+		}
+		for b := r.From; b <= r.To; b++ {
+			if covered[b] {
+				return nil, fmt.Errorf("bucket [ %d ] is routed more than once", b)
+			}
+			covered[b] = true
+			table.routes[b] = bucketRoute{dbSuffix: r.DBSuffix, tbSuffix: r.TBSuffix}
+		}
+	}
+
+	for b, ok := range covered {
+		if !ok {
+			return nil, fmt.Errorf("bucket [ %d ] is not routed", b)
+		}
+	}
+	return table, nil
+}
+
+// NewModuloBucketTable 创建与 ModuloSharding 路由结果相同的路由表。
+// 分库数 * 分表数能整除 BucketCount 时，从取模分片切换到虚拟桶分片不需要迁移数据。
+func NewModuloBucketTable(dbShardCount, tbShardCount uint64) (*BucketTable, error) {
+	if dbShardCount == 0 || tbShardCount == 0 {
+		return nil, errors.New("dbShardCount and tbShardCount must be greater than 0")
+	}
+
+	table := &BucketTable{}
+	totalShards := dbShardCount * tbShardCount
+	for b := range uint64(BucketCount) {
+		shardIndex := b % totalShards
+		table.routes[b] = bucketRoute{
+			dbSuffix: shardIndex % dbShardCount,
+			tbSuffix: shardIndex / dbShardCount,
+		}
+	}
+	return table, nil
+}
+
+// Ranges 将路由表压缩为连续的路由区间，用于持久化或展示。
+func (t *BucketTable) Ranges() []BucketRange {
+	var res []BucketRange
+	for b := range uint64(BucketCount) {
+		route := t.routes[b]
+		if n := len(res); n > 0 && res[n-1].To == b-1 &&
+			res[n-1].DBSuffix == route.dbSuffix && res[n-1].TBSuffix == route.tbSuffix {
+			res[n-1].To = b
+			continue
+		}
+		res = append(res, BucketRange{From: b, To: b, DBSuffix: route.dbSuffix, TBSuffix: route.tbSuffix})
+	}
+	return res
+}
+
+// Route 返回虚拟桶所在的物理分片后缀。
+func (t *BucketTable) Route(bucket uint64) (dbSuffix, tbSuffix uint64) {
+	route := t.routes[BucketOf(bucket)]
+	return route.dbSuffix, route.tbSuffix
+}
+
+// Buckets 返回路由到指定物理分片的所有虚拟桶。
+func (t *BucketTable) Buckets(dbSuffix, tbSuffix uint64) []uint64 {
+	var res []uint64
+	for b := range uint64(BucketCount) {
+		if t.routes[b].dbSuffix == dbSuffix && t.routes[b].tbSuffix == tbSuffix {
+			res = append(res, b)
+		}
+	}
+	return res
+}
+
+// WithMoved 返回将虚拟桶移动到新物理分片后的路由表，原路由表不变。
+func (t *BucketTable) WithMoved(bucket, dbSuffix, tbSuffix uint64) (*BucketTable, error) {
+	if bucket >= BucketCount {
+		return nil, fmt.Errorf("invalid bucket [ %d ]", bucket)
+	}
+
+	moved := &BucketTable{routes: t.routes}
+	moved.routes[bucket] = bucketRoute{dbSuffix: dbSuffix, tbSuffix: tbSuffix}
+	return moved, nil
+}
+
+var _ Strategy = (*BucketSharding)(nil)
+
+// BucketSharding 虚拟桶分片策略。
+// 分片值先映射到 1024 个虚拟桶之一 ( 分片值的低 10 位 )，再通过路由表映射到物理库表。
+//
+// 与 ModuloSharding 不同，调整分片数量时不需要重新计算所有数据的位置：
+// 只需要将部分虚拟桶的数据迁移到新的物理分片，然后修改这些桶的路由。
+//
+// 路由表可以在运行时通过 SetTable 原子替换，替换后新的请求立即使用新的路由。
+type BucketSharding struct {
+	extractor ShardValExtractor
+
+	dbPrefix string // 数据库名前缀
+	tbPrefix string // 表名前缀
+
+	table atomic.Pointer[BucketTable]
+}
+
+// NewBucketSharding 创建虚拟桶分片策略。
+// - extractor: 分片值提取器，不能为 nil
+// - dbPrefix: 数据库名前缀，如 "hermet_db"
+// - tbPrefix: 表名前缀，如 "message"
+// - table: 虚拟桶路由表，不能为 nil
+func NewBucketSharding(
+	extractor ShardValExtractor,
+	dbPrefix string,
+	tbPrefix string,
+	table *BucketTable,
+) (*BucketSharding, error) {
+	if extractor == nil {
+		return nil, errors.New("extractor cannot be nil")
+	}
+	if dbPrefix == "" {
+		return nil, errors.New("dbPrefix cannot be empty")
+	}
+	if tbPrefix == "" {
+		return nil, errors.New("tbPrefix cannot be empty")
+	}
+	if table == nil {
+		return nil, errors.New("bucket table cannot be nil")
+	}
+
+	s := &BucketSharding{
+		extractor: extractor,
+		dbPrefix:  dbPrefix,
+		tbPrefix:  tbPrefix,
+	}
+	s.table.Store(table)
+	return s, nil
+}
+
+// Table 返回当前的路由表。
+func (s *BucketSharding) Table() *BucketTable {
+	return s.table.Load()
+}
+
+// SetTable 原子替换路由表。
+func (s *BucketSharding) SetTable(table *BucketTable) error {
+	if table == nil {
+		return errors.New("bucket table cannot be nil")
+	}
+	s.table.Store(table)
+	return nil
+}
+
+func (s *BucketSharding) Shard(shardVal uint64) (Dst, error) {
+	return s.DstFromShardVal(shardVal)
+}
+
+func (s *BucketSharding) DstFromID(id uint64) (Dst, error) {
+	return s.DstFromShardVal(s.extractor.ExtractShardVal(id))
+}
+
+func (s *BucketSharding) DstFromShardVal(shardVal uint64) (Dst, error) {
+	dbSuffix, tbSuffix := s.table.Load().Route(BucketOf(shardVal))
+	return s.dst(dbSuffix, tbSuffix), nil
+}
+
+// DstFromBucket 返回虚拟桶所在的物理分片。
+func (s *BucketSharding) DstFromBucket(bucket uint64) (Dst, error) {
+	if bucket >= BucketCount {
+		return Dst{}, fmt.Errorf("invalid bucket [ %d ]", bucket)
+	}
+	dbSuffix, tbSuffix := s.table.Load().Route(bucket)
+	return s.dst(dbSuffix, tbSuffix), nil
+}
+
+// DstOf 返回库后缀、表后缀对应的物理分片，不检查路由表中是否存在该分片。
+func (s *BucketSharding) DstOf(dbSuffix, tbSuffix uint64) Dst {
+	return s.dst(dbSuffix, tbSuffix)
+}
+
+// Broadcast 返回路由表中出现的所有物理分片，按照库后缀、表后缀排序。
+func (s *BucketSharding) Broadcast() []Dst {
+	table := s.table.Load()
+
+	seen := make(map[bucketRoute]struct{})
+	routes := make([]bucketRoute, 0)
+	for _, route := range table.routes {
+		if _, ok := seen[route]; ok {
+			continue
+		}
+		seen[route] = struct{}{}
+		routes = append(routes, route)
+	}
+
+	slices.SortFunc(routes, func(a, b bucketRoute) int {
+		if a.dbSuffix != b.dbSuffix {
+			return cmp.Compare(a.dbSuffix, b.dbSuffix)
+		}
+		return cmp.Compare(a.tbSuffix, b.tbSuffix)
+	})
+
+	res := make([]Dst, 0, len(routes))
+	for _, route := range routes {
+		res = append(res, s.dst(route.dbSuffix, route.tbSuffix))
+	}
+	return res
+}
+
+func (s *BucketSharding) dst(dbSuffix, tbSuffix uint64) Dst {
+	return Dst{
+		DBSuffix: dbSuffix,
+		TBSuffix: tbSuffix,
+		DB:       fmt.Sprintf("%s_%d", s.dbPrefix, dbSuffix),
+		TB:       fmt.Sprintf("%s_%d", s.tbPrefix, tbSuffix),
+	}
+}
@@ -0,0 +1,201 @@
+package sharding
+
+import (
+	"testing"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen/snowflake"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBucketTable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ranges  []BucketRange
+		wantErr bool
+	}{
+		{
+			name: "full coverage",
+			ranges: []BucketRange{
+				{From: 0, To: 511, DBSuffix: 0, TBSuffix: 0},
+				{From: 512, To: 1023, DBSuffix: 1, TBSuffix: 0},
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing bucket",
+			ranges: []BucketRange{
+				{From: 0, To: 511, DBSuffix: 0, TBSuffix: 0},
+				{From: 513, To: 1023, DBSuffix: 1, TBSuffix: 0},
+			},
+			wantErr: true,
+		},
+		{
+			name: "overlapping ranges",
+			ranges: []BucketRange{
+				{From: 0, To: 512, DBSuffix: 0, TBSuffix: 0},
+				{From: 512, To: 1023, DBSuffix: 1, TBSuffix: 0},
+			},
+			wantErr: true,
+		},
+		{
+			name: "out of range",
+			ranges: []BucketRange{
+				{From: 0, To: 1024, DBSuffix: 0, TBSuffix: 0},
+			},
+			wantErr: true,
+		},
+		{
+			name: "reversed range",
+			ranges: []BucketRange{
+				{From: 1023, To: 0, DBSuffix: 0, TBSuffix: 0},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewBucketTable(tt.ranges)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewBucketTable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewBucketSharding(t *testing.T) {
+	t.Parallel()
+
+	extractor := snowflake.NewExtractor()
+	table, err := NewModuloBucketTable(2, 4)
+	require.NoError(t, err)
+
+	_, err = NewBucketSharding(extractor, "db", "table", table)
+	require.NoError(t, err)
+
+	_, err = NewBucketSharding(nil, "db", "table", table)
+	require.Error(t, err)
+
+	_, err = NewBucketSharding(extractor, "", "table", table)
+	require.Error(t, err)
+
+	_, err = NewBucketSharding(extractor, "db", "", table)
+	require.Error(t, err)
+
+	_, err = NewBucketSharding(extractor, "db", "table", nil)
+	require.Error(t, err)
+}
+
+func TestBucketSharding_CompatibleWithModulo(t *testing.T) {
+	t.Parallel()
+
+	extractor := snowflake.NewExtractor()
+	modulo, err := NewModuloSharding(extractor, "db", "table", 8, 4)
+	require.NoError(t, err)
+
+	table, err := NewModuloBucketTable(8, 4)
+	require.NoError(t, err)
+	bucket, err := NewBucketSharding(extractor, "db", "table", table)
+	require.NoError(t, err)
+
+	// 分片总数能整除 1024 时，两种策略的路由结果完全相同。
+	for i := range 5000 {
+		shardVal := uint64(i) * 7919
+
+		want, err := modulo.Shard(shardVal)
+		require.NoError(t, err)
+		got, err := bucket.Shard(shardVal)
+		require.NoError(t, err)
+
+		require.Equal(t, want, got, "shard val %d", shardVal)
+	}
+
+	require.ElementsMatch(t, modulo.Broadcast(), bucket.Broadcast())
+}
+
+func TestBucketSharding_DstFromID(t *testing.T) {
+	t.Parallel()
+
+	extractor := snowflake.NewExtractor()
+	table, err := NewModuloBucketTable(2, 4)
+	require.NoError(t, err)
+	strategy, err := NewBucketSharding(extractor, "db", "table", table)
+	require.NoError(t, err)
+
+	gen := snowflake.NewGenerator()
+
+	// 分片值超过 10 位时，ID 中只保存低 10 位，两种方式计算的分片必须一致。
+	for i := range 100 {
+		shardVal := uint64(i) * 104729
+
+		expected, err := strategy.Shard(shardVal)
+		require.NoError(t, err)
+
+		id, err := gen.NextID(shardVal)
+		require.NoError(t, err)
+
+		actual, err := strategy.DstFromID(id)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	}
+}
+
+func TestBucketSharding_MoveBucket(t *testing.T) {
+	t.Parallel()
+
+	extractor := snowflake.NewExtractor()
+	table, err := NewModuloBucketTable(2, 1)
+	require.NoError(t, err)
+	strategy, err := NewBucketSharding(extractor, "db", "table", table)
+	require.NoError(t, err)
+
+	// 将桶 0 移动到新的分库 db_2。
+	moved, err := strategy.Table().WithMoved(0, 2, 0)
+	require.NoError(t, err)
+	require.NoError(t, strategy.SetTable(moved))
+
+	dst, err := strategy.Shard(0)
+	require.NoError(t, err)
+	require.Equal(t, "db_2", dst.DB)
+
+	// 只有被移动的桶路由发生变化。
+	for b := uint64(1); b < BucketCount; b++ {
+		dst, err := strategy.DstFromBucket(b)
+		require.NoError(t, err)
+
+		oldDB, oldTB := table.Route(b)
+		require.Equal(t, oldDB, dst.DBSuffix)
+		require.Equal(t, oldTB, dst.TBSuffix)
+	}
+
+	// 原路由表不受影响。
+	dbSuffix, _ := table.Route(0)
+	require.Equal(t, uint64(0), dbSuffix)
+
+	dsts := strategy.Broadcast()
+	require.Len(t, dsts, 3)
+	require.Equal(t, "db_2", dsts[2].DB)
+}
+
+func TestBucketTable_Ranges(t *testing.T) {
+	t.Parallel()
+
+	ranges := []BucketRange{
+		{From: 0, To: 99, DBSuffix: 0, TBSuffix: 1},
+		{From: 100, To: 1000, DBSuffix: 1, TBSuffix: 0},
+		{From: 1001, To: 1023, DBSuffix: 0, TBSuffix: 1},
+	}
+	table, err := NewBucketTable(ranges)
+	require.NoError(t, err)
+	require.Equal(t, ranges, table.Ranges())
+
+	rebuilt, err := NewBucketTable(table.Ranges())
+	require.NoError(t, err)
+	require.Equal(t, table, rebuilt)
+
+	require.Len(t, table.Buckets(0, 1), 100+23)
+}
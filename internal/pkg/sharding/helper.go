@@ -2,6 +2,7 @@ package sharding
 
 import (
 	"errors"
+	"time"
 
 	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen"
 )
@@ -52,6 +53,7 @@ func NewShardHelper(gen idgen.Generator, strategy Strategy) (*ShardHelper, error
 
 // NextIDAndShard 同时生成 ID 和计算分片目标。
 // 这是最常用的方法，一次调用完成 ID 生成和分片计算，性能最优。
+// 分片目标从生成的 ID 计算，与之后 DstFromID 的结果一致 ( 包括按时间分表的策略 )。
 // 返回值：
 // - uint64: 生成的 ID ( 包含分片信息 )
 // - Dst: 分片目标信息
@@ -66,7 +68,7 @@ func (s *ShardHelper) NextIDAndShard(sharder Sharder) (uint64, Dst, error) {
 		return 0, Dst{}, err
 	}
 
-	dst, err := s.strategy.Shard(shardVal)
+	dst, err := s.strategy.DstFromID(id)
 	if err != nil {
 		return 0, Dst{}, err
 	}
@@ -87,6 +89,7 @@ func (s *ShardHelper) NextID(sharder Sharder) (uint64, error) {
 
 // Shard 计算分片目标。
 // 适用于不需要生成 ID，只需要知道数据应该存放在哪个分片的场景。
+// 按时间分表的策略无法只根据分片值路由，返回 ErrTimeRequired。
 func (s *ShardHelper) Shard(sharder Sharder) (Dst, error) {
 	shardVal, err := sharder.ShardVal()
 	if err != nil {
@@ -101,6 +104,7 @@ func (s *ShardHelper) DstFromID(id uint64) (Dst, error) {
 	return s.strategy.DstFromID(id)
 }
 
+// DstFromSharder 根据 sharder 的分片值计算分片目标，按时间分表的策略返回 ErrTimeRequired。
 func (s *ShardHelper) DstFromSharder(sharder Sharder) (Dst, error) {
 	shardVal, err := sharder.ShardVal()
 	if err != nil {
@@ -110,7 +114,16 @@ func (s *ShardHelper) DstFromSharder(sharder Sharder) (Dst, error) {
 }
 
 // Broadcast 返回所有分片的目标列表。
-// 适用于需要在所有分片上执行查询的场景 ( 如全量扫描 )。
+// 适用于需要在所有分片上执行查询的场景 ( 如全量扫描 )，并发查询并合并结果使用 ScatterGather。
 func (s *ShardHelper) Broadcast() []Dst {
 	return s.strategy.Broadcast()
 }
+
+// BroadcastRange 返回 [from, to] 时间范围内的分片目标列表。
+// 适用于按时间分表的数据 ( 如 TimeRangeSharding )，分片策略不支持按时间范围广播时返回所有分片。
+func (s *ShardHelper) BroadcastRange(from, to time.Time) []Dst {
+	if rb, ok := s.strategy.(RangeBroadcaster); ok {
+		return rb.BroadcastRange(from, to)
+	}
+	return s.strategy.Broadcast()
+}
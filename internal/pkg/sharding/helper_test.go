@@ -149,6 +149,32 @@ func TestShardHelper_NextId(t *testing.T) {
 	}
 }
 
+func TestShardHelper_TimeRange(t *testing.T) {
+	t.Parallel()
+
+	strategy, err := NewTimeRangeSharding(snowflake.NewExtractor(), TimeRangeConfig{
+		DBPrefix: "db", TBPrefix: "message", DBShardCount: 2, TBShardCount: 2, Period: TimePeriodMonth,
+	})
+	require.NoError(t, err)
+	helper, err := NewShardHelper(snowflake.NewGenerator(), strategy)
+	require.NoError(t, err)
+
+	sharder := NewSingleIDSharder(12345)
+
+	// 分片目标从生成的 ID 计算，与 DstFromID 一致。
+	id, dst, err := helper.NextIDAndShard(sharder)
+	require.NoError(t, err)
+	dstFromID, err := helper.DstFromID(id)
+	require.NoError(t, err)
+	require.Equal(t, dstFromID, dst)
+
+	// 只有分片值时无法路由。
+	_, err = helper.Shard(sharder)
+	require.ErrorIs(t, err, ErrTimeRequired)
+	_, err = helper.DstFromSharder(sharder)
+	require.ErrorIs(t, err, ErrTimeRequired)
+}
+
 func TestShardHelper_Shard(t *testing.T) {
 	t.Parallel()
 
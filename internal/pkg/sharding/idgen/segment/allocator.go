@@ -0,0 +1,239 @@
+package segment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen"
+)
+
+const (
+	defaultStep          = 1000
+	defaultPrefetchRatio = 0.2
+	defaultLoadTimeout   = 3 * time.Second
+)
+
+var _ idgen.SeqGenerator = (*Allocator)(nil)
+
+// Config 号段分配器配置。
+type Config struct {
+	// Step 每次从 Store 分配的号段长度，默认为 1000。
+	// 为 1 时不在内存中缓存号段，每次都直接从 Store 分配，多个实例之间的序列号按分配的先后顺序递增。
+	// 大于 1 时不同实例之间的序列号不保证有序，依赖序列号顺序的场景只能单实例部署。
+	Step uint64
+	// PrefetchRatio 当前号段剩余比例小于该值时异步加载下一个号段，默认为 0.2。
+	PrefetchRatio float64
+	// LoadTimeout 异步加载号段的超时时间，默认为 3s。
+	LoadTimeout time.Duration
+}
+
+// Allocator 号段 ( segment ) 分配器，每个 key 在内存中缓存两个号段 ( 双 buffer )：
+// 当前号段用完之前异步加载下一个号段，当前号段用完后直接切换，Store 短暂不可用时不影响分配。
+//
+// 生成的序列号：
+// - 同一个实例内每个 key 的序列号严格递增；
+// - 多个实例之间不重复，但是不保证按照分配的先后顺序递增 ( 每个实例持有不同的号段，Step 为 1 时除外 )；
+// - 实例重启时未用完的号段会被丢弃，序列号会出现空洞。
+//
+// 因此双 buffer 只适用于单实例部署，或者只要求序列号唯一、不要求跨实例有序的场景；
+// 多实例部署并且依赖序列号顺序时 Step 必须为 1 ( 每次都从 Store 分配 )。
+//
+// 相比 snowflake，序列号紧凑并且不依赖时钟，但是不携带分片信息和时间信息，不能作为分片表的 ID。
+type Allocator struct {
+	store  Store
+	cfg    Config
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	buffers map[string]*buffer
+}
+
+// buffer 单个 key 的双 buffer。
+type buffer struct {
+	mu sync.Mutex
+
+	cur     Segment       // 当前号段，cur.Start 为下一个可用的序列号
+	next    *Segment      // 预加载的下一个号段
+	loading chan struct{} // 不为 nil 时正在加载下一个号段，加载结束后关闭
+	err     error         // 最近一次加载的错误
+}
+
+func NewAllocator(store Store, cfg Config, logger *slog.Logger) (*Allocator, error) {
+	if store == nil {
+		return nil, errors.New("store cannot be nil")
+	}
+	if cfg.Step == 0 {
+		cfg.Step = defaultStep
+	}
+	if cfg.PrefetchRatio <= 0 || cfg.PrefetchRatio >= 1 {
+		cfg.PrefetchRatio = defaultPrefetchRatio
+	}
+	if cfg.LoadTimeout <= 0 {
+		cfg.LoadTimeout = defaultLoadTimeout
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Allocator{
+		store:   store,
+		cfg:     cfg,
+		logger:  logger,
+		buffers: make(map[string]*buffer),
+	}, nil
+}
+
+// NextSeq 生成 key 的下一个序列号，两个号段都用完时等待号段加载完成。
+// floor 只在 Store 中没有 key 时调用 ( 见 Store.Reserve )。
+func (a *Allocator) NextSeq(ctx context.Context, key string, floor idgen.SeqFloorFunc) (uint64, error) {
+	if a.cfg.Step == 1 {
+		seg, err := a.store.Reserve(ctx, key, 1, floor)
+		if err != nil {
+			return 0, err
+		}
+		return seg.Start, nil
+	}
+
+	b := a.buffer(key)
+
+	b.mu.Lock()
+	for {
+		if b.cur.Start < b.cur.End {
+			seq := b.cur.Start
+			b.cur.Start++
+			a.prefetch(key, floor, b)
+			b.mu.Unlock()
+			return seq, nil
+		}
+
+		if b.next != nil {
+			b.cur, b.next = *b.next, nil
+			continue
+		}
+
+		if b.loading == nil {
+			a.load(key, floor, b)
+		}
+		loading := b.loading
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-loading:
+		}
+
+		b.mu.Lock()
+		if b.next == nil && b.err != nil {
+			err := b.err
+			b.mu.Unlock()
+			return 0, err
+		}
+	}
+}
+
+func (a *Allocator) buffer(key string) *buffer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	b, ok := a.buffers[key]
+	if !ok {
+		b = &buffer{}
+		a.buffers[key] = b
+	}
+	return b
+}
+
+// prefetch 当前号段剩余比例小于 PrefetchRatio 时异步加载下一个号段，需要持有 b.mu。
+func (a *Allocator) prefetch(key string, floor idgen.SeqFloorFunc, b *buffer) {
+	if b.next != nil || b.loading != nil {
+		return
+	}
+	if float64(b.cur.End-b.cur.Start) > float64(a.cfg.Step)*a.cfg.PrefetchRatio {
+		return
+	}
+	a.load(key, floor, b)
+}
+
+// load 异步加载下一个号段，需要持有 b.mu。
+func (a *Allocator) load(key string, floor idgen.SeqFloorFunc, b *buffer) {
+	done := make(chan struct{})
+	b.loading = done
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), a.cfg.LoadTimeout)
+		seg, err := a.store.Reserve(ctx, key, a.cfg.Step, floor)
+		cancel()
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if err != nil {
+			b.err = err
+			a.logger.Error("[hermet-idgen-segment] failed to load segment", slog.String("key", key), slog.Any("err", err))
+		} else {
+			b.next = &seg
+			b.err = nil
+		}
+		b.loading = nil
+		close(done)
+	}()
+}
+
+var _ idgen.SeqGenerator = (*LazyAllocator)(nil)
+
+// LazyAllocator 第一次生成序列号时才创建 Store 和 Allocator，Store 的依赖 ( 如数据库 ) 不可用时不影响启动。
+// 创建失败时返回错误，下一次生成序列号时重新创建。
+type LazyAllocator struct {
+	newStore func() (Store, error)
+	cfg      Config
+	logger   *slog.Logger
+
+	mu        sync.Mutex // 创建 Allocator 时持有
+	allocator atomic.Pointer[Allocator]
+}
+
+func NewLazyAllocator(newStore func() (Store, error), cfg Config, logger *slog.Logger) *LazyAllocator {
+	return &LazyAllocator{
+		newStore: newStore,
+		cfg:      cfg,
+		logger:   logger,
+	}
+}
+
+func (a *LazyAllocator) NextSeq(ctx context.Context, key string, floor idgen.SeqFloorFunc) (uint64, error) {
+	allocator, err := a.load()
+	if err != nil {
+		return 0, err
+	}
+	return allocator.NextSeq(ctx, key, floor)
+}
+
+func (a *LazyAllocator) load() (*Allocator, error) {
+	if allocator := a.allocator.Load(); allocator != nil {
+		return allocator, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if allocator := a.allocator.Load(); allocator != nil {
+		return allocator, nil
+	}
+
+	store, err := a.newStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create segment store: %w", err)
+	}
+	allocator, err := NewAllocator(store, a.cfg, a.logger)
+	if err != nil {
+		return nil, err
+	}
+	a.allocator.Store(allocator)
+	return allocator, nil
+}
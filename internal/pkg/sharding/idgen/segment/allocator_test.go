@@ -0,0 +1,249 @@
+package segment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen"
+	"github.com/stretchr/testify/require"
+)
+
+type memStore struct {
+	mu     sync.Mutex
+	maxIDs map[string]uint64
+
+	calls atomic.Int64
+	err   atomic.Pointer[error]
+}
+
+func newMemStore() *memStore {
+	return &memStore{maxIDs: make(map[string]uint64)}
+}
+
+func (s *memStore) Reserve(ctx context.Context, key string, step uint64, floor idgen.SeqFloorFunc) (Segment, error) {
+	s.calls.Add(1)
+	if err := s.err.Load(); err != nil {
+		return Segment{}, *err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.maxIDs[key]; !ok {
+		initID, err := initialMaxID(ctx, key, floor)
+		if err != nil {
+			return Segment{}, err
+		}
+		s.maxIDs[key] = initID
+	}
+	s.maxIDs[key] += step
+	return Segment{Start: s.maxIDs[key] - step + 1, End: s.maxIDs[key] + 1}, nil
+}
+
+func TestNewAllocator(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewAllocator(nil, Config{}, nil)
+	require.Error(t, err)
+
+	a, err := NewAllocator(newMemStore(), Config{PrefetchRatio: 2}, nil)
+	require.NoError(t, err)
+	require.Equal(t, Config{Step: defaultStep, PrefetchRatio: defaultPrefetchRatio, LoadTimeout: defaultLoadTimeout}, a.cfg)
+}
+
+func TestAllocator_NextSeq(t *testing.T) {
+	t.Parallel()
+
+	store := newMemStore()
+	a, err := NewAllocator(store, Config{Step: 10, PrefetchRatio: 0.5}, nil)
+	require.NoError(t, err)
+
+	// 同一个 key 的序列号从 1 开始连续递增，不同 key 相互独立。
+	for want := uint64(1); want <= 35; want++ {
+		seq, err := a.NextSeq(t.Context(), "channel:1", nil)
+		require.NoError(t, err)
+		require.Equal(t, want, seq)
+	}
+
+	seq, err := a.NextSeq(t.Context(), "channel:2", nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), seq)
+}
+
+func TestAllocator_Prefetch(t *testing.T) {
+	t.Parallel()
+
+	store := newMemStore()
+	a, err := NewAllocator(store, Config{Step: 10, PrefetchRatio: 0.5}, nil)
+	require.NoError(t, err)
+
+	for range 6 {
+		_, err := a.NextSeq(t.Context(), "key", nil)
+		require.NoError(t, err)
+	}
+
+	// 剩余 4 个 ( < 10 * 0.5 ) 时已经开始加载下一个号段。
+	require.Eventually(t, func() bool { return store.calls.Load() == 2 }, time.Second, time.Millisecond)
+
+	// 当前号段用完前 Store 不可用，仍然可以使用预加载的号段。
+	errStore := errors.New("store unavailable")
+	store.err.Store(&errStore)
+	for want := uint64(7); want <= 15; want++ {
+		seq, err := a.NextSeq(t.Context(), "key", nil)
+		require.NoError(t, err)
+		require.Equal(t, want, seq)
+	}
+}
+
+func TestAllocator_StoreError(t *testing.T) {
+	t.Parallel()
+
+	store := newMemStore()
+	errStore := errors.New("store unavailable")
+	store.err.Store(&errStore)
+
+	a, err := NewAllocator(store, Config{Step: 10}, nil)
+	require.NoError(t, err)
+
+	_, err = a.NextSeq(t.Context(), "key", nil)
+	require.ErrorIs(t, err, errStore)
+
+	// Store 恢复后可以继续分配。
+	store.err.Store(nil)
+	seq, err := a.NextSeq(t.Context(), "key", nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), seq)
+}
+
+func TestAllocator_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	store := newMemStore()
+	a, err := NewAllocator(store, Config{Step: 50}, nil)
+	require.NoError(t, err)
+
+	const (
+		workers = 8
+		perWork = 500
+	)
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[uint64]struct{}, workers*perWork)
+		wg   sync.WaitGroup
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var last uint64
+			for range perWork {
+				seq, err := a.NextSeq(context.Background(), "key", nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// 同一个 goroutine 得到的序列号递增。
+				if seq <= last {
+					t.Errorf("seq %d is not greater than %d", seq, last)
+				}
+				last = seq
+
+				mu.Lock()
+				seen[seq] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, seen, workers*perWork)
+}
+
+func TestAllocator_NextSeqWithoutBuffer(t *testing.T) {
+	t.Parallel()
+
+	store := newMemStore()
+	a1, err := NewAllocator(store, Config{Step: 1}, nil)
+	require.NoError(t, err)
+	a2, err := NewAllocator(store, Config{Step: 1}, nil)
+	require.NoError(t, err)
+
+	// Step 为 1 时每次都从 Store 分配，多个实例交替分配的序列号按分配顺序递增。
+	for want := uint64(1); want <= 6; want++ {
+		a := a1
+		if want%2 == 0 {
+			a = a2
+		}
+		seq, err := a.NextSeq(t.Context(), "user:1", nil)
+		require.NoError(t, err)
+		require.Equal(t, want, seq)
+	}
+	require.Equal(t, int64(6), store.calls.Load())
+	require.Empty(t, a1.buffers)
+
+	failed := errors.New("store unavailable")
+	store.err.Store(&failed)
+	_, err = a1.NextSeq(t.Context(), "user:1", nil)
+	require.ErrorIs(t, err, failed)
+}
+
+func TestAllocator_NextSeqFloor(t *testing.T) {
+	t.Parallel()
+
+	for _, step := range []uint64{1, 10} {
+		t.Run(fmt.Sprintf("step %d", step), func(t *testing.T) {
+			t.Parallel()
+
+			store := newMemStore()
+			a, err := NewAllocator(store, Config{Step: step}, nil)
+			require.NoError(t, err)
+
+			var calls atomic.Int64
+			floor := func(_ context.Context, key string) (uint64, error) {
+				calls.Add(1)
+				require.Equal(t, "user:1", key)
+				return 41, nil
+			}
+
+			// 从 floor 的下一个序列号开始生成，key 初始化之后不再调用 floor。
+			for want := uint64(42); want <= 56; want++ {
+				seq, err := a.NextSeq(t.Context(), "user:1", floor)
+				require.NoError(t, err)
+				require.Equal(t, want, seq)
+			}
+			require.Equal(t, int64(1), calls.Load())
+		})
+	}
+}
+
+func TestLazyAllocator_NextSeq(t *testing.T) {
+	t.Parallel()
+
+	store := newMemStore()
+	failed := errors.New("database is not configured")
+	var builds atomic.Int64
+	a := NewLazyAllocator(func() (Store, error) {
+		// 第一次创建失败，之后创建成功。
+		if builds.Add(1) == 1 {
+			return nil, failed
+		}
+		return store, nil
+	}, Config{Step: 1}, nil)
+
+	_, err := a.NextSeq(t.Context(), "user:1", nil)
+	require.ErrorIs(t, err, failed)
+
+	for want := uint64(1); want <= 3; want++ {
+		seq, err := a.NextSeq(t.Context(), "user:1", nil)
+		require.NoError(t, err)
+		require.Equal(t, want, seq)
+	}
+	// 创建成功后不会再次创建。
+	require.Equal(t, int64(2), builds.Load())
+}
@@ -0,0 +1,189 @@
+package segment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Segment 号段，可用的 ID 为 [ Start, End )。
+type Segment struct {
+	Start uint64
+	End   uint64
+}
+
+// Store 号段存储，多个实例共享，保证每次分配的号段不重叠且递增。
+type Store interface {
+	// Reserve 为 key 分配长度为 step 的号段。
+	// 存储中没有 key 时先使用 floor 的返回值初始化 ( floor 为 nil 时为 0 )，号段从初始值的下一个序列号开始。
+	Reserve(ctx context.Context, key string, step uint64, floor idgen.SeqFloorFunc) (Segment, error)
+}
+
+// initialMaxID 返回 key 初始化时已经分配的最大 ID。
+func initialMaxID(ctx context.Context, key string, floor idgen.SeqFloorFunc) (uint64, error) {
+	if floor == nil {
+		return 0, nil
+	}
+	maxID, err := floor(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load floor of [ %s ]: %w", key, err)
+	}
+	return maxID, nil
+}
+
+var (
+	_ Store = (*PostgresStore)(nil)
+	_ Store = (*RedisStore)(nil)
+)
+
+// PostgresTable 号段表，保存每个 key 已经分配的最大 ID。
+const PostgresTable = "id_segment"
+
+const createPostgresTableSQL = `CREATE TABLE IF NOT EXISTS ` + PostgresTable + ` (
+    biz_key VARCHAR(128) PRIMARY KEY,
+    max_id BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL
+)`
+
+const reservePostgresSQL = `UPDATE ` + PostgresTable + ` SET max_id = max_id + ?, updated_at = ? WHERE biz_key = ? RETURNING max_id`
+
+// initPostgresSQL 初始化 key，其他实例已经初始化时不做处理。
+const initPostgresSQL = `INSERT INTO ` + PostgresTable + ` (biz_key, max_id, updated_at) VALUES (?, ?, ?)
+ON CONFLICT (biz_key) DO NOTHING`
+
+// PostgresStore 基于 postgres 的号段存储，通过一条 update 语句原子地分配号段。
+// 号段表在第一次分配时自动创建，key 不存在时先初始化再分配。
+type PostgresStore struct {
+	db *gorm.DB
+
+	mu      sync.Mutex
+	created bool
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Reserve(ctx context.Context, key string, step uint64, floor idgen.SeqFloorFunc) (Segment, error) {
+	if err := s.ensureTable(ctx); err != nil {
+		return Segment{}, err
+	}
+
+	maxID, ok, err := s.reserve(ctx, key, step)
+	if err != nil {
+		return Segment{}, err
+	}
+	if !ok {
+		initID, err := initialMaxID(ctx, key, floor)
+		if err != nil {
+			return Segment{}, err
+		}
+		if err := s.db.WithContext(ctx).
+			Exec(initPostgresSQL, key, initID, time.Now().UnixMilli()).Error; err != nil {
+			return Segment{}, fmt.Errorf("failed to init segment of [ %s ]: %w", key, err)
+		}
+
+		maxID, ok, err = s.reserve(ctx, key, step)
+		if err != nil {
+			return Segment{}, err
+		}
+		if !ok {
+			return Segment{}, fmt.Errorf("segment of [ %s ] is not initialized", key)
+		}
+	}
+	return Segment{Start: maxID - step + 1, End: maxID + 1}, nil
+}
+
+// reserve 分配号段，key 不存在时返回 ok = false。
+func (s *PostgresStore) reserve(ctx context.Context, key string, step uint64) (uint64, bool, error) {
+	var maxIDs []uint64
+	if err := s.db.WithContext(ctx).
+		Raw(reservePostgresSQL, step, time.Now().UnixMilli(), key).
+		Scan(&maxIDs).Error; err != nil {
+		return 0, false, fmt.Errorf("failed to reserve segment of [ %s ]: %w", key, err)
+	}
+	if len(maxIDs) == 0 {
+		return 0, false, nil
+	}
+	return maxIDs[0], true, nil
+}
+
+func (s *PostgresStore) ensureTable(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.created {
+		return nil
+	}
+	if err := s.db.WithContext(ctx).Exec(createPostgresTableSQL).Error; err != nil {
+		return fmt.Errorf("failed to create segment table: %w", err)
+	}
+	s.created = true
+	return nil
+}
+
+const defaultRedisStoreKeyPrefix = "idgen:segment"
+
+// reserveIfExistsScript 只有 key 存在时才分配号段，key 不存在时返回 -1。
+var reserveIfExistsScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('INCRBY', KEYS[1], ARGV[1])
+end
+return -1
+`)
+
+// RedisStore 基于 redis INCRBY 的号段存储。
+// key 不存在时 ( 第一次使用或者 redis 丢失数据 ) 使用 floor 的返回值初始化，避免序列号从 1 重新开始。
+// 注意：没有传入 floor 时 redis 丢失数据会分配重复的号段，对可靠性要求高的场景使用 PostgresStore。
+type RedisStore struct {
+	rdb    redis.Cmdable
+	prefix string
+}
+
+func NewRedisStore(rdb redis.Cmdable) *RedisStore {
+	return &RedisStore{
+		rdb:    rdb,
+		prefix: defaultRedisStoreKeyPrefix,
+	}
+}
+
+func (s *RedisStore) Reserve(ctx context.Context, key string, step uint64, floor idgen.SeqFloorFunc) (Segment, error) {
+	storeKey := s.prefix + ":" + key
+
+	maxID, err := s.reserve(ctx, storeKey, step)
+	if err != nil {
+		return Segment{}, fmt.Errorf("failed to reserve segment of [ %s ]: %w", key, err)
+	}
+	if maxID < 0 {
+		initID, err := initialMaxID(ctx, key, floor)
+		if err != nil {
+			return Segment{}, err
+		}
+		if err := s.rdb.SetNX(ctx, storeKey, initID, 0).Err(); err != nil {
+			return Segment{}, fmt.Errorf("failed to init segment of [ %s ]: %w", key, err)
+		}
+
+		maxID, err = s.reserve(ctx, storeKey, step)
+		if err != nil {
+			return Segment{}, fmt.Errorf("failed to reserve segment of [ %s ]: %w", key, err)
+		}
+		if maxID < 0 {
+			return Segment{}, fmt.Errorf("segment of [ %s ] is not initialized", key)
+		}
+	}
+	if maxID == 0 {
+		return Segment{}, errors.New("invalid segment max id")
+	}
+	return Segment{Start: uint64(maxID) - step + 1, End: uint64(maxID) + 1}, nil
+}
+
+// reserve 分配号段，key 不存在时返回 -1。
+func (s *RedisStore) reserve(ctx context.Context, storeKey string, step uint64) (int64, error) {
+	return reserveIfExistsScript.Run(ctx, s.rdb, []string{storeKey}, step).Int64()
+}
@@ -0,0 +1,214 @@
+package segment
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newSQLiteDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "segment.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// sqlite 不支持并发写入。
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return db
+}
+
+func newMiniRedis(t *testing.T) (*miniredis.Miniredis, redis.Cmdable) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return mr, rdb
+}
+
+// storeCases 使用 sqlite 代替 postgres ( 号段分配的 upsert 语句两者都支持 )，使用 miniredis 代替 redis。
+func storeCases() []struct {
+	name     string
+	newStore func(t *testing.T) Store
+} {
+	return []struct {
+		name     string
+		newStore func(t *testing.T) Store
+	}{
+		{
+			name: "postgres",
+			newStore: func(t *testing.T) Store {
+				t.Helper()
+				return NewPostgresStore(newSQLiteDB(t))
+			},
+		}, {
+			name: "redis",
+			newStore: func(t *testing.T) Store {
+				t.Helper()
+				_, rdb := newMiniRedis(t)
+				return NewRedisStore(rdb)
+			},
+		},
+	}
+}
+
+func TestStore_Reserve(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range storeCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := tc.newStore(t)
+
+			// 第一次分配从 1 开始，之后的号段紧接上一个号段。
+			seg, err := store.Reserve(t.Context(), "channel:1", 10, nil)
+			require.NoError(t, err)
+			require.Equal(t, Segment{Start: 1, End: 11}, seg)
+
+			seg, err = store.Reserve(t.Context(), "channel:1", 5, nil)
+			require.NoError(t, err)
+			require.Equal(t, Segment{Start: 11, End: 16}, seg)
+
+			// 不同的 key 相互独立。
+			seg, err = store.Reserve(t.Context(), "channel:2", 1, nil)
+			require.NoError(t, err)
+			require.Equal(t, Segment{Start: 1, End: 2}, seg)
+		})
+	}
+}
+
+func TestStore_ReserveFloor(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range storeCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := tc.newStore(t)
+
+			var calls atomic.Int64
+			floor := func(context.Context, string) (uint64, error) {
+				calls.Add(1)
+				return 100, nil
+			}
+
+			// key 不存在时从 floor 的下一个序列号开始分配。
+			seg, err := store.Reserve(t.Context(), "user:1", 10, floor)
+			require.NoError(t, err)
+			require.Equal(t, Segment{Start: 101, End: 111}, seg)
+
+			// key 已经存在时不再调用 floor。
+			seg, err = store.Reserve(t.Context(), "user:1", 1, floor)
+			require.NoError(t, err)
+			require.Equal(t, Segment{Start: 111, End: 112}, seg)
+			require.Equal(t, int64(1), calls.Load())
+
+			// floor 失败时不初始化 key。
+			errFloor := errors.New("mock error")
+			_, err = store.Reserve(t.Context(), "user:2", 1, func(context.Context, string) (uint64, error) {
+				return 0, errFloor
+			})
+			require.ErrorIs(t, err, errFloor)
+			seg, err = store.Reserve(t.Context(), "user:2", 1, nil)
+			require.NoError(t, err)
+			require.Equal(t, Segment{Start: 1, End: 2}, seg)
+		})
+	}
+}
+
+func TestRedisStore_ReserveAfterDataLoss(t *testing.T) {
+	t.Parallel()
+
+	mr, rdb := newMiniRedis(t)
+	store := NewRedisStore(rdb)
+
+	var maxSeq atomic.Uint64
+	floor := func(context.Context, string) (uint64, error) {
+		return maxSeq.Load(), nil
+	}
+
+	for range 5 {
+		seg, err := store.Reserve(t.Context(), "user:1", 1, floor)
+		require.NoError(t, err)
+		maxSeq.Store(seg.Start)
+	}
+
+	// redis 丢失数据后使用 floor 重新初始化，序列号继续递增。
+	mr.FlushAll()
+	seg, err := store.Reserve(t.Context(), "user:1", 1, floor)
+	require.NoError(t, err)
+	require.Equal(t, Segment{Start: 6, End: 7}, seg)
+}
+
+func TestStore_ReserveConcurrently(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range storeCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := tc.newStore(t)
+
+			const (
+				workers = 8
+				step    = 3
+			)
+			segs := make([]Segment, workers)
+			var wg sync.WaitGroup
+			for i := range workers {
+				wg.Go(func() {
+					seg, err := store.Reserve(t.Context(), "user:1", step, nil)
+					if err == nil {
+						segs[i] = seg
+					}
+				})
+			}
+			wg.Wait()
+
+			// 并发分配的号段互不重叠，并且正好覆盖 [ 1, workers * step ]。
+			seen := make(map[uint64]struct{}, workers*step)
+			for _, seg := range segs {
+				require.Equal(t, uint64(step), seg.End-seg.Start)
+				for id := seg.Start; id < seg.End; id++ {
+					seen[id] = struct{}{}
+				}
+			}
+			require.Len(t, seen, workers*step)
+			for id := uint64(1); id <= workers*step; id++ {
+				require.Contains(t, seen, id)
+			}
+		})
+	}
+}
+
+func TestRedisStore_ReserveError(t *testing.T) {
+	t.Parallel()
+
+	mr, rdb := newMiniRedis(t)
+	store := NewRedisStore(rdb)
+
+	// key 不是整数时 INCRBY 失败。
+	mr.Set(defaultRedisStoreKeyPrefix+":user:1", "invalid")
+	_, err := store.Reserve(t.Context(), "user:1", 1, nil)
+	require.Error(t, err)
+
+	mr.Close()
+	_, err = store.Reserve(t.Context(), "user:2", 1, nil)
+	require.Error(t, err)
+}
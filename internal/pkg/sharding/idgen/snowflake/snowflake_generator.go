@@ -2,6 +2,7 @@ package snowflake
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -27,8 +28,14 @@ const (
 
 	// maxBackwardMillis 最大允许的时钟回拨毫秒数 ( 5s )
 	maxBackwardMillis = 5000
+
+	// MaxWorkerBits worker ID 最多占用的序列号位数，保证每毫秒每个分片值至少可以生成 16 个 ID。
+	MaxWorkerBits = sequenceBits - 4
 )
 
+// WorkerIDFunc 返回当前实例的 worker ID，返回错误时 ( 如 worker ID 租约失效 ) 不能生成 ID。
+type WorkerIDFunc func() (uint64, error)
+
 var _ idgen.Generator = (*Generator)(nil)
 
 // Generator 是自定义的雪花算法 id 生成器。
@@ -45,6 +52,15 @@ var _ idgen.Generator = (*Generator)(nil)
 // - ...
 // 通过 id 能解析出分片值从而获得分库分表信息。
 //
+// 多个实例为同一个分片值在同一毫秒内生成 ID 时会产生重复的 ID，多实例部署时需要使用 NewWorkerGenerator，
+// 将 12 位序列号的高位划分给 worker ID：
+//
+// ┌───────────────────────────────────────────────────────────────────────────────┐
+// │ 41位时间戳 │ 10位分片值 ( shardVal ) │ workerBits 位 worker ID │ 剩余位序列号 │
+// └───────────────────────────────────────────────────────────────────────────────┘
+//
+// 时间戳和分片值的位置不变，SnowflakeExtractor 对两种布局都适用。
+//
 // 并发安全：Generator 内部使用互斥锁保证并发安全。
 // 注意：shardVal 参数只有低 10 位会被使用，高位会被截断。
 type Generator struct {
@@ -53,6 +69,10 @@ type Generator struct {
 	sequence uint64
 	lastTime uint64 // 上一次生成 id 的时间 ( 毫秒 )
 
+	seqBits  uint   // 序列号位数
+	seqMask  uint64 // 序列号掩码
+	workerID WorkerIDFunc
+
 	epoch time.Time
 }
 
@@ -60,16 +80,35 @@ func NewGenerator() *Generator {
 	return &Generator{
 		sequence: 0,
 		lastTime: 0,
+		seqBits:  sequenceBits,
+		seqMask:  sequenceMask,
 		epoch:    time.Unix(int64(epochMillis/number1000), int64((epochMillis%number1000)*number1000000)),
 	}
 }
 
+// NewWorkerGenerator 创建带 worker ID 的 id 生成器，序列号的高 workerBits 位为 worker ID。
+// 每个实例需要使用不同的 worker ID ( 如 worker.RedisLease 租用的 ID )，workerID 的取值范围为 [ 0, 2^workerBits )。
+func NewWorkerGenerator(workerBits uint, workerID WorkerIDFunc) (*Generator, error) {
+	if workerBits == 0 || workerBits > MaxWorkerBits {
+		return nil, fmt.Errorf("workerBits must be in [ 1, %d ]", MaxWorkerBits)
+	}
+	if workerID == nil {
+		return nil, errors.New("workerID cannot be nil")
+	}
+
+	g := NewGenerator()
+	g.seqBits = sequenceBits - workerBits
+	g.seqMask = (uint64(1) << g.seqBits) - 1
+	g.workerID = workerID
+	return g, nil
+}
+
 // NextID 生成 id。
 //
 // id 组成信息:
 // ├── 41 位时间戳，基准时间为 2025-01-01 00:00:00。
 // ├── 10 位分片值 ( 用于分库分表 )。
-// ├── 12 位自增序列。
+// ├── 12 位自增序列 ( 使用 NewWorkerGenerator 时高位为 worker ID )。
 //
 // 参数 shardVal 将被截取低 10 位嵌入到 ID 中。
 //
@@ -78,8 +117,20 @@ func NewGenerator() *Generator {
 //
 // 如果超过 5 秒，会 panic ( 需要人工介入处理 )。
 //
-// 序列号溢出：同一毫秒内如果序列号用完 ( >4095 )，会等待下一毫秒。
+// 序列号溢出：同一毫秒内如果序列号用完 ( 没有 worker ID 时 >4095 )，会等待下一毫秒。
 func (g *Generator) NextID(shardVal uint64) (uint64, error) {
+	var worker uint64
+	if g.workerID != nil {
+		id, err := g.workerID()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get worker id: %w", err)
+		}
+		if id > sequenceMask>>g.seqBits {
+			return 0, fmt.Errorf("worker id [ %d ] is out of range", id)
+		}
+		worker = id << g.seqBits
+	}
+
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
@@ -99,7 +150,7 @@ func (g *Generator) NextID(shardVal uint64) (uint64, error) {
 
 	// 同一毫秒内，序列号递增。
 	if timestamp == g.lastTime {
-		g.sequence = (g.sequence + 1) & sequenceMask
+		g.sequence = (g.sequence + 1) & g.seqMask
 		// 序列号溢出，等待下一毫秒。
 		if g.sequence == 0 {
 			for timestamp <= g.lastTime {
@@ -116,7 +167,7 @@ func (g *Generator) NextID(shardVal uint64) (uint64, error) {
 
 	return (timestamp&timestampMask)<<timestampShift |
 		(shardVal&hashMask)<<shardValShift |
-		g.sequence, nil
+		worker | g.sequence, nil
 }
 
 // SnowflakeExtractor 实现了 ShardValExtractor 接口。
@@ -133,8 +184,18 @@ func (e *SnowflakeExtractor) ExtractShardVal(id uint64) uint64 {
 	return (id >> shardValShift) & hashMask
 }
 
+// ExtractTime 实现 sharding.TimeExtractor 接口，从 ID 中提取生成时间 ( 毫秒精度 )。
+func (e *SnowflakeExtractor) ExtractTime(id uint64) time.Time {
+	return ExtractTime(id)
+}
+
 // ExtractShardVal 独立函数，从 ID 中提取分片值。
 // 便捷函数，无需创建 Extractor 实例。
 func ExtractShardVal(id uint64) uint64 {
 	return (id >> shardValShift) & hashMask
 }
+
+// ExtractTime 独立函数，从 ID 中提取生成时间 ( 毫秒精度 )。
+func ExtractTime(id uint64) time.Time {
+	return time.UnixMilli(int64((id>>timestampShift)&timestampMask + epochMillis)) //nolint:gosec // 41 位时间戳不会溢出
+}
@@ -1,7 +1,9 @@
 package snowflake
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -92,6 +94,25 @@ func TestGenerator_DifferentShardValues(t *testing.T) {
 	}
 }
 
+func TestExtractTime(t *testing.T) {
+	t.Parallel()
+
+	g := NewGenerator()
+
+	before := time.Now().Truncate(time.Millisecond)
+	id, err := g.NextID(42)
+	require.NoError(t, err)
+	after := time.Now()
+
+	got := NewExtractor().ExtractTime(id)
+	require.False(t, got.Before(before))
+	require.False(t, got.After(after))
+	require.Equal(t, got, ExtractTime(id))
+
+	// 时间戳为 0 时为 epoch。
+	require.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), ExtractTime(42<<shardValShift).UTC())
+}
+
 func BenchmarkGenerator_NextId(b *testing.B) {
 	g := NewGenerator()
 	shardValue := uint64(42)
@@ -112,3 +133,79 @@ func BenchmarkExtractHash(b *testing.B) {
 		_ = ExtractShardVal(id)
 	}
 }
+
+func TestNewWorkerGenerator(t *testing.T) {
+	t.Parallel()
+
+	workerID := func() (uint64, error) { return 1, nil }
+
+	tests := []struct {
+		name       string
+		workerBits uint
+		workerID   WorkerIDFunc
+		wantErr    bool
+	}{
+		{name: "valid", workerBits: 4, workerID: workerID},
+		{name: "max worker bits", workerBits: MaxWorkerBits, workerID: workerID},
+		{name: "zero worker bits", workerBits: 0, workerID: workerID, wantErr: true},
+		{name: "too many worker bits", workerBits: MaxWorkerBits + 1, workerID: workerID, wantErr: true},
+		{name: "nil worker id", workerBits: 4, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewWorkerGenerator(tt.workerBits, tt.workerID)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWorkerGenerator_NoCollision(t *testing.T) {
+	t.Parallel()
+
+	const workerBits = 4
+
+	// 两个实例使用不同的 worker ID，为同一个分片值生成的 ID 不会重复。
+	g1, err := NewWorkerGenerator(workerBits, func() (uint64, error) { return 3, nil })
+	require.NoError(t, err)
+	g2, err := NewWorkerGenerator(workerBits, func() (uint64, error) { return 12, nil })
+	require.NoError(t, err)
+
+	shardVal := uint64(77)
+	seen := make(map[uint64]struct{}, 2*5000)
+	for range 5000 {
+		for _, g := range []*Generator{g1, g2} {
+			id, err := g.NextID(shardVal)
+			require.NoError(t, err)
+
+			_, dup := seen[id]
+			require.False(t, dup)
+			seen[id] = struct{}{}
+
+			// 时间戳和分片值的位置不变。
+			require.Equal(t, shardVal, ExtractShardVal(id))
+			require.WithinDuration(t, time.Now(), ExtractTime(id), time.Second)
+		}
+	}
+}
+
+func TestWorkerGenerator_WorkerIDError(t *testing.T) {
+	t.Parallel()
+
+	errExpired := errors.New("lease expired")
+	g, err := NewWorkerGenerator(4, func() (uint64, error) { return 0, errExpired })
+	require.NoError(t, err)
+	_, err = g.NextID(1)
+	require.ErrorIs(t, err, errExpired)
+
+	g, err = NewWorkerGenerator(4, func() (uint64, error) { return 16, nil })
+	require.NoError(t, err)
+	_, err = g.NextID(1)
+	require.Error(t, err)
+}
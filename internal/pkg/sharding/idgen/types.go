@@ -1,7 +1,21 @@
 package idgen
 
+import "context"
+
 // Generator 是 ID 生成器的接口。
 type Generator interface {
 	// NextID 生成 ID，需要传入分片值 ( 用于嵌入到 ID 中以支持分片 )。
 	NextID(shardVal uint64) (uint64, error)
 }
+
+// SeqFloorFunc 返回 key 已经使用的最大序列号 ( 如同步日志中的最大序列号 )，没有使用过时返回 0。
+type SeqFloorFunc func(ctx context.Context, key string) (uint64, error)
+
+// SeqGenerator 是按 key 生成序列号的接口，每个 key 的序列号相互独立。
+// 适用于需要紧凑、递增序列号的场景 ( 如频道内的消息序列号 )。
+type SeqGenerator interface {
+	// NextSeq 生成 key 的下一个序列号。
+	// 序列号存储中没有 key 时 ( 第一次使用或者存储丢失数据 ) 调用 floor，从 floor 返回值的下一个序列号开始生成，
+	// 保证序列号在存储丢失数据后仍然单调递增。floor 为 nil 时从 1 开始。
+	NextSeq(ctx context.Context, key string, floor SeqFloorFunc) (uint64, error)
+}
@@ -0,0 +1,169 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultRedisLeaseKeyPrefix = "idgen:snowflake:worker"
+
+// ErrLeaseExpired worker ID 租约没有获取或者已经过期。
+var ErrLeaseExpired = errors.New("worker id lease is expired")
+
+// renewScript 续期租约，只有租约的持有者才能续期。
+// KEYS[1]: 租约 key
+// ARGV[1]: 持有者 token，ARGV[2]: ttl ( 毫秒 )
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript 释放租约，只有租约的持有者才能释放。
+// KEYS[1]: 租约 key
+// ARGV[1]: 持有者 token
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisLease 从 redis 租用 worker ID，保证同一时间每个 worker ID 只被一个实例使用。
+//
+// 每个 worker ID 对应一个带 ttl 的 key ( {prefix}:{workerID} )，实例通过 SET NX 获取租约，之后定期续期。
+// 租约的本地有效期从发起请求的时间开始计算，续期失败时 WorkerID 在本地有效期结束后返回 ErrLeaseExpired，
+// 保证 redis 中的 key 过期被其它实例获取之前，当前实例已经停止使用该 worker ID。
+type RedisLease struct {
+	rdb        redis.Cmdable
+	prefix     string
+	maxWorkers uint64
+	ttl        time.Duration
+	token      string
+	logger     *slog.Logger
+
+	mu       sync.RWMutex
+	workerID uint64
+	deadline time.Time // 本地有效期，零值表示没有租约
+}
+
+// NewRedisLease 创建 worker ID 租约，worker ID 的取值范围为 [ 0, 2^workerBits )。
+func NewRedisLease(rdb redis.Cmdable, workerBits uint, ttl time.Duration, logger *slog.Logger) *RedisLease {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &RedisLease{
+		rdb:        rdb,
+		prefix:     defaultRedisLeaseKeyPrefix,
+		maxWorkers: uint64(1) << workerBits,
+		ttl:        ttl,
+		token:      uuid.NewString(),
+		logger:     logger,
+	}
+}
+
+// Acquire 获取一个空闲的 worker ID，从随机位置开始查找以减少多个实例同时启动时的冲突。
+func (l *RedisLease) Acquire(ctx context.Context) error {
+	start := rand.Uint64N(l.maxWorkers) //nolint:gosec // 只用于分散查找的起点
+	for i := range l.maxWorkers {
+		id := (start + i) % l.maxWorkers
+
+		requestedAt := time.Now()
+		ok, err := l.rdb.SetNX(ctx, l.key(id), l.token, l.ttl).Result()
+		if err != nil {
+			return fmt.Errorf("failed to acquire worker id [ %d ]: %w", id, err)
+		}
+		if !ok {
+			continue
+		}
+
+		l.mu.Lock()
+		l.workerID = id
+		l.deadline = requestedAt.Add(l.ttl)
+		l.mu.Unlock()
+
+		l.logger.Info("[hermet-idgen-worker] worker id acquired", slog.Uint64("worker_id", id))
+		return nil
+	}
+	return fmt.Errorf("no free worker id, all %d worker ids are leased", l.maxWorkers)
+}
+
+// WorkerID 返回租用的 worker ID，租约没有获取或者已经过期时返回 ErrLeaseExpired。
+func (l *RedisLease) WorkerID() (uint64, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.deadline.IsZero() || !time.Now().Before(l.deadline) {
+		return 0, ErrLeaseExpired
+	}
+	return l.workerID, nil
+}
+
+// Run 按照 ttl / 3 的间隔续期租约，直到 ctx 结束。
+// 租约已经被其它实例获取 ( 如长时间没有续期 ) 时重新获取 worker ID。
+func (l *RedisLease) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.ttl / 3) //nolint:mnd // 每个 ttl 内至少尝试续期两次
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.renew(ctx); err != nil && ctx.Err() == nil {
+				l.logger.Error("[hermet-idgen-worker] failed to renew worker id lease", slog.Any("err", err))
+			}
+		}
+	}
+}
+
+func (l *RedisLease) renew(ctx context.Context) error {
+	l.mu.RLock()
+	id := l.workerID
+	l.mu.RUnlock()
+
+	requestedAt := time.Now()
+	res, err := renewScript.Run(ctx, l.rdb, []string{l.key(id)}, l.token, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+
+	if res == 0 {
+		// 租约已经过期，立即停止使用旧的 worker ID 并重新获取。
+		l.mu.Lock()
+		l.deadline = time.Time{}
+		l.mu.Unlock()
+
+		l.logger.Warn("[hermet-idgen-worker] worker id lease is lost, acquiring a new one", slog.Uint64("worker_id", id))
+		return l.Acquire(ctx)
+	}
+
+	l.mu.Lock()
+	l.deadline = requestedAt.Add(l.ttl)
+	l.mu.Unlock()
+	return nil
+}
+
+// Release 释放租约，之后 WorkerID 返回 ErrLeaseExpired。
+func (l *RedisLease) Release(ctx context.Context) error {
+	l.mu.Lock()
+	id := l.workerID
+	l.deadline = time.Time{}
+	l.mu.Unlock()
+
+	return releaseScript.Run(ctx, l.rdb, []string{l.key(id)}, l.token).Err()
+}
+
+func (l *RedisLease) key(id uint64) string {
+	return l.prefix + ":" + strconv.FormatUint(id, 10)
+}
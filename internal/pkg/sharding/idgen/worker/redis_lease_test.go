@@ -0,0 +1,240 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func newMiniRedis(t *testing.T) (*miniredis.Miniredis, redis.Cmdable) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return mr, rdb
+}
+
+func TestRedisLease_Acquire(t *testing.T) {
+	t.Parallel()
+
+	mr, rdb := newMiniRedis(t)
+
+	// 2 位 worker ID，最多 4 个实例同时持有租约。
+	leases := make([]*RedisLease, 0, 4)
+	seen := make(map[uint64]struct{})
+	for range 4 {
+		l := NewRedisLease(rdb, 2, time.Minute, nil)
+		require.NoError(t, l.Acquire(t.Context()))
+
+		id, err := l.WorkerID()
+		require.NoError(t, err)
+		require.Less(t, id, uint64(4))
+		seen[id] = struct{}{}
+		leases = append(leases, l)
+
+		got, err := mr.Get(l.key(id))
+		require.NoError(t, err)
+		require.Equal(t, l.token, got)
+		require.Equal(t, time.Minute, mr.TTL(l.key(id)))
+	}
+	require.Len(t, seen, 4)
+
+	// 所有 worker ID 都被租用时获取失败。
+	l := NewRedisLease(rdb, 2, time.Minute, nil)
+	require.ErrorContains(t, l.Acquire(t.Context()), "no free worker id")
+	_, err := l.WorkerID()
+	require.ErrorIs(t, err, ErrLeaseExpired)
+
+	// 释放之后其它实例可以获取。
+	id, err := leases[0].WorkerID()
+	require.NoError(t, err)
+	require.NoError(t, leases[0].Release(t.Context()))
+	require.NoError(t, l.Acquire(t.Context()))
+	got, err := l.WorkerID()
+	require.NoError(t, err)
+	require.Equal(t, id, got)
+}
+
+func TestRedisLease_AcquireError(t *testing.T) {
+	t.Parallel()
+
+	mr, rdb := newMiniRedis(t)
+	mr.SetError("mock error")
+
+	l := NewRedisLease(rdb, 2, time.Minute, nil)
+	require.ErrorContains(t, l.Acquire(t.Context()), "mock error")
+	_, err := l.WorkerID()
+	require.ErrorIs(t, err, ErrLeaseExpired)
+}
+
+func TestRedisLease_Renew(t *testing.T) {
+	t.Parallel()
+
+	mr, rdb := newMiniRedis(t)
+	const ttl = 300 * time.Millisecond
+
+	l := NewRedisLease(rdb, 2, ttl, nil)
+	require.NoError(t, l.Acquire(t.Context()))
+	id, err := l.WorkerID()
+	require.NoError(t, err)
+
+	// 续期之后 redis 中的 ttl 和本地有效期都重新开始计算。
+	mr.FastForward(200 * time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, l.renew(t.Context()))
+	require.Equal(t, ttl, mr.TTL(l.key(id)))
+
+	time.Sleep(200 * time.Millisecond)
+	got, err := l.WorkerID()
+	require.NoError(t, err)
+	require.Equal(t, id, got)
+}
+
+func TestRedisLease_RenewFailed(t *testing.T) {
+	t.Parallel()
+
+	mr, rdb := newMiniRedis(t)
+	const ttl = 100 * time.Millisecond
+
+	l := NewRedisLease(rdb, 2, ttl, nil)
+	require.NoError(t, l.Acquire(t.Context()))
+	_, err := l.WorkerID()
+	require.NoError(t, err)
+
+	// 续期失败时不会延长本地有效期，有效期结束后停止使用 worker ID。
+	mr.SetError("mock error")
+	require.Error(t, l.renew(t.Context()))
+	_, err = l.WorkerID()
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, err := l.WorkerID()
+		return err != nil
+	}, time.Second, 5*time.Millisecond)
+	_, err = l.WorkerID()
+	require.ErrorIs(t, err, ErrLeaseExpired)
+}
+
+func TestRedisLease_LeaseLost(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		// taken 租约过期之后是否被其它实例获取。
+		taken bool
+	}{
+		{name: "expired"},
+		{name: "taken by another instance", taken: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mr, rdb := newMiniRedis(t)
+
+			l := NewRedisLease(rdb, 1, time.Minute, nil)
+			require.NoError(t, l.Acquire(t.Context()))
+			id, err := l.WorkerID()
+			require.NoError(t, err)
+
+			mr.FastForward(time.Minute)
+			require.False(t, mr.Exists(l.key(id)))
+			if tc.taken {
+				require.NoError(t, mr.Set(l.key(id), "other"))
+			}
+
+			// 续期时发现租约已经丢失，重新获取空闲的 worker ID。
+			require.NoError(t, l.renew(t.Context()))
+			got, err := l.WorkerID()
+			require.NoError(t, err)
+			if tc.taken {
+				require.NotEqual(t, id, got)
+			}
+
+			token, err := mr.Get(l.key(got))
+			require.NoError(t, err)
+			require.Equal(t, l.token, token)
+		})
+	}
+
+	t.Run("no free worker id", func(t *testing.T) {
+		t.Parallel()
+
+		mr, rdb := newMiniRedis(t)
+
+		l := NewRedisLease(rdb, 1, time.Minute, nil)
+		require.NoError(t, l.Acquire(t.Context()))
+		id, err := l.WorkerID()
+		require.NoError(t, err)
+
+		// 所有 worker ID 都被其它实例持有。
+		require.NoError(t, mr.Set(l.key(id), "other"))
+		require.NoError(t, mr.Set(l.key(1-id), "other"))
+
+		require.ErrorContains(t, l.renew(t.Context()), "no free worker id")
+		// 失去租约之后立即停止使用旧的 worker ID。
+		_, err = l.WorkerID()
+		require.ErrorIs(t, err, ErrLeaseExpired)
+	})
+}
+
+func TestRedisLease_Release(t *testing.T) {
+	t.Parallel()
+
+	mr, rdb := newMiniRedis(t)
+
+	l := NewRedisLease(rdb, 1, time.Minute, nil)
+	require.NoError(t, l.Acquire(t.Context()))
+	id, err := l.WorkerID()
+	require.NoError(t, err)
+
+	require.NoError(t, l.Release(t.Context()))
+	require.False(t, mr.Exists(l.key(id)))
+	_, err = l.WorkerID()
+	require.ErrorIs(t, err, ErrLeaseExpired)
+
+	// 租约已经被其它实例获取时不会删除其它实例的 key。
+	require.NoError(t, l.Acquire(t.Context()))
+	id, err = l.WorkerID()
+	require.NoError(t, err)
+	require.NoError(t, mr.Set(l.key(id), "other"))
+	require.NoError(t, l.Release(t.Context()))
+	got, err := mr.Get(l.key(id))
+	require.NoError(t, err)
+	require.Equal(t, "other", got)
+}
+
+func TestRedisLease_Run(t *testing.T) {
+	t.Parallel()
+
+	mr, rdb := newMiniRedis(t)
+	const ttl = 60 * time.Millisecond
+
+	l := NewRedisLease(rdb, 2, ttl, nil)
+	require.NoError(t, l.Acquire(t.Context()))
+	id, err := l.WorkerID()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Run(ctx)
+	}()
+
+	// 定期续期，超过 ttl 之后租约仍然有效。
+	time.Sleep(3 * ttl)
+	got, err := l.WorkerID()
+	require.NoError(t, err)
+	require.Equal(t, id, got)
+	require.True(t, mr.Exists(l.key(id)))
+
+	cancel()
+	<-done
+}
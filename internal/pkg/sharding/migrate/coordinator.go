@@ -0,0 +1,196 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/jit/xsync"
+	"gorm.io/gorm"
+)
+
+// WriteFunc 在指定库表上执行写操作，DAO 通过它描述需要同步到目标分片的写入。
+type WriteFunc func(db *gorm.DB, tb string) error
+
+// Coordinator 在业务进程中应用迁移状态：
+// - 迁移中 ( 双写 ) 的虚拟桶：DAO 写入源分片成功后通过 Mirror 同步写入目标分片；
+// - 已切换的虚拟桶：修改对应逻辑表虚拟桶分片策略的路由表。
+//
+// 迁移状态由迁移工具写入 Store，Coordinator 定期刷新。
+// 迁移工具在修改状态后会等待至少两个刷新间隔，保证所有业务进程都已经应用新的状态。
+type Coordinator struct {
+	store     Store
+	dbs       *xsync.Map[string, *gorm.DB]
+	extractor sharding.ShardValExtractor
+	logger    *slog.Logger
+
+	mu         sync.RWMutex
+	strategies map[string]*sharding.BucketSharding
+	mirrors    map[mirrorKey]Task
+
+	mirrorFailures atomic.Int64
+}
+
+// mirrorKey 以源物理表 + 虚拟桶标识迁移任务，DAO 不需要知道逻辑表名。
+type mirrorKey struct {
+	db     string
+	tb     string
+	bucket uint64
+}
+
+func NewCoordinator(
+	store Store,
+	dbs *xsync.Map[string, *gorm.DB],
+	extractor sharding.ShardValExtractor,
+	logger *slog.Logger,
+) *Coordinator {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Coordinator{
+		store:      store,
+		dbs:        dbs,
+		extractor:  extractor,
+		logger:     logger,
+		strategies: make(map[string]*sharding.BucketSharding),
+		mirrors:    make(map[mirrorKey]Task),
+	}
+}
+
+// Register 注册逻辑表的虚拟桶分片策略，只有注册过的逻辑表才能切换路由。
+func (c *Coordinator) Register(table string, strategy *sharding.BucketSharding) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.strategies[table] = strategy
+}
+
+// Strategy 返回逻辑表的虚拟桶分片策略。
+func (c *Coordinator) Strategy(table string) (*sharding.BucketSharding, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	strategy, ok := c.strategies[table]
+	return strategy, ok
+}
+
+// MirrorFailures 返回同步写入目标分片失败的次数。
+func (c *Coordinator) MirrorFailures() int64 {
+	return c.mirrorFailures.Load()
+}
+
+// Refresh 从 Store 加载迁移状态并应用。
+// 先切换路由再更新双写任务：路由切换后 DAO 写入的源分片即为目标分片，不会再触发双写。
+func (c *Coordinator) Refresh(ctx context.Context) error {
+	cps, err := c.store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list migration checkpoints: %w", err)
+	}
+
+	mirrors := make(map[mirrorKey]Task)
+	for _, cp := range cps {
+		if cp.Phase != PhaseSwitched {
+			mirrors[mirrorKey{db: cp.Source.DB, tb: cp.Source.TB, bucket: cp.Bucket}] = cp.Task
+			continue
+		}
+		if err := c.switchRoute(cp.Task); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	c.mirrors = mirrors
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Coordinator) switchRoute(task Task) error {
+	strategy, ok := c.Strategy(task.Table)
+	if !ok {
+		c.logger.Warn("[hermet-shard-migrate] table is not using bucket sharding, ignore switched checkpoint",
+			slog.String("table", task.Table), slog.Uint64("bucket", task.Bucket))
+		return nil
+	}
+
+	table := strategy.Table()
+	dbSuffix, tbSuffix := table.Route(task.Bucket)
+	if dbSuffix == task.Target.DBSuffix && tbSuffix == task.Target.TBSuffix {
+		return nil
+	}
+
+	moved, err := table.WithMoved(task.Bucket, task.Target.DBSuffix, task.Target.TBSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to move bucket [ %s:%d ]: %w", task.Table, task.Bucket, err)
+	}
+	if err := strategy.SetTable(moved); err != nil {
+		return err
+	}
+
+	c.logger.Info("[hermet-shard-migrate] bucket route switched",
+		slog.String("table", task.Table),
+		slog.Uint64("bucket", task.Bucket),
+		slog.String("target", task.Target.FullTable()),
+	)
+	return nil
+}
+
+// Run 按照 interval 定期刷新迁移状态，直到 ctx 结束。
+func (c *Coordinator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Refresh(ctx); err != nil && ctx.Err() == nil {
+				c.logger.Error("[hermet-shard-migrate] failed to refresh migration state", slog.Any("err", err))
+			}
+		}
+	}
+}
+
+// Mirror 在分片值所在的虚拟桶正在从 src 迁移时，将写操作同步到目标分片。
+// DAO 需要在写入 src 成功后调用，write 在目标分片上执行与源分片相同的写操作，
+// 目标分片中还不存在的行 ( 尚未复制 ) 由迁移工具复制，新增行需要使用 upsert。
+//
+// 同步失败只记录日志，不影响业务写入：迁移工具在切换路由前会校验并修复源 / 目标分片的差异。
+func (c *Coordinator) Mirror(ctx context.Context, shardVal uint64, src sharding.Dst, write WriteFunc) {
+	bucket := sharding.BucketOf(shardVal)
+
+	c.mu.RLock()
+	task, ok := c.mirrors[mirrorKey{db: src.DB, tb: src.TB, bucket: bucket}]
+	c.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	db, ok := c.dbs.Load(task.Target.DB)
+	if !ok {
+		c.mirrorFailed(task, shardVal, fmt.Errorf("failed to load database [ %s ]", task.Target.DB))
+		return
+	}
+
+	if err := write(db.WithContext(ctx), task.Target.TB); err != nil {
+		c.mirrorFailed(task, shardVal, err)
+	}
+}
+
+// MirrorID 与 Mirror 相同，分片值从 id 中提取。
+func (c *Coordinator) MirrorID(ctx context.Context, id uint64, src sharding.Dst, write WriteFunc) {
+	c.Mirror(ctx, c.extractor.ExtractShardVal(id), src, write)
+}
+
+func (c *Coordinator) mirrorFailed(task Task, shardVal uint64, err error) {
+	c.mirrorFailures.Add(1)
+	c.logger.Error("[hermet-shard-migrate] failed to mirror write to target shard, verify before switching",
+		slog.String("table", task.Table),
+		slog.Uint64("bucket", task.Bucket),
+		slog.Uint64("shard_val", shardVal),
+		slog.String("target", task.Target.FullTable()),
+		slog.Any("err", err),
+	)
+}
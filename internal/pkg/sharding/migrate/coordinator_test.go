@@ -0,0 +1,184 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen/snowflake"
+	"github.com/jrmarcco/jit/xsync"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type memStore struct {
+	cps []Checkpoint
+}
+
+func (s *memStore) Load(_ context.Context, table string, bucket uint64) (Checkpoint, bool, error) {
+	for _, cp := range s.cps {
+		if cp.Table == table && cp.Bucket == bucket {
+			return cp, true, nil
+		}
+	}
+	return Checkpoint{}, false, nil
+}
+
+func (s *memStore) Save(_ context.Context, cp Checkpoint) error {
+	for i := range s.cps {
+		if s.cps[i].Table == cp.Table && s.cps[i].Bucket == cp.Bucket {
+			s.cps[i] = cp
+			return nil
+		}
+	}
+	s.cps = append(s.cps, cp)
+	return nil
+}
+
+func (s *memStore) List(_ context.Context) ([]Checkpoint, error) {
+	return s.cps, nil
+}
+
+func newTestCoordinator(t *testing.T, store Store) (*Coordinator, *sharding.BucketSharding) {
+	t.Helper()
+
+	table, err := sharding.NewModuloBucketTable(2, 2)
+	require.NoError(t, err)
+	strategy, err := sharding.NewBucketSharding(snowflake.NewExtractor(), "hermet", "biz_user", table)
+	require.NoError(t, err)
+
+	// 不会真正连接数据库。
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 sslmode=disable"), &gorm.Config{DisableAutomaticPing: true})
+	require.NoError(t, err)
+
+	var dbs xsync.Map[string, *gorm.DB]
+	dbs.Store("hermet_0", db)
+	dbs.Store("hermet_1", db)
+
+	c := NewCoordinator(store, &dbs, snowflake.NewExtractor(), nil)
+	c.Register("biz_user", strategy)
+	return c, strategy
+}
+
+func TestCoordinator_Refresh(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		phase   Phase
+		wantDst sharding.Dst
+	}{
+		{
+			name:  "dual write keeps route",
+			phase: PhaseDualWrite,
+			wantDst: sharding.Dst{
+				DBSuffix: 1, TBSuffix: 0, DB: "hermet_1", TB: "biz_user_0",
+			},
+		},
+		{
+			name:  "verified keeps route",
+			phase: PhaseVerified,
+			wantDst: sharding.Dst{
+				DBSuffix: 1, TBSuffix: 0, DB: "hermet_1", TB: "biz_user_0",
+			},
+		},
+		{
+			name:  "switched moves route",
+			phase: PhaseSwitched,
+			wantDst: sharding.Dst{
+				DBSuffix: 0, TBSuffix: 1, DB: "hermet_0", TB: "biz_user_1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := &memStore{}
+			c, strategy := newTestCoordinator(t, store)
+
+			// 虚拟桶 5 在 2 * 2 的取模路由中位于 hermet_1.biz_user_0。
+			source, err := strategy.DstFromBucket(5)
+			require.NoError(t, err)
+			require.NoError(t, store.Save(t.Context(), Checkpoint{
+				Task: Task{
+					Table:  "biz_user",
+					Bucket: 5,
+					Source: source,
+					Target: strategy.DstOf(0, 1),
+				},
+				Phase: tt.phase,
+			}))
+
+			require.NoError(t, c.Refresh(t.Context()))
+
+			dst, err := strategy.DstFromBucket(5)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantDst, dst)
+
+			// 其他虚拟桶的路由不变。
+			dst, err = strategy.DstFromBucket(1)
+			require.NoError(t, err)
+			require.Equal(t, source, dst)
+		})
+	}
+}
+
+func TestCoordinator_Mirror(t *testing.T) {
+	t.Parallel()
+
+	store := &memStore{}
+	c, strategy := newTestCoordinator(t, store)
+
+	source, err := strategy.DstFromBucket(5)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(t.Context(), Checkpoint{
+		Task: Task{
+			Table:  "biz_user",
+			Bucket: 5,
+			Source: source,
+			Target: strategy.DstOf(0, 1),
+		},
+		Phase: PhaseDualWrite,
+	}))
+	require.NoError(t, c.Refresh(t.Context()))
+
+	gen := snowflake.NewGenerator()
+
+	tests := []struct {
+		name     string
+		shardVal uint64
+		src      sharding.Dst
+		wantTB   string
+	}{
+		{name: "migrating bucket", shardVal: 5, src: source, wantTB: "biz_user_1"},
+		{name: "same bucket in high bits", shardVal: 5 + 3*sharding.BucketCount, src: source, wantTB: "biz_user_1"},
+		{name: "other bucket", shardVal: 1, src: source, wantTB: ""},
+		{name: "other source", shardVal: 5, src: strategy.DstOf(0, 0), wantTB: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mirrored string
+			c.Mirror(t.Context(), tt.shardVal, tt.src, func(_ *gorm.DB, tb string) error {
+				mirrored = tb
+				return nil
+			})
+			require.Equal(t, tt.wantTB, mirrored)
+
+			id, err := gen.NextID(tt.shardVal)
+			require.NoError(t, err)
+
+			mirrored = ""
+			c.MirrorID(t.Context(), id, tt.src, func(_ *gorm.DB, tb string) error {
+				mirrored = tb
+				return nil
+			})
+			require.Equal(t, tt.wantTB, mirrored)
+		})
+	}
+}
@@ -0,0 +1,518 @@
+package migrate
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // 只用于校验数据是否一致
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jrmarcco/jit/xsync"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultBatchSize    = 500
+	defaultKeyColumn    = "id"
+	defaultVerifyRounds = 3
+)
+
+// Config 迁移配置。
+type Config struct {
+	BatchSize    int           // 每批复制的行数，<= 0 时为 500
+	KeyColumn    string        // 主键列，用于分批复制和校验，为空时为 "id"
+	BucketExpr   string        // 计算行所在虚拟桶的 SQL 表达式，来自逻辑表分片配置的 bucket_expr，不能为空
+	Propagation  time.Duration // 修改迁移状态后等待业务进程应用的时间，应大于业务进程的刷新间隔
+	VerifyRounds int           // 校验不一致时修复并重新校验的最大轮数，<= 0 时为 3
+	DryRun       bool          // 只统计源 / 目标分片的数据，不写入数据也不修改迁移状态
+}
+
+// Stat 虚拟桶在一个分片上的行数和校验和。
+type Stat struct {
+	RowCount int64
+	Checksum string
+}
+
+// Report 迁移结果。
+type Report struct {
+	Phase    Phase
+	Copied   int64 // 复制的行数 ( 包含之前中断前复制的行数 )
+	Repaired int64 // 校验时修复的行数
+	Source   Stat
+	Target   Stat
+}
+
+// Migrator 将一个虚拟桶的数据从源分片在线迁移到目标分片：
+//  1. 写入双写状态，等待业务进程开启双写；
+//  2. 按主键分批复制存量数据 ( 已存在的行跳过 )，每批完成后保存进度，中断后从进度继续；
+//  3. 对比源 / 目标分片的行数和校验和，不一致时逐批修复 ( 复制期间的并发写入可能导致目标分片数据落后 )；
+//  4. 写入切换状态，业务进程原子替换路由表。
+//
+// 校验和按主键顺序对整行计算，要求源 / 目标表结构一致 ( postgres 在数据库中计算，还要求列顺序一致 )。
+// 切换后源分片中的数据不会被删除，确认无误后需要手动清理。
+type Migrator struct {
+	store  Store
+	src    *gorm.DB
+	dst    *gorm.DB
+	task   Task
+	cfg    Config
+	logger *slog.Logger
+}
+
+func NewMigrator(
+	store Store,
+	dbs *xsync.Map[string, *gorm.DB],
+	task Task,
+	cfg Config,
+	logger *slog.Logger,
+) (*Migrator, error) {
+	if task.Table == "" {
+		return nil, errors.New("table cannot be empty")
+	}
+	if cfg.BucketExpr == "" {
+		return nil, fmt.Errorf("bucket expression of table [ %s ] is not configured", task.Table)
+	}
+	if task.Source == task.Target {
+		return nil, fmt.Errorf("bucket [ %s:%d ] is already in [ %s ]", task.Table, task.Bucket, task.Target.FullTable())
+	}
+
+	src, ok := dbs.Load(task.Source.DB)
+	if !ok {
+		return nil, fmt.Errorf("failed to load database [ %s ]", task.Source.DB)
+	}
+	dst, ok := dbs.Load(task.Target.DB)
+	if !ok {
+		return nil, fmt.Errorf("failed to load database [ %s ]", task.Target.DB)
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.KeyColumn == "" {
+		cfg.KeyColumn = defaultKeyColumn
+	}
+	if cfg.VerifyRounds <= 0 {
+		cfg.VerifyRounds = defaultVerifyRounds
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Migrator{
+		store:  store,
+		src:    src,
+		dst:    dst,
+		task:   task,
+		cfg:    cfg,
+		logger: logger.With(slog.String("table", task.Table), slog.Uint64("bucket", task.Bucket)),
+	}, nil
+}
+
+// Run 执行迁移，已经完成的阶段会被跳过。
+func (m *Migrator) Run(ctx context.Context) (Report, error) {
+	cp, err := m.checkpoint(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+
+	if m.cfg.DryRun {
+		return m.dryRun(ctx, cp)
+	}
+
+	if cp.Phase == PhaseSwitched {
+		m.logger.Info("[hermet-shard-migrate] bucket is already switched")
+		return Report{Phase: cp.Phase, Copied: cp.Copied}, nil
+	}
+
+	if cp.Phase == "" {
+		cp.Phase = PhaseDualWrite
+		if err := m.save(ctx, &cp); err != nil {
+			return Report{}, err
+		}
+		m.logger.Info("[hermet-shard-migrate] dual write enabled, waiting for propagation",
+			slog.Duration("propagation", m.cfg.Propagation))
+		if err := m.wait(ctx); err != nil {
+			return Report{}, err
+		}
+	}
+
+	if cp.Phase == PhaseDualWrite {
+		if err := m.copy(ctx, &cp); err != nil {
+			return Report{}, err
+		}
+	}
+
+	report, err := m.verify(ctx)
+	report.Copied = cp.Copied
+	if err != nil {
+		return report, err
+	}
+
+	cp.Phase = PhaseVerified
+	if err := m.save(ctx, &cp); err != nil {
+		return report, err
+	}
+
+	cp.Phase = PhaseSwitched
+	if err := m.save(ctx, &cp); err != nil {
+		return report, err
+	}
+	m.logger.Info("[hermet-shard-migrate] route switched, waiting for propagation",
+		slog.String("target", m.task.Target.FullTable()),
+		slog.Duration("propagation", m.cfg.Propagation))
+	if err := m.wait(ctx); err != nil {
+		return report, err
+	}
+
+	report.Phase = cp.Phase
+	return report, nil
+}
+
+// checkpoint 加载迁移进度，没有进度或者上一次迁移已经切换时返回新的进度。
+func (m *Migrator) checkpoint(ctx context.Context) (Checkpoint, error) {
+	cp, ok, err := m.store.Load(ctx, m.task.Table, m.task.Bucket)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if !ok {
+		return Checkpoint{Task: m.task}, nil
+	}
+
+	if cp.Task == m.task {
+		return cp, nil
+	}
+	if cp.Phase != PhaseSwitched {
+		return Checkpoint{}, fmt.Errorf("bucket [ %s:%d ] is being migrated to [ %s ]",
+			m.task.Table, m.task.Bucket, cp.Target.FullTable())
+	}
+	if cp.Target != m.task.Source {
+		return Checkpoint{}, fmt.Errorf("bucket [ %s:%d ] was switched to [ %s ], not [ %s ]",
+			m.task.Table, m.task.Bucket, cp.Target.FullTable(), m.task.Source.FullTable())
+	}
+	// 上一次迁移已经完成，开始新的迁移。
+	return Checkpoint{Task: m.task}, nil
+}
+
+func (m *Migrator) dryRun(ctx context.Context, cp Checkpoint) (Report, error) {
+	srcStat, err := m.stat(ctx, m.src, m.task.Source.TB, 0, math.MaxInt64)
+	if err != nil {
+		return Report{}, err
+	}
+	dstStat, err := m.stat(ctx, m.dst, m.task.Target.TB, 0, math.MaxInt64)
+	if err != nil {
+		return Report{}, err
+	}
+
+	m.logger.Info("[hermet-shard-migrate] dry run",
+		slog.String("source", m.task.Source.FullTable()),
+		slog.String("target", m.task.Target.FullTable()),
+		slog.String("phase", string(cp.Phase)),
+		slog.Uint64("last_key", cp.LastKey),
+		slog.Int64("source_rows", srcStat.RowCount),
+		slog.Int64("target_rows", dstStat.RowCount),
+		slog.Bool("consistent", srcStat == dstStat),
+	)
+	return Report{Phase: cp.Phase, Copied: cp.Copied, Source: srcStat, Target: dstStat}, nil
+}
+
+// copy 从 cp.LastKey 开始分批复制存量数据。
+// 目标分片中已经存在的行 ( 双写写入的行 ) 比复制的数据更新，因此跳过。
+func (m *Migrator) copy(ctx context.Context, cp *Checkpoint) error {
+	for {
+		rows, err := m.fetch(ctx, cp.LastKey)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		lastKey, err := m.keyOf(rows[len(rows)-1])
+		if err != nil {
+			return err
+		}
+
+		err = m.dst.WithContext(ctx).Table(m.task.Target.TB).
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: m.cfg.KeyColumn}},
+				DoNothing: true,
+			}).
+			Create(&rows).Error
+		if err != nil {
+			return fmt.Errorf("failed to copy rows to [ %s ]: %w", m.task.Target.FullTable(), err)
+		}
+
+		cp.LastKey = lastKey
+		cp.Copied += int64(len(rows))
+		if err := m.save(ctx, cp); err != nil {
+			return err
+		}
+		m.logger.Info("[hermet-shard-migrate] batch copied",
+			slog.Uint64("last_key", cp.LastKey), slog.Int64("copied", cp.Copied))
+	}
+}
+
+// verify 对比源 / 目标分片，不一致时修复后重新对比，直到一致或者超过最大轮数。
+func (m *Migrator) verify(ctx context.Context) (Report, error) {
+	report := Report{Phase: PhaseDualWrite}
+	for round := range m.cfg.VerifyRounds {
+		srcStat, err := m.stat(ctx, m.src, m.task.Source.TB, 0, math.MaxInt64)
+		if err != nil {
+			return report, err
+		}
+		dstStat, err := m.stat(ctx, m.dst, m.task.Target.TB, 0, math.MaxInt64)
+		if err != nil {
+			return report, err
+		}
+
+		report.Source = srcStat
+		report.Target = dstStat
+		if srcStat == dstStat {
+			m.logger.Info("[hermet-shard-migrate] bucket verified", slog.Int64("rows", srcStat.RowCount))
+			return report, nil
+		}
+
+		m.logger.Warn("[hermet-shard-migrate] bucket is inconsistent, repairing",
+			slog.Int("round", round+1),
+			slog.Int64("source_rows", srcStat.RowCount),
+			slog.Int64("target_rows", dstStat.RowCount),
+		)
+		repaired, err := m.repair(ctx)
+		report.Repaired += repaired
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, fmt.Errorf("bucket [ %s:%d ] is still inconsistent after %d rounds",
+		m.task.Table, m.task.Bucket, m.cfg.VerifyRounds)
+}
+
+// repair 按批对比源 / 目标分片，使用源分片的数据覆盖不一致的批次，返回修复的行数。
+func (m *Migrator) repair(ctx context.Context) (int64, error) {
+	var (
+		repaired int64
+		from     uint64
+	)
+	for {
+		rows, err := m.fetch(ctx, from)
+		if err != nil {
+			return repaired, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		to, err := m.keyOf(rows[len(rows)-1])
+		if err != nil {
+			return repaired, err
+		}
+
+		srcStat, err := m.stat(ctx, m.src, m.task.Source.TB, from, to)
+		if err != nil {
+			return repaired, err
+		}
+		dstStat, err := m.stat(ctx, m.dst, m.task.Target.TB, from, to)
+		if err != nil {
+			return repaired, err
+		}
+		if srcStat != dstStat {
+			n, err := m.overwrite(ctx, rows, from, to)
+			repaired += n
+			if err != nil {
+				return repaired, err
+			}
+		}
+		from = to
+	}
+
+	// 目标分片中主键大于源分片最大主键的行在源分片中已经被删除。
+	res := m.dst.WithContext(ctx).Exec(
+		fmt.Sprintf("DELETE FROM ? WHERE %s = ? AND ? > ?", m.cfg.BucketExpr),
+		clause.Table{Name: m.task.Target.TB}, m.task.Bucket, clause.Column{Name: m.cfg.KeyColumn}, from,
+	)
+	if res.Error != nil {
+		return repaired, fmt.Errorf("failed to delete stale rows from [ %s ]: %w", m.task.Target.FullTable(), res.Error)
+	}
+	return repaired + res.RowsAffected, nil
+}
+
+// overwrite 使用源分片 ( from, to ] 范围内的数据覆盖目标分片。
+func (m *Migrator) overwrite(ctx context.Context, rows []map[string]any, from, to uint64) (int64, error) {
+	keys := make([]any, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row[m.cfg.KeyColumn])
+	}
+
+	columns := make([]string, 0, len(rows[0]))
+	for col := range rows[0] {
+		if col != m.cfg.KeyColumn {
+			columns = append(columns, col)
+		}
+	}
+	slices.Sort(columns)
+
+	var repaired int64
+	err := m.dst.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Exec(
+			fmt.Sprintf("DELETE FROM ? WHERE %s = ? AND ? > ? AND ? <= ? AND ? NOT IN ?", m.cfg.BucketExpr),
+			clause.Table{Name: m.task.Target.TB}, m.task.Bucket,
+			clause.Column{Name: m.cfg.KeyColumn}, from,
+			clause.Column{Name: m.cfg.KeyColumn}, to,
+			clause.Column{Name: m.cfg.KeyColumn}, keys,
+		)
+		if res.Error != nil {
+			return res.Error
+		}
+		repaired += res.RowsAffected
+
+		res = tx.Table(m.task.Target.TB).
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: m.cfg.KeyColumn}},
+				DoUpdates: clause.AssignmentColumns(columns),
+			}).
+			Create(&rows)
+		if res.Error != nil {
+			return res.Error
+		}
+		repaired += res.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to overwrite rows of [ %s ]: %w", m.task.Target.FullTable(), err)
+	}
+	return repaired, nil
+}
+
+// fetch 按主键顺序读取源分片中主键大于 after 的一批数据。
+func (m *Migrator) fetch(ctx context.Context, after uint64) ([]map[string]any, error) {
+	rows, err := m.batch(ctx, m.src, m.task.Source.TB, after, math.MaxInt64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rows from [ %s ]: %w", m.task.Source.FullTable(), err)
+	}
+	return rows, nil
+}
+
+// batch 按主键顺序读取虚拟桶在主键范围 ( after, to ] 内的一批数据。
+func (m *Migrator) batch(ctx context.Context, db *gorm.DB, tb string, after, to uint64) ([]map[string]any, error) {
+	key := clause.Column{Name: m.cfg.KeyColumn}
+
+	var rows []map[string]any
+	err := db.WithContext(ctx).Table(tb).
+		Where(m.cfg.BucketExpr+" = ?", m.task.Bucket).
+		Where(clause.Gt{Column: key, Value: after}).
+		Where(clause.Lte{Column: key, Value: to}).
+		Order(clause.OrderByColumn{Column: key}).
+		Limit(m.cfg.BatchSize).
+		Find(&rows).Error
+	return rows, err
+}
+
+// stat 计算虚拟桶在主键范围 ( from, to ] 内的行数和校验和。
+// postgres 在数据库中计算校验和，其他数据库分批读取数据后在本地计算。
+func (m *Migrator) stat(ctx context.Context, db *gorm.DB, tb string, from, to uint64) (Stat, error) {
+	if db.Name() != "postgres" {
+		return m.localStat(ctx, db, tb, from, to)
+	}
+
+	key := clause.Column{Table: "t", Name: m.cfg.KeyColumn}
+
+	var stat Stat
+	err := db.WithContext(ctx).Raw(
+		fmt.Sprintf(
+			"SELECT count(*) AS row_count, coalesce(md5(string_agg(md5(t::text), '' ORDER BY ?)), '') AS checksum "+
+				"FROM ? AS t WHERE %s = ? AND ? > ? AND ? <= ?",
+			m.cfg.BucketExpr,
+		),
+		key, clause.Table{Name: tb}, m.task.Bucket, key, from, key, to,
+	).Scan(&stat).Error
+	if err != nil {
+		return Stat{}, fmt.Errorf("failed to stat [ %s ]: %w", tb, err)
+	}
+	return stat, nil
+}
+
+// localStat 分批读取虚拟桶在主键范围 ( from, to ] 内的数据并计算行数和校验和。
+// 每行按列名排序后计算 md5，再按主键顺序对所有行的 md5 计算 md5，不要求源 / 目标表的列顺序一致。
+func (m *Migrator) localStat(ctx context.Context, db *gorm.DB, tb string, from, to uint64) (Stat, error) {
+	var (
+		stat Stat
+		sum  = md5.New() //nolint:gosec // 只用于校验数据是否一致
+	)
+	for {
+		rows, err := m.batch(ctx, db, tb, from, to)
+		if err != nil {
+			return Stat{}, fmt.Errorf("failed to stat [ %s ]: %w", tb, err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			rowSum := md5.Sum([]byte(rowText(row))) //nolint:gosec // 只用于校验数据是否一致
+			_, _ = sum.Write([]byte(hex.EncodeToString(rowSum[:])))
+		}
+		stat.RowCount += int64(len(rows))
+
+		if from, err = m.keyOf(rows[len(rows)-1]); err != nil {
+			return Stat{}, err
+		}
+	}
+
+	if stat.RowCount > 0 {
+		stat.Checksum = hex.EncodeToString(sum.Sum(nil))
+	}
+	return stat, nil
+}
+
+// rowText 按列名顺序将一行数据转换为文本。
+func rowText(row map[string]any) string {
+	var b strings.Builder
+	for _, col := range slices.Sorted(maps.Keys(row)) {
+		fmt.Fprintf(&b, "%s=%v;", col, row[col])
+	}
+	return b.String()
+}
+
+func (m *Migrator) keyOf(row map[string]any) (uint64, error) {
+	switch v := row[m.cfg.KeyColumn].(type) {
+	case int64:
+		return uint64(v), nil
+	case int32:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("unsupported key type [ %T ] of column [ %s ]", v, m.cfg.KeyColumn)
+	}
+}
+
+func (m *Migrator) save(ctx context.Context, cp *Checkpoint) error {
+	cp.UpdatedAt = time.Now().UnixMilli()
+	if err := m.store.Save(ctx, *cp); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) wait(ctx context.Context) error {
+	if m.cfg.Propagation <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(m.cfg.Propagation)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
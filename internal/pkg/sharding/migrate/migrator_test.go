@@ -0,0 +1,334 @@
+package migrate
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/jit/xsync"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testBucket     = 5
+	testBucketExpr = "((id >> 12) & 1023)"
+)
+
+type testRow struct {
+	ID   uint64 `gorm:"column:id;primaryKey;autoIncrement:false"`
+	Name string `gorm:"column:name"`
+}
+
+// bucketID 生成落在 bucket 中的第 n 个主键，与 snowflake ID 的布局一致。
+func bucketID(bucket, n uint64) uint64 {
+	return n<<22 | bucket<<12
+}
+
+type migratorFixture struct {
+	src   *gorm.DB
+	dst   *gorm.DB
+	dbs   *xsync.Map[string, *gorm.DB]
+	store *memStore
+	task  Task
+}
+
+// newMigratorFixture 使用 sqlite 代替 postgres，源分片为 hermet_0.biz_user_0，目标分片为 hermet_1.biz_user_1。
+func newMigratorFixture(t *testing.T) *migratorFixture {
+	t.Helper()
+
+	f := &migratorFixture{
+		src:   newSQLiteDB(t, "hermet_0", "biz_user_0"),
+		dst:   newSQLiteDB(t, "hermet_1", "biz_user_1"),
+		dbs:   &xsync.Map[string, *gorm.DB]{},
+		store: &memStore{},
+		task: Task{
+			Table:  "biz_user",
+			Bucket: testBucket,
+			Source: sharding.Dst{DBSuffix: 0, TBSuffix: 0, DB: "hermet_0", TB: "biz_user_0"},
+			Target: sharding.Dst{DBSuffix: 1, TBSuffix: 1, DB: "hermet_1", TB: "biz_user_1"},
+		},
+	}
+	f.dbs.Store("hermet_0", f.src)
+	f.dbs.Store("hermet_1", f.dst)
+	return f
+}
+
+func newSQLiteDB(t *testing.T, name, tb string) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name+".db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	require.NoError(t, db.Table(tb).Migrator().CreateTable(&testRow{}))
+	return db
+}
+
+func (f *migratorFixture) insert(t *testing.T, db *gorm.DB, tb string, rows ...testRow) {
+	t.Helper()
+	require.NoError(t, db.Table(tb).Create(&rows).Error)
+}
+
+func (f *migratorFixture) rows(t *testing.T, db *gorm.DB, tb string) []testRow {
+	t.Helper()
+
+	var rows []testRow
+	require.NoError(t, db.Table(tb).Order("id").Find(&rows).Error)
+	return rows
+}
+
+// seed 在源分片中写入 bucket 的 n 行数据以及其他虚拟桶的一行数据，返回 bucket 的数据。
+func (f *migratorFixture) seed(t *testing.T, n uint64) []testRow {
+	t.Helper()
+
+	rows := make([]testRow, 0, n)
+	for i := uint64(1); i <= n; i++ {
+		rows = append(rows, testRow{ID: bucketID(testBucket, i), Name: fmt.Sprintf("user-%d", i)})
+	}
+	f.insert(t, f.src, f.task.Source.TB, rows...)
+	f.insert(t, f.src, f.task.Source.TB, testRow{ID: bucketID(testBucket+1, 1), Name: "other"})
+	return rows
+}
+
+func (f *migratorFixture) migrator(t *testing.T, cfg Config) *Migrator {
+	t.Helper()
+
+	if cfg.BucketExpr == "" {
+		cfg.BucketExpr = testBucketExpr
+	}
+	m, err := NewMigrator(f.store, f.dbs, f.task, cfg, nil)
+	require.NoError(t, err)
+	return m
+}
+
+func TestNewMigrator(t *testing.T) {
+	t.Parallel()
+
+	f := newMigratorFixture(t)
+
+	tests := []struct {
+		name    string
+		task    func(task Task) Task
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "ok", cfg: Config{BucketExpr: testBucketExpr}},
+		{name: "bucket expr not configured", wantErr: true},
+		{
+			name:    "empty table",
+			task:    func(task Task) Task { task.Table = ""; return task },
+			cfg:     Config{BucketExpr: testBucketExpr},
+			wantErr: true,
+		},
+		{
+			name:    "source equals target",
+			task:    func(task Task) Task { task.Target = task.Source; return task },
+			cfg:     Config{BucketExpr: testBucketExpr},
+			wantErr: true,
+		},
+		{
+			name:    "database not found",
+			task:    func(task Task) Task { task.Target.DB = "hermet_2"; return task },
+			cfg:     Config{BucketExpr: testBucketExpr},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			task := f.task
+			if tc.task != nil {
+				task = tc.task(task)
+			}
+			_, err := NewMigrator(f.store, f.dbs, task, tc.cfg, nil)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMigrator_Run(t *testing.T) {
+	t.Parallel()
+
+	f := newMigratorFixture(t)
+	want := f.seed(t, 5)
+
+	report, err := f.migrator(t, Config{BatchSize: 2}).Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, PhaseSwitched, report.Phase)
+	require.EqualValues(t, 5, report.Copied)
+	require.Zero(t, report.Repaired)
+	require.EqualValues(t, 5, report.Source.RowCount)
+	require.Equal(t, report.Source, report.Target)
+
+	// 只复制 bucket 中的数据。
+	require.Equal(t, want, f.rows(t, f.dst, f.task.Target.TB))
+
+	cp, ok, err := f.store.Load(t.Context(), f.task.Table, f.task.Bucket)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, PhaseSwitched, cp.Phase)
+	require.Equal(t, bucketID(testBucket, 5), cp.LastKey)
+	require.EqualValues(t, 5, cp.Copied)
+
+	// 已经切换的迁移再次执行时直接返回。
+	report, err = f.migrator(t, Config{BatchSize: 2}).Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, PhaseSwitched, report.Phase)
+	require.EqualValues(t, 5, report.Copied)
+}
+
+func TestMigrator_RunResumesFromCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	f := newMigratorFixture(t)
+	want := f.seed(t, 4)
+
+	// 上一次迁移复制了前两行后中断，其中第二行没有写入目标分片 ( 由校验修复 )。
+	f.insert(t, f.dst, f.task.Target.TB, want[0])
+	require.NoError(t, f.store.Save(t.Context(), Checkpoint{
+		Task:    f.task,
+		Phase:   PhaseDualWrite,
+		LastKey: want[1].ID,
+		Copied:  2,
+	}))
+
+	report, err := f.migrator(t, Config{BatchSize: 10}).Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, PhaseSwitched, report.Phase)
+	require.EqualValues(t, 4, report.Copied)
+	require.Positive(t, report.Repaired)
+	require.Equal(t, want, f.rows(t, f.dst, f.task.Target.TB))
+}
+
+func TestMigrator_RunRepairsTarget(t *testing.T) {
+	t.Parallel()
+
+	f := newMigratorFixture(t)
+	want := f.seed(t, 4)
+
+	other := testRow{ID: bucketID(testBucket+1, 2), Name: "other"}
+	f.insert(t, f.dst, f.task.Target.TB,
+		// 与源分片不一致的行，复制时跳过，校验时覆盖。
+		testRow{ID: want[0].ID, Name: "stale"},
+		// 源分片中已经删除的行。
+		testRow{ID: bucketID(testBucket, 10), Name: "deleted"},
+		// 其他虚拟桶的行不受影响。
+		other,
+	)
+	require.NoError(t, f.src.Table(f.task.Source.TB).Where("id = ?", want[2].ID).Delete(&testRow{}).Error)
+	f.insert(t, f.dst, f.task.Target.TB, want[2])
+	want = append(want[:2], want[3])
+
+	report, err := f.migrator(t, Config{BatchSize: 2}).Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, PhaseSwitched, report.Phase)
+	require.Positive(t, report.Repaired)
+	require.Equal(t, report.Source, report.Target)
+
+	require.ElementsMatch(t, append(want, other), f.rows(t, f.dst, f.task.Target.TB))
+}
+
+func TestMigrator_RunDryRun(t *testing.T) {
+	t.Parallel()
+
+	f := newMigratorFixture(t)
+	f.seed(t, 3)
+
+	report, err := f.migrator(t, Config{DryRun: true}).Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, report.Phase)
+	require.EqualValues(t, 3, report.Source.RowCount)
+	require.NotEmpty(t, report.Source.Checksum)
+	require.Equal(t, Stat{}, report.Target)
+
+	require.Empty(t, f.rows(t, f.dst, f.task.Target.TB))
+	require.Empty(t, f.store.cps)
+}
+
+func TestMigrator_RunRejectsConflictingCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cp   func(task Task) Checkpoint
+	}{
+		{
+			name: "migrating to another target",
+			cp: func(task Task) Checkpoint {
+				task.Target.TB = "biz_user_2"
+				return Checkpoint{Task: task, Phase: PhaseDualWrite}
+			},
+		},
+		{
+			name: "switched to another shard",
+			cp: func(task Task) Checkpoint {
+				task.Target.TB = "biz_user_2"
+				return Checkpoint{Task: task, Phase: PhaseSwitched}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := newMigratorFixture(t)
+			f.seed(t, 1)
+			require.NoError(t, f.store.Save(t.Context(), tc.cp(f.task)))
+
+			_, err := f.migrator(t, Config{}).Run(t.Context())
+			require.Error(t, err)
+			require.Empty(t, f.rows(t, f.dst, f.task.Target.TB))
+		})
+	}
+}
+
+func TestMigrator_LocalStat(t *testing.T) {
+	t.Parallel()
+
+	f := newMigratorFixture(t)
+	rows := f.seed(t, 3)
+	m := f.migrator(t, Config{BatchSize: 2})
+
+	all, err := m.stat(t.Context(), f.src, f.task.Source.TB, 0, rows[2].ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, all.RowCount)
+
+	// 主键范围为左开右闭区间。
+	part, err := m.stat(t.Context(), f.src, f.task.Source.TB, rows[0].ID, rows[2].ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, part.RowCount)
+	require.NotEqual(t, all.Checksum, part.Checksum)
+
+	// 数据相同时校验和相同，数据不同时校验和不同。
+	f.insert(t, f.dst, f.task.Target.TB, rows...)
+	same, err := m.stat(t.Context(), f.dst, f.task.Target.TB, 0, rows[2].ID)
+	require.NoError(t, err)
+	require.Equal(t, all, same)
+
+	require.NoError(t, f.dst.Table(f.task.Target.TB).Where("id = ?", rows[1].ID).Update("name", "changed").Error)
+	changed, err := m.stat(t.Context(), f.dst, f.task.Target.TB, 0, rows[2].ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, changed.RowCount)
+	require.NotEqual(t, all.Checksum, changed.Checksum)
+
+	empty, err := m.stat(t.Context(), f.dst, f.task.Target.TB, rows[2].ID, rows[2].ID)
+	require.NoError(t, err)
+	require.Equal(t, Stat{}, empty)
+}
@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultRedisStoreKey = "sharding:migration"
+
+var _ Store = (*RedisStore)(nil)
+
+// RedisStore 基于 redis hash 的迁移进度存储，field 为 {table}:{bucket}。
+type RedisStore struct {
+	rdb redis.Cmdable
+	key string
+}
+
+func NewRedisStore(rdb redis.Cmdable) *RedisStore {
+	return &RedisStore{
+		rdb: rdb,
+		key: defaultRedisStoreKey,
+	}
+}
+
+func (s *RedisStore) Load(ctx context.Context, table string, bucket uint64) (Checkpoint, bool, error) {
+	val, err := s.rdb.HGet(ctx, s.key, s.field(table, bucket)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return Checkpoint{}, false, nil
+		}
+		return Checkpoint{}, false, err
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(val, &cp); err != nil {
+		return Checkpoint{}, false, fmt.Errorf("failed to unmarshal checkpoint of [ %s:%d ]: %w", table, bucket, err)
+	}
+	return cp, true, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, cp Checkpoint) error {
+	val, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	return s.rdb.HSet(ctx, s.key, s.field(cp.Table, cp.Bucket), val).Err()
+}
+
+func (s *RedisStore) List(ctx context.Context) ([]Checkpoint, error) {
+	vals, err := s.rdb.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Checkpoint, 0, len(vals))
+	for field, val := range vals {
+		var cp Checkpoint
+		if err := json.Unmarshal([]byte(val), &cp); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal checkpoint of [ %s ]: %w", field, err)
+		}
+		res = append(res, cp)
+	}
+	return res, nil
+}
+
+func (s *RedisStore) field(table string, bucket uint64) string {
+	return fmt.Sprintf("%s:%d", table, bucket)
+}
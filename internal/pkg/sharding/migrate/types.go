@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"context"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+)
+
+// Phase 虚拟桶迁移阶段。
+type Phase string
+
+const (
+	// PhaseDualWrite 已开启双写，正在复制 / 校验存量数据。
+	PhaseDualWrite Phase = "dual_write"
+	// PhaseVerified 存量数据复制完成且校验通过，仍在双写。
+	PhaseVerified Phase = "verified"
+	// PhaseSwitched 路由已切换到目标分片，不再双写。
+	PhaseSwitched Phase = "switched"
+)
+
+// Task 迁移任务：将逻辑表 Table 的虚拟桶 Bucket 从 Source 迁移到 Target。
+type Task struct {
+	Table  string       `json:"table"` // 逻辑表名，与分片配置的名称一致，如 "biz_user"
+	Bucket uint64       `json:"bucket"`
+	Source sharding.Dst `json:"source"`
+	Target sharding.Dst `json:"target"`
+}
+
+// Checkpoint 迁移进度，迁移工具中断后从这里继续。
+// 业务进程根据 Checkpoint 决定是否双写以及是否切换路由。
+type Checkpoint struct {
+	Task
+
+	Phase     Phase  `json:"phase"`
+	LastKey   uint64 `json:"last_key"` // 已复制的最大主键
+	Copied    int64  `json:"copied"`   // 已复制的行数
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Store 迁移进度存储，迁移工具和业务进程共享。
+type Store interface {
+	Load(ctx context.Context, table string, bucket uint64) (Checkpoint, bool, error)
+	Save(ctx context.Context, cp Checkpoint) error
+	List(ctx context.Context) ([]Checkpoint, error)
+}
@@ -0,0 +1,186 @@
+package sharding
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen"
+)
+
+// StrategyType 基础分片策略类型。
+type StrategyType string
+
+const (
+	StrategyTypeModulo StrategyType = "modulo" // 取模分片
+	StrategyTypeBucket StrategyType = "bucket" // 虚拟桶分片
+
+	StrategyTypeTimeRange StrategyType = "time_range" // 时间范围分片
+)
+
+// TableSpec 逻辑表的分片配置。
+type TableSpec struct {
+	Name string // 逻辑表名称
+
+	DBPrefix     string
+	TBPrefix     string
+	DBShardCount uint64
+	TBShardCount uint64
+
+	Strategy      StrategyType  // 基础分片策略，为空时为 modulo
+	BroadcastMode BroadcastMode // 广播模式，为空时为 round_robin
+	Buckets       []BucketRange // bucket 策略的路由表，为空时使用与取模分片相同的路由
+	BucketExpr    string        // 计算行所在虚拟桶的 SQL 表达式，迁移虚拟桶时使用，为空时不能迁移
+
+	Period           TimePeriod // time_range 策略的分表周期
+	BroadcastPeriods int        // time_range 策略 Broadcast 覆盖的周期数
+}
+
+// NewStrategy 根据配置创建分片策略，返回基础分片策略以及包装了广播模式的分片策略。
+func (s TableSpec) NewStrategy(extractor ShardValExtractor) (Strategy, *BalancedSharding, error) {
+	var (
+		base Strategy
+		err  error
+	)
+	switch s.Strategy {
+	case "", StrategyTypeModulo:
+		base, err = NewModuloSharding(extractor, s.DBPrefix, s.TBPrefix, s.DBShardCount, s.TBShardCount)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create modulo sharding: %w", err)
+		}
+	case StrategyTypeBucket:
+		table, err := s.bucketTable()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create bucket table: %w", err)
+		}
+		base, err = NewBucketSharding(extractor, s.DBPrefix, s.TBPrefix, table)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create bucket sharding: %w", err)
+		}
+	case StrategyTypeTimeRange:
+		base, err = NewTimeRangeSharding(extractor, TimeRangeConfig{
+			DBPrefix:         s.DBPrefix,
+			TBPrefix:         s.TBPrefix,
+			DBShardCount:     s.DBShardCount,
+			TBShardCount:     s.TBShardCount,
+			Period:           s.Period,
+			BroadcastPeriods: s.BroadcastPeriods,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create time range sharding: %w", err)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported sharding strategy: %s", s.Strategy)
+	}
+
+	mode := s.BroadcastMode
+	if mode == "" {
+		mode = BroadcastModeRoundRobin
+	}
+	strategy, err := NewBalancedSharding(base, mode)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create balanced sharding: %w", err)
+	}
+	return base, strategy, nil
+}
+
+func (s TableSpec) bucketTable() (*BucketTable, error) {
+	if len(s.Buckets) == 0 {
+		return NewModuloBucketTable(s.DBShardCount, s.TBShardCount)
+	}
+	return NewBucketTable(s.Buckets)
+}
+
+// Registry 逻辑表注册表，按照逻辑表名称管理分片策略和分片辅助工具。
+type Registry struct {
+	names   []string
+	specs   map[string]TableSpec
+	bases   map[string]Strategy
+	helpers map[string]*ShardHelper
+}
+
+// NewRegistry 根据配置创建所有逻辑表的分片辅助工具。
+// dbs 为已经配置连接的数据库名称，逻辑表路由到的所有数据库都必须在 dbs 中，为 nil 时不校验。
+// 所有逻辑表的配置错误会合并后一起返回。
+func NewRegistry(
+	gen idgen.Generator,
+	extractor ShardValExtractor,
+	specs []TableSpec,
+	dbs []string,
+) (*Registry, error) {
+	r := &Registry{
+		names:   make([]string, 0, len(specs)),
+		specs:   make(map[string]TableSpec, len(specs)),
+		bases:   make(map[string]Strategy, len(specs)),
+		helpers: make(map[string]*ShardHelper, len(specs)),
+	}
+
+	var errs []error
+	for _, spec := range specs {
+		if err := r.register(gen, extractor, spec, dbs); err != nil {
+			errs = append(errs, fmt.Errorf("invalid sharding table [ %s ]: %w", spec.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	slices.Sort(r.names)
+	return r, nil
+}
+
+func (r *Registry) register(gen idgen.Generator, extractor ShardValExtractor, spec TableSpec, dbs []string) error {
+	if spec.Name == "" {
+		return errors.New("table name cannot be empty")
+	}
+	if _, ok := r.helpers[spec.Name]; ok {
+		return errors.New("table is registered more than once")
+	}
+
+	base, strategy, err := spec.NewStrategy(extractor)
+	if err != nil {
+		return err
+	}
+
+	if dbs != nil {
+		for _, dst := range strategy.Broadcast() {
+			if !slices.Contains(dbs, dst.DB) {
+				return fmt.Errorf("database [ %s ] is not configured", dst.DB)
+			}
+		}
+	}
+
+	helper, err := NewShardHelper(gen, strategy)
+	if err != nil {
+		return err
+	}
+
+	r.names = append(r.names, spec.Name)
+	r.specs[spec.Name] = spec
+	r.bases[spec.Name] = base
+	r.helpers[spec.Name] = helper
+	return nil
+}
+
+// Names 返回所有逻辑表名称 ( 按名称排序 )。
+func (r *Registry) Names() []string {
+	return slices.Clone(r.names)
+}
+
+// Helper 返回逻辑表的分片辅助工具。
+func (r *Registry) Helper(name string) (*ShardHelper, bool) {
+	helper, ok := r.helpers[name]
+	return helper, ok
+}
+
+// Spec 返回逻辑表的分片配置。
+func (r *Registry) Spec(name string) (TableSpec, bool) {
+	spec, ok := r.specs[name]
+	return spec, ok
+}
+
+// Base 返回逻辑表的基础分片策略 ( 没有包装广播模式 )，用于获取 BucketSharding 等具体策略。
+func (r *Registry) Base(name string) (Strategy, bool) {
+	base, ok := r.bases[name]
+	return base, ok
+}
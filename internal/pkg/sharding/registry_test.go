@@ -0,0 +1,187 @@
+package sharding
+
+import (
+	"testing"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen/snowflake"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRegistry(t *testing.T) {
+	t.Parallel()
+
+	dbs := []string{"hermet_0", "hermet_1"}
+
+	tests := []struct {
+		name    string
+		specs   []TableSpec
+		dbs     []string
+		wantErr bool
+	}{
+		{
+			name: "modulo and bucket",
+			specs: []TableSpec{
+				{Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 2, TBShardCount: 4},
+				{
+					Name: "channel", DBPrefix: "hermet", TBPrefix: "channel", DBShardCount: 2, TBShardCount: 2,
+					Strategy: StrategyTypeBucket, BroadcastMode: BroadcastModeShuffle,
+				},
+			},
+			dbs: dbs,
+		},
+		{
+			name: "time range",
+			specs: []TableSpec{
+				{
+					Name: "message", DBPrefix: "hermet", TBPrefix: "message", DBShardCount: 2, TBShardCount: 2,
+					Strategy: StrategyTypeTimeRange, Period: TimePeriodWeek,
+				},
+			},
+			dbs: dbs,
+		},
+		{
+			name: "time range without period",
+			specs: []TableSpec{
+				{
+					Name: "message", DBPrefix: "hermet", TBPrefix: "message", DBShardCount: 2, TBShardCount: 2,
+					Strategy: StrategyTypeTimeRange,
+				},
+			},
+			dbs:     dbs,
+			wantErr: true,
+		},
+		{
+			name: "database not configured",
+			specs: []TableSpec{
+				{Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 4, TBShardCount: 4},
+			},
+			dbs:     dbs,
+			wantErr: true,
+		},
+		{
+			name: "bucket route to database not configured",
+			specs: []TableSpec{
+				{
+					Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 2, TBShardCount: 1,
+					Strategy: StrategyTypeBucket,
+					Buckets: []BucketRange{
+						{From: 0, To: 511, DBSuffix: 0},
+						{From: 512, To: 1023, DBSuffix: 2},
+					},
+				},
+			},
+			dbs:     dbs,
+			wantErr: true,
+		},
+		{
+			name: "skip database check",
+			specs: []TableSpec{
+				{Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 4, TBShardCount: 4},
+			},
+		},
+		{
+			name: "invalid strategy",
+			specs: []TableSpec{
+				{Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 2, TBShardCount: 4, Strategy: "hash"},
+			},
+			dbs:     dbs,
+			wantErr: true,
+		},
+		{
+			name: "invalid broadcast mode",
+			specs: []TableSpec{
+				{Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 2, TBShardCount: 4, BroadcastMode: "random"},
+			},
+			dbs:     dbs,
+			wantErr: true,
+		},
+		{
+			name: "zero shard count",
+			specs: []TableSpec{
+				{Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 2},
+			},
+			dbs:     dbs,
+			wantErr: true,
+		},
+		{
+			name: "duplicate table",
+			specs: []TableSpec{
+				{Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 2, TBShardCount: 4},
+				{Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 2, TBShardCount: 4},
+			},
+			dbs:     dbs,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			registry, err := NewRegistry(snowflake.NewGenerator(), snowflake.NewExtractor(), tt.specs, tt.dbs)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			for _, spec := range tt.specs {
+				helper, ok := registry.Helper(spec.Name)
+				require.True(t, ok)
+				require.Len(t, helper.Broadcast(), int(spec.DBShardCount*spec.TBShardCount))
+			}
+		})
+	}
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	t.Parallel()
+
+	registry, err := NewRegistry(snowflake.NewGenerator(), snowflake.NewExtractor(), []TableSpec{
+		{Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 2, TBShardCount: 4},
+		{
+			Name: "channel", DBPrefix: "hermet", TBPrefix: "channel", DBShardCount: 2, TBShardCount: 2,
+			Strategy: StrategyTypeBucket, BucketExpr: "((id >> 12) & 1023)",
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"channel", "user"}, registry.Names())
+
+	base, ok := registry.Base("channel")
+	require.True(t, ok)
+	require.IsType(t, &BucketSharding{}, base)
+
+	base, ok = registry.Base("user")
+	require.True(t, ok)
+	require.IsType(t, &ModuloSharding{}, base)
+
+	// 默认广播模式为 round_robin。
+	helper, ok := registry.Helper("user")
+	require.True(t, ok)
+	dsts := helper.Broadcast()
+	require.Equal(t, "hermet_0", dsts[0].DB)
+	require.Equal(t, "hermet_1", dsts[1].DB)
+
+	spec, ok := registry.Spec("channel")
+	require.True(t, ok)
+	require.Equal(t, "((id >> 12) & 1023)", spec.BucketExpr)
+
+	_, ok = registry.Helper("unknown")
+	require.False(t, ok)
+	_, ok = registry.Base("unknown")
+	require.False(t, ok)
+	_, ok = registry.Spec("unknown")
+	require.False(t, ok)
+}
+
+func TestRegistry_ErrorsJoined(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewRegistry(snowflake.NewGenerator(), snowflake.NewExtractor(), []TableSpec{
+		{Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 4, TBShardCount: 4},
+		{Name: "channel", DBPrefix: "hermet", TBPrefix: "channel", DBShardCount: 2, TBShardCount: 2, Strategy: "hash"},
+	}, []string{"hermet_0", "hermet_1"})
+	require.ErrorContains(t, err, "invalid sharding table [ user ]: database [ hermet_2 ] is not configured")
+	require.ErrorContains(t, err, "invalid sharding table [ channel ]: unsupported sharding strategy: hash")
+}
@@ -0,0 +1,300 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FailurePolicy 部分分片查询失败时的处理策略。
+type FailurePolicy string
+
+const (
+	// FailurePolicyFailFast 任一分片失败时取消其他分片的查询并返回错误。
+	FailurePolicyFailFast FailurePolicy = "fail_fast"
+	// FailurePolicyBestEffort 忽略失败的分片，返回成功分片的结果以及失败的分片。
+	// 所有分片都失败时返回错误。
+	FailurePolicyBestEffort FailurePolicy = "best_effort"
+)
+
+// QueryFunc 在单个分片上执行查询。
+// limit 为单个分片最多需要返回的行数 ( 全局 offset + limit )，0 表示不限制。
+// 需要全局排序时，单个分片的查询也需要按照相同的顺序排序，否则截断后的结果不正确。
+type QueryFunc[T any] func(ctx context.Context, dst Dst, limit int) ([]T, error)
+
+// ScatterOptions 分片并发查询选项。
+type ScatterOptions[T any] struct {
+	Parallelism      int           // 同时查询的最大分片数，<= 0 时不限制
+	PerDBParallelism int           // 同一个数据库同时查询的最大分片数，<= 0 时不限制
+	ShardTimeout     time.Duration // 单个分片的查询超时，<= 0 时不限制
+
+	Policy FailurePolicy // 部分分片失败时的处理策略，为空时为 fail_fast
+
+	// Compare 全局排序规则，为 nil 时按照分片顺序拼接结果。
+	Compare func(a, b T) int
+	Offset  int // 全局偏移量
+	Limit   int // 全局最大行数，<= 0 时不限制
+}
+
+// ScatterResult 分片并发查询结果。
+type ScatterResult[T any] struct {
+	Items  []T
+	Failed []*ShardError // best_effort 策略下失败的分片
+}
+
+// ShardError 单个分片的查询错误。
+type ShardError struct {
+	Dst Dst
+	Err error
+}
+
+func (e *ShardError) Error() string {
+	return fmt.Sprintf("failed to query shard [ %s ]: %v", e.Dst.FullTable(), e.Err)
+}
+
+func (e *ShardError) Unwrap() error {
+	return e.Err
+}
+
+// ScatterGather 在 dsts 上并发执行查询并合并结果。
+//
+// 分片按照 dsts 的顺序调度：配合 ShardHelper.Broadcast 使用时由 BalancedSharding 的广播模式决定调度顺序，
+// round_robin 模式下并发查询会均匀分布在各个数据库上，shuffle 模式下每次调度的顺序都不同。
+// 设置 PerDBParallelism 时，数据库并发已满的分片会被跳过，优先调度其他数据库的分片。
+//
+// 结果合并：
+// - Compare 不为 nil 时对所有结果全局排序 ( 稳定排序，相同顺序的结果保持分片顺序 )；
+// - Compare 为 nil 时按照 dsts 的顺序拼接；
+// - 最后在全局结果上应用 Offset 和 Limit。
+//
+// 使用示例：
+//
+//	res, err := sharding.ScatterGather(ctx, helper.Broadcast(),
+//		func(ctx context.Context, dst sharding.Dst, limit int) ([]Channel, error) {
+//			// 在 dst 上查询，按照 last_message_at 排序并限制 limit 行
+//		},
+//		sharding.ScatterOptions[Channel]{
+//			Parallelism: 4,
+//			Compare:     func(a, b Channel) int { return cmp.Compare(a.LastMessageAt, b.LastMessageAt) },
+//			Limit:       100,
+//		},
+//	)
+func ScatterGather[T any](
+	ctx context.Context,
+	dsts []Dst,
+	query QueryFunc[T],
+	opts ScatterOptions[T],
+) (ScatterResult[T], error) {
+	if query == nil {
+		return ScatterResult[T]{}, errors.New("query func cannot be nil")
+	}
+	switch opts.Policy {
+	case FailurePolicyFailFast, FailurePolicyBestEffort:
+	case "":
+		opts.Policy = FailurePolicyFailFast
+	default:
+		return ScatterResult[T]{}, fmt.Errorf("invalid failure policy: %s", opts.Policy)
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+	if len(dsts) == 0 {
+		return ScatterResult[T]{}, nil
+	}
+
+	shardLimit := 0
+	if opts.Limit > 0 {
+		shardLimit = opts.Offset + opts.Limit
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		results = make([][]T, len(dsts))
+		errs    = make([]*ShardError, len(dsts))
+
+		firstErrOnce sync.Once
+		firstErr     error
+	)
+
+	s := newScatterScheduler(dsts, opts.Parallelism, opts.PerDBParallelism)
+
+	var wg sync.WaitGroup
+	for {
+		i, ok := s.next(ctx)
+		if !ok {
+			break
+		}
+
+		wg.Go(func() {
+			defer s.done(i)
+
+			items, err := runShardQuery(ctx, dsts[i], query, shardLimit, opts.ShardTimeout)
+			if err != nil {
+				errs[i] = &ShardError{Dst: dsts[i], Err: err}
+				if opts.Policy == FailurePolicyFailFast {
+					firstErrOnce.Do(func() {
+						firstErr = errs[i]
+						cancel()
+					})
+				}
+				return
+			}
+			results[i] = items
+		})
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return ScatterResult[T]{}, firstErr
+	}
+
+	// 外部 ctx 结束时没有调度的分片也视为失败。
+	for _, i := range s.pending {
+		errs[i] = &ShardError{Dst: dsts[i], Err: ctx.Err()}
+	}
+
+	res := ScatterResult[T]{}
+	for _, err := range errs {
+		if err != nil {
+			res.Failed = append(res.Failed, err)
+		}
+	}
+	if len(res.Failed) > 0 && (opts.Policy == FailurePolicyFailFast || len(res.Failed) == len(dsts)) {
+		joined := make([]error, 0, len(res.Failed))
+		for _, err := range res.Failed {
+			joined = append(joined, err)
+		}
+		return ScatterResult[T]{}, errors.Join(joined...)
+	}
+
+	res.Items = mergeScatterResults(results, opts)
+	return res, nil
+}
+
+func runShardQuery[T any](
+	ctx context.Context,
+	dst Dst,
+	query QueryFunc[T],
+	limit int,
+	timeout time.Duration,
+) (items []T, err error) {
+	ctx = ContextWithDst(ctx, dst)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return query(ctx, dst, limit)
+}
+
+func mergeScatterResults[T any](results [][]T, opts ScatterOptions[T]) []T {
+	total := 0
+	for _, items := range results {
+		total += len(items)
+	}
+
+	merged := make([]T, 0, total)
+	for _, items := range results {
+		merged = append(merged, items...)
+	}
+	if opts.Compare != nil {
+		slices.SortStableFunc(merged, opts.Compare)
+	}
+
+	if opts.Offset >= len(merged) {
+		return []T{}
+	}
+	merged = merged[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(merged) {
+		merged = merged[:opts.Limit]
+	}
+	return merged
+}
+
+// scatterScheduler 按照分片顺序调度查询，同时限制全局并发和单个数据库的并发。
+type scatterScheduler struct {
+	dsts             []Dst
+	parallelism      int
+	perDBParallelism int
+
+	mu      sync.Mutex
+	pending []int // 尚未调度的分片下标，保持 dsts 的顺序
+	running int
+	runDB   map[string]int
+
+	wake chan struct{}
+}
+
+func newScatterScheduler(dsts []Dst, parallelism, perDBParallelism int) *scatterScheduler {
+	pending := make([]int, len(dsts))
+	for i := range dsts {
+		pending[i] = i
+	}
+	return &scatterScheduler{
+		dsts:             dsts,
+		parallelism:      parallelism,
+		perDBParallelism: perDBParallelism,
+		pending:          pending,
+		runDB:            make(map[string]int),
+		wake:             make(chan struct{}, 1),
+	}
+}
+
+// next 返回下一个可以执行的分片下标，没有空闲并发时阻塞。
+// 所有分片都已经调度或者 ctx 结束时返回 false。
+func (s *scatterScheduler) next(ctx context.Context) (int, bool) {
+	for {
+		if ctx.Err() != nil {
+			return 0, false
+		}
+
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			s.mu.Unlock()
+			return 0, false
+		}
+		if s.parallelism <= 0 || s.running < s.parallelism {
+			for j, i := range s.pending {
+				db := s.dsts[i].DB
+				if s.perDBParallelism > 0 && s.runDB[db] >= s.perDBParallelism {
+					continue
+				}
+				s.pending = slices.Delete(s.pending, j, j+1)
+				s.running++
+				s.runDB[db]++
+				s.mu.Unlock()
+				return i, true
+			}
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return 0, false
+		case <-s.wake:
+		}
+	}
+}
+
+func (s *scatterScheduler) done(i int) {
+	s.mu.Lock()
+	s.running--
+	s.runDB[s.dsts[i].DB]--
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,324 @@
+package sharding
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen/snowflake"
+	"github.com/stretchr/testify/require"
+)
+
+func newScatterDsts(t *testing.T, mode BroadcastMode) []Dst {
+	t.Helper()
+
+	base, err := NewModuloSharding(snowflake.NewExtractor(), "db", "table", 2, 3)
+	require.NoError(t, err)
+	strategy, err := NewBalancedSharding(base, mode)
+	require.NoError(t, err)
+	return strategy.Broadcast()
+}
+
+func TestScatterGather_Merge(t *testing.T) {
+	t.Parallel()
+
+	dsts := newScatterDsts(t, BroadcastModeDefault)
+
+	// 每个分片返回 [ shard, shard + 10, shard + 20 ]，分片内有序。
+	query := func(_ context.Context, dst Dst, limit int) ([]int, error) {
+		shard := int(dst.DBSuffix*3 + dst.TBSuffix)
+		items := []int{shard, shard + 10, shard + 20}
+		if limit > 0 && limit < len(items) {
+			items = items[:limit]
+		}
+		return items, nil
+	}
+
+	tests := []struct {
+		name string
+		opts ScatterOptions[int]
+		want []int
+	}{
+		{
+			name: "concat in dst order",
+			opts: ScatterOptions[int]{Limit: 4},
+			want: []int{0, 10, 20, 1},
+		},
+		{
+			name: "global order",
+			opts: ScatterOptions[int]{Compare: cmp.Compare[int]},
+			want: []int{0, 1, 2, 3, 4, 5, 10, 11, 12, 13, 14, 15, 20, 21, 22, 23, 24, 25},
+		},
+		{
+			name: "global order with offset and limit",
+			opts: ScatterOptions[int]{Compare: cmp.Compare[int], Offset: 4, Limit: 4},
+			want: []int{4, 5, 10, 11},
+		},
+		{
+			name: "offset out of range",
+			opts: ScatterOptions[int]{Compare: cmp.Compare[int], Offset: 100},
+			want: []int{},
+		},
+		{
+			name: "bounded parallelism",
+			opts: ScatterOptions[int]{Parallelism: 2, PerDBParallelism: 1, Compare: cmp.Compare[int], Limit: 3},
+			want: []int{0, 1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := ScatterGather(t.Context(), dsts, query, tt.opts)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, res.Items)
+			require.Empty(t, res.Failed)
+		})
+	}
+}
+
+func TestScatterGather_ShardLimit(t *testing.T) {
+	t.Parallel()
+
+	dsts := newScatterDsts(t, BroadcastModeDefault)
+
+	var got atomic.Int64
+	_, err := ScatterGather(t.Context(), dsts, func(_ context.Context, _ Dst, limit int) ([]int, error) {
+		got.Store(int64(limit))
+		return nil, nil
+	}, ScatterOptions[int]{Offset: 5, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, int64(15), got.Load())
+}
+
+func TestScatterGather_FailurePolicy(t *testing.T) {
+	t.Parallel()
+
+	dsts := newScatterDsts(t, BroadcastModeDefault)
+	errShard := errors.New("shard unavailable")
+
+	tests := []struct {
+		name       string
+		policy     FailurePolicy
+		failing    func(dst Dst) bool
+		wantErr    bool
+		wantItems  int
+		wantFailed int
+	}{
+		{
+			name:    "fail fast",
+			policy:  FailurePolicyFailFast,
+			failing: func(dst Dst) bool { return dst.DB == "db_1" && dst.TB == "table_1" },
+			wantErr: true,
+		},
+		{
+			name:       "best effort",
+			policy:     FailurePolicyBestEffort,
+			failing:    func(dst Dst) bool { return dst.DB == "db_1" },
+			wantErr:    false,
+			wantItems:  3,
+			wantFailed: 3,
+		},
+		{
+			name:    "best effort all failed",
+			policy:  FailurePolicyBestEffort,
+			failing: func(Dst) bool { return true },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := ScatterGather(t.Context(), dsts, func(_ context.Context, dst Dst, _ int) ([]Dst, error) {
+				if tt.failing(dst) {
+					return nil, errShard
+				}
+				return []Dst{dst}, nil
+			}, ScatterOptions[Dst]{Policy: tt.policy})
+
+			if tt.wantErr {
+				require.Error(t, err)
+				require.ErrorIs(t, err, errShard)
+
+				var shardErr *ShardError
+				require.ErrorAs(t, err, &shardErr)
+				require.True(t, tt.failing(shardErr.Dst))
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, res.Items, tt.wantItems)
+			require.Len(t, res.Failed, tt.wantFailed)
+			for _, f := range res.Failed {
+				require.True(t, tt.failing(f.Dst))
+			}
+		})
+	}
+}
+
+func TestScatterGather_FailFastCancelsOthers(t *testing.T) {
+	t.Parallel()
+
+	dsts := newScatterDsts(t, BroadcastModeDefault)
+
+	_, err := ScatterGather(t.Context(), dsts, func(ctx context.Context, dst Dst, _ int) ([]int, error) {
+		if dst == dsts[0] {
+			return nil, errors.New("boom")
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, ScatterOptions[int]{})
+	require.EqualError(t, err, "failed to query shard [ db_0.table_0 ]: boom")
+}
+
+func TestScatterGather_ShardTimeout(t *testing.T) {
+	t.Parallel()
+
+	dsts := newScatterDsts(t, BroadcastModeDefault)
+
+	res, err := ScatterGather(t.Context(), dsts, func(ctx context.Context, dst Dst, _ int) ([]int, error) {
+		if dst.DB == "db_1" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return []int{1}, nil
+	}, ScatterOptions[int]{Policy: FailurePolicyBestEffort, ShardTimeout: 20 * time.Millisecond})
+	require.NoError(t, err)
+	require.Len(t, res.Items, 3)
+	require.Len(t, res.Failed, 3)
+	for _, f := range res.Failed {
+		require.ErrorIs(t, f, context.DeadlineExceeded)
+	}
+}
+
+func TestScatterGather_Parallelism(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		parallelism      int
+		perDBParallelism int
+	}{
+		{name: "global", parallelism: 2},
+		{name: "per db", perDBParallelism: 1},
+		{name: "global and per db", parallelism: 3, perDBParallelism: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dsts := newScatterDsts(t, BroadcastModeDefault)
+
+			var (
+				mu        sync.Mutex
+				running   int
+				runningDB = make(map[string]int)
+				violated  atomic.Bool
+			)
+			_, err := ScatterGather(t.Context(), dsts, func(ctx context.Context, dst Dst, _ int) ([]int, error) {
+				// 分片信息会被放入 ctx。
+				if ctxDst, ok := DstFromContext(ctx); !ok || ctxDst != dst {
+					violated.Store(true)
+				}
+
+				mu.Lock()
+				running++
+				runningDB[dst.DB]++
+				if tt.parallelism > 0 && running > tt.parallelism {
+					violated.Store(true)
+				}
+				if tt.perDBParallelism > 0 && runningDB[dst.DB] > tt.perDBParallelism {
+					violated.Store(true)
+				}
+				mu.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				runningDB[dst.DB]--
+				mu.Unlock()
+				return nil, nil
+			}, ScatterOptions[int]{Parallelism: tt.parallelism, PerDBParallelism: tt.perDBParallelism})
+			require.NoError(t, err)
+			require.False(t, violated.Load())
+		})
+	}
+}
+
+func TestScatterGather_ScheduleOrder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		mode BroadcastMode
+		want []string
+	}{
+		{
+			name: "default",
+			mode: BroadcastModeDefault,
+			want: []string{
+				"db_0.table_0", "db_0.table_1", "db_0.table_2", "db_1.table_0", "db_1.table_1", "db_1.table_2",
+			},
+		},
+		{
+			name: "round robin",
+			mode: BroadcastModeRoundRobin,
+			want: []string{
+				"db_0.table_0", "db_1.table_0", "db_0.table_1", "db_1.table_1", "db_0.table_2", "db_1.table_2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dsts := newScatterDsts(t, tt.mode)
+
+			var (
+				mu    sync.Mutex
+				order []string
+			)
+			res, err := ScatterGather(t.Context(), dsts, func(_ context.Context, dst Dst, _ int) ([]string, error) {
+				mu.Lock()
+				order = append(order, dst.FullTable())
+				mu.Unlock()
+				return []string{dst.FullTable()}, nil
+			}, ScatterOptions[string]{Parallelism: 1})
+			require.NoError(t, err)
+
+			// 并发为 1 时按照广播顺序调度，未排序时结果也按照广播顺序拼接。
+			require.Equal(t, tt.want, order)
+			require.Equal(t, tt.want, res.Items)
+		})
+	}
+}
+
+func TestScatterGather_InvalidArgs(t *testing.T) {
+	t.Parallel()
+
+	dsts := newScatterDsts(t, BroadcastModeDefault)
+
+	_, err := ScatterGather[int](t.Context(), dsts, nil, ScatterOptions[int]{})
+	require.Error(t, err)
+
+	_, err = ScatterGather(t.Context(), dsts, func(context.Context, Dst, int) ([]int, error) {
+		return nil, nil
+	}, ScatterOptions[int]{Policy: "invalid"})
+	require.Error(t, err)
+
+	res, err := ScatterGather(t.Context(), nil, func(context.Context, Dst, int) ([]int, error) {
+		return []int{1}, nil
+	}, ScatterOptions[int]{})
+	require.NoError(t, err)
+	require.Empty(t, res.Items)
+}
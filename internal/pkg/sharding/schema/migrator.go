@@ -0,0 +1,360 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/jit/xsync"
+	"gorm.io/gorm"
+)
+
+// HistoryTable 迁移历史表，每个分库一张，记录该库中每个物理表已经执行的迁移版本。
+const HistoryTable = "sharding_schema_history"
+
+const createHistoryTableSQL = `CREATE TABLE IF NOT EXISTS ` + HistoryTable + ` (
+    tb VARCHAR(128) NOT NULL,
+    version BIGINT NOT NULL,
+    description VARCHAR(256) NOT NULL DEFAULT '',
+    applied_at BIGINT NOT NULL,
+    PRIMARY KEY (tb, version)
+)`
+
+type historyRecord struct {
+	TB          string `gorm:"column:tb;primaryKey"`
+	Version     uint64 `gorm:"column:version;primaryKey"`
+	Description string `gorm:"column:description"`
+	AppliedAt   int64  `gorm:"column:applied_at"`
+}
+
+func (historyRecord) TableName() string {
+	return HistoryTable
+}
+
+// target 逻辑表的一个物理表。
+type target struct {
+	table  string
+	dst    sharding.Dst
+	period bool // 是否为周期表，周期表可能还没有创建
+}
+
+// Migrator 在逻辑表的所有物理表上执行版本化迁移。
+//
+// 逻辑表的物理表由分片配置计算：
+// - modulo / bucket 策略：Broadcast 返回的所有物理表；
+// - time_range 策略：每个库的模板表、Broadcast 覆盖的周期表以及提前创建的周期表 ( Upcoming )，
+// 还没有创建的周期表会跳过，之后创建时会复制模板表的结构以及迁移历史，更早的周期表不会迁移。
+//
+// 每个物理表的每个迁移在一个事务中执行并写入迁移历史 ( Postgres 的 DDL 支持事务 )，
+// 事务中使用 advisory lock 保证多个迁移工具同时执行时同一个物理表的迁移只执行一次。
+type Migrator struct {
+	dbs              *xsync.Map[string, *gorm.DB]
+	registry         *sharding.Registry
+	migrations       map[string][]Migration
+	precreatePeriods int
+	logger           *slog.Logger
+}
+
+// NewMigrator 创建迁移执行器，迁移的逻辑表必须在分片配置中。
+// precreatePeriods 为周期表提前创建的周期数，与周期表创建器一致。
+func NewMigrator(
+	dbs *xsync.Map[string, *gorm.DB],
+	registry *sharding.Registry,
+	migrations []Migration,
+	precreatePeriods int,
+	logger *slog.Logger,
+) (*Migrator, error) {
+	grouped, err := groupMigrations(migrations)
+	if err != nil {
+		return nil, err
+	}
+	for table := range grouped {
+		if _, ok := registry.Helper(table); !ok {
+			return nil, fmt.Errorf("migration table [ %s ] is not a sharding table", table)
+		}
+	}
+
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Migrator{
+		dbs:              dbs,
+		registry:         registry,
+		migrations:       grouped,
+		precreatePeriods: precreatePeriods,
+		logger:           logger,
+	}, nil
+}
+
+// Up 在逻辑表的所有物理表上执行版本号 <= target 的迁移，target 为 0 时执行所有迁移。
+// table 为空时迁移所有注册了迁移的逻辑表。
+func (m *Migrator) Up(ctx context.Context, table string, target uint64) error {
+	targets, err := m.existingTargets(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		applied, err := m.applied(ctx, t.dst)
+		if err != nil {
+			return err
+		}
+		for _, migration := range upPlan(m.migrations[t.table], applied, target) {
+			if err := m.apply(ctx, t.dst, migration, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Down 在逻辑表的所有物理表上回滚版本号 > target 的迁移，target 为 0 时回滚所有迁移。
+func (m *Migrator) Down(ctx context.Context, table string, target uint64) error {
+	if table == "" {
+		return errors.New("table is required for down migration")
+	}
+	targets, err := m.existingTargets(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		applied, err := m.applied(ctx, t.dst)
+		if err != nil {
+			return err
+		}
+		plan, err := downPlan(m.migrations[t.table], applied, target)
+		if err != nil {
+			return fmt.Errorf("failed to roll back [ %s ]: %w", t.dst.FullTable(), err)
+		}
+		for _, migration := range plan {
+			if err := m.apply(ctx, t.dst, migration, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Status 返回逻辑表所有物理表的迁移状态，table 为空时返回所有注册了迁移的逻辑表。
+func (m *Migrator) Status(ctx context.Context, table string) ([]ShardStatus, error) {
+	targets, err := m.existingTargets(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]ShardStatus, 0, len(targets))
+	for _, t := range targets {
+		applied, err := m.applied(ctx, t.dst)
+		if err != nil {
+			return nil, err
+		}
+		pending, unknown := status(m.migrations[t.table], applied)
+		res = append(res, ShardStatus{
+			Table:   t.table,
+			DB:      t.dst.DB,
+			TB:      t.dst.TB,
+			Applied: applied,
+			Pending: pending,
+			Unknown: unknown,
+		})
+	}
+	return res, nil
+}
+
+// Check 检查所有物理表的迁移历史与注册的迁移是否一致，不一致时返回 ErrSchemaDrift。
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, s := range statuses {
+		if s.Drift() {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrSchemaDrift, s))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Migrator) targets(table string) ([]target, error) {
+	tables := []string{table}
+	if table == "" {
+		tables = make([]string, 0, len(m.migrations))
+		for name := range m.migrations {
+			tables = append(tables, name)
+		}
+		slices.Sort(tables)
+	} else if _, ok := m.migrations[table]; !ok {
+		return nil, fmt.Errorf("table [ %s ] has no migration", table)
+	}
+
+	var res []target
+	for _, name := range tables {
+		helper, _ := m.registry.Helper(name)
+		base, _ := m.registry.Base(name)
+		trs, ok := base.(*sharding.TimeRangeSharding)
+		if !ok {
+			for _, dst := range helper.Broadcast() {
+				res = append(res, target{table: name, dst: dst})
+			}
+			continue
+		}
+
+		for _, dst := range trs.TemplateDsts() {
+			res = append(res, target{table: name, dst: dst})
+		}
+		seen := make(map[string]struct{})
+		for _, dst := range slices.Concat(helper.Broadcast(), trs.Upcoming(m.precreatePeriods)) {
+			if _, ok := seen[dst.FullTable()]; ok {
+				continue
+			}
+			seen[dst.FullTable()] = struct{}{}
+			res = append(res, target{table: name, dst: dst, period: true})
+		}
+	}
+	return res, nil
+}
+
+// existingTargets 返回逻辑表的物理表，跳过还没有创建的周期表。
+func (m *Migrator) existingTargets(ctx context.Context, table string) ([]target, error) {
+	targets, err := m.targets(table)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]target, 0, len(targets))
+	for _, t := range targets {
+		if t.period {
+			db, err := m.db(t.dst)
+			if err != nil {
+				return nil, err
+			}
+			if !db.WithContext(ctx).Migrator().HasTable(t.dst.TB) {
+				continue
+			}
+		}
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+// applied 返回物理表已经执行的迁移版本 ( 从小到大 )，迁移历史表不存在时返回空。
+func (m *Migrator) applied(ctx context.Context, dst sharding.Dst) ([]uint64, error) {
+	db, err := m.db(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	if !db.WithContext(ctx).Migrator().HasTable(HistoryTable) {
+		return []uint64{}, nil
+	}
+
+	var versions []uint64
+	if err := db.WithContext(ctx).Model(&historyRecord{}).
+		Where("tb = ?", dst.TB).
+		Order("version").
+		Pluck("version", &versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to load migration history of [ %s ]: %w", dst.FullTable(), err)
+	}
+	return versions, nil
+}
+
+// apply 在一个事务中执行 ( 或回滚 ) 迁移并修改迁移历史。
+func (m *Migrator) apply(ctx context.Context, dst sharding.Dst, migration Migration, up bool) error {
+	db, err := m.db(dst)
+	if err != nil {
+		return err
+	}
+	if err := db.WithContext(ctx).Exec(createHistoryTableSQL).Error; err != nil {
+		return fmt.Errorf("failed to create migration history table in [ %s ]: %w", dst.DB, err)
+	}
+
+	action := "up"
+	if !up {
+		action = "down"
+	}
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := LockTable(tx, dst.TB); err != nil {
+			return err
+		}
+
+		// 拿到锁后重新检查，其它迁移工具可能已经执行过。
+		var count int64
+		if err := tx.Model(&historyRecord{}).
+			Where("tb = ? AND version = ?", dst.TB, migration.Version).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if (count > 0) == up {
+			return nil
+		}
+
+		if !up {
+			if err := migration.Down(tx, dst.TB); err != nil {
+				return err
+			}
+			return tx.Where("tb = ? AND version = ?", dst.TB, migration.Version).Delete(&historyRecord{}).Error
+		}
+
+		if err := migration.Up(tx, dst.TB); err != nil {
+			return err
+		}
+		return tx.Create(&historyRecord{
+			TB:          dst.TB,
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now().UnixMilli(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to migrate [ %s ] %s to version [ %d ]: %w", dst.FullTable(), action, migration.Version, err)
+	}
+
+	m.logger.Info("[hermet-shard-schema] migration applied",
+		slog.String("table", dst.FullTable()),
+		slog.String("action", action),
+		slog.Uint64("version", migration.Version),
+		slog.String("description", migration.Description),
+	)
+	return nil
+}
+
+// LockTable 在事务中获取物理表 tb 的迁移锁，事务结束时释放。
+// 迁移执行器在修改物理表的结构以及迁移历史前获取同一个锁。
+// 锁使用 postgres 的 advisory lock，其他数据库 ( 如只允许一个写事务的 sqlite ) 不加锁。
+func LockTable(tx *gorm.DB, tb string) error {
+	if tx.Name() != "postgres" {
+		return nil
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", HistoryTable+"."+tb).Error
+}
+
+// CopyHistory 将物理表 from 的迁移历史复制给物理表 to，用于复制 from 的结构创建的表 ( 如周期表复制模板表 )。
+// 需要与复制表结构在同一个事务中执行，并且事务中已经通过 LockTable 获取了 from 的迁移锁，
+// 保证复制的表结构与迁移历史一致。
+func CopyHistory(tx *gorm.DB, from, to string) error {
+	if err := tx.Exec(createHistoryTableSQL).Error; err != nil {
+		return err
+	}
+	return tx.Exec(
+		"INSERT INTO "+HistoryTable+" (tb, version, description, applied_at) "+
+			"SELECT ?, version, description, applied_at FROM "+HistoryTable+" WHERE tb = ? "+
+			"ON CONFLICT (tb, version) DO NOTHING",
+		to, from,
+	).Error
+}
+
+func (m *Migrator) db(dst sharding.Dst) (*gorm.DB, error) {
+	db, ok := m.dbs.Load(dst.DB)
+	if !ok {
+		return nil, fmt.Errorf("failed to load database [ %s ]", dst.DB)
+	}
+	return db, nil
+}
@@ -0,0 +1,312 @@
+package schema
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen/snowflake"
+	"github.com/jrmarcco/jit/xsync"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMigrator_Targets(t *testing.T) {
+	t.Parallel()
+
+	registry, err := sharding.NewRegistry(snowflake.NewGenerator(), snowflake.NewExtractor(), []sharding.TableSpec{
+		{Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 2, TBShardCount: 2},
+		{
+			Name: "message", DBPrefix: "hermet", TBPrefix: "message", DBShardCount: 2, TBShardCount: 1,
+			Strategy: sharding.StrategyTypeTimeRange, Period: sharding.TimePeriodMonth,
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	var dbs xsync.Map[string, *gorm.DB]
+
+	_, err = NewMigrator(&dbs, registry, []Migration{Baseline("unknown")}, 2, nil)
+	require.Error(t, err)
+
+	migrator, err := NewMigrator(&dbs, registry, []Migration{Baseline("user"), Baseline("message")}, 2, nil)
+	require.NoError(t, err)
+
+	targets, err := migrator.targets("")
+	require.NoError(t, err)
+	// message: 2 个模板表 + 当前周期以及之后 2 个周期的 6 张表，user: 4 张表。
+	require.Len(t, targets, 12)
+	require.Equal(t, "message", targets[0].table)
+	require.Equal(t, "message_template", targets[0].dst.TB)
+	require.False(t, targets[0].period)
+	require.True(t, targets[2].period)
+
+	targets, err = migrator.targets("user")
+	require.NoError(t, err)
+	require.Len(t, targets, 4)
+
+	_, err = migrator.targets("channel")
+	require.Error(t, err)
+}
+
+type schemaFixture struct {
+	dbs      *xsync.Map[string, *gorm.DB]
+	registry *sharding.Registry
+}
+
+// newSchemaFixture 使用 sqlite 代替 postgres，创建 user 逻辑表 ( 2 库 * 2 表 ) 的所有物理表，
+// 以及 message 逻辑表 ( 时间范围分片，2 库 * 1 表 ) 的模板表和当前周期的周期表。
+func newSchemaFixture(t *testing.T) *schemaFixture {
+	t.Helper()
+
+	registry, err := sharding.NewRegistry(snowflake.NewGenerator(), snowflake.NewExtractor(), []sharding.TableSpec{
+		{Name: "user", DBPrefix: "hermet", TBPrefix: "user", DBShardCount: 2, TBShardCount: 2},
+		{
+			Name: "message", DBPrefix: "hermet", TBPrefix: "message", DBShardCount: 2, TBShardCount: 1,
+			Strategy: sharding.StrategyTypeTimeRange, Period: sharding.TimePeriodMonth,
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	f := &schemaFixture{dbs: &xsync.Map[string, *gorm.DB]{}, registry: registry}
+	for _, name := range []string{"hermet_0", "hermet_1"} {
+		db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name+".db")), &gorm.Config{
+			Logger: logger.Discard,
+		})
+		require.NoError(t, err)
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		sqlDB.SetMaxOpenConns(1)
+		t.Cleanup(func() { _ = sqlDB.Close() })
+		f.dbs.Store(name, db)
+	}
+
+	userHelper, _ := registry.Helper("user")
+	messageHelper, _ := registry.Helper("message")
+	messageBase, _ := registry.Base("message")
+	dsts := slices.Concat(
+		userHelper.Broadcast(),
+		messageHelper.Broadcast(),
+		messageBase.(*sharding.TimeRangeSharding).TemplateDsts(),
+	)
+	for _, dst := range dsts {
+		f.exec(t, dst, "CREATE TABLE "+dst.TB+" (id BIGINT PRIMARY KEY)")
+	}
+	return f
+}
+
+func (f *schemaFixture) db(t *testing.T, dst sharding.Dst) *gorm.DB {
+	t.Helper()
+
+	db, ok := f.dbs.Load(dst.DB)
+	require.True(t, ok)
+	return db
+}
+
+func (f *schemaFixture) exec(t *testing.T, dst sharding.Dst, sql string, vars ...any) {
+	t.Helper()
+	require.NoError(t, f.db(t, dst).Exec(sql, vars...).Error)
+}
+
+func (f *schemaFixture) migrator(t *testing.T, migrations ...Migration) *Migrator {
+	t.Helper()
+
+	m, err := NewMigrator(f.dbs, f.registry, migrations, 2, nil)
+	require.NoError(t, err)
+	return m
+}
+
+func (f *schemaFixture) userDsts() []sharding.Dst {
+	helper, _ := f.registry.Helper("user")
+	return helper.Broadcast()
+}
+
+func (f *schemaFixture) history(t *testing.T, dst sharding.Dst) []historyRecord {
+	t.Helper()
+
+	var records []historyRecord
+	require.NoError(t, f.db(t, dst).Where("tb = ?", dst.TB).Order("version").Find(&records).Error)
+	return records
+}
+
+func (f *schemaFixture) hasRemark(t *testing.T, dst sharding.Dst) bool {
+	t.Helper()
+	return f.db(t, dst).Migrator().HasColumn(dst.TB, "remark")
+}
+
+func addRemark() Migration {
+	return Migration{
+		Table:       "user",
+		Version:     2,
+		Description: "add user.remark",
+		Up:          Exec("ALTER TABLE {{table}} ADD COLUMN remark VARCHAR(256) NOT NULL DEFAULT '?'"),
+		Down:        Exec("ALTER TABLE {{table}} DROP COLUMN remark"),
+	}
+}
+
+func TestMigrator_Up(t *testing.T) {
+	t.Parallel()
+
+	f := newSchemaFixture(t)
+	m := f.migrator(t, Baseline("user"), addRemark(), Baseline("message"))
+
+	// 只迁移到版本 1。
+	require.NoError(t, m.Up(t.Context(), "user", 1))
+	for _, dst := range f.userDsts() {
+		require.False(t, f.hasRemark(t, dst))
+		require.Len(t, f.history(t, dst), 1)
+	}
+
+	require.NoError(t, m.Up(t.Context(), "", 0))
+	for _, dst := range f.userDsts() {
+		require.True(t, f.hasRemark(t, dst))
+
+		records := f.history(t, dst)
+		require.Len(t, records, 2)
+		require.Equal(t, dst.TB, records[1].TB)
+		require.EqualValues(t, 2, records[1].Version)
+		require.Equal(t, "add user.remark", records[1].Description)
+		require.Positive(t, records[1].AppliedAt)
+
+		// SQL 中的 ? 原样执行。
+		f.exec(t, dst, "INSERT INTO "+dst.TB+" (id) VALUES (1)")
+		var remark string
+		require.NoError(t, f.db(t, dst).Table(dst.TB).Select("remark").Scan(&remark).Error)
+		require.Equal(t, "?", remark)
+	}
+
+	// 已经执行过的迁移不会重复执行。
+	require.NoError(t, m.Up(t.Context(), "", 0))
+
+	// 还没有创建的周期表会跳过。
+	statuses, err := m.Status(t.Context(), "message")
+	require.NoError(t, err)
+	require.Len(t, statuses, 4)
+	for _, s := range statuses {
+		require.Equal(t, []uint64{1}, s.Applied)
+	}
+}
+
+func TestMigrator_UpFailed(t *testing.T) {
+	t.Parallel()
+
+	f := newSchemaFixture(t)
+	m := f.migrator(t, Baseline("user"), Migration{
+		Table:   "user",
+		Version: 2,
+		Up: func(tx *gorm.DB, tb string) error {
+			if err := Exec("ALTER TABLE {{table}} ADD COLUMN remark VARCHAR(256)")(tx, tb); err != nil {
+				return err
+			}
+			return errors.New("mock error")
+		},
+	})
+
+	require.ErrorContains(t, m.Up(t.Context(), "user", 0), "mock error")
+
+	// 迁移和迁移历史在同一个事务中回滚。
+	dst := f.userDsts()[0]
+	require.False(t, f.hasRemark(t, dst))
+	require.Len(t, f.history(t, dst), 1)
+}
+
+func TestMigrator_Down(t *testing.T) {
+	t.Parallel()
+
+	f := newSchemaFixture(t)
+	m := f.migrator(t, Baseline("user"), addRemark(), Migration{Table: "user", Version: 3, Up: noop})
+	require.NoError(t, m.Up(t.Context(), "user", 2))
+
+	require.Error(t, m.Down(t.Context(), "", 1))
+
+	require.NoError(t, m.Down(t.Context(), "user", 1))
+	for _, dst := range f.userDsts() {
+		require.False(t, f.hasRemark(t, dst))
+		require.Len(t, f.history(t, dst), 1)
+	}
+
+	// 没有 Down 的迁移不能回滚。
+	require.NoError(t, m.Up(t.Context(), "user", 0))
+	require.ErrorIs(t, m.Down(t.Context(), "user", 2), ErrIrreversible)
+	require.Len(t, f.history(t, f.userDsts()[0]), 3)
+}
+
+func TestMigrator_Check(t *testing.T) {
+	t.Parallel()
+
+	f := newSchemaFixture(t)
+	m := f.migrator(t, Baseline("user"), addRemark())
+
+	// 还没有执行的迁移。
+	require.ErrorIs(t, m.Check(t.Context()), ErrSchemaDrift)
+
+	require.NoError(t, m.Up(t.Context(), "", 0))
+	require.NoError(t, m.Check(t.Context()))
+
+	// 执行过但是没有注册的迁移。
+	dst := f.userDsts()[0]
+	f.exec(t, dst, "INSERT INTO "+HistoryTable+" (tb, version, applied_at) VALUES (?, 3, 0)", dst.TB)
+	err := m.Check(t.Context())
+	require.ErrorIs(t, err, ErrSchemaDrift)
+	require.ErrorContains(t, err, "unknown=[3]")
+}
+
+func TestMigrator_ApplyRechecksHistory(t *testing.T) {
+	t.Parallel()
+
+	f := newSchemaFixture(t)
+	dst := f.userDsts()[0]
+
+	calls := 0
+	counted := func(*gorm.DB, string) error {
+		calls++
+		return nil
+	}
+	migration := Migration{Table: "user", Version: 2, Up: counted, Down: counted}
+	m := f.migrator(t, Baseline("user"), migration)
+
+	// 模拟其它迁移工具在读取迁移历史之后、获取锁之前执行了迁移。
+	require.NoError(t, m.apply(t.Context(), dst, Baseline("user"), true))
+	f.exec(t, dst, "INSERT INTO "+HistoryTable+" (tb, version, applied_at) VALUES (?, 2, 0)", dst.TB)
+	require.NoError(t, m.apply(t.Context(), dst, migration, true))
+	require.Zero(t, calls)
+
+	// 其它迁移工具已经回滚。
+	f.exec(t, dst, "DELETE FROM "+HistoryTable+" WHERE tb = ? AND version = 2", dst.TB)
+	require.NoError(t, m.apply(t.Context(), dst, migration, false))
+	require.Zero(t, calls)
+
+	require.NoError(t, m.apply(t.Context(), dst, migration, true))
+	require.Equal(t, 1, calls)
+	require.Len(t, f.history(t, dst), 2)
+}
+
+func TestCopyHistory(t *testing.T) {
+	t.Parallel()
+
+	f := newSchemaFixture(t)
+	m := f.migrator(t, Baseline("message"), Migration{Table: "message", Version: 2, Up: noop})
+	require.NoError(t, m.Up(t.Context(), "message", 0))
+
+	tmpl := sharding.Dst{DB: "hermet_0", TB: "message_template"}
+	period := sharding.Dst{DB: "hermet_0", TB: "message_209901"}
+	db := f.db(t, tmpl)
+	for range 2 {
+		// 重复复制不会报错。
+		require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+			if err := LockTable(tx, tmpl.TB); err != nil {
+				return err
+			}
+			return CopyHistory(tx, tmpl.TB, period.TB)
+		}))
+	}
+
+	records := f.history(t, period)
+	require.Len(t, records, 2)
+	require.EqualValues(t, 1, records[0].Version)
+	require.Equal(t, "baseline, schema created by scripts/sql", records[0].Description)
+	require.EqualValues(t, 2, records[1].Version)
+}
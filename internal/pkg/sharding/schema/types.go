@@ -0,0 +1,158 @@
+package schema
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrSchemaDrift 分片的迁移历史与注册的迁移不一致。
+	ErrSchemaDrift = errors.New("sharding schema drift")
+	// ErrIrreversible 迁移没有提供 Down，不能回滚。
+	ErrIrreversible = errors.New("migration is irreversible")
+)
+
+// MigrateFunc 在物理表 tb 上执行迁移，tx 为该表所在库的事务。
+type MigrateFunc func(tx *gorm.DB, tb string) error
+
+// Migration 逻辑表的版本化迁移，会在逻辑表的每个物理表上执行。
+// 同一逻辑表的版本号必须唯一且大于 0，按照版本号从小到大执行。
+type Migration struct {
+	Table       string // 逻辑表名，与 sharding.yaml 中的名称一致
+	Version     uint64
+	Description string
+
+	Up   MigrateFunc
+	Down MigrateFunc // 为 nil 时不能回滚
+}
+
+// TablePlaceholder Exec 的 SQL 中物理表名的占位符。
+const TablePlaceholder = "{{table}}"
+
+// Exec 返回执行 SQL 的 MigrateFunc，SQL 中的 {{table}} 会被替换为 ( 加了引号的 ) 物理表名，其他内容原样执行。
+//
+// 示例：
+//
+//	schema.Exec("ALTER TABLE {{table}} ADD COLUMN IF NOT EXISTS remark VARCHAR(256) NOT NULL DEFAULT ''")
+func Exec(sql string) MigrateFunc {
+	return func(tx *gorm.DB, tb string) error {
+		return tx.Exec(strings.ReplaceAll(sql, TablePlaceholder, tx.Statement.Quote(clause.Table{Name: tb}))).Error
+	}
+}
+
+// Baseline 返回逻辑表的基线迁移 ( 版本 1 )。
+// 基线之前的表结构由 scripts/sql 中的脚本创建，基线只记录迁移历史，不修改表结构。
+func Baseline(table string) Migration {
+	noop := func(*gorm.DB, string) error { return nil }
+	return Migration{
+		Table:       table,
+		Version:     1,
+		Description: "baseline, schema created by scripts/sql",
+		Up:          noop,
+		Down:        noop,
+	}
+}
+
+// ShardStatus 物理表的迁移状态。
+type ShardStatus struct {
+	Table string // 逻辑表名
+	DB    string
+	TB    string
+
+	Applied []uint64 // 已经执行的版本
+	Pending []uint64 // 注册了但是还没有执行的版本
+	Unknown []uint64 // 执行过但是没有注册的版本 ( 代码回退或者迁移被删除 )
+}
+
+// Drift 物理表的迁移历史与注册的迁移是否不一致。
+func (s ShardStatus) Drift() bool {
+	return len(s.Pending) > 0 || len(s.Unknown) > 0
+}
+
+func (s ShardStatus) String() string {
+	return fmt.Sprintf("%s.%s ( %s ): applied=%v pending=%v unknown=%v", s.DB, s.TB, s.Table, s.Applied, s.Pending, s.Unknown)
+}
+
+// groupMigrations 按照逻辑表分组并按照版本号排序，校验版本号。
+func groupMigrations(migrations []Migration) (map[string][]Migration, error) {
+	res := make(map[string][]Migration)
+	for _, m := range migrations {
+		if m.Table == "" {
+			return nil, errors.New("migration table cannot be empty")
+		}
+		if m.Version == 0 {
+			return nil, fmt.Errorf("migration version of [ %s ] must be greater than 0", m.Table)
+		}
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration [ %s:%d ] has no up", m.Table, m.Version)
+		}
+		res[m.Table] = append(res[m.Table], m)
+	}
+
+	for table, ms := range res {
+		slices.SortFunc(ms, func(a, b Migration) int {
+			return cmp.Compare(a.Version, b.Version)
+		})
+		for i := 1; i < len(ms); i++ {
+			if ms[i].Version == ms[i-1].Version {
+				return nil, fmt.Errorf("migration [ %s:%d ] is registered more than once", table, ms[i].Version)
+			}
+		}
+	}
+	return res, nil
+}
+
+// upPlan 返回需要执行的迁移 ( 版本号从小到大 )，target 为 0 时执行所有迁移。
+func upPlan(ms []Migration, applied []uint64, target uint64) []Migration {
+	var res []Migration
+	for _, m := range ms {
+		if target > 0 && m.Version > target {
+			break
+		}
+		if !slices.Contains(applied, m.Version) {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// downPlan 返回需要回滚的迁移 ( 版本号从大到小 )，回滚所有版本号大于 target 的迁移。
+// 执行过但是没有注册的版本不能回滚，返回错误。
+func downPlan(ms []Migration, applied []uint64, target uint64) ([]Migration, error) {
+	var res []Migration
+	for _, version := range slices.Backward(slices.Sorted(slices.Values(applied))) {
+		if version <= target {
+			break
+		}
+		idx := slices.IndexFunc(ms, func(m Migration) bool { return m.Version == version })
+		if idx < 0 {
+			return nil, fmt.Errorf("applied migration [ %d ] is not registered", version)
+		}
+		if ms[idx].Down == nil {
+			return nil, fmt.Errorf("%w: [ %s:%d ]", ErrIrreversible, ms[idx].Table, version)
+		}
+		res = append(res, ms[idx])
+	}
+	return res, nil
+}
+
+// status 比较已经执行的版本和注册的迁移。
+func status(ms []Migration, applied []uint64) (pending, unknown []uint64) {
+	for _, m := range ms {
+		if !slices.Contains(applied, m.Version) {
+			pending = append(pending, m.Version)
+		}
+	}
+	for _, version := range applied {
+		if !slices.ContainsFunc(ms, func(m Migration) bool { return m.Version == version }) {
+			unknown = append(unknown, version)
+		}
+	}
+	return pending, unknown
+}
@@ -0,0 +1,124 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func noop(*gorm.DB, string) error { return nil }
+
+func versions(ms []Migration) []uint64 {
+	res := make([]uint64, 0, len(ms))
+	for _, m := range ms {
+		res = append(res, m.Version)
+	}
+	return res
+}
+
+func TestGroupMigrations(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		migrations []Migration
+		want       map[string][]uint64
+		wantErr    bool
+	}{
+		{
+			name: "sorted by version",
+			migrations: []Migration{
+				{Table: "user", Version: 3, Up: noop},
+				Baseline("user"),
+				{Table: "user", Version: 2, Up: noop},
+				Baseline("channel"),
+			},
+			want: map[string][]uint64{"user": {1, 2, 3}, "channel": {1}},
+		},
+		{
+			name:       "empty table",
+			migrations: []Migration{{Version: 1, Up: noop}},
+			wantErr:    true,
+		},
+		{
+			name:       "zero version",
+			migrations: []Migration{{Table: "user", Up: noop}},
+			wantErr:    true,
+		},
+		{
+			name:       "no up",
+			migrations: []Migration{{Table: "user", Version: 1}},
+			wantErr:    true,
+		},
+		{
+			name:       "duplicate version",
+			migrations: []Migration{Baseline("user"), {Table: "user", Version: 1, Up: noop}},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			grouped, err := groupMigrations(tt.migrations)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, grouped, len(tt.want))
+			for table, want := range tt.want {
+				require.Equal(t, want, versions(grouped[table]))
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+
+	ms := []Migration{
+		Baseline("user"),
+		{Table: "user", Version: 2, Up: noop, Down: noop},
+		{Table: "user", Version: 3, Up: noop},
+		{Table: "user", Version: 4, Up: noop, Down: noop},
+	}
+
+	require.Equal(t, []uint64{2, 3, 4}, versions(upPlan(ms, []uint64{1}, 0)))
+	require.Equal(t, []uint64{1, 2}, versions(upPlan(ms, nil, 2)))
+	require.Empty(t, upPlan(ms, []uint64{1, 2, 3, 4}, 0))
+	// 跳过的版本 ( 如合并分支后插入的迁移 ) 也会执行。
+	require.Equal(t, []uint64{2}, versions(upPlan(ms, []uint64{1, 3, 4}, 0)))
+
+	plan, err := downPlan(ms, []uint64{1, 2, 3, 4}, 3)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{4}, versions(plan))
+
+	_, err = downPlan(ms, []uint64{1, 2, 3, 4}, 2)
+	require.ErrorIs(t, err, ErrIrreversible)
+
+	_, err = downPlan(ms, []uint64{1, 5}, 1)
+	require.Error(t, err)
+
+	plan, err = downPlan(ms, []uint64{2, 1}, 0)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 1}, versions(plan))
+}
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+
+	ms := []Migration{Baseline("user"), {Table: "user", Version: 2, Up: noop}}
+
+	pending, unknown := status(ms, []uint64{1, 2})
+	require.Empty(t, pending)
+	require.Empty(t, unknown)
+	require.False(t, ShardStatus{Pending: pending, Unknown: unknown}.Drift())
+
+	pending, unknown = status(ms, []uint64{1, 5})
+	require.Equal(t, []uint64{2}, pending)
+	require.Equal(t, []uint64{5}, unknown)
+	require.True(t, ShardStatus{Pending: pending, Unknown: unknown}.Drift())
+}
@@ -0,0 +1,243 @@
+package sharding
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	_ Strategy         = (*TimeRangeSharding)(nil)
+	_ RangeBroadcaster = (*TimeRangeSharding)(nil)
+)
+
+// ErrTimeRequired 按时间分表时只有分片值无法确定周期表，需要使用 ID ( DstFromID ) 或者时间范围 ( BroadcastRange ) 路由。
+var ErrTimeRequired = errors.New("time range sharding requires an id or a time range to route")
+
+// TimePeriod 按时间分表的周期。
+type TimePeriod string
+
+const (
+	TimePeriodMonth TimePeriod = "month" // 按月分表，表名如 message_202601
+	TimePeriodWeek  TimePeriod = "week"  // 按 ISO 周分表，表名如 message_2026w03
+)
+
+// TimeRangeConfig 时间范围分片配置。
+type TimeRangeConfig struct {
+	DBPrefix string // 数据库名前缀
+	TBPrefix string // 表名前缀
+
+	// DBShardCount 分库数量，必须大于 0。
+	DBShardCount uint64
+	// TBShardCount 每个周期的分表数量 ( 哈希部分 )，为 1 时表名不带哈希后缀。
+	TBShardCount uint64
+
+	Period TimePeriod // 分表周期
+	// BroadcastPeriods Broadcast 覆盖的周期数 ( 包含当前周期 )，<= 0 时只覆盖当前周期。
+	BroadcastPeriods int
+}
+
+// TimeRangeSharding 时间范围分片策略。
+// 按照 ID 中的时间戳将数据路由到周期表 ( 月表 / 周表 )，再按照分片值取模确定库以及周期内的哈希分表。
+// 适用于消息、事件日志等随时间增长并且主要按时间查询的数据。
+//
+// 分片算法：
+// 1. 周期：ID 中的时间戳 ( UTC ) 所在的月 / ISO 周；
+// 2. 哈希：shardIndex = shardVal % ( dbShardCount * tbShardCount )，
+//   - dbSuffix = shardIndex % dbShardCount
+//   - tbSuffix = shardIndex / dbShardCount
+//
+// 3. 表名：{tbPrefix}_{周期}_{tbSuffix}，tbShardCount 为 1 时为 {tbPrefix}_{周期}。
+//
+// 示例 ( 2 个库，每个月 2 张表 )：
+//
+//	2026-01 生成的 ID，shardVal=3 -> db_1.message_202601_1
+//	2026-02 生成的 ID，shardVal=3 -> db_1.message_202602_1
+//
+// 注意：
+//   - extractor 需要同时实现 TimeExtractor；
+//   - Shard / DstFromShardVal 没有时间信息，返回 ErrTimeRequired ( 按当前周期路由会漏掉之前周期的数据 )，
+//     写入时使用 ID 路由，查询时使用 DstFromID 或 BroadcastRange；
+//   - Broadcast 只覆盖最近 BroadcastPeriods 个周期，查询更早的数据使用 BroadcastRange。
+type TimeRangeSharding struct {
+	extractor     ShardValExtractor
+	timeExtractor TimeExtractor
+
+	cfg TimeRangeConfig
+
+	now func() time.Time
+}
+
+// NewTimeRangeSharding 创建时间范围分片策略。
+func NewTimeRangeSharding(extractor ShardValExtractor, cfg TimeRangeConfig) (*TimeRangeSharding, error) {
+	if extractor == nil {
+		return nil, errors.New("extractor cannot be nil")
+	}
+	timeExtractor, ok := extractor.(TimeExtractor)
+	if !ok {
+		return nil, errors.New("extractor must implement TimeExtractor")
+	}
+	if cfg.DBShardCount == 0 {
+		return nil, errors.New("dbShardCount must be greater than 0")
+	}
+	if cfg.TBShardCount == 0 {
+		return nil, errors.New("tbShardCount must be greater than 0")
+	}
+	if cfg.DBPrefix == "" {
+		return nil, errors.New("dbPrefix cannot be empty")
+	}
+	if cfg.TBPrefix == "" {
+		return nil, errors.New("tbPrefix cannot be empty")
+	}
+	switch cfg.Period {
+	case TimePeriodMonth, TimePeriodWeek:
+	default:
+		return nil, fmt.Errorf("invalid time period: %s", cfg.Period)
+	}
+	if cfg.BroadcastPeriods <= 0 {
+		cfg.BroadcastPeriods = 1
+	}
+
+	return &TimeRangeSharding{
+		extractor:     extractor,
+		timeExtractor: timeExtractor,
+		cfg:           cfg,
+		now:           time.Now,
+	}, nil
+}
+
+// Shard 只有分片值无法确定周期表，返回 ErrTimeRequired。
+func (s *TimeRangeSharding) Shard(uint64) (Dst, error) {
+	return Dst{}, ErrTimeRequired
+}
+
+// DstFromID 使用 ID 中的时间戳和分片值计算分片目标。
+func (s *TimeRangeSharding) DstFromID(id uint64) (Dst, error) {
+	return s.dst(s.periodStart(s.timeExtractor.ExtractTime(id)), s.extractor.ExtractShardVal(id)), nil
+}
+
+// DstFromShardVal 只有分片值无法确定周期表，返回 ErrTimeRequired。
+func (s *TimeRangeSharding) DstFromShardVal(uint64) (Dst, error) {
+	return Dst{}, ErrTimeRequired
+}
+
+// Broadcast 返回最近 BroadcastPeriods 个周期 ( 包含当前周期 ) 的所有分片目标，新的周期在前。
+func (s *TimeRangeSharding) Broadcast() []Dst {
+	now := s.now()
+	from := s.periodStart(now)
+	for range s.cfg.BroadcastPeriods - 1 {
+		from = s.prevPeriod(from)
+	}
+	return s.BroadcastRange(from, now)
+}
+
+// BroadcastRange 返回与 [from, to] 有交集的周期的所有分片目标，新的周期在前。
+// 周期内按照 库 -> 表 的顺序排列，from 晚于 to 时返回空。
+func (s *TimeRangeSharding) BroadcastRange(from, to time.Time) []Dst {
+	if from.After(to) {
+		return []Dst{}
+	}
+
+	var periods []time.Time
+	for start := s.periodStart(from); !start.After(to); start = s.nextPeriod(start) {
+		periods = append(periods, start)
+	}
+
+	res := make([]Dst, 0, len(periods)*int(s.cfg.DBShardCount*s.cfg.TBShardCount)) //nolint:gosec // 分片数量不会溢出
+	for i := len(periods) - 1; i >= 0; i-- {
+		res = append(res, s.periodDsts(periods[i])...)
+	}
+	return res
+}
+
+// Upcoming 返回当前周期以及之后 n 个周期的所有分片目标 ( 按时间顺序 )，用于提前创建周期表。
+func (s *TimeRangeSharding) Upcoming(n int) []Dst {
+	start := s.periodStart(s.now())
+
+	res := make([]Dst, 0, (n+1)*int(s.cfg.DBShardCount*s.cfg.TBShardCount)) //nolint:gosec // 分片数量不会溢出
+	for range n + 1 {
+		res = append(res, s.periodDsts(start)...)
+		start = s.nextPeriod(start)
+	}
+	return res
+}
+
+// TemplateTable 周期表的模板表名称 ( {tbPrefix}_template )，自动创建的周期表会复制模板表的结构。
+func (s *TimeRangeSharding) TemplateTable() string {
+	return s.cfg.TBPrefix + "_template"
+}
+
+// TemplateDsts 返回每个库中模板表的分片目标。
+func (s *TimeRangeSharding) TemplateDsts() []Dst {
+	res := make([]Dst, 0, s.cfg.DBShardCount)
+	for i := range s.cfg.DBShardCount {
+		res = append(res, Dst{
+			DBSuffix: i,
+			DB:       fmt.Sprintf("%s_%d", s.cfg.DBPrefix, i),
+			TB:       s.TemplateTable(),
+		})
+	}
+	return res
+}
+
+func (s *TimeRangeSharding) periodDsts(start time.Time) []Dst {
+	res := make([]Dst, 0, s.cfg.DBShardCount*s.cfg.TBShardCount)
+	for i := range s.cfg.DBShardCount {
+		for j := range s.cfg.TBShardCount {
+			res = append(res, s.dstOf(start, i, j))
+		}
+	}
+	return res
+}
+
+func (s *TimeRangeSharding) dst(start time.Time, shardVal uint64) Dst {
+	shardIndex := shardVal % (s.cfg.DBShardCount * s.cfg.TBShardCount)
+	return s.dstOf(start, shardIndex%s.cfg.DBShardCount, shardIndex/s.cfg.DBShardCount)
+}
+
+func (s *TimeRangeSharding) dstOf(start time.Time, dbSuffix, tbSuffix uint64) Dst {
+	tb := fmt.Sprintf("%s_%s", s.cfg.TBPrefix, s.periodKey(start))
+	if s.cfg.TBShardCount > 1 {
+		tb = fmt.Sprintf("%s_%d", tb, tbSuffix)
+	}
+	return Dst{
+		DBSuffix: dbSuffix,
+		TBSuffix: tbSuffix,
+		DB:       fmt.Sprintf("%s_%d", s.cfg.DBPrefix, dbSuffix),
+		TB:       tb,
+	}
+}
+
+// periodStart 返回 t 所在周期的开始时间 ( UTC )。
+func (s *TimeRangeSharding) periodStart(t time.Time) time.Time {
+	t = t.UTC()
+	if s.cfg.Period == TimePeriodWeek {
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		// ISO 周从周一开始。
+		offset := (int(day.Weekday()) + 6) % 7 //nolint:mnd // 一周 7 天
+		return day.AddDate(0, 0, -offset)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *TimeRangeSharding) nextPeriod(start time.Time) time.Time {
+	if s.cfg.Period == TimePeriodWeek {
+		return start.AddDate(0, 0, 7) //nolint:mnd // 一周 7 天
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+func (s *TimeRangeSharding) prevPeriod(start time.Time) time.Time {
+	if s.cfg.Period == TimePeriodWeek {
+		return start.AddDate(0, 0, -7) //nolint:mnd // 一周 7 天
+	}
+	return start.AddDate(0, -1, 0)
+}
+
+func (s *TimeRangeSharding) periodKey(start time.Time) string {
+	if s.cfg.Period == TimePeriodWeek {
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%04dw%02d", year, week)
+	}
+	return start.Format("200601")
+}
@@ -0,0 +1,282 @@
+package sharding
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen/snowflake"
+	"github.com/stretchr/testify/require"
+)
+
+// timeRangeID 按照 snowflake 的位布局构造指定时间和分片值的 ID。
+func timeRangeID(t time.Time, shardVal uint64) uint64 {
+	const epochMillis = uint64(1735689600000)
+	return (uint64(t.UnixMilli())-epochMillis)<<22 | (shardVal&1023)<<12
+}
+
+// shardValOnlyExtractor 只能提取分片值，不能提取时间。
+type shardValOnlyExtractor struct{}
+
+func (shardValOnlyExtractor) ExtractShardVal(id uint64) uint64 { return id }
+
+func newTestTimeRangeSharding(t *testing.T, cfg TimeRangeConfig, now time.Time) *TimeRangeSharding {
+	t.Helper()
+
+	s, err := NewTimeRangeSharding(snowflake.NewExtractor(), cfg)
+	require.NoError(t, err)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestNewTimeRangeSharding(t *testing.T) {
+	t.Parallel()
+
+	valid := TimeRangeConfig{DBPrefix: "db", TBPrefix: "message", DBShardCount: 2, TBShardCount: 2, Period: TimePeriodMonth}
+
+	tests := []struct {
+		name      string
+		extractor ShardValExtractor
+		modify    func(cfg *TimeRangeConfig)
+		wantErr   bool
+	}{
+		{name: "valid", extractor: snowflake.NewExtractor(), modify: func(*TimeRangeConfig) {}},
+		{name: "nil extractor", extractor: nil, modify: func(*TimeRangeConfig) {}, wantErr: true},
+		{
+			name:      "extractor without time",
+			extractor: shardValOnlyExtractor{},
+			modify:    func(*TimeRangeConfig) {},
+			wantErr:   true,
+		},
+		{
+			name:      "zero db shard count",
+			extractor: snowflake.NewExtractor(),
+			modify:    func(cfg *TimeRangeConfig) { cfg.DBShardCount = 0 },
+			wantErr:   true,
+		},
+		{
+			name:      "zero tb shard count",
+			extractor: snowflake.NewExtractor(),
+			modify:    func(cfg *TimeRangeConfig) { cfg.TBShardCount = 0 },
+			wantErr:   true,
+		},
+		{
+			name:      "empty tb prefix",
+			extractor: snowflake.NewExtractor(),
+			modify:    func(cfg *TimeRangeConfig) { cfg.TBPrefix = "" },
+			wantErr:   true,
+		},
+		{
+			name:      "invalid period",
+			extractor: snowflake.NewExtractor(),
+			modify:    func(cfg *TimeRangeConfig) { cfg.Period = "day" },
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := valid
+			tt.modify(&cfg)
+			_, err := NewTimeRangeSharding(tt.extractor, cfg)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestTimeRangeSharding_DstFromID(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		cfg      TimeRangeConfig
+		at       time.Time
+		shardVal uint64
+		want     Dst
+	}{
+		{
+			name:     "month with hash part",
+			cfg:      TimeRangeConfig{DBPrefix: "db", TBPrefix: "message", DBShardCount: 2, TBShardCount: 2, Period: TimePeriodMonth},
+			at:       time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
+			shardVal: 3,
+			want:     Dst{DBSuffix: 1, TBSuffix: 1, DB: "db_1", TB: "message_202601_1"},
+		},
+		{
+			name:     "month without hash part",
+			cfg:      TimeRangeConfig{DBPrefix: "db", TBPrefix: "message", DBShardCount: 2, TBShardCount: 1, Period: TimePeriodMonth},
+			at:       time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			shardVal: 3,
+			want:     Dst{DBSuffix: 1, TBSuffix: 0, DB: "db_1", TB: "message_202602"},
+		},
+		{
+			name:     "week",
+			cfg:      TimeRangeConfig{DBPrefix: "db", TBPrefix: "message", DBShardCount: 1, TBShardCount: 1, Period: TimePeriodWeek},
+			at:       time.Date(2026, 1, 14, 8, 0, 0, 0, time.UTC),
+			shardVal: 5,
+			want:     Dst{DB: "db_0", TB: "message_2026w03"},
+		},
+		{
+			name:     "iso week belongs to previous year",
+			cfg:      TimeRangeConfig{DBPrefix: "db", TBPrefix: "message", DBShardCount: 1, TBShardCount: 1, Period: TimePeriodWeek},
+			at:       time.Date(2027, 1, 1, 8, 0, 0, 0, time.UTC),
+			shardVal: 0,
+			want:     Dst{DB: "db_0", TB: "message_2026w53"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newTestTimeRangeSharding(t, tt.cfg, now)
+
+			dst, err := s.DstFromID(timeRangeID(tt.at, tt.shardVal))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, dst)
+		})
+	}
+}
+
+func TestTimeRangeSharding_ShardRequiresTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	s := newTestTimeRangeSharding(t, TimeRangeConfig{
+		DBPrefix: "db", TBPrefix: "message", DBShardCount: 2, TBShardCount: 2, Period: TimePeriodMonth,
+	}, now)
+
+	// 只有分片值时无法确定周期表，不能按照当前周期路由 ( 会漏掉之前周期的数据 )。
+	_, err := s.Shard(2)
+	require.ErrorIs(t, err, ErrTimeRequired)
+
+	_, err = s.DstFromShardVal(2)
+	require.ErrorIs(t, err, ErrTimeRequired)
+
+	// 之前周期生成的 ID 路由到 ID 所在周期的表。
+	dst, err := s.DstFromID(timeRangeID(time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC), 2))
+	require.NoError(t, err)
+	require.Equal(t, "db_0.message_202601_1", dst.FullTable())
+}
+
+func TestTimeRangeSharding_BroadcastRange(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	s := newTestTimeRangeSharding(t, TimeRangeConfig{
+		DBPrefix: "db", TBPrefix: "message", DBShardCount: 2, TBShardCount: 1, Period: TimePeriodMonth, BroadcastPeriods: 2,
+	}, now)
+
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		want []string
+	}{
+		{
+			name: "newest period first",
+			from: time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{
+				"db_0.message_202603", "db_1.message_202603",
+				"db_0.message_202602", "db_1.message_202602",
+				"db_0.message_202601", "db_1.message_202601",
+			},
+		},
+		{
+			name: "single period",
+			from: time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC),
+			want: []string{"db_0.message_202602", "db_1.message_202602"},
+		},
+		{
+			name: "from after to",
+			from: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := make([]string, 0, len(tt.want))
+			for _, dst := range s.BroadcastRange(tt.from, tt.to) {
+				got = append(got, dst.FullTable())
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	// Broadcast 覆盖最近 BroadcastPeriods 个周期。
+	got := make([]string, 0, 4)
+	for _, dst := range s.Broadcast() {
+		got = append(got, dst.FullTable())
+	}
+	require.Equal(t, []string{
+		"db_0.message_202603", "db_1.message_202603",
+		"db_0.message_202602", "db_1.message_202602",
+	}, got)
+}
+
+func TestTimeRangeSharding_Upcoming(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 12, 30, 12, 0, 0, 0, time.UTC)
+	s := newTestTimeRangeSharding(t, TimeRangeConfig{
+		DBPrefix: "db", TBPrefix: "message", DBShardCount: 1, TBShardCount: 2, Period: TimePeriodWeek,
+	}, now)
+
+	got := make([]string, 0, 4)
+	for _, dst := range s.Upcoming(1) {
+		got = append(got, dst.FullTable())
+	}
+	require.Equal(t, []string{
+		"db_0.message_2026w53_0", "db_0.message_2026w53_1",
+		"db_0.message_2027w01_0", "db_0.message_2027w01_1",
+	}, got)
+	require.Equal(t, "message_template", s.TemplateTable())
+	require.Equal(t, []Dst{{DB: "db_0", TB: "message_template"}}, s.TemplateDsts())
+}
+
+func TestTimeRangeSharding_WithBalancedSharding(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	base := newTestTimeRangeSharding(t, TimeRangeConfig{
+		DBPrefix: "db", TBPrefix: "message", DBShardCount: 2, TBShardCount: 2, Period: TimePeriodMonth,
+	}, now)
+
+	strategy, err := NewBalancedSharding(base, BroadcastModeRoundRobin)
+	require.NoError(t, err)
+	helper, err := NewShardHelper(snowflake.NewGenerator(), strategy)
+	require.NoError(t, err)
+
+	got := make([]string, 0, 8)
+	for _, dst := range helper.BroadcastRange(
+		time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	) {
+		got = append(got, dst.FullTable())
+	}
+	require.Equal(t, []string{
+		"db_0.message_202603_0", "db_1.message_202603_0",
+		"db_0.message_202603_1", "db_1.message_202603_1",
+		"db_0.message_202602_0", "db_1.message_202602_0",
+		"db_0.message_202602_1", "db_1.message_202602_1",
+	}, got)
+
+	// 不支持按时间范围广播的分片策略返回所有分片。
+	modulo, err := NewModuloSharding(snowflake.NewExtractor(), "db", "table", 2, 2)
+	require.NoError(t, err)
+	helper, err = NewShardHelper(snowflake.NewGenerator(), modulo)
+	require.NoError(t, err)
+	require.Len(t, helper.BroadcastRange(now, now), 4)
+}
@@ -0,0 +1,117 @@
+package timetable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/schema"
+	"github.com/jrmarcco/jit/xsync"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Creator 为时间范围分片的逻辑表提前创建周期表。
+//
+// 周期表通过复制模板表 ( {tbPrefix}_template ) 的结构创建，模板表需要在每个分库中预先建好。
+// 创建时同时复制模板表的迁移历史，之后的迁移会从模板表已经执行的版本继续。
+// 建表语句使用 IF NOT EXISTS，多个业务进程同时执行不会冲突。
+type Creator struct {
+	dbs    *xsync.Map[string, *gorm.DB]
+	logger *slog.Logger
+
+	mu         sync.Mutex
+	strategies map[string]*sharding.TimeRangeSharding
+	created    map[string]struct{} // 已经创建的物理表 ( db.tb )
+}
+
+func NewCreator(dbs *xsync.Map[string, *gorm.DB], logger *slog.Logger) *Creator {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Creator{
+		dbs:        dbs,
+		logger:     logger,
+		strategies: make(map[string]*sharding.TimeRangeSharding),
+		created:    make(map[string]struct{}),
+	}
+}
+
+// Register 注册逻辑表的时间范围分片策略，只有注册过的逻辑表才会自动创建周期表。
+func (c *Creator) Register(table string, strategy *sharding.TimeRangeSharding) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.strategies[table] = strategy
+}
+
+// Ensure 为所有注册的逻辑表创建当前周期以及之后 periods 个周期的周期表。
+// 单个表创建失败不影响其它表，所有错误合并后返回。
+func (c *Creator) Ensure(ctx context.Context, periods int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for table, strategy := range c.strategies {
+		tmpl := strategy.TemplateTable()
+		for _, dst := range strategy.Upcoming(periods) {
+			if err := c.create(ctx, dst, tmpl); err != nil {
+				errs = append(errs, fmt.Errorf("failed to create period table [ %s ] for [ %s ]: %w", dst.FullTable(), table, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Creator) create(ctx context.Context, dst sharding.Dst, tmpl string) error {
+	key := dst.FullTable()
+	if _, ok := c.created[key]; ok {
+		return nil
+	}
+
+	db, ok := c.dbs.Load(dst.DB)
+	if !ok {
+		return fmt.Errorf("failed to load database [ %s ]", dst.DB)
+	}
+
+	// 持有模板表的迁移锁复制表结构以及迁移历史，避免与迁移工具并发修改模板表时两者不一致。
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := schema.LockTable(tx, tmpl); err != nil {
+			return err
+		}
+		if err := tx.Exec(
+			"CREATE TABLE IF NOT EXISTS ? (LIKE ? INCLUDING ALL)",
+			clause.Table{Name: dst.TB}, clause.Table{Name: tmpl},
+		).Error; err != nil {
+			return err
+		}
+		return schema.CopyHistory(tx, tmpl, dst.TB)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.created[key] = struct{}{}
+	c.logger.Info("[hermet-time-table] period table is ready", slog.String("table", key))
+	return nil
+}
+
+// Run 按照 interval 定期创建之后 periods 个周期的周期表，直到 ctx 结束。
+func (c *Creator) Run(ctx context.Context, interval time.Duration, periods int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Ensure(ctx, periods); err != nil && ctx.Err() == nil {
+				c.logger.Error("[hermet-time-table] failed to create period tables", slog.Any("err", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package timetable
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen/snowflake"
+	"github.com/jrmarcco/jit/xsync"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	require.NoError(t, err)
+	return db, mock
+}
+
+// expectCreate 期望在一个事务中持有模板表的迁移锁，复制模板表的结构以及迁移历史。
+func expectCreate(mock sqlmock.Sqlmock, tb string, createErr error) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock(hashtext($1))")).
+		WithArgs("sharding_schema_history.message_template").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	create := mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "` + tb + `" (LIKE "message_template" INCLUDING ALL)`))
+	if createErr != nil {
+		create.WillReturnError(createErr)
+		mock.ExpectRollback()
+		return
+	}
+	create.WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS sharding_schema_history")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO sharding_schema_history (tb, version, description, applied_at) SELECT $1")).
+		WithArgs(tb, "message_template").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func newMessageSharding(t *testing.T, dbShardCount uint64) *sharding.TimeRangeSharding {
+	t.Helper()
+
+	strategy, err := sharding.NewTimeRangeSharding(snowflake.NewExtractor(), sharding.TimeRangeConfig{
+		DBPrefix:     "hermet",
+		TBPrefix:     "message",
+		DBShardCount: dbShardCount,
+		TBShardCount: 2,
+		Period:       sharding.TimePeriodMonth,
+	})
+	require.NoError(t, err)
+	return strategy
+}
+
+func TestCreator_Ensure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		dbShardCount uint64
+		createErr    error
+		wantErr      string
+		wantCreated  int
+	}{
+		{name: "all databases configured", dbShardCount: 2, wantCreated: 12},
+		{
+			name:         "database not configured",
+			dbShardCount: 3,
+			wantErr:      "failed to load database [ hermet_2 ]",
+			wantCreated:  12,
+		},
+		{
+			name:         "create failed",
+			dbShardCount: 2,
+			createErr:    errors.New("permission denied"),
+			wantErr:      "permission denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// 所有库共用一个连接，按照创建顺序校验执行的语句。
+			db, mock := newMockDB(t)
+			var dbs xsync.Map[string, *gorm.DB]
+			dbs.Store("hermet_0", db)
+			dbs.Store("hermet_1", db)
+
+			strategy := newMessageSharding(t, tt.dbShardCount)
+			for _, dst := range strategy.Upcoming(2) {
+				if _, ok := dbs.Load(dst.DB); ok {
+					expectCreate(mock, dst.TB, tt.createErr)
+				}
+			}
+
+			creator := NewCreator(&dbs, nil)
+			creator.Register("message", strategy)
+
+			// 当前周期 + 之后 2 个周期，每个周期每个库 2 张表。
+			err := creator.Ensure(t.Context(), 2)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Len(t, creator.created, tt.wantCreated)
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			if tt.createErr != nil {
+				return
+			}
+			// 已经创建的表不会重复创建。
+			_ = creator.Ensure(t.Context(), 2)
+			require.Len(t, creator.created, tt.wantCreated)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -3,6 +3,7 @@ package sharding
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 // ShardValExtractor 是提取分片值的接口。
@@ -10,6 +11,11 @@ type ShardValExtractor interface {
 	ExtractShardVal(id uint64) uint64
 }
 
+// TimeExtractor 是从 ID 中提取生成时间的接口，按时间分片的策略需要 extractor 同时实现此接口。
+type TimeExtractor interface {
+	ExtractTime(id uint64) time.Time
+}
+
 // Strategy 是分片策略的接口。
 // 负责根据分片值计算目标数据库和表的位置。
 //
@@ -41,6 +47,13 @@ type Strategy interface {
 	Broadcast() []Dst
 }
 
+// RangeBroadcaster 是支持按照时间范围广播的分片策略。
+// 数据按时间分表时，广播查询只需要访问时间范围内的分表。
+type RangeBroadcaster interface {
+	// BroadcastRange 返回 [from, to] 时间范围内的所有分库分表目标。
+	BroadcastRange(from, to time.Time) []Dst
+}
+
 // Dst 是分片的目标，包含目标数据库和表的完整信息。
 type Dst struct {
 	DBSuffix uint64 // 数据库后缀（数字）
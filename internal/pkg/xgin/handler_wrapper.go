@@ -162,6 +162,30 @@ func writeRes(ctx *gin.Context, res R, err error) {
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
+	if errors.Is(err, errs.ErrPermissionDenied) {
+		slog.Debug("permission denied", slog.Any("err", err))
+		ctx.PureJSON(http.StatusForbidden, gin.H{
+			"code": http.StatusForbidden,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, errs.ErrInvalidParam) {
+		slog.Debug("invalid param", slog.Any("err", err))
+		ctx.PureJSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, errs.ErrShardUnavailable) {
+		slog.Error("shard unavailable", slog.Any("err", err))
+		ctx.PureJSON(http.StatusServiceUnavailable, gin.H{
+			"code": http.StatusServiceUnavailable,
+			"msg":  err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		slog.Error("failed to handle request", slog.Any("err", err))
 		ctx.PureJSON(http.StatusInternalServerError, gin.H{
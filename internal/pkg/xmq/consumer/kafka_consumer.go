@@ -6,12 +6,21 @@ import (
 	"io"
 	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jrmarcco/hermet/internal/pkg/xmq"
 	"github.com/segmentio/kafka-go"
 )
 
-const defaultMessageChanSize = 1024
+const (
+	defaultMessageChanSize = 1024
+
+	defaultCommitInterval  = time.Second
+	defaultCommitBatchSize = 100
+
+	finalCommitTimeout = 5 * time.Second
+)
 
 // KafkaReaderCreateFunc 是创建 kafka Reader 的工厂函数。
 type KafkaReaderCreateFunc func(topic string, groupId string) *kafka.Reader
@@ -22,27 +31,41 @@ var _ ConsumerFactory = (*KafkaConsumerFactory)(nil)
 // 负责创建 kafka 消费者。
 type KafkaConsumerFactory struct {
 	readerCreateFunc KafkaReaderCreateFunc
+	cfg              Config
 }
 
-func NewKafkaConsumerFactory(readerCreateFunc KafkaReaderCreateFunc) *KafkaConsumerFactory {
+func NewKafkaConsumerFactory(readerCreateFunc KafkaReaderCreateFunc, cfg Config) *KafkaConsumerFactory {
 	return &KafkaConsumerFactory{
 		readerCreateFunc: readerCreateFunc,
+		cfg:              cfg,
 	}
 }
 
 func (f *KafkaConsumerFactory) NewConsumer(topic, groupID string) (Consumer, error) {
-	return NewKafkaConsumer(topic, groupID, f.readerCreateFunc), nil
+	return NewKafkaConsumer(topic, groupID, f.readerCreateFunc, f.cfg), nil
 }
 
 var _ Consumer = (*KafkaConsumer)(nil)
 
 // KafkaConsumer 是 kafka 消费者。
 // 负责从 kafka 中消费消息，并转换为 xmq.Message。
+//
+// 手动确认模式下使用 FetchMessage 拉取消息，由 Ack 记录处理完成的消息，
+// 后台协程按照 CommitInterval / CommitBatchSize 批量提交每个分区连续确认的最大 offset。
+// 消费者组发生 rebalance 后清空跟踪状态并丢弃尚未提交的位置，rebalance 之前拉取的消息的确认会被忽略，
+// 这些消息由重新分配到分区的消费者从已提交的位置重新消费，只会导致重复消费，不会丢失消息。
 type KafkaConsumer struct {
 	topic   string
 	groupID string
+	cfg     Config
+
+	reader  *kafka.Reader
+	tracker *offsetTracker
+	// genMu 保证检查 rebalance 和记录拉取 / 读取可提交位置之间不会切换代。
+	genMu sync.Mutex
 
-	reader *kafka.Reader
+	commitSignal chan struct{}
+	commitDone   chan struct{}
 
 	messageChan chan *xmq.Message
 
@@ -50,16 +73,29 @@ type KafkaConsumer struct {
 	ctx        context.Context
 	cancelFunc context.CancelFunc
 
+	acked atomic.Int64 // 上次提交之后可提交位置前进的次数
+
 	closeOnce sync.Once
 }
 
-func NewKafkaConsumer(topic, groupID string, readerCreateFunc KafkaReaderCreateFunc) *KafkaConsumer {
+func NewKafkaConsumer(topic, groupID string, readerCreateFunc KafkaReaderCreateFunc, cfg Config) *KafkaConsumer {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	if cfg.AckMode == "" {
+		cfg.AckMode = AckModeAuto
+	}
+	if cfg.CommitInterval <= 0 {
+		cfg.CommitInterval = defaultCommitInterval
+	}
+	if cfg.CommitBatchSize <= 0 {
+		cfg.CommitBatchSize = defaultCommitBatchSize
+	}
+
 	reader := readerCreateFunc(topic, groupID)
 	consumer := &KafkaConsumer{
 		topic:   topic,
 		groupID: groupID,
+		cfg:     cfg,
 
 		reader: reader,
 
@@ -69,6 +105,13 @@ func NewKafkaConsumer(topic, groupID string, readerCreateFunc KafkaReaderCreateF
 		cancelFunc: cancel,
 	}
 
+	if cfg.AckMode == AckModeManual {
+		consumer.tracker = newOffsetTracker()
+		consumer.commitSignal = make(chan struct{}, 1)
+		consumer.commitDone = make(chan struct{})
+		go consumer.commitLoop()
+	}
+
 	go consumer.readMessage()
 	return consumer
 }
@@ -76,7 +119,7 @@ func NewKafkaConsumer(topic, groupID string, readerCreateFunc KafkaReaderCreateF
 func (c *KafkaConsumer) Consume(ctx context.Context) (*xmq.Message, error) {
 	select {
 	case <-c.ctx.Done():
-		return nil, c.ctx.Err()
+		return nil, xmq.ErrConsumerClosed
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	case msg, ok := <-c.messageChan:
@@ -104,7 +147,7 @@ func (c *KafkaConsumer) readMessage() {
 	}()
 
 	for {
-		kafkaMsg, err := c.reader.ReadMessage(c.ctx)
+		kafkaMsg, generation, err := c.fetch()
 		if err != nil {
 			if errors.Is(err, io.ErrClosedPipe) || errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
 				return
@@ -114,6 +157,7 @@ func (c *KafkaConsumer) readMessage() {
 		}
 
 		msg := c.convertMessage(&kafkaMsg)
+		msg.Generation = generation
 		select {
 		case c.messageChan <- msg:
 		case <-c.ctx.Done():
@@ -122,6 +166,134 @@ func (c *KafkaConsumer) readMessage() {
 	}
 }
 
+// fetch 读取一条消息并返回拉取时的代，自动确认模式下读取后由 reader 自动提交。
+func (c *KafkaConsumer) fetch() (kafka.Message, int64, error) {
+	if c.tracker == nil {
+		kafkaMsg, err := c.reader.ReadMessage(c.ctx)
+		return kafkaMsg, 0, err
+	}
+
+	kafkaMsg, err := c.reader.FetchMessage(c.ctx)
+	if err != nil {
+		return kafkaMsg, 0, err
+	}
+
+	c.genMu.Lock()
+	defer c.genMu.Unlock()
+
+	c.syncGeneration()
+	return kafkaMsg, c.tracker.fetched(kafkaMsg.Partition, kafkaMsg.Offset), nil
+}
+
+// syncGeneration 检查消费者组是否发生了 rebalance ( 分区被撤销或者重新分配 )，
+// 发生 rebalance 时清空跟踪状态进入新的代，丢弃尚未提交的位置。
+// kafka reader 没有暴露分区分配的回调，这里通过 Stats 中的 rebalance 计数判断 ( 读取后计数清零 )。
+// 调用方需要持有 genMu。
+func (c *KafkaConsumer) syncGeneration() {
+	if c.reader.Stats().Rebalances == 0 {
+		return
+	}
+
+	generation := c.tracker.reset()
+	slog.Info(
+		"[hermet-xmq-consumer] consumer group rebalanced, dropping uncommitted offsets",
+		"topic", c.topic,
+		"group_id", c.groupID,
+		"generation", generation,
+	)
+}
+
+func (c *KafkaConsumer) Ack(_ context.Context, msg *xmq.Message) error {
+	if c.tracker == nil || msg.Topic != c.topic {
+		return nil
+	}
+
+	if c.tracker.ack(msg.Generation, msg.Partition, msg.Offset) && c.acked.Add(1) >= int64(c.cfg.CommitBatchSize) {
+		c.acked.Store(0)
+		select {
+		case c.commitSignal <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Nack 否认消息并关闭消费者。
+// kafka 不会在分区内重新投递单条消息，继续消费会使该分区永远无法提交，
+// 因此关闭时提交每个分区连续确认的位置 ( 停在这条消息之前 ) 并退出消费者组，
+// 由调用方重新创建消费者，从这条消息开始重新消费。
+func (c *KafkaConsumer) Nack(_ context.Context, msg *xmq.Message) error {
+	if c.tracker == nil || msg.Topic != c.topic {
+		return nil
+	}
+
+	c.tracker.nack(msg.Generation, msg.Partition, msg.Offset)
+	slog.Error(
+		"[hermet-xmq-consumer] message nacked, closing consumer to redeliver from the last committed offset",
+		"topic", msg.Topic,
+		"group_id", c.groupID,
+		"partition", msg.Partition,
+		"offset", msg.Offset,
+	)
+	return c.Close()
+}
+
+// commitLoop 定时或者累计确认数量达到阈值时批量提交，消费者关闭时最后提交一次。
+func (c *KafkaConsumer) commitLoop() {
+	defer close(c.commitDone)
+
+	ticker := time.NewTicker(c.cfg.CommitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.commit(c.ctx)
+		case <-c.commitSignal:
+			c.commit(c.ctx)
+		case <-c.ctx.Done():
+			// 这里必须使用 context.Background()，c.ctx 已经取消。
+			ctx, cancel := context.WithTimeout(context.Background(), finalCommitTimeout)
+			c.commit(ctx)
+			cancel()
+			return
+		}
+	}
+}
+
+func (c *KafkaConsumer) commit(ctx context.Context) {
+	c.genMu.Lock()
+	c.syncGeneration()
+	generation, pending := c.tracker.pending()
+	c.genMu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	msgs := make([]kafka.Message, 0, len(pending))
+	for partition, offset := range pending {
+		msgs = append(msgs, kafka.Message{
+			Topic:     c.topic,
+			Partition: partition,
+			Offset:    offset,
+		})
+	}
+
+	// 提交失败时保留状态，下次提交时重试。
+	if err := c.reader.CommitMessages(ctx, msgs...); err != nil {
+		slog.Warn(
+			"[hermet-xmq-consumer] failed to commit messages to kafka",
+			"topic", c.topic,
+			"group_id", c.groupID,
+			"err", err.Error(),
+		)
+		return
+	}
+	for partition, offset := range pending {
+		c.tracker.markCommitted(generation, partition, offset)
+	}
+}
+
 func (c *KafkaConsumer) convertMessage(kafkaMsg *kafka.Message) *xmq.Message {
 	headers := xmq.Headers{}
 	for _, header := range kafkaMsg.Headers {
@@ -144,6 +316,10 @@ func (c *KafkaConsumer) Close() error {
 	var err error
 	c.closeOnce.Do(func() {
 		c.cancelFunc()
+		if c.commitDone != nil {
+			// 关闭 reader 之前等待最后一次提交完成。
+			<-c.commitDone
+		}
 		err = c.reader.Close()
 	})
 	return err
@@ -0,0 +1,143 @@
+package consumer
+
+import (
+	"sync"
+)
+
+// offsetTracker 记录手动确认模式下每个分区已拉取和已确认的 offset，计算可以提交的位置。
+// 消息可能被不同的协程乱序确认，只有某个 offset 之前拉取的消息全部确认后才能提交该 offset。
+//
+// 消费者组发生 rebalance 时调用 reset 清空所有分区的状态并进入新的代，
+// 之前拉取的消息的确认和尚未提交的位置都会被丢弃，避免提交已经分配给其他消费者的分区。
+type offsetTracker struct {
+	mu         sync.Mutex
+	generation int64
+	partitions map[int]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	// inflight 已拉取但尚未提交的 offset，按拉取顺序 ( 递增 ) 排列。
+	inflight []int64
+	// done 已经处理完成的 offset，true 为确认，false 为否认。
+	done map[int64]bool
+
+	lastFetched int64
+	committable int64 // 可以提交的最大 offset，-1 表示没有
+	committed   int64 // 已经提交的最大 offset，-1 表示没有
+}
+
+func newPartitionOffsets() *partitionOffsets {
+	return &partitionOffsets{
+		done:        make(map[int64]bool),
+		lastFetched: -1,
+		committable: -1,
+		committed:   -1,
+	}
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[int]*partitionOffsets),
+	}
+}
+
+// reset 清空所有分区的状态并进入新的代，返回新的代。
+func (t *offsetTracker) reset() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.generation++
+	t.partitions = make(map[int]*partitionOffsets)
+	return t.generation
+}
+
+// fetched 记录拉取到的消息，返回当前的代。
+// offset 没有递增说明分区发生了 rebalance 或者 offset 被重置，消息会从已提交的位置重新投递，
+// 此时丢弃该分区的所有状态，已拉取但未确认的消息会被重新处理。
+func (t *offsetTracker) fetched(partition int, offset int64) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[partition]
+	if !ok || offset <= p.lastFetched {
+		p = newPartitionOffsets()
+		t.partitions[partition] = p
+	}
+	p.lastFetched = offset
+	p.inflight = append(p.inflight, offset)
+	return t.generation
+}
+
+// ack 确认消息，返回可提交位置是否前进。
+func (t *offsetTracker) ack(generation int64, partition int, offset int64) bool {
+	return t.complete(generation, partition, offset, true)
+}
+
+// nack 否认消息。
+// 被否认的消息不会提交，该分区的可提交位置停在这条消息之前。
+// kafka 不会在分区内重新投递单条消息，否认之后消费者随即关闭 ( 见 KafkaConsumer.Nack )，
+// 跟踪状态不会因为分区长期无法提交而持续增长。
+func (t *offsetTracker) nack(generation int64, partition int, offset int64) {
+	t.complete(generation, partition, offset, false)
+}
+
+func (t *offsetTracker) complete(generation int64, partition int, offset int64, acked bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// 消息在 rebalance 之前拉取，分区可能已经分配给其他消费者，忽略。
+	if generation != t.generation {
+		return false
+	}
+	p, ok := t.partitions[partition]
+	// 分区状态已经重置，或者消息已经提交，忽略。
+	if !ok || len(p.inflight) == 0 || offset < p.inflight[0] || offset > p.lastFetched {
+		return false
+	}
+	if _, ok := p.done[offset]; ok {
+		return false
+	}
+	p.done[offset] = acked
+
+	advanced := false
+	for len(p.inflight) > 0 {
+		head := p.inflight[0]
+		if !p.done[head] {
+			// 未完成或者被否认。
+			break
+		}
+		delete(p.done, head)
+		p.inflight = p.inflight[1:]
+		p.committable = head
+		advanced = true
+	}
+	return advanced
+}
+
+// pending 返回当前的代以及可以提交但尚未提交的位置 ( partition -> offset )。
+func (t *offsetTracker) pending() (int64, map[int]int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make(map[int]int64)
+	for partition, p := range t.partitions {
+		if p.committable > p.committed {
+			res[partition] = p.committable
+		}
+	}
+	return t.generation, res
+}
+
+// markCommitted 记录已经提交的位置。
+func (t *offsetTracker) markCommitted(generation int64, partition int, offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// 提交期间分区可能已经重置，此时不能更新新的状态。
+	if generation != t.generation {
+		return
+	}
+	if p, ok := t.partitions[partition]; ok && offset <= p.committable && offset > p.committed {
+		p.committed = offset
+	}
+}
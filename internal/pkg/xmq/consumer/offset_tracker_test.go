@@ -0,0 +1,197 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type trackerOp struct {
+	ack    bool
+	offset int64
+	// advanced 确认之后可提交位置是否前进，否认时忽略。
+	advanced bool
+}
+
+// pendingOf 返回可以提交但尚未提交的位置，忽略代。
+func pendingOf(tracker *offsetTracker) map[int]int64 {
+	_, pending := tracker.pending()
+	return pending
+}
+
+func TestOffsetTracker_Complete(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		fetched []int64
+		ops     []trackerOp
+		want    map[int]int64
+	}{
+		{
+			name:    "in order",
+			fetched: []int64{10, 11, 12},
+			ops: []trackerOp{
+				{ack: true, offset: 10, advanced: true},
+				{ack: true, offset: 11, advanced: true},
+			},
+			want: map[int]int64{0: 11},
+		},
+		{
+			name:    "out of order",
+			fetched: []int64{10, 11, 12},
+			ops: []trackerOp{
+				{ack: true, offset: 12},
+				{ack: true, offset: 11},
+				// 最早的消息确认后可提交位置一次前进到最后一条连续确认的消息。
+				{ack: true, offset: 10, advanced: true},
+			},
+			want: map[int]int64{0: 12},
+		},
+		{
+			name:    "head not acked",
+			fetched: []int64{10, 11, 12},
+			ops: []trackerOp{
+				{ack: true, offset: 11},
+				{ack: true, offset: 12},
+			},
+			want: map[int]int64{},
+		},
+		{
+			name:    "nack stops the watermark",
+			fetched: []int64{10, 11, 12, 13},
+			ops: []trackerOp{
+				{ack: true, offset: 10, advanced: true},
+				{offset: 11},
+				{ack: true, offset: 12},
+				{ack: true, offset: 13},
+			},
+			want: map[int]int64{0: 10},
+		},
+		{
+			name:    "duplicate or unknown offset ignored",
+			fetched: []int64{10, 11},
+			ops: []trackerOp{
+				{ack: true, offset: 10, advanced: true},
+				{ack: true, offset: 10},
+				{ack: true, offset: 9},
+				{ack: true, offset: 12},
+			},
+			want: map[int]int64{0: 10},
+		},
+		{
+			name:    "acked after nack ignored",
+			fetched: []int64{10, 11},
+			ops: []trackerOp{
+				{offset: 10},
+				{ack: true, offset: 10},
+				{ack: true, offset: 11},
+			},
+			want: map[int]int64{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tracker := newOffsetTracker()
+			for _, offset := range tc.fetched {
+				tracker.fetched(0, offset)
+			}
+			for _, op := range tc.ops {
+				if !op.ack {
+					tracker.nack(0, 0, op.offset)
+					continue
+				}
+				require.Equal(t, op.advanced, tracker.ack(0, 0, op.offset), "ack offset %d", op.offset)
+			}
+			require.Equal(t, tc.want, pendingOf(tracker))
+		})
+	}
+}
+
+func TestOffsetTracker_Partitions(t *testing.T) {
+	t.Parallel()
+
+	tracker := newOffsetTracker()
+	tracker.fetched(0, 10)
+	tracker.fetched(1, 20)
+	tracker.fetched(0, 11)
+	tracker.fetched(1, 21)
+
+	// 分区之间互不影响。
+	tracker.nack(0, 0, 10)
+	require.True(t, tracker.ack(0, 1, 20))
+	require.False(t, tracker.ack(0, 0, 11))
+	require.Equal(t, map[int]int64{1: 20}, pendingOf(tracker))
+}
+
+func TestOffsetTracker_Watermark(t *testing.T) {
+	t.Parallel()
+
+	tracker := newOffsetTracker()
+	for offset := int64(10); offset < 14; offset++ {
+		tracker.fetched(0, offset)
+	}
+
+	require.True(t, tracker.ack(0, 0, 10))
+	require.True(t, tracker.ack(0, 0, 11))
+	require.Equal(t, map[int]int64{0: 11}, pendingOf(tracker))
+
+	// 提交之后不再重复提交，直到可提交位置继续前进。
+	tracker.markCommitted(0, 0, 11)
+	require.Empty(t, pendingOf(tracker))
+
+	// 提交位置不会回退，也不会超过可提交位置。
+	tracker.markCommitted(0, 0, 10)
+	tracker.markCommitted(0, 0, 13)
+	require.Empty(t, pendingOf(tracker))
+
+	require.True(t, tracker.ack(0, 0, 12))
+	require.Equal(t, map[int]int64{0: 12}, pendingOf(tracker))
+
+	// 提交期间分区发生 rebalance ( offset 没有递增 )，旧的提交结果不会更新新的状态。
+	tracker.fetched(0, 11)
+	tracker.markCommitted(0, 0, 12)
+	require.Empty(t, pendingOf(tracker))
+	require.True(t, tracker.ack(0, 0, 11))
+	require.Equal(t, map[int]int64{0: 11}, pendingOf(tracker))
+
+	// 重置之前拉取的消息确认时忽略。
+	require.False(t, tracker.ack(0, 0, 13))
+	require.Equal(t, map[int]int64{0: 11}, pendingOf(tracker))
+}
+
+func TestOffsetTracker_Reset(t *testing.T) {
+	t.Parallel()
+
+	tracker := newOffsetTracker()
+	oldGen := tracker.fetched(0, 10)
+	tracker.fetched(0, 11)
+	tracker.fetched(1, 20)
+	require.True(t, tracker.ack(oldGen, 0, 10))
+	require.True(t, tracker.ack(oldGen, 1, 20))
+	gen, pending := tracker.pending()
+	require.Equal(t, oldGen, gen)
+	require.Equal(t, map[int]int64{0: 10, 1: 20}, pending)
+
+	// rebalance 之后丢弃尚未提交的位置 ( 分区 1 已经分配给其他消费者 )。
+	newGen := tracker.reset()
+	require.NotEqual(t, oldGen, newGen)
+	require.Empty(t, pendingOf(tracker))
+
+	// 分区 0 重新分配给本消费者，从已提交的位置重新拉取。
+	require.Equal(t, newGen, tracker.fetched(0, 10))
+	tracker.fetched(0, 11)
+
+	// 旧的代拉取的消息的确认和提交结果被忽略。
+	require.False(t, tracker.ack(oldGen, 0, 10))
+	tracker.markCommitted(oldGen, 0, 10)
+	require.Empty(t, pendingOf(tracker))
+
+	require.True(t, tracker.ack(newGen, 0, 10))
+	require.Equal(t, map[int]int64{0: 10}, pendingOf(tracker))
+	tracker.markCommitted(newGen, 0, 10)
+	require.Empty(t, pendingOf(tracker))
+}
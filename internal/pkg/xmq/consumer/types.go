@@ -2,6 +2,7 @@ package consumer
 
 import (
 	"context"
+	"time"
 
 	"github.com/jrmarcco/hermet/internal/pkg/xmq"
 )
@@ -14,5 +15,35 @@ type ConsumerFactory interface {
 type Consumer interface {
 	Consume(ctx context.Context) (*xmq.Message, error)
 	ConsumeChan(ctx context.Context) (<-chan *xmq.Message, error)
+
+	// Ack 确认消息处理完成，消息可以提交。
+	// 自动确认模式下不做任何处理。
+	Ack(ctx context.Context, msg *xmq.Message) error
+	// Nack 否认消息，消息不会提交。
+	// 手动确认模式下消费者随即关闭 ( Consume 返回 xmq.ErrConsumerClosed )，
+	// 重新创建的消费者从最后一次提交的位置 ( 不晚于这条消息 ) 重新消费。
+	// 自动确认模式下不做任何处理。
+	Nack(ctx context.Context, msg *xmq.Message) error
+
 	Close() error
 }
+
+// AckMode 消息确认模式。
+type AckMode string
+
+const (
+	// AckModeAuto 读取消息后由 kafka reader 按照 CommitInterval 自动提交，
+	// 消息可能在处理完成之前提交，进程崩溃时会丢失消息。
+	AckModeAuto AckMode = "auto"
+	// AckModeManual 消息处理完成并调用 Ack 之后才会提交，进程崩溃时会重复消费，不会丢失消息。
+	AckModeManual AckMode = "manual"
+)
+
+// Config 消费者配置。
+type Config struct {
+	AckMode AckMode
+
+	// 手动确认模式下批量提交的配置，满足任意一个条件时提交。
+	CommitInterval  time.Duration // 提交间隔，<= 0 时为 1s
+	CommitBatchSize int           // 累计确认的消息数，<= 0 时为 100
+}
@@ -0,0 +1,169 @@
+package memory
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+)
+
+const (
+	defaultPartitions  = 4
+	defaultMaxMessages = 100_000
+)
+
+// Config 内存 broker 配置。
+type Config struct {
+	Partitions  int // 自动创建 topic 时的分区数
+	MaxMessages int // 每个分区最多保留的消息数，超过时丢弃最早的一半消息
+}
+
+// Broker 内存消息队列，用于测试和单节点开发环境。
+//
+// 支持 topic / 分区 / 消费者组 / offset:
+//   - topic 在第一次生产或消费时自动创建；
+//   - 相同 key 的消息写入同一个分区，key 为空时轮询写入；
+//   - 同一个消费者组的消费者平分分区，消费者加入或退出时重新分配 ( rebalance )；
+//   - 新的消费者组从最早的消息开始消费，已提交的 offset 在 broker 关闭前一直保留。
+//
+// 消息只保存在内存中，进程退出后全部丢失。
+type Broker struct {
+	cfg Config
+
+	mu     sync.Mutex
+	topics map[string]*topic
+	closed bool
+	// notify 有新消息、rebalance 或关闭时关闭并替换，用于唤醒等待中的消费者。
+	notify chan struct{}
+}
+
+type topic struct {
+	name       string
+	partitions []*partition
+	groups     map[string]*group
+	next       uint64 // key 为空时轮询写入的分区
+}
+
+type partition struct {
+	base int64 // msgs[0] 的 offset
+	msgs []*xmq.Message
+}
+
+// end 返回下一条写入消息的 offset。
+func (p *partition) end() int64 {
+	return p.base + int64(len(p.msgs))
+}
+
+func NewBroker(cfg Config) *Broker {
+	if cfg.Partitions <= 0 {
+		cfg.Partitions = defaultPartitions
+	}
+	if cfg.MaxMessages <= 0 {
+		cfg.MaxMessages = defaultMaxMessages
+	}
+	return &Broker{
+		cfg:    cfg,
+		topics: make(map[string]*topic),
+		notify: make(chan struct{}),
+	}
+}
+
+// Close 关闭 broker，所有消费者返回 xmq.ErrConsumerClosed，生产者返回 xmq.ErrProducerClosed。
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	b.broadcastLocked()
+}
+
+// topicLocked 返回 topic，不存在时创建，调用方需要持有锁。
+func (b *Broker) topicLocked(name string) *topic {
+	t, ok := b.topics[name]
+	if ok {
+		return t
+	}
+
+	t = &topic{
+		name:       name,
+		partitions: make([]*partition, b.cfg.Partitions),
+		groups:     make(map[string]*group),
+	}
+	for i := range t.partitions {
+		t.partitions[i] = &partition{}
+	}
+	b.topics[name] = t
+	return t
+}
+
+// broadcastLocked 唤醒所有等待中的消费者，调用方需要持有锁。
+func (b *Broker) broadcastLocked() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// append 写入消息，返回消息所在的分区和 offset。
+func (b *Broker) append(msg *xmq.Message) (int, int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return 0, 0, xmq.ErrProducerClosed
+	}
+
+	t := b.topicLocked(msg.Topic)
+
+	var idx int
+	if len(msg.Key) == 0 {
+		idx = int(t.next % uint64(len(t.partitions)))
+		t.next++
+	} else {
+		idx = int(xxhash.Sum64(msg.Key) % uint64(len(t.partitions)))
+	}
+
+	p := t.partitions[idx]
+	offset := p.end()
+	p.msgs = append(p.msgs, &xmq.Message{
+		Headers:   maps.Clone(msg.Headers),
+		Topic:     msg.Topic,
+		Partition: idx,
+		Offset:    offset,
+		Key:       msg.Key,
+		Val:       msg.Val,
+	})
+
+	// 超过保留数量时丢弃最早的一半消息，offset 保持不变。
+	if len(p.msgs) > b.cfg.MaxMessages {
+		drop := len(p.msgs) / 2
+		p.msgs = append([]*xmq.Message(nil), p.msgs[drop:]...)
+		p.base += int64(drop)
+	}
+
+	b.broadcastLocked()
+	return idx, offset, nil
+}
+
+// Offsets 返回消费者组在 topic 每个分区上已提交的 offset ( 下一条要消费的消息 )，用于测试断言。
+func (b *Broker) Offsets(topicName, groupID string) []int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t, ok := b.topics[topicName]
+	if !ok {
+		return nil
+	}
+	g, ok := t.groups[groupID]
+	if !ok {
+		return nil
+	}
+
+	res := make([]int64, len(g.partitions))
+	for i, gp := range g.partitions {
+		res[i] = gp.committed
+	}
+	return res
+}
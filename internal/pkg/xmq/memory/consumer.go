@@ -0,0 +1,219 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sync"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/consumer"
+)
+
+var _ consumer.ConsumerFactory = (*ConsumerFactory)(nil)
+
+// ConsumerFactory 内存消费者工厂。
+type ConsumerFactory struct {
+	broker  *Broker
+	ackMode consumer.AckMode
+}
+
+func NewConsumerFactory(broker *Broker, ackMode consumer.AckMode) *ConsumerFactory {
+	if ackMode == "" {
+		ackMode = consumer.AckModeAuto
+	}
+	return &ConsumerFactory{
+		broker:  broker,
+		ackMode: ackMode,
+	}
+}
+
+func (f *ConsumerFactory) NewConsumer(topic, groupID string) (consumer.Consumer, error) {
+	return newConsumer(f.broker, topic, groupID, f.ackMode)
+}
+
+var _ consumer.Consumer = (*Consumer)(nil)
+
+// Consumer 内存消费者。
+// 自动确认模式下拉取即提交，手动确认模式下 Ack 之后按照 offset 连续提交。
+type Consumer struct {
+	broker  *Broker
+	topic   string
+	group   *group
+	ackMode consumer.AckMode
+
+	next int // 下一次从哪个分区开始拉取，避免某个分区饿死
+
+	closed    bool
+	closeOnce sync.Once
+
+	chOnce sync.Once
+	ch     chan *xmq.Message
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newConsumer(b *Broker, topicName, groupID string, ackMode consumer.AckMode) (*Consumer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, xmq.ErrConsumerClosed
+	}
+
+	t := b.topicLocked(topicName)
+	g, ok := t.groups[groupID]
+	if !ok {
+		g = newGroup(groupID, len(t.partitions))
+		t.groups[groupID] = g
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Consumer{
+		broker:  b,
+		topic:   topicName,
+		group:   g,
+		ackMode: ackMode,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	g.join(c)
+	b.broadcastLocked()
+	return c, nil
+}
+
+func (c *Consumer) Consume(ctx context.Context) (*xmq.Message, error) {
+	for {
+		c.broker.mu.Lock()
+		if c.closed || c.broker.closed {
+			c.broker.mu.Unlock()
+			return nil, xmq.ErrConsumerClosed
+		}
+
+		if msg := c.pollLocked(); msg != nil {
+			c.broker.mu.Unlock()
+			return msg, nil
+		}
+		notify := c.broker.notify
+		c.broker.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-c.ctx.Done():
+			return nil, xmq.ErrConsumerClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// pollLocked 从分配给本消费者的分区中拉取一条消息，调用方需要持有锁。
+func (c *Consumer) pollLocked() *xmq.Message {
+	t := c.broker.topics[c.topic]
+	n := len(c.group.partitions)
+	for i := range n {
+		idx := (c.next + i) % n
+		gp := c.group.partitions[idx]
+		if gp.owner != c {
+			continue
+		}
+
+		p := t.partitions[idx]
+		// 消息已经超过保留数量被丢弃，从最早保留的消息开始。
+		if gp.position < p.base {
+			gp.position = p.base
+			if gp.committed < p.base {
+				gp.committed = p.base
+				clear(gp.done)
+			}
+		}
+		if gp.position >= p.end() {
+			continue
+		}
+
+		stored := p.msgs[gp.position-p.base]
+		gp.position++
+		if c.ackMode == consumer.AckModeAuto {
+			gp.committed = gp.position
+		}
+		c.next = idx + 1
+
+		msg := *stored
+		msg.Headers = maps.Clone(stored.Headers)
+		return &msg
+	}
+	return nil
+}
+
+func (c *Consumer) ConsumeChan(ctx context.Context) (<-chan *xmq.Message, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	c.chOnce.Do(func() {
+		c.ch = make(chan *xmq.Message)
+		go func() {
+			defer close(c.ch)
+			for {
+				msg, err := c.Consume(c.ctx)
+				if err != nil {
+					return
+				}
+				select {
+				case c.ch <- msg:
+				case <-c.ctx.Done():
+					return
+				}
+			}
+		}()
+	})
+	return c.ch, nil
+}
+
+func (c *Consumer) Ack(_ context.Context, msg *xmq.Message) error {
+	c.complete(msg, true)
+	return nil
+}
+
+// Nack 否认消息，手动确认模式下与 kafka 消费者一致，关闭消费者并退出消费者组，
+// 重新创建的消费者从这条消息开始重新消费。
+func (c *Consumer) Nack(_ context.Context, msg *xmq.Message) error {
+	if c.ackMode != consumer.AckModeManual || msg.Topic != c.topic {
+		return nil
+	}
+	c.complete(msg, false)
+	return c.Close()
+}
+
+func (c *Consumer) complete(msg *xmq.Message, acked bool) {
+	if c.ackMode != consumer.AckModeManual || msg.Topic != c.topic {
+		return
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	if msg.Partition < 0 || msg.Partition >= len(c.group.partitions) {
+		return
+	}
+	gp := c.group.partitions[msg.Partition]
+	// 分区已经分配给其他消费者，消息会被重新投递。
+	if gp.owner != c {
+		return
+	}
+	gp.complete(msg.Offset, acked)
+}
+
+// Close 退出消费者组，分配给本消费者的分区重新分配给组内其他消费者。
+func (c *Consumer) Close() error {
+	c.closeOnce.Do(func() {
+		c.cancel()
+
+		c.broker.mu.Lock()
+		defer c.broker.mu.Unlock()
+
+		c.closed = true
+		c.group.leave(c)
+		c.broker.broadcastLocked()
+	})
+	return nil
+}
@@ -0,0 +1,335 @@
+package memory
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/consumer"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTopic = "order"
+	testGroup = "hermet"
+
+	waitTimeout = 3 * time.Second
+)
+
+func newTestBroker(t *testing.T, cfg Config) (*Broker, *Producer) {
+	t.Helper()
+
+	b := NewBroker(cfg)
+	t.Cleanup(b.Close)
+	return b, NewProducer(b)
+}
+
+func newTestConsumer(t *testing.T, b *Broker, groupID string, ackMode consumer.AckMode) consumer.Consumer {
+	t.Helper()
+
+	c, err := NewConsumerFactory(b, ackMode).NewConsumer(testTopic, groupID)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// produceN 写入 n 条相同 key 的消息。
+func produceN(t *testing.T, p *Producer, key string, n int) {
+	t.Helper()
+
+	for i := range n {
+		require.NoError(t, p.Produce(t.Context(), &xmq.Message{
+			Topic:   testTopic,
+			Key:     []byte(key),
+			Val:     []byte(strconv.Itoa(i)),
+			Headers: map[string]string{"seq": strconv.Itoa(i)},
+		}))
+	}
+}
+
+// consumeN 拉取 n 条消息。
+func consumeN(t *testing.T, c consumer.Consumer, n int) []*xmq.Message {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(t.Context(), waitTimeout)
+	defer cancel()
+
+	msgs := make([]*xmq.Message, 0, n)
+	for range n {
+		msg, err := c.Consume(ctx)
+		require.NoError(t, err)
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// requireNoMessage 断言短时间内没有可以拉取的消息。
+func requireNoMessage(t *testing.T, c consumer.Consumer) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := c.Consume(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func sum(offsets []int64) int64 {
+	var res int64
+	for _, offset := range offsets {
+		res += offset
+	}
+	return res
+}
+
+func TestProducer_Partition(t *testing.T) {
+	t.Parallel()
+
+	b, p := newTestBroker(t, Config{Partitions: 4})
+	c := newTestConsumer(t, b, testGroup, consumer.AckModeAuto)
+
+	// 相同 key 的消息写入同一个分区，offset 连续。
+	produceN(t, p, "user-1", 5)
+	msgs := consumeN(t, c, 5)
+	for i, msg := range msgs {
+		require.Equal(t, msgs[0].Partition, msg.Partition)
+		require.Equal(t, int64(i), msg.Offset)
+		require.Equal(t, strconv.Itoa(i), string(msg.Val))
+		require.Equal(t, strconv.Itoa(i), msg.Headers["seq"])
+	}
+
+	// key 为空时轮询写入所有分区。
+	produceN(t, p, "", 4)
+	partitions := make(map[int]struct{})
+	for _, msg := range consumeN(t, c, 4) {
+		partitions[msg.Partition] = struct{}{}
+	}
+	require.Len(t, partitions, 4)
+}
+
+func TestProducer_ProduceBatch(t *testing.T) {
+	t.Parallel()
+
+	b, p := newTestBroker(t, Config{Partitions: 2})
+	c := newTestConsumer(t, b, testGroup, consumer.AckModeAuto)
+
+	require.NoError(t, p.ProduceBatch(t.Context(), []*xmq.Message{
+		{Topic: testTopic, Val: []byte("a")},
+		{Topic: testTopic, Val: []byte("b")},
+	}))
+	require.Len(t, consumeN(t, c, 2), 2)
+
+	// broker 关闭之后每条消息都返回错误。
+	b.Close()
+	var batchErr produce.BatchError
+	err := p.ProduceBatch(t.Context(), []*xmq.Message{{Topic: testTopic}, {Topic: testTopic}})
+	require.ErrorAs(t, err, &batchErr)
+	require.Len(t, batchErr, 2)
+	for _, err := range batchErr {
+		require.ErrorIs(t, err, xmq.ErrProducerClosed)
+	}
+}
+
+func TestConsumer_Groups(t *testing.T) {
+	t.Parallel()
+
+	b, p := newTestBroker(t, Config{Partitions: 2})
+	c1 := newTestConsumer(t, b, "group-1", consumer.AckModeAuto)
+	c2 := newTestConsumer(t, b, "group-2", consumer.AckModeAuto)
+
+	// 每个消费者组都消费全部消息。
+	produceN(t, p, "", 4)
+	require.Len(t, consumeN(t, c1, 4), 4)
+	require.Len(t, consumeN(t, c2, 4), 4)
+
+	// 新的消费者组从最早的消息开始消费。
+	c3 := newTestConsumer(t, b, "group-3", consumer.AckModeAuto)
+	require.Len(t, consumeN(t, c3, 4), 4)
+	requireNoMessage(t, c3)
+
+	require.Nil(t, b.Offsets(testTopic, "unknown"))
+	require.Nil(t, b.Offsets("unknown", "group-1"))
+}
+
+func TestConsumer_Rebalance(t *testing.T) {
+	t.Parallel()
+
+	b, p := newTestBroker(t, Config{Partitions: 4})
+	c1 := newTestConsumer(t, b, testGroup, consumer.AckModeManual)
+	c2 := newTestConsumer(t, b, testGroup, consumer.AckModeManual)
+
+	// 两个消费者平分分区，每个分区只属于一个消费者。
+	produceN(t, p, "", 8)
+	owners := make(map[int]consumer.Consumer)
+	var c1Msgs []*xmq.Message
+	for _, c := range []consumer.Consumer{c1, c2} {
+		msgs := consumeN(t, c, 4)
+		for _, msg := range msgs {
+			owner, ok := owners[msg.Partition]
+			require.True(t, !ok || owner == c)
+			owners[msg.Partition] = c
+		}
+		requireNoMessage(t, c)
+		if c == c1 {
+			c1Msgs = msgs
+		}
+	}
+	require.Len(t, owners, 4)
+
+	// c1 确认自己拉取的消息，c2 不确认。
+	for _, msg := range c1Msgs {
+		require.NoError(t, c1.Ack(t.Context(), msg))
+	}
+	require.EqualValues(t, 4, sum(b.Offsets(testTopic, testGroup)))
+
+	// c2 退出后分区重新分配给 c1，从已提交的 offset 开始消费，c2 没有确认的消息重新投递。
+	require.NoError(t, c2.Close())
+	_, err := c2.Consume(t.Context())
+	require.ErrorIs(t, err, xmq.ErrConsumerClosed)
+
+	for _, msg := range consumeN(t, c1, 4) {
+		require.Equal(t, c2, owners[msg.Partition])
+	}
+	requireNoMessage(t, c1)
+}
+
+func TestConsumer_AutoCommit(t *testing.T) {
+	t.Parallel()
+
+	b, p := newTestBroker(t, Config{Partitions: 2})
+	c := newTestConsumer(t, b, testGroup, consumer.AckModeAuto)
+
+	produceN(t, p, "", 4)
+	consumeN(t, c, 3)
+	// 自动确认模式下拉取即提交，Nack 不影响 offset。
+	require.EqualValues(t, 3, sum(b.Offsets(testTopic, testGroup)))
+	require.NoError(t, c.Nack(t.Context(), &xmq.Message{Topic: testTopic}))
+
+	// 重新加入的消费者从已提交的 offset 开始，只剩 1 条消息。
+	require.NoError(t, c.Close())
+	c = newTestConsumer(t, b, testGroup, consumer.AckModeAuto)
+	consumeN(t, c, 1)
+	requireNoMessage(t, c)
+	require.EqualValues(t, 4, sum(b.Offsets(testTopic, testGroup)))
+}
+
+func TestConsumer_ManualCommit(t *testing.T) {
+	t.Parallel()
+
+	b, p := newTestBroker(t, Config{Partitions: 1})
+	c := newTestConsumer(t, b, testGroup, consumer.AckModeManual)
+
+	produceN(t, p, "", 4)
+	msgs := consumeN(t, c, 4)
+	require.Equal(t, []int64{0}, b.Offsets(testTopic, testGroup))
+
+	// 乱序确认时只提交连续确认的 offset。
+	require.NoError(t, c.Ack(t.Context(), msgs[1]))
+	require.NoError(t, c.Ack(t.Context(), msgs[2]))
+	require.Equal(t, []int64{0}, b.Offsets(testTopic, testGroup))
+
+	require.NoError(t, c.Ack(t.Context(), msgs[0]))
+	require.Equal(t, []int64{3}, b.Offsets(testTopic, testGroup))
+
+	// 重复确认和其他 topic 的消息被忽略。
+	require.NoError(t, c.Ack(t.Context(), msgs[0]))
+	require.NoError(t, c.Ack(t.Context(), &xmq.Message{Topic: "other", Offset: 3}))
+	require.Equal(t, []int64{3}, b.Offsets(testTopic, testGroup))
+
+	require.NoError(t, c.Ack(t.Context(), msgs[3]))
+	require.Equal(t, []int64{4}, b.Offsets(testTopic, testGroup))
+}
+
+func TestConsumer_Nack(t *testing.T) {
+	t.Parallel()
+
+	b, p := newTestBroker(t, Config{Partitions: 1})
+	c := newTestConsumer(t, b, testGroup, consumer.AckModeManual)
+
+	produceN(t, p, "", 3)
+	msgs := consumeN(t, c, 3)
+	require.NoError(t, c.Ack(t.Context(), msgs[0]))
+	require.NoError(t, c.Ack(t.Context(), msgs[2]))
+
+	// 否认消息后消费者关闭，否认的消息阻塞提交。
+	require.NoError(t, c.Nack(t.Context(), msgs[1]))
+	_, err := c.Consume(t.Context())
+	require.ErrorIs(t, err, xmq.ErrConsumerClosed)
+	require.Equal(t, []int64{1}, b.Offsets(testTopic, testGroup))
+
+	// 重新创建的消费者从否认的消息开始重新消费。
+	c = newTestConsumer(t, b, testGroup, consumer.AckModeManual)
+	redelivered := consumeN(t, c, 2)
+	require.Equal(t, int64(1), redelivered[0].Offset)
+	require.Equal(t, int64(2), redelivered[1].Offset)
+}
+
+func TestConsumer_ConsumeChan(t *testing.T) {
+	t.Parallel()
+
+	b, p := newTestBroker(t, Config{Partitions: 2})
+	c := newTestConsumer(t, b, testGroup, consumer.AckModeAuto)
+
+	ch, err := c.ConsumeChan(t.Context())
+	require.NoError(t, err)
+
+	produceN(t, p, "", 3)
+	for range 3 {
+		select {
+		case msg := <-ch:
+			require.Equal(t, testTopic, msg.Topic)
+		case <-time.After(waitTimeout):
+			t.Fatal("timeout waiting for message")
+		}
+	}
+
+	// 关闭消费者后通道关闭。
+	require.NoError(t, c.Close())
+	require.Eventually(t, func() bool {
+		_, ok := <-ch
+		return !ok
+	}, waitTimeout, 5*time.Millisecond)
+}
+
+func TestBroker_MaxMessages(t *testing.T) {
+	t.Parallel()
+
+	b, p := newTestBroker(t, Config{Partitions: 1, MaxMessages: 4})
+
+	// 超过保留数量时丢弃最早的一半消息，offset 保持不变。
+	produceN(t, p, "", 5)
+	c := newTestConsumer(t, b, testGroup, consumer.AckModeAuto)
+	msgs := consumeN(t, c, 3)
+	require.Equal(t, int64(2), msgs[0].Offset)
+	require.Equal(t, int64(4), msgs[2].Offset)
+	require.Equal(t, []int64{5}, b.Offsets(testTopic, testGroup))
+}
+
+func TestBroker_Close(t *testing.T) {
+	t.Parallel()
+
+	b, p := newTestBroker(t, Config{})
+	c := newTestConsumer(t, b, testGroup, consumer.AckModeAuto)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := c.Consume(context.Background())
+		errCh <- err
+	}()
+
+	// 关闭 broker 唤醒等待中的消费者。
+	b.Close()
+	select {
+	case err := <-errCh:
+		require.ErrorIs(t, err, xmq.ErrConsumerClosed)
+	case <-time.After(waitTimeout):
+		t.Fatal("timeout waiting for consumer")
+	}
+
+	require.ErrorIs(t, p.Produce(t.Context(), &xmq.Message{Topic: testTopic}), xmq.ErrProducerClosed)
+	_, err := NewConsumerFactory(b, "").NewConsumer(testTopic, testGroup)
+	require.ErrorIs(t, err, xmq.ErrConsumerClosed)
+}
@@ -0,0 +1,77 @@
+package memory
+
+// group 消费者组在一个 topic 上的状态。
+type group struct {
+	id         string
+	members    []*Consumer
+	partitions []*groupPartition
+}
+
+// groupPartition 消费者组在一个分区上的消费状态。
+type groupPartition struct {
+	owner *Consumer
+
+	committed int64          // 已提交的 offset ( 下一条要消费的消息 )
+	position  int64          // 下一条要拉取的消息
+	done      map[int64]bool // 已经处理完成但尚未提交的 offset，true 为确认，false 为否认
+}
+
+func newGroup(id string, partitions int) *group {
+	g := &group{
+		id:         id,
+		partitions: make([]*groupPartition, partitions),
+	}
+	for i := range g.partitions {
+		g.partitions[i] = &groupPartition{done: make(map[int64]bool)}
+	}
+	return g
+}
+
+// join 加入消费者组并重新分配分区。
+func (g *group) join(c *Consumer) {
+	g.members = append(g.members, c)
+	g.rebalance()
+}
+
+// leave 退出消费者组并重新分配分区。
+func (g *group) leave(c *Consumer) {
+	for i, member := range g.members {
+		if member == c {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			break
+		}
+	}
+	g.rebalance()
+}
+
+// rebalance 按照加入顺序轮流分配分区。
+// 分区重新分配后从已提交的 offset 开始拉取，已拉取但没有提交的消息会重新投递。
+func (g *group) rebalance() {
+	for i, gp := range g.partitions {
+		var owner *Consumer
+		if len(g.members) > 0 {
+			owner = g.members[i%len(g.members)]
+		}
+		if gp.owner != owner {
+			gp.owner = owner
+			gp.position = gp.committed
+			clear(gp.done)
+		}
+	}
+}
+
+// complete 记录处理完成的消息，确认的消息按照 offset 连续提交。
+func (gp *groupPartition) complete(offset int64, acked bool) {
+	if offset < gp.committed || offset >= gp.position {
+		return
+	}
+	if _, ok := gp.done[offset]; ok {
+		return
+	}
+	gp.done[offset] = acked
+
+	for gp.done[gp.committed] {
+		delete(gp.done, gp.committed)
+		gp.committed++
+	}
+}
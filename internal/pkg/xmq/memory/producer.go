@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
+)
+
+var _ produce.Producer = (*Producer)(nil)
+
+// Producer 内存生产者，消息写入后立即可以被消费。
+type Producer struct {
+	broker *Broker
+}
+
+func NewProducer(broker *Broker) *Producer {
+	return &Producer{broker: broker}
+}
+
+func (p *Producer) Produce(ctx context.Context, msg *xmq.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, _, err := p.broker.append(msg)
+	return err
+}
+
+func (p *Producer) ProduceBatch(ctx context.Context, msgs []*xmq.Message) error {
+	var errs produce.BatchError
+	for i, msg := range msgs {
+		if err := p.Produce(ctx, msg); err != nil {
+			if errs == nil {
+				errs = make(produce.BatchError, len(msgs))
+			}
+			errs[i] = err
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
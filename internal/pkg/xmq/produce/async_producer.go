@@ -0,0 +1,203 @@
+package produce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+)
+
+const (
+	defaultAsyncBufferSize = 4096
+	defaultAsyncBatchSize  = 100
+	defaultAsyncLinger     = 5 * time.Millisecond
+	defaultAsyncWorkers    = 1
+
+	defaultAsyncWriteTimeout = 10 * time.Second
+)
+
+// AsyncConfig 异步生产者配置。
+type AsyncConfig struct {
+	BufferSize   int           // 缓冲区大小，缓冲区满时 ProduceAsync 返回 xmq.ErrBufferFull
+	BatchSize    int           // 单次批量发送的最大消息数
+	Linger       time.Duration // 凑批等待时间，到达 BatchSize 或等待超时后发送
+	Workers      int           // 发送协程数
+	WriteTimeout time.Duration // 单次批量发送的超时时间
+}
+
+type asyncRecord struct {
+	msg *xmq.Message
+	cb  DeliveryCallback
+}
+
+var _ AsyncProducer = (*BatchingAsyncProducer)(nil)
+
+// BatchingAsyncProducer 基于 Producer.ProduceBatch 的异步生产者。
+// 消息先放入有界缓冲区，由发送协程凑批后调用 ProduceBatch 发送，调用方不需要等待 kafka 的批量超时。
+// 只有一个发送协程 ( 默认 ) 时保证消息按照放入缓冲区的顺序发送，多个发送协程之间不保证顺序。
+type BatchingAsyncProducer struct {
+	producer Producer
+	cfg      AsyncConfig
+
+	buffer chan asyncRecord
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewBatchingAsyncProducer(producer Producer, cfg AsyncConfig) *BatchingAsyncProducer {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultAsyncBufferSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultAsyncBatchSize
+	}
+	if cfg.Linger <= 0 {
+		cfg.Linger = defaultAsyncLinger
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultAsyncWorkers
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultAsyncWriteTimeout
+	}
+
+	p := &BatchingAsyncProducer{
+		producer: producer,
+		cfg:      cfg,
+		buffer:   make(chan asyncRecord, cfg.BufferSize),
+	}
+	for range cfg.Workers {
+		p.wg.Add(1)
+		go p.loop()
+	}
+	return p
+}
+
+func (p *BatchingAsyncProducer) ProduceAsync(msg *xmq.Message, cb DeliveryCallback) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return xmq.ErrProducerClosed
+	}
+
+	select {
+	case p.buffer <- asyncRecord{msg: msg, cb: cb}:
+		return nil
+	default:
+		return xmq.ErrBufferFull
+	}
+}
+
+func (p *BatchingAsyncProducer) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.buffer)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to flush async producer buffer: %w", ctx.Err())
+	}
+}
+
+// loop 从缓冲区凑批发送，缓冲区关闭后发送剩余的消息并退出。
+func (p *BatchingAsyncProducer) loop() {
+	defer p.wg.Done()
+
+	batch := make([]asyncRecord, 0, p.cfg.BatchSize)
+	timer := time.NewTimer(p.cfg.Linger)
+	timer.Stop()
+
+	for {
+		record, ok := <-p.buffer
+		if !ok {
+			return
+		}
+		batch = append(batch, record)
+
+		timer.Reset(p.cfg.Linger)
+		closed := false
+	collect:
+		for len(batch) < p.cfg.BatchSize {
+			select {
+			case record, ok := <-p.buffer:
+				if !ok {
+					closed = true
+					break collect
+				}
+				batch = append(batch, record)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		p.send(batch)
+		clear(batch)
+		batch = batch[:0]
+
+		if closed {
+			return
+		}
+	}
+}
+
+func (p *BatchingAsyncProducer) send(batch []asyncRecord) {
+	msgs := make([]*xmq.Message, 0, len(batch))
+	for _, record := range batch {
+		msgs = append(msgs, record.msg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.WriteTimeout)
+	err := p.producer.ProduceBatch(ctx, msgs)
+	cancel()
+
+	var batchErr BatchError
+	isBatchErr := errors.As(err, &batchErr) && len(batchErr) == len(batch)
+	for i, record := range batch {
+		recordErr := err
+		if isBatchErr {
+			recordErr = batchErr[i]
+		}
+		p.callback(record, recordErr)
+	}
+}
+
+// callback 调用发送回调，回调 panic 时只记录日志，不影响其他消息。
+func (p *BatchingAsyncProducer) callback(record asyncRecord, err error) {
+	if record.cb == nil {
+		if err != nil {
+			slog.Error(
+				"[synp-xmq-producer] failed to produce message asynchronously",
+				"topic", record.msg.Topic,
+				"key", string(record.msg.Key),
+				"err", err.Error(),
+			)
+		}
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("[synp-xmq-producer] delivery callback panic recovered", "topic", record.msg.Topic, "panic", r)
+		}
+	}()
+	record.cb(record.msg, err)
+}
@@ -2,6 +2,7 @@ package produce
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 
 	"github.com/jrmarcco/hermet/internal/pkg/xmq"
@@ -21,20 +22,56 @@ func NewKafkaProducer(writer *kafka.Writer) *KafkaProducer {
 }
 
 func (p *KafkaProducer) Produce(ctx context.Context, msg *xmq.Message) error {
-	err := p.writer.WriteMessages(ctx, kafka.Message{
-		Topic: msg.Topic,
-		Key:   msg.Key,
-		Value: msg.Val,
-	})
-	if err != nil {
+	if err := p.writer.WriteMessages(ctx, convertMessage(msg)); err != nil {
 		return err
 	}
 
 	slog.Debug(
 		"[synp-xmq-producer] successfully produced message to kafka",
 		"topic", msg.Topic,
-		"offset", msg.Offset,
+		"key", string(msg.Key),
 		"message", string(msg.Val),
 	)
 	return nil
 }
+
+func (p *KafkaProducer) ProduceBatch(ctx context.Context, msgs []*xmq.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	kafkaMsgs := make([]kafka.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		kafkaMsgs = append(kafkaMsgs, convertMessage(msg))
+	}
+
+	err := p.writer.WriteMessages(ctx, kafkaMsgs...)
+	if err == nil {
+		slog.Debug("[synp-xmq-producer] successfully produced messages to kafka", "count", len(msgs))
+		return nil
+	}
+
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) {
+		return BatchError(writeErrs)
+	}
+	return err
+}
+
+// convertMessage 将 xmq.Message 转换为 kafka.Message。
+func convertMessage(msg *xmq.Message) kafka.Message {
+	var headers []kafka.Header
+	if len(msg.Headers) > 0 {
+		headers = make([]kafka.Header, 0, len(msg.Headers))
+		for key, val := range msg.Headers {
+			headers = append(headers, kafka.Header{Key: key, Value: []byte(val)})
+		}
+	}
+
+	return kafka.Message{
+		Topic:   msg.Topic,
+		Key:     msg.Key,
+		Value:   msg.Val,
+		Headers: headers,
+	}
+}
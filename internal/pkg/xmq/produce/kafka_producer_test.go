@@ -0,0 +1,49 @@
+package produce
+
+import (
+	"testing"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertMessage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		msg         *xmq.Message
+		wantHeaders []kafka.Header
+	}{
+		{
+			name: "without headers",
+			msg:  &xmq.Message{Topic: "message-sent", Key: []byte("k"), Val: []byte("v")},
+		},
+		{
+			name: "with headers",
+			msg: &xmq.Message{
+				Topic:   "message-sent",
+				Key:     []byte("k"),
+				Val:     []byte("v"),
+				Headers: xmq.Headers{"x-retry-attempts": "1", "trace-id": "t1"},
+			},
+			wantHeaders: []kafka.Header{
+				{Key: "x-retry-attempts", Value: []byte("1")},
+				{Key: "trace-id", Value: []byte("t1")},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := convertMessage(tc.msg)
+			require.Equal(t, tc.msg.Topic, res.Topic)
+			require.Equal(t, tc.msg.Key, res.Key)
+			require.Equal(t, tc.msg.Val, res.Value)
+			require.ElementsMatch(t, tc.wantHeaders, res.Headers)
+		})
+	}
+}
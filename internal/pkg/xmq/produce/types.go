@@ -2,10 +2,46 @@ package produce
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/jrmarcco/hermet/internal/pkg/xmq"
 )
 
+// Producer 同步生产者，消息的 Headers 会随消息一起写入 ( 如 kafka 的 record header )。
 type Producer interface {
 	Produce(ctx context.Context, msg *xmq.Message) error
+	// ProduceBatch 批量发送消息，所有消息发送完成后返回。
+	// 部分消息发送失败时返回 BatchError。
+	ProduceBatch(ctx context.Context, msgs []*xmq.Message) error
+}
+
+// DeliveryCallback 异步发送完成的回调，err 为 nil 表示发送成功。
+// 回调在发送协程中执行，不能阻塞。
+type DeliveryCallback func(msg *xmq.Message, err error)
+
+// AsyncProducer 异步生产者。
+// 消息放入有界缓冲区后立即返回，由后台协程批量发送，发送完成后调用回调。
+type AsyncProducer interface {
+	// ProduceAsync 将消息放入发送缓冲区，缓冲区已满时返回 xmq.ErrBufferFull，不会阻塞。
+	// cb 可以为空。
+	ProduceAsync(msg *xmq.Message, cb DeliveryCallback) error
+	// Close 停止接收新消息，并等待缓冲区中的消息发送完成 ( 或 ctx 超时 )。
+	Close(ctx context.Context) error
+}
+
+// BatchError 批量发送部分失败时返回的错误，与发送的消息一一对应，发送成功的消息为 nil。
+type BatchError []error
+
+func (e BatchError) Error() string {
+	failed := 0
+	var first error
+	for _, err := range e {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	return fmt.Sprintf("failed to produce %d of %d messages: %v", failed, len(e), first)
 }
@@ -2,7 +2,12 @@ package xmq
 
 import "errors"
 
-var ErrConsumerClosed = errors.New("consumer closed")
+var (
+	ErrConsumerClosed = errors.New("consumer closed")
+	ErrProducerClosed = errors.New("producer closed")
+	// ErrBufferFull 异步发送缓冲区已满。
+	ErrBufferFull = errors.New("producer buffer full")
+)
 
 type Headers map[string]string
 
@@ -12,6 +17,8 @@ type Message struct {
 	Topic     string
 	Partition int
 	Offset    int64
+	// Generation 拉取消息时消费者组的代，由消费者设置，用于忽略分区重新分配之前拉取的消息的确认。
+	Generation int64
 
 	Key []byte
 	Val []byte
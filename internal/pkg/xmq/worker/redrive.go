@@ -0,0 +1,135 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/consumer"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
+)
+
+const (
+	// HeaderRedrivenAt 重新投递的时间 ( unix 毫秒 )。
+	HeaderRedrivenAt = "x-redriven-at"
+
+	defaultRedriveIdleTimeout = 5 * time.Second
+)
+
+// RedriveOptions 重新投递选项。
+type RedriveOptions struct {
+	GroupID     string        // 读取死信 topic 使用的消费者组，为空时使用 {dlq}.redrive
+	Max         int           // 最多重新投递的消息数，<= 0 时不限制
+	IdleTimeout time.Duration // 超过该时间没有读取到消息时认为死信 topic 已经读完，<= 0 时为 5s
+}
+
+// Redriver 将死信消息重新投递到死信 topic 所属消费者组的重新投递 topic ( 见 RedriveTopic )。
+// 重新投递的消息只会被该消费者组再次处理，原始 topic 的其他消费者组不受影响，Handler 需要保证幂等。
+// 自动确认模式下消费者预读但没有重新投递的消息也会被提交，重新投递需要使用手动确认模式。
+type Redriver struct {
+	factory  consumer.ConsumerFactory
+	producer produce.Producer
+	logger   *slog.Logger
+}
+
+func NewRedriver(factory consumer.ConsumerFactory, producer produce.Producer, logger *slog.Logger) *Redriver {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Redriver{
+		factory:  factory,
+		producer: producer,
+		logger:   logger,
+	}
+}
+
+// Redrive 读取死信 topic 并重新投递到对应的重新投递 topic，返回重新投递的消息数。
+// 死信 topic 的名称必须由 DLQTopic 生成。
+// 重新投递时移除失败信息相关的 header ( 保留原始位置 )，消息重新从第一次处理开始。
+func (r *Redriver) Redrive(ctx context.Context, dlqTopic string, opts RedriveOptions) (int, error) {
+	if dlqTopic == "" {
+		return 0, errors.New("dlq topic is required")
+	}
+	prefix, ok := strings.CutSuffix(dlqTopic, dlqTopicSuffix)
+	if !ok || prefix == "" {
+		return 0, fmt.Errorf("[ %s ] is not a dlq topic", dlqTopic)
+	}
+	redriveTopic := prefix + redriveTopicSuffix
+	if opts.GroupID == "" {
+		opts.GroupID = dlqTopic + ".redrive"
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = defaultRedriveIdleTimeout
+	}
+
+	c, err := r.factory.NewConsumer(dlqTopic, opts.GroupID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create consumer of [ %s ]: %w", dlqTopic, err)
+	}
+	defer func() {
+		if err := c.Close(); err != nil {
+			r.logger.Warn("[synp-xmq-redriver] failed to close consumer", slog.String("topic", dlqTopic), slog.Any("err", err))
+		}
+	}()
+
+	count := 0
+	for opts.Max <= 0 || count < opts.Max {
+		consumeCtx, cancel := context.WithTimeout(ctx, opts.IdleTimeout)
+		msg, err := c.Consume(consumeCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return count, ctx.Err()
+			}
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, xmq.ErrConsumerClosed) {
+				return count, nil
+			}
+			return count, fmt.Errorf("failed to consume message from [ %s ]: %w", dlqTopic, err)
+		}
+
+		if err := r.replay(ctx, msg, redriveTopic); err != nil {
+			_ = c.Nack(ctx, msg)
+			return count, err
+		}
+		if err := c.Ack(ctx, msg); err != nil {
+			return count, fmt.Errorf("failed to ack message of [ %s ]: %w", dlqTopic, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (r *Redriver) replay(ctx context.Context, msg *xmq.Message, topic string) error {
+	headers := make(xmq.Headers, len(msg.Headers)+1)
+	maps.Copy(headers, msg.Headers)
+	for _, key := range []string{
+		HeaderAttempts, HeaderNotBefore,
+		HeaderHandler, HeaderError, HeaderFailedAt,
+	} {
+		delete(headers, key)
+	}
+	headers[HeaderRedrivenAt] = strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	if err := r.producer.Produce(ctx, &xmq.Message{
+		Headers: headers,
+		Topic:   topic,
+		Key:     msg.Key,
+		Val:     msg.Val,
+	}); err != nil {
+		return fmt.Errorf("failed to redrive message to [ %s ]: %w", topic, err)
+	}
+
+	r.logger.Info(
+		"[synp-xmq-redriver] message redriven",
+		slog.String("dlq", msg.Topic),
+		slog.Int64("offset", msg.Offset),
+		slog.String("topic", topic),
+	)
+	return nil
+}
@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/consumer"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedriver_Redrive(t *testing.T) {
+	t.Parallel()
+
+	broker := memory.NewBroker(memory.Config{Partitions: 1})
+	t.Cleanup(broker.Close)
+	factory := memory.NewConsumerFactory(broker, consumer.AckModeManual)
+	producer := memory.NewProducer(broker)
+
+	dlq := DLQTopic("message-sent", "hermet-push")
+	for _, key := range []string{"k1", "k2", "k3"} {
+		require.NoError(t, producer.Produce(t.Context(), &xmq.Message{
+			Topic: dlq,
+			Key:   []byte(key),
+			Val:   []byte("val-" + key),
+			Headers: xmq.Headers{
+				HeaderAttempts:          "3",
+				HeaderOriginalTopic:     "message-sent",
+				HeaderOriginalPartition: "0",
+				HeaderOriginalOffset:    "7",
+				HeaderHandler:           "push",
+				HeaderError:             "mock error",
+				HeaderFailedAt:          "1",
+				"trace-id":              "trace-" + key,
+			},
+		}))
+	}
+
+	redriver := NewRedriver(factory, producer, nil)
+
+	_, err := redriver.Redrive(t.Context(), "message-sent", RedriveOptions{})
+	require.Error(t, err)
+
+	count, err := redriver.Redrive(t.Context(), dlq, RedriveOptions{Max: 2, IdleTimeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	// 只投递到该消费者组的重新投递 topic，原始 topic 的其他消费者组不会再次消费。
+	origin, err := factory.NewConsumer("message-sent", "hermet-fanout")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = origin.Close() })
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	_, err = origin.Consume(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	c, err := factory.NewConsumer(RedriveTopic("message-sent", "hermet-push"), "test")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	for _, key := range []string{"k1", "k2"} {
+		msg, err := c.Consume(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, key, string(msg.Key))
+		require.Equal(t, "val-"+key, string(msg.Val))
+
+		// 移除失败信息，保留原始位置以及业务 header。
+		require.Equal(t, "message-sent", msg.Headers[HeaderOriginalTopic])
+		require.Equal(t, "7", msg.Headers[HeaderOriginalOffset])
+		require.Equal(t, "trace-"+key, msg.Headers["trace-id"])
+		require.NotEmpty(t, msg.Headers[HeaderRedrivenAt])
+		for _, header := range []string{HeaderAttempts, HeaderHandler, HeaderError, HeaderFailedAt} {
+			require.NotContains(t, msg.Headers, header)
+		}
+	}
+
+	// 剩余的死信消息可以继续重新投递。
+	count, err = redriver.Redrive(t.Context(), dlq, RedriveOptions{IdleTimeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
@@ -0,0 +1,157 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
+)
+
+// 重试和死信消息携带的 header。
+const (
+	HeaderAttempts          = "x-retry-attempts"     // 已经失败的次数
+	HeaderNotBefore         = "x-retry-not-before"   // 最早可以重试的时间 ( unix 毫秒 )
+	HeaderOriginalTopic     = "x-original-topic"     // 原始 topic
+	HeaderOriginalPartition = "x-original-partition" // 原始分区
+	HeaderOriginalOffset    = "x-original-offset"    // 原始 offset
+	HeaderHandler           = "x-handler"            // 处理失败的 handler ( Registration.Name )
+	HeaderError             = "x-error"              // 最后一次失败的错误信息
+	HeaderFailedAt          = "x-failed-at"          // 最后一次失败的时间 ( unix 毫秒 )
+)
+
+// maxErrorHeaderLen 错误信息 header 的最大长度。
+const maxErrorHeaderLen = 1024
+
+// RetryPolicy 消息处理失败后的重试策略。
+//
+// 每个重试间隔对应一个重试 topic ( 如 message-sent.hermet-push.retry.1m )，
+// 处理失败的消息按照失败次数依次进入对应的重试 topic，到达重试时间后再次交给 Handler 处理。
+// 所有重试都失败后，消息进入死信 topic ( 如 message-sent.hermet-push.dlq )，
+// 死信消息的 header 中记录了原始位置和失败信息，可以通过 Redriver 重新投递到该消费者组的重新投递 topic
+// ( 如 message-sent.hermet-push.redrive )，由同一个 Handler 重新处理。
+//
+// 重试 topic、死信 topic 和重新投递 topic 的名称包含消费者组，同一个 topic 的不同消费者组互不影响。
+// 空策略 ( 没有重试间隔且不使用死信 topic ) 表示处理失败的消息直接丢弃。
+type RetryPolicy struct {
+	Delays []time.Duration `mapstructure:"delays"` // 每一级重试的间隔，为空时失败后直接进入死信 topic
+	DLQ    bool            `mapstructure:"dlq"`    // 是否使用死信 topic，关闭时重试全部失败的消息会被丢弃
+}
+
+const (
+	dlqTopicSuffix     = ".dlq"
+	redriveTopicSuffix = ".redrive"
+)
+
+// RetryTopic 返回重试 topic 的名称。
+func RetryTopic(topic, groupID string, delay time.Duration) string {
+	return fmt.Sprintf("%s.%s.retry.%s", topic, groupID, delayLabel(delay))
+}
+
+// DLQTopic 返回死信 topic 的名称。
+func DLQTopic(topic, groupID string) string {
+	return topic + "." + groupID + dlqTopicSuffix
+}
+
+// RedriveTopic 返回重新投递 topic 的名称，死信消息重新投递到这里，只有该消费者组会再次处理。
+func RedriveTopic(topic, groupID string) string {
+	return topic + "." + groupID + redriveTopicSuffix
+}
+
+// delayLabel 将重试间隔转换为 topic 名称中的标签 ( 如 30s / 1m / 2h )。
+func delayLabel(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	case d%time.Minute == 0:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	case d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	default:
+		return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	}
+}
+
+// retrier 将处理失败的消息转发到下一级重试 topic 或死信 topic。
+type retrier struct {
+	reg      Registration
+	producer produce.Producer
+}
+
+// forward 转发处理失败的消息，返回转发的目标 topic ( 按照策略丢弃时为空 )。
+// 返回 nil 时原消息可以确认。
+func (r *retrier) forward(ctx context.Context, msg *xmq.Message, cause error) (string, error) {
+	headers := make(xmq.Headers, len(msg.Headers)+8)
+	maps.Copy(headers, msg.Headers)
+
+	// 第一次失败时记录原始位置。
+	if _, ok := headers[HeaderOriginalTopic]; !ok {
+		headers[HeaderOriginalTopic] = msg.Topic
+		headers[HeaderOriginalPartition] = strconv.Itoa(msg.Partition)
+		headers[HeaderOriginalOffset] = strconv.FormatInt(msg.Offset, 10)
+	}
+
+	attempts, _ := strconv.Atoi(headers[HeaderAttempts])
+	attempts++
+
+	now := time.Now()
+	errMsg := cause.Error()
+	if len(errMsg) > maxErrorHeaderLen {
+		errMsg = errMsg[:maxErrorHeaderLen]
+	}
+	headers[HeaderAttempts] = strconv.Itoa(attempts)
+	headers[HeaderHandler] = r.reg.Name
+	headers[HeaderError] = errMsg
+	headers[HeaderFailedAt] = strconv.FormatInt(now.UnixMilli(), 10)
+
+	var topic string
+	if attempts <= len(r.reg.Retry.Delays) {
+		delay := r.reg.Retry.Delays[attempts-1]
+		topic = RetryTopic(r.reg.Topic, r.reg.GroupID, delay)
+		headers[HeaderNotBefore] = strconv.FormatInt(now.Add(delay).UnixMilli(), 10)
+	} else {
+		if !r.reg.Retry.DLQ {
+			return "", nil
+		}
+		topic = DLQTopic(r.reg.Topic, r.reg.GroupID)
+		delete(headers, HeaderNotBefore)
+	}
+
+	err := r.producer.Produce(ctx, &xmq.Message{
+		Headers: headers,
+		Topic:   topic,
+		Key:     msg.Key,
+		Val:     msg.Val,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to forward message to [ %s ]: %w", topic, err)
+	}
+	return topic, nil
+}
+
+// waitNotBefore 等待到达消息的重试时间，ctx 取消时返回 false。
+// 同一个重试 topic 的间隔相同，分区内的消息按照重试时间有序，阻塞等待不会延误后面的消息。
+func waitNotBefore(ctx context.Context, msg *xmq.Message) bool {
+	ms, err := strconv.ParseInt(msg.Headers[HeaderNotBefore], 10, 64)
+	if err != nil {
+		return true
+	}
+
+	wait := time.Until(time.UnixMilli(ms))
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
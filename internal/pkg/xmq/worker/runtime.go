@@ -0,0 +1,171 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/consumer"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
+)
+
+// Runtime 消费者运行时，统一管理所有注册的消费者的生命周期。
+type Runtime struct {
+	factory  consumer.ConsumerFactory
+	producer produce.Producer // 用于转发重试和死信消息
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	regs    []Registration
+	workers []*worker
+	started bool
+}
+
+func NewRuntime(factory consumer.ConsumerFactory, producer produce.Producer, logger *slog.Logger) *Runtime {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Runtime{
+		factory:  factory,
+		producer: producer,
+		logger:   logger,
+	}
+}
+
+// Register 注册消费者，必须在 Start 之前调用。
+func (r *Runtime) Register(reg Registration) error {
+	if reg.Topic == "" || reg.GroupID == "" {
+		return errors.New("topic and group id are required")
+	}
+	if reg.Handler == nil {
+		return fmt.Errorf("handler of topic [ %s ] is nil", reg.Topic)
+	}
+	if reg.Concurrency <= 0 {
+		reg.Concurrency = 1
+	}
+	if reg.Name == "" {
+		reg.Name = reg.Topic
+	}
+	if reg.Retry == nil {
+		return fmt.Errorf("retry policy of [ %s ] is required, use an empty policy to drop failed messages", reg.Name)
+	}
+	if (len(reg.Retry.Delays) > 0 || reg.Retry.DLQ) && r.producer == nil {
+		return fmt.Errorf("producer is required for retry policy of [ %s ]", reg.Name)
+	}
+	for _, delay := range reg.Retry.Delays {
+		if delay <= 0 {
+			return fmt.Errorf("retry delay of [ %s ] must be positive", reg.Name)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.started {
+		return errors.New("runtime already started")
+	}
+	r.regs = append(r.regs, reg)
+	return nil
+}
+
+// Start 为每个注册创建消费者并开始消费。
+// 任意一个消费者创建失败时，已经启动的消费者会被停止。
+func (r *Runtime) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.started {
+		return errors.New("runtime already started")
+	}
+
+	workers := make([]*worker, 0, len(r.regs))
+	for _, reg := range r.regs {
+		for _, stage := range r.stages(reg) {
+			c, err := r.factory.NewConsumer(stage.reg.Topic, stage.reg.GroupID)
+			if err != nil {
+				for _, w := range workers {
+					_ = w.stop(context.Background())
+				}
+				return fmt.Errorf("failed to create consumer [ %s ]: %w", stage.reg.Name, err)
+			}
+
+			w := newWorker(stage, r.factory, c, r.logger)
+			w.start()
+			workers = append(workers, w)
+
+			r.logger.Info(
+				"[synp-xmq-worker] worker started",
+				slog.String("name", stage.reg.Name),
+				slog.String("topic", stage.reg.Topic),
+				slog.String("group_id", stage.reg.GroupID),
+				slog.Int("concurrency", stage.reg.Concurrency),
+			)
+		}
+	}
+
+	r.workers = workers
+	r.started = true
+	return nil
+}
+
+// stage 消费的一个阶段，原始 topic、某一级重试 topic 或者重新投递 topic。
+type stage struct {
+	reg     Registration
+	retrier *retrier
+	retry   bool // 是否为重试 topic，重试 topic 的消息需要等待到达重试时间
+}
+
+// stages 返回注册对应的所有消费阶段，每一级重试 topic 以及重新投递 topic 使用独立的消费者组。
+// 重试 topic 按分区分配处理协程，保证分区内的消息按照重试时间顺序处理。
+// 使用死信 topic 时同时消费重新投递 topic，重新投递的消息处理失败后重新开始重试。
+func (r *Runtime) stages(reg Registration) []stage {
+	rt := &retrier{reg: reg, producer: r.producer}
+	stages := make([]stage, 0, len(reg.Retry.Delays)+2)
+	stages = append(stages, stage{reg: reg, retrier: rt})
+	if reg.Retry.DLQ {
+		redriveReg := reg
+		redriveReg.Name = reg.Name + ".redrive"
+		redriveReg.Topic = RedriveTopic(reg.Topic, reg.GroupID)
+		redriveReg.GroupID = reg.GroupID + ".redrive"
+		stages = append(stages, stage{reg: redriveReg, retrier: rt})
+	}
+	for _, delay := range reg.Retry.Delays {
+		retryReg := reg
+		retryReg.Name = reg.Name + ".retry." + delayLabel(delay)
+		retryReg.Topic = RetryTopic(reg.Topic, reg.GroupID, delay)
+		retryReg.GroupID = reg.GroupID + ".retry." + delayLabel(delay)
+		retryReg.Affinity = AffinityPartition
+		stages = append(stages, stage{reg: retryReg, retrier: rt, retry: true})
+	}
+	return stages
+}
+
+// Stop 停止所有消费者，等待正在处理的消息处理完成 ( 或 ctx 超时 )。
+func (r *Runtime) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	workers := r.workers
+	r.workers = nil
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(workers))
+	for _, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.stop(ctx); err != nil {
+				errCh <- fmt.Errorf("failed to stop worker [ %s ]: %w", w.reg.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+
+	var errs []error
+	for err := range errCh {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,556 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/consumer"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/memory"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTopic = "order"
+	testGroup = "hermet"
+
+	waitTimeout = 3 * time.Second
+	waitTick    = 5 * time.Millisecond
+)
+
+var errMock = errors.New("mock error")
+
+type runtimeFixture struct {
+	broker   *memory.Broker
+	factory  *memory.ConsumerFactory
+	producer *memory.Producer
+	runtime  *Runtime
+}
+
+func newRuntimeFixture(t *testing.T, partitions int) *runtimeFixture {
+	t.Helper()
+
+	broker := memory.NewBroker(memory.Config{Partitions: partitions})
+	t.Cleanup(broker.Close)
+
+	f := &runtimeFixture{
+		broker:   broker,
+		factory:  memory.NewConsumerFactory(broker, consumer.AckModeManual),
+		producer: memory.NewProducer(broker),
+	}
+	f.runtime = NewRuntime(f.factory, f.producer, nil)
+	return f
+}
+
+// start 注册并启动运行时，测试结束时停止。
+func (f *runtimeFixture) start(t *testing.T, regs ...Registration) {
+	t.Helper()
+
+	for _, reg := range regs {
+		require.NoError(t, f.runtime.Register(reg))
+	}
+	require.NoError(t, f.runtime.Start())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+		defer cancel()
+		_ = f.runtime.Stop(ctx)
+	})
+}
+
+func (f *runtimeFixture) produce(t *testing.T, topic, key, val string) {
+	t.Helper()
+	require.NoError(t, f.producer.Produce(t.Context(), &xmq.Message{Topic: topic, Key: []byte(key), Val: []byte(val)}))
+}
+
+// committed 返回消费者组在 topic 所有分区上已提交的消息数。
+func (f *runtimeFixture) committed(topic, groupID string) int64 {
+	var sum int64
+	for _, offset := range f.broker.Offsets(topic, groupID) {
+		sum += offset
+	}
+	return sum
+}
+
+// consumeAll 使用新的消费者组读取 topic 中的 n 条消息。
+func (f *runtimeFixture) consumeAll(t *testing.T, topic string, n int) []*xmq.Message {
+	t.Helper()
+
+	c, err := f.factory.NewConsumer(topic, "test")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx, cancel := context.WithTimeout(t.Context(), waitTimeout)
+	defer cancel()
+
+	msgs := make([]*xmq.Message, 0, n)
+	for range n {
+		msg, err := c.Consume(ctx)
+		require.NoError(t, err)
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestRuntime_Register(t *testing.T) {
+	t.Parallel()
+
+	handler := HandlerFunc(func(context.Context, *xmq.Message) error { return nil })
+
+	tests := []struct {
+		name     string
+		noWriter bool
+		reg      Registration
+		wantErr  bool
+	}{
+		{name: "ok", reg: Registration{Topic: testTopic, GroupID: testGroup, Handler: handler, Retry: &RetryPolicy{}}},
+		{name: "topic required", reg: Registration{GroupID: testGroup, Handler: handler}, wantErr: true},
+		{name: "group required", reg: Registration{Topic: testTopic, Handler: handler}, wantErr: true},
+		{name: "handler required", reg: Registration{Topic: testTopic, GroupID: testGroup}, wantErr: true},
+		{name: "retry policy required", reg: Registration{Topic: testTopic, GroupID: testGroup, Handler: handler}, wantErr: true},
+		{
+			name:     "empty retry policy without producer",
+			noWriter: true,
+			reg:      Registration{Topic: testTopic, GroupID: testGroup, Handler: handler, Retry: &RetryPolicy{}},
+		},
+		{
+			name:     "retry requires producer",
+			noWriter: true,
+			reg: Registration{
+				Topic: testTopic, GroupID: testGroup, Handler: handler,
+				Retry: &RetryPolicy{DLQ: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "retry delay must be positive",
+			reg: Registration{
+				Topic: testTopic, GroupID: testGroup, Handler: handler,
+				Retry: &RetryPolicy{Delays: []time.Duration{time.Second, 0}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := newRuntimeFixture(t, 1)
+			if tc.noWriter {
+				f.runtime = NewRuntime(f.factory, nil, nil)
+			}
+
+			err := f.runtime.Register(tc.reg)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("register after start", func(t *testing.T) {
+		t.Parallel()
+
+		f := newRuntimeFixture(t, 1)
+		f.start(t, Registration{Topic: testTopic, GroupID: testGroup, Handler: handler, Retry: &RetryPolicy{}})
+		require.Error(t, f.runtime.Register(Registration{Topic: "other", GroupID: testGroup, Handler: handler, Retry: &RetryPolicy{}}))
+		require.Error(t, f.runtime.Start())
+	})
+}
+
+func TestRuntime_Stages(t *testing.T) {
+	t.Parallel()
+
+	f := newRuntimeFixture(t, 1)
+	stages := f.runtime.stages(Registration{
+		Name:    "push",
+		Topic:   testTopic,
+		GroupID: testGroup,
+		Retry:   &RetryPolicy{Delays: []time.Duration{30 * time.Second, time.Minute}, DLQ: true},
+	})
+
+	type summary struct {
+		name, topic, groupID string
+		affinity             Affinity
+		retry                bool
+	}
+	got := make([]summary, 0, len(stages))
+	for _, s := range stages {
+		require.NotNil(t, s.retrier)
+		got = append(got, summary{s.reg.Name, s.reg.Topic, s.reg.GroupID, s.reg.Affinity, s.retry})
+	}
+	require.Equal(t, []summary{
+		{"push", "order", "hermet", AffinityKey, false},
+		{"push.redrive", "order.hermet.redrive", "hermet.redrive", AffinityKey, false},
+		{"push.retry.30s", "order.hermet.retry.30s", "hermet.retry.30s", AffinityPartition, true},
+		{"push.retry.1m", "order.hermet.retry.1m", "hermet.retry.1m", AffinityPartition, true},
+	}, got)
+
+	// 空策略时只消费原始 topic。
+	stages = f.runtime.stages(Registration{Topic: testTopic, GroupID: testGroup, Retry: &RetryPolicy{}})
+	require.Len(t, stages, 1)
+	require.NotNil(t, stages[0].retrier)
+}
+
+func TestRuntime_KeyOrder(t *testing.T) {
+	t.Parallel()
+
+	f := newRuntimeFixture(t, 2)
+
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	f.start(t, Registration{
+		Topic:       testTopic,
+		GroupID:     testGroup,
+		Concurrency: 4,
+		Retry:       &RetryPolicy{},
+		Handler: HandlerFunc(func(_ context.Context, msg *xmq.Message) error {
+			seq, err := strconv.Atoi(string(msg.Val))
+			if err != nil {
+				return err
+			}
+			// 处理时间不同，不同 key 的消息交错完成。
+			time.Sleep(time.Duration(seq%3) * time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			got[string(msg.Key)] = append(got[string(msg.Key)], seq)
+			return nil
+		}),
+	})
+
+	keys := []string{"k0", "k1", "k2", "k3", "k4", "k5"}
+	const perKey = 20
+	for seq := range perKey {
+		for _, key := range keys {
+			f.produce(t, testTopic, key, strconv.Itoa(seq))
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		return f.committed(testTopic, testGroup) == int64(len(keys)*perKey)
+	}, waitTimeout, waitTick)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, key := range keys {
+		// 相同 key 的消息按照生产顺序处理。
+		require.Len(t, got[key], perKey, key)
+		require.True(t, slices.IsSorted(got[key]), "%s: %v", key, got[key])
+	}
+}
+
+func TestRuntime_Concurrency(t *testing.T) {
+	t.Parallel()
+
+	const concurrency = 3
+	f := newRuntimeFixture(t, 1)
+
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	f.start(t, Registration{
+		Topic:       testTopic,
+		GroupID:     testGroup,
+		Concurrency: concurrency,
+		Retry:       &RetryPolicy{},
+		Handler: HandlerFunc(func(ctx context.Context, _ *xmq.Message) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+
+			select {
+			case <-release:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}),
+	})
+
+	// key 为空时轮询分配，所有处理协程都会分配到消息。
+	const total = 10
+	for i := range total {
+		f.produce(t, testTopic, "", strconv.Itoa(i))
+	}
+
+	require.Eventually(t, func() bool { return running.Load() == concurrency }, waitTimeout, waitTick)
+	// 处理协程全部阻塞时不会处理更多的消息，也不会提交。
+	time.Sleep(20 * time.Millisecond)
+	require.EqualValues(t, concurrency, peak.Load())
+	require.Zero(t, f.committed(testTopic, testGroup))
+
+	close(release)
+	require.Eventually(t, func() bool { return f.committed(testTopic, testGroup) == total }, waitTimeout, waitTick)
+	require.EqualValues(t, concurrency, peak.Load())
+}
+
+func TestRuntime_Retry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		dlq  bool
+	}{
+		{name: "dead letter after retries", dlq: true},
+		{name: "dropped after retries"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := newRuntimeFixture(t, 1)
+			delays := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}
+
+			var mu sync.Mutex
+			var attempts []*xmq.Message
+			f.start(t, Registration{
+				Name:    "order-handler",
+				Topic:   testTopic,
+				GroupID: testGroup,
+				Retry:   &RetryPolicy{Delays: delays, DLQ: tc.dlq},
+				Handler: HandlerFunc(func(_ context.Context, msg *xmq.Message) error {
+					mu.Lock()
+					defer mu.Unlock()
+					attempts = append(attempts, msg)
+					return fmt.Errorf("attempt %d: %w", len(attempts), errMock)
+				}),
+			})
+
+			f.produce(t, testTopic, "k1", "v1")
+
+			// 原始 topic 处理 1 次，每一级重试处理 1 次。
+			require.Eventually(t, func() bool {
+				return f.committed(RetryTopic(testTopic, testGroup, delays[1]), testGroup+".retry.20ms") == 1
+			}, waitTimeout, waitTick)
+
+			mu.Lock()
+			require.Len(t, attempts, len(delays)+1)
+			require.Equal(t, testTopic, attempts[0].Topic)
+			for i, delay := range delays {
+				msg := attempts[i+1]
+				require.Equal(t, RetryTopic(testTopic, testGroup, delay), msg.Topic)
+				require.Equal(t, strconv.Itoa(i+1), msg.Headers[HeaderAttempts])
+				require.Equal(t, testTopic, msg.Headers[HeaderOriginalTopic])
+				require.Equal(t, "0", msg.Headers[HeaderOriginalOffset])
+				require.Equal(t, "k1", string(msg.Key))
+			}
+			mu.Unlock()
+
+			// 每一级消息转发之后都会提交。
+			require.EqualValues(t, 1, f.committed(testTopic, testGroup))
+			require.EqualValues(t, 1, f.committed(RetryTopic(testTopic, testGroup, delays[0]), testGroup+".retry.10ms"))
+
+			dlq := DLQTopic(testTopic, testGroup)
+			if !tc.dlq {
+				c, err := f.factory.NewConsumer(dlq, "test")
+				require.NoError(t, err)
+				t.Cleanup(func() { _ = c.Close() })
+				ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+				defer cancel()
+				_, err = c.Consume(ctx)
+				require.ErrorIs(t, err, context.DeadlineExceeded)
+				return
+			}
+
+			msg := f.consumeAll(t, dlq, 1)[0]
+			require.Equal(t, "k1", string(msg.Key))
+			require.Equal(t, "v1", string(msg.Val))
+			require.Equal(t, "3", msg.Headers[HeaderAttempts])
+			require.Equal(t, "order-handler", msg.Headers[HeaderHandler])
+			require.Contains(t, msg.Headers[HeaderError], "attempt 3")
+			require.NotContains(t, msg.Headers, HeaderNotBefore)
+		})
+	}
+}
+
+func TestRuntime_RetryWaitsNotBefore(t *testing.T) {
+	t.Parallel()
+
+	f := newRuntimeFixture(t, 1)
+	const delay = 50 * time.Millisecond
+
+	var mu sync.Mutex
+	var handledAt []time.Time
+	f.start(t, Registration{
+		Topic:   testTopic,
+		GroupID: testGroup,
+		Retry:   &RetryPolicy{Delays: []time.Duration{delay}},
+		Handler: HandlerFunc(func(context.Context, *xmq.Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handledAt = append(handledAt, time.Now())
+			if len(handledAt) == 1 {
+				return errMock
+			}
+			return nil
+		}),
+	})
+
+	f.produce(t, testTopic, "k1", "v1")
+	require.Eventually(t, func() bool {
+		return f.committed(RetryTopic(testTopic, testGroup, delay), testGroup+".retry.50ms") == 1
+	}, waitTimeout, waitTick)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, handledAt, 2)
+	require.GreaterOrEqual(t, handledAt[1].Sub(handledAt[0]), delay-5*time.Millisecond)
+}
+
+func TestRuntime_RedriveStage(t *testing.T) {
+	t.Parallel()
+
+	f := newRuntimeFixture(t, 1)
+
+	var mu sync.Mutex
+	var attempts []*xmq.Message
+	f.start(t, Registration{
+		Name:    "push",
+		Topic:   testTopic,
+		GroupID: testGroup,
+		Retry:   &RetryPolicy{DLQ: true},
+		Handler: HandlerFunc(func(_ context.Context, msg *xmq.Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts = append(attempts, msg)
+			// 第一次处理失败直接进入死信 topic，重新投递后处理成功。
+			if len(attempts) == 1 {
+				return errMock
+			}
+			return nil
+		}),
+	})
+
+	f.produce(t, testTopic, "k1", "v1")
+	dlq := DLQTopic(testTopic, testGroup)
+	require.Eventually(t, func() bool { return f.committed(testTopic, testGroup) == 1 }, waitTimeout, waitTick)
+
+	count, err := NewRedriver(f.factory, f.producer, nil).Redrive(t.Context(), dlq, RedriveOptions{IdleTimeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	redrive := RedriveTopic(testTopic, testGroup)
+	require.Eventually(t, func() bool { return f.committed(redrive, testGroup+".redrive") == 1 }, waitTimeout, waitTick)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, attempts, 2)
+	msg := attempts[1]
+	require.Equal(t, redrive, msg.Topic)
+	require.Equal(t, "v1", string(msg.Val))
+	require.Equal(t, testTopic, msg.Headers[HeaderOriginalTopic])
+	require.NotEmpty(t, msg.Headers[HeaderRedrivenAt])
+	require.NotContains(t, msg.Headers, HeaderAttempts)
+}
+
+// flakyProducer 前 failures 次发送失败，之后使用内存生产者发送。
+type flakyProducer struct {
+	*memory.Producer
+
+	failures atomic.Int32
+}
+
+func (p *flakyProducer) Produce(ctx context.Context, msg *xmq.Message) error {
+	if p.failures.Add(-1) >= 0 {
+		return errMock
+	}
+	return p.Producer.Produce(ctx, msg)
+}
+
+func TestRuntime_DropOnFailure(t *testing.T) {
+	t.Parallel()
+
+	f := newRuntimeFixture(t, 1)
+
+	var calls atomic.Int32
+	f.start(t, Registration{
+		Topic:   testTopic,
+		GroupID: testGroup,
+		Retry:   &RetryPolicy{},
+		Handler: HandlerFunc(func(_ context.Context, msg *xmq.Message) error {
+			calls.Add(1)
+			if string(msg.Val) == "v1" {
+				return errMock
+			}
+			return nil
+		}),
+	})
+
+	f.produce(t, testTopic, "k1", "v1")
+	f.produce(t, testTopic, "k1", "v2")
+
+	// 空策略时处理失败的消息被丢弃 ( 确认 )，不会重新投递。
+	require.Eventually(t, func() bool { return f.committed(testTopic, testGroup) == 2 }, waitTimeout, waitTick)
+	require.EqualValues(t, 2, calls.Load())
+}
+
+func TestRuntime_NackRedelivers(t *testing.T) {
+	t.Parallel()
+
+	f := newRuntimeFixture(t, 1)
+	producer := &flakyProducer{Producer: f.producer}
+	producer.failures.Store(1)
+	f.runtime = NewRuntime(f.factory, producer, nil)
+
+	var calls atomic.Int32
+	f.start(t, Registration{
+		Topic:   testTopic,
+		GroupID: testGroup,
+		Retry:   &RetryPolicy{DLQ: true},
+		Handler: HandlerFunc(func(_ context.Context, msg *xmq.Message) error {
+			// 第一次处理失败时转发到死信 topic 失败，否认消息，重新创建消费者后再次投递。
+			if string(msg.Val) == "v1" && calls.Add(1) == 1 {
+				return errMock
+			}
+			return nil
+		}),
+	})
+
+	f.produce(t, testTopic, "k1", "v1")
+	f.produce(t, testTopic, "k1", "v2")
+
+	require.Eventually(t, func() bool { return f.committed(testTopic, testGroup) == 2 }, waitTimeout, waitTick)
+	require.EqualValues(t, 2, calls.Load())
+}
+
+func TestRuntime_Stop(t *testing.T) {
+	t.Parallel()
+
+	f := newRuntimeFixture(t, 1)
+
+	started := make(chan struct{})
+	var once sync.Once
+	f.start(t, Registration{
+		Topic:   testTopic,
+		GroupID: testGroup,
+		Retry:   &RetryPolicy{},
+		Handler: HandlerFunc(func(context.Context, *xmq.Message) error {
+			once.Do(func() { close(started) })
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		}),
+	})
+
+	f.produce(t, testTopic, "k1", "v1")
+	<-started
+
+	// 停止时等待正在处理的消息处理完成并提交。
+	ctx, cancel := context.WithTimeout(t.Context(), waitTimeout)
+	defer cancel()
+	require.NoError(t, f.runtime.Stop(ctx))
+	require.EqualValues(t, 1, f.committed(testTopic, testGroup))
+}
@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+)
+
+// Handler 消息处理器。
+// 返回 nil 表示消息处理完成 ( 可以提交 )，返回 error 表示处理失败。
+type Handler interface {
+	Handle(ctx context.Context, msg *xmq.Message) error
+}
+
+var _ Handler = HandlerFunc(nil)
+
+// HandlerFunc 函数形式的消息处理器。
+type HandlerFunc func(ctx context.Context, msg *xmq.Message) error
+
+func (f HandlerFunc) Handle(ctx context.Context, msg *xmq.Message) error {
+	return f(ctx, msg)
+}
+
+// Affinity 消息分配策略，决定消息由哪个处理协程处理。
+// 分配到同一个处理协程的消息按照消费顺序串行处理。
+type Affinity int
+
+const (
+	// AffinityKey 按消息 key 分配，相同 key 的消息保证顺序 ( key 为空时轮询分配 )。
+	AffinityKey Affinity = iota
+	// AffinityPartition 按分区分配，同一分区的消息保证顺序。
+	AffinityPartition
+)
+
+// Registration 消费者注册信息，每个 topic / 消费者组对应一个注册。
+type Registration struct {
+	Name string // 用于日志，为空时使用 topic
+
+	Topic   string
+	GroupID string
+
+	Handler Handler
+
+	Concurrency int // 处理协程数，<= 0 时为 1
+	Affinity    Affinity
+
+	// Retry 重试策略，必须设置。
+	// 处理失败的消息否认后，自动确认模式下会丢失，手动确认模式下会无限重新投递，因此不允许没有重试策略。
+	// 允许丢失的消息使用空策略 ( 不重试、不使用死信 topic )，处理失败时记录日志后丢弃。
+	Retry *RetryPolicy
+}
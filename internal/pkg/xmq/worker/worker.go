@@ -0,0 +1,307 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/consumer"
+)
+
+// laneBufferSize 每个处理协程的缓冲区大小。
+// 缓冲区较小，停止时只需要处理少量已经分配的消息。
+const laneBufferSize = 16
+
+// reconnectInterval 消费者被关闭后重新创建的间隔。
+const reconnectInterval = time.Second
+
+// worker 单个 topic / 消费者组的消费循环。
+// 一个分发协程从消费者读取消息，按照 Affinity 分配到处理协程 ( lane )，每个 lane 串行处理消息。
+// 消息被否认时消费者随即关闭，分发协程重新创建消费者，从最后一次提交的位置重新消费。
+type worker struct {
+	reg     Registration
+	retrier *retrier
+	retry   bool
+	factory consumer.ConsumerFactory
+	logger  *slog.Logger
+
+	mu       sync.Mutex
+	consumer consumer.Consumer // 当前的消费者，重新创建时替换
+
+	lanes []chan delivery
+	next  atomic.Uint64 // key 为空时轮询分配
+
+	// consumeCtx 控制分发协程，handleCtx 传递给 Handler。
+	// 停止时先取消 consumeCtx，等待 lane 处理完已分配的消息，超时后再取消 handleCtx。
+	consumeCtx    context.Context
+	cancelConsume context.CancelFunc
+	handleCtx     context.Context
+	cancelHandle  context.CancelFunc
+
+	wg sync.WaitGroup
+}
+
+// delivery 分配给处理协程的消息以及拉取该消息的消费者，消息只能由拉取它的消费者确认。
+type delivery struct {
+	msg      *xmq.Message
+	consumer consumer.Consumer
+}
+
+func newWorker(stage stage, factory consumer.ConsumerFactory, c consumer.Consumer, logger *slog.Logger) *worker {
+	reg := stage.reg
+	lanes := make([]chan delivery, reg.Concurrency)
+	for i := range lanes {
+		lanes[i] = make(chan delivery, laneBufferSize)
+	}
+
+	consumeCtx, cancelConsume := context.WithCancel(context.Background())
+	handleCtx, cancelHandle := context.WithCancel(context.Background())
+	return &worker{
+		reg:           reg,
+		retrier:       stage.retrier,
+		retry:         stage.retry,
+		factory:       factory,
+		consumer:      c,
+		logger:        logger.With(slog.String("worker", reg.Name)),
+		lanes:         lanes,
+		consumeCtx:    consumeCtx,
+		cancelConsume: cancelConsume,
+		handleCtx:     handleCtx,
+		cancelHandle:  cancelHandle,
+	}
+}
+
+func (w *worker) start() {
+	for _, lane := range w.lanes {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for d := range lane {
+				w.process(d)
+			}
+		}()
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer func() {
+			for _, lane := range w.lanes {
+				close(lane)
+			}
+		}()
+		w.dispatch()
+	}()
+}
+
+func (w *worker) dispatch() {
+	w.mu.Lock()
+	c := w.consumer
+	w.mu.Unlock()
+
+	for {
+		msg, err := c.Consume(w.consumeCtx)
+		if err != nil {
+			if w.consumeCtx.Err() != nil {
+				return
+			}
+			if errors.Is(err, xmq.ErrConsumerClosed) {
+				if c = w.reconnect(c); c == nil {
+					return
+				}
+				continue
+			}
+			w.logger.Error("[synp-xmq-worker] failed to consume message", slog.Any("err", err))
+			continue
+		}
+
+		select {
+		case w.lanes[w.laneOf(msg)] <- delivery{msg: msg, consumer: c}:
+		case <-w.consumeCtx.Done():
+			return
+		}
+	}
+}
+
+// reconnect 消费者被关闭 ( 如消息被否认 ) 后重新创建消费者，停止时返回 nil。
+// 已经分配给处理协程的消息仍然由原来的消费者确认，不会被新的消费者提交，重新消费时可能重复。
+func (w *worker) reconnect(closed consumer.Consumer) consumer.Consumer {
+	_ = closed.Close()
+	w.logger.Error(
+		"[synp-xmq-worker] consumer closed unexpectedly, recreating",
+		slog.String("topic", w.reg.Topic),
+		slog.String("group_id", w.reg.GroupID),
+	)
+
+	timer := time.NewTimer(reconnectInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-w.consumeCtx.Done():
+			return nil
+		}
+
+		c, err := w.factory.NewConsumer(w.reg.Topic, w.reg.GroupID)
+		if err != nil {
+			w.logger.Error("[synp-xmq-worker] failed to recreate consumer", slog.Any("err", err))
+			timer.Reset(reconnectInterval)
+			continue
+		}
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		// 停止时由 stop 关闭当前的消费者，这里创建的消费者需要自己关闭。
+		if w.consumeCtx.Err() != nil {
+			_ = c.Close()
+			return nil
+		}
+		w.consumer = c
+		return c
+	}
+}
+
+func (w *worker) laneOf(msg *xmq.Message) int {
+	n := uint64(len(w.lanes))
+	if n == 1 {
+		return 0
+	}
+
+	switch w.reg.Affinity {
+	case AffinityPartition:
+		return int(uint64(msg.Partition) % n)
+	default:
+		if len(msg.Key) == 0 {
+			return int(w.next.Add(1) % n)
+		}
+		return int(xxhash.Sum64(msg.Key) % n)
+	}
+}
+
+// process 处理单条消息，Handler panic 时恢复并作为处理失败记录。
+func (w *worker) process(d delivery) {
+	msg := d.msg
+	// 停止时不再等待重试时间，也不否认消息 ( 否认会立即关闭消费者 )。
+	// 消息没有确认，所在分区的提交位置停在这条消息之前，重启后重新消费。
+	if w.retry && !waitNotBefore(w.consumeCtx, msg) {
+		return
+	}
+
+	start := time.Now()
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("handler panic: %v", r)
+				w.logger.Error(
+					"[synp-xmq-worker] handler panic recovered",
+					slog.Any("panic", r),
+					slog.String("stack", string(debug.Stack())),
+				)
+			}
+		}()
+		return w.reg.Handler.Handle(w.handleCtx, msg)
+	}()
+
+	attrs := []any{
+		slog.String("topic", msg.Topic),
+		slog.Int("partition", msg.Partition),
+		slog.Int64("offset", msg.Offset),
+		slog.String("key", string(msg.Key)),
+		slog.Duration("elapsed", time.Since(start)),
+	}
+	if err != nil {
+		w.logger.Error("[synp-xmq-worker] failed to handle message", append(attrs, slog.Any("err", err))...)
+		w.fail(d, err)
+		return
+	}
+	w.logger.Debug("[synp-xmq-worker] message handled", attrs...)
+	w.ack(d)
+}
+
+// fail 处理失败的消息。
+// 转发到下一级重试 topic 或死信 topic ( 按照策略丢弃 )，转发成功后确认原消息，转发失败时否认消息。
+func (w *worker) fail(d delivery, cause error) {
+	msg := d.msg
+	topic, err := w.retrier.forward(w.handleCtx, msg, cause)
+	if err != nil {
+		w.logger.Error(
+			"[synp-xmq-worker] failed to forward failed message",
+			slog.String("topic", msg.Topic),
+			slog.Int("partition", msg.Partition),
+			slog.Int64("offset", msg.Offset),
+			slog.Any("err", err),
+		)
+		w.nack(d)
+		return
+	}
+
+	if topic == "" {
+		w.logger.Warn(
+			"[synp-xmq-worker] retries exhausted, message dropped",
+			slog.String("topic", msg.Topic),
+			slog.Int("partition", msg.Partition),
+			slog.Int64("offset", msg.Offset),
+		)
+	}
+	w.ack(d)
+}
+
+// ack 确认消息，处理完成之后才确认，手动确认模式下保证消息不会在处理之前提交。
+func (w *worker) ack(d delivery) {
+	msg := d.msg
+	if err := d.consumer.Ack(w.handleCtx, msg); err != nil {
+		w.logger.Error(
+			"[synp-xmq-worker] failed to ack message",
+			slog.String("topic", msg.Topic),
+			slog.Int64("offset", msg.Offset),
+			slog.Any("err", err),
+		)
+	}
+}
+
+func (w *worker) nack(d delivery) {
+	msg := d.msg
+	if err := d.consumer.Nack(w.handleCtx, msg); err != nil {
+		w.logger.Error(
+			"[synp-xmq-worker] failed to nack message",
+			slog.String("topic", msg.Topic),
+			slog.Int64("offset", msg.Offset),
+			slog.Any("err", err),
+		)
+	}
+}
+
+// stop 停止分发，等待已分配的消息处理完成 ( 确认 ) 之后再关闭消费者，
+// 保证关闭时最后一次提交包含所有处理完成的消息。
+func (w *worker) stop(ctx context.Context) error {
+	w.cancelConsume()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	var waitErr error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	}
+	w.cancelHandle()
+
+	w.mu.Lock()
+	c := w.consumer
+	w.mu.Unlock()
+	return errors.Join(waitErr, c.Close())
+}
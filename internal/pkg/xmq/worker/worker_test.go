@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorker_LaneOf(t *testing.T) {
+	t.Parallel()
+
+	newTestWorker := func(concurrency int, affinity Affinity) *worker {
+		return newWorker(stage{reg: Registration{
+			Topic:       "order",
+			GroupID:     "hermet",
+			Concurrency: concurrency,
+			Affinity:    affinity,
+		}}, nil, nil, slog.Default())
+	}
+
+	t.Run("same key same lane", func(t *testing.T) {
+		t.Parallel()
+
+		w := newTestWorker(8, AffinityKey)
+		lanes := make(map[int]struct{})
+		for i := range 100 {
+			lane := w.laneOf(&xmq.Message{Key: []byte("user-1"), Partition: i % 4})
+			lanes[lane] = struct{}{}
+		}
+		require.Len(t, lanes, 1)
+	})
+
+	t.Run("keys spread across lanes", func(t *testing.T) {
+		t.Parallel()
+
+		w := newTestWorker(4, AffinityKey)
+		lanes := make(map[int]struct{})
+		for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"} {
+			lanes[w.laneOf(&xmq.Message{Key: []byte(key)})] = struct{}{}
+		}
+		require.Greater(t, len(lanes), 1)
+	})
+
+	t.Run("empty key round robin", func(t *testing.T) {
+		t.Parallel()
+
+		w := newTestWorker(3, AffinityKey)
+		got := make([]int, 0, 6)
+		for range 6 {
+			got = append(got, w.laneOf(&xmq.Message{}))
+		}
+		require.Equal(t, []int{1, 2, 0, 1, 2, 0}, got)
+	})
+
+	t.Run("partition affinity", func(t *testing.T) {
+		t.Parallel()
+
+		w := newTestWorker(3, AffinityPartition)
+		for partition := range 6 {
+			for _, key := range []string{"a", "b", ""} {
+				lane := w.laneOf(&xmq.Message{Key: []byte(key), Partition: partition})
+				require.Equal(t, partition%3, lane)
+			}
+		}
+	})
+
+	t.Run("single lane", func(t *testing.T) {
+		t.Parallel()
+
+		w := newTestWorker(1, AffinityKey)
+		require.Zero(t, w.laneOf(&xmq.Message{Key: []byte("a")}))
+		require.Zero(t, w.laneOf(&xmq.Message{}))
+	})
+}
@@ -0,0 +1,107 @@
+package xpush
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const defaultAPNsEndpoint = "https://api.push.apple.com"
+
+var _ Provider = (*APNsProvider)(nil)
+
+// APNsProvider 基于 APNs HTTP/2 接口的推送提供方。
+// 使用 token-based 鉴权，token 由 TokenSource 提供。
+type APNsProvider struct {
+	endpoint string
+	topic    string // bundle id
+
+	tokenSource TokenSource
+	client      *http.Client
+}
+
+func NewAPNsProvider(endpoint, topic string, tokenSource TokenSource, client *http.Client) *APNsProvider {
+	if endpoint == "" {
+		endpoint = defaultAPNsEndpoint
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &APNsProvider{
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		topic:       topic,
+		tokenSource: tokenSource,
+		client:      client,
+	}
+}
+
+type apnsAlert struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type apnsAps struct {
+	Alert apnsAlert `json:"alert"`
+	Badge int       `json:"badge,omitempty"`
+	Sound string    `json:"sound"`
+}
+
+func (p *APNsProvider) Push(ctx context.Context, n Notification) error {
+	payload := map[string]any{
+		"aps": apnsAps{
+			Alert: apnsAlert{Title: n.Title, Body: n.Body},
+			Badge: n.Badge,
+			Sound: "default",
+		},
+	}
+	for k, v := range n.Data {
+		payload[k] = v
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal apns payload: %w", err)
+	}
+
+	authToken, err := p.tokenSource.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get apns auth token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/3/device/"+n.Token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("authorization", "bearer "+authToken)
+	req.Header.Set("apns-topic", p.topic)
+	req.Header.Set("apns-push-type", "alert")
+	if n.CollapseKey != "" {
+		req.Header.Set("apns-collapse-id", n.CollapseKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var res struct {
+		Reason string `json:"reason"`
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(raw, &res)
+
+	// 410: 设备已取消注册，400 BadDeviceToken: token 格式错误。
+	if resp.StatusCode == http.StatusGone || res.Reason == "BadDeviceToken" || res.Reason == "Unregistered" {
+		return fmt.Errorf("%w: %s", ErrInvalidToken, res.Reason)
+	}
+	return fmt.Errorf("apns responded with status [ %d ], reason [ %s ]", resp.StatusCode, res.Reason)
+}
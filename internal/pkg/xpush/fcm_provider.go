@@ -0,0 +1,103 @@
+package xpush
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const defaultFCMEndpoint = "https://fcm.googleapis.com"
+
+var _ Provider = (*FCMProvider)(nil)
+
+// FCMProvider 基于 FCM HTTP v1 接口的推送提供方。
+// OAuth2 access token 由 TokenSource 提供。
+type FCMProvider struct {
+	endpoint  string
+	projectID string
+
+	tokenSource TokenSource
+	client      *http.Client
+}
+
+func NewFCMProvider(endpoint, projectID string, tokenSource TokenSource, client *http.Client) *FCMProvider {
+	if endpoint == "" {
+		endpoint = defaultFCMEndpoint
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &FCMProvider{
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		projectID:   projectID,
+		tokenSource: tokenSource,
+		client:      client,
+	}
+}
+
+type fcmNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type fcmAndroid struct {
+	CollapseKey string `json:"collapse_key,omitempty"`
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+	Android      *fcmAndroid       `json:"android,omitempty"`
+}
+
+func (p *FCMProvider) Push(ctx context.Context, n Notification) error {
+	msg := fcmMessage{
+		Token:        n.Token,
+		Notification: fcmNotification{Title: n.Title, Body: n.Body},
+		Data:         n.Data,
+	}
+	if n.CollapseKey != "" {
+		msg.Android = &fcmAndroid{CollapseKey: n.CollapseKey}
+	}
+
+	body, err := json.Marshal(map[string]any{"message": msg})
+	if err != nil {
+		return fmt.Errorf("failed to marshal fcm payload: %w", err)
+	}
+
+	accessToken, err := p.tokenSource.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get fcm access token: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/projects/%s/messages:send", p.endpoint, p.projectID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	raw, _ := io.ReadAll(resp.Body)
+
+	// 404 UNREGISTERED: token 已失效。
+	if resp.StatusCode == http.StatusNotFound || bytes.Contains(raw, []byte("UNREGISTERED")) {
+		return fmt.Errorf("%w: %s", ErrInvalidToken, string(raw))
+	}
+	return fmt.Errorf("fcm responded with status [ %d ]: %s", resp.StatusCode, string(raw))
+}
@@ -0,0 +1,46 @@
+package xpush
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+var _ Provider = (*LogProvider)(nil)
+
+// LogProvider 将通知以 json lines 格式写入 writer ( 文件 / 标准输出 )，用于开发和测试环境。
+type LogProvider struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogProvider(w io.Writer) *LogProvider {
+	return &LogProvider{
+		w: w,
+	}
+}
+
+type logRecord struct {
+	Notification
+
+	PushedAt int64 `json:"pushedAt"`
+}
+
+func (p *LogProvider) Push(_ context.Context, n Notification) error {
+	line, err := json.Marshal(logRecord{
+		Notification: n,
+		PushedAt:     time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, err = p.w.Write(append(line, '\n'))
+	return err
+}
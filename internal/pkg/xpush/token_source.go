@@ -0,0 +1,222 @@
+package xpush
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// apnsTokenRefresh APNs provider token 的刷新间隔。
+	// APNs 拒绝签发超过 1 小时的 token，并且 20 分钟内多次更换 token 会返回 TooManyProviderTokenUpdates。
+	apnsTokenRefresh = 50 * time.Minute
+
+	// fcmScope FCM HTTP v1 接口需要的 OAuth2 scope。
+	fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+	// fcmAssertionTTL 换取 access token 的 JWT 断言有效期 ( Google 允许的最大值 )。
+	fcmAssertionTTL = time.Hour
+	// fcmTokenRefreshBefore access token 过期前多久刷新，避免请求发出时 token 刚好过期。
+	fcmTokenRefreshBefore = 5 * time.Minute
+
+	defaultGoogleTokenURI = "https://oauth2.googleapis.com/token"
+)
+
+var _ TokenSource = (*APNsTokenSource)(nil)
+
+// APNsTokenSource 使用 APNs 鉴权私钥 ( .p8 ) 签发 provider token ( ES256 JWT )。
+// 签发的 token 缓存 apnsTokenRefresh 之后重新签发。
+type APNsTokenSource struct {
+	keyID  string
+	teamID string
+	key    *ecdsa.PrivateKey
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+
+	now func() time.Time
+}
+
+// NewAPNsTokenSource 创建 APNs provider token，keyID 为私钥的 Key ID，teamID 为开发者账号的 Team ID，
+// privateKey 为 .p8 文件的内容 ( PEM 格式 )。
+func NewAPNsTokenSource(keyID, teamID string, privateKey []byte) (*APNsTokenSource, error) {
+	if keyID == "" || teamID == "" {
+		return nil, errors.New("apns key id and team id are required")
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse apns private key: %w", err)
+	}
+	return &APNsTokenSource{
+		keyID:  keyID,
+		teamID: teamID,
+		key:    key,
+		now:    time.Now,
+	}, nil
+}
+
+func (s *APNsTokenSource) Token(_ context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.token != "" && now.Sub(s.issuedAt) < apnsTokenRefresh {
+		return s.token, nil
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": s.teamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = s.keyID
+
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign apns provider token: %w", err)
+	}
+	s.token = signed
+	s.issuedAt = now
+	return signed, nil
+}
+
+var _ TokenSource = (*FCMTokenSource)(nil)
+
+// ServiceAccount Google 服务账号密钥文件 ( JSON ) 中换取 access token 需要的字段。
+type ServiceAccount struct {
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// FCMTokenSource 使用服务账号通过 OAuth2 JWT bearer 流程换取 FCM access token。
+// access token 缓存到过期前 fcmTokenRefreshBefore 之后重新换取。
+type FCMTokenSource struct {
+	account ServiceAccount
+	key     *rsa.PrivateKey
+	client  *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+
+	now func() time.Time
+}
+
+// NewFCMTokenSource 根据服务账号密钥文件的内容创建 FCM access token。
+func NewFCMTokenSource(credentials []byte, client *http.Client) (*FCMTokenSource, error) {
+	var account ServiceAccount
+	if err := json.Unmarshal(credentials, &account); err != nil {
+		return nil, fmt.Errorf("failed to parse fcm service account: %w", err)
+	}
+	if account.ClientEmail == "" {
+		return nil, errors.New("fcm service account client_email is required")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = defaultGoogleTokenURI
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fcm service account private key: %w", err)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &FCMTokenSource{
+		account: account,
+		key:     key,
+		client:  client,
+		now:     time.Now,
+	}, nil
+}
+
+// ProjectID 返回服务账号所属的项目。
+func (s *FCMTokenSource) ProjectID() string {
+	return s.account.ProjectID
+}
+
+// Token 返回缓存的 access token，即将过期时重新换取。
+// 换取期间持有锁，并发调用只会换取一次。
+func (s *FCMTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.token != "" && now.Add(fcmTokenRefreshBefore).Before(s.expiresAt) {
+		return s.token, nil
+	}
+
+	token, expiresIn, err := s.exchange(ctx, now)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	s.expiresAt = now.Add(expiresIn)
+	return token, nil
+}
+
+type googleTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"` // 秒
+}
+
+// exchange 签发 JWT 断言并换取 access token。
+func (s *FCMTokenSource) exchange(ctx context.Context, now time.Time) (string, time.Duration, error) {
+	assertion := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   s.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   s.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(fcmAssertionTTL).Unix(),
+	})
+	if s.account.PrivateKeyID != "" {
+		assertion.Header["kid"] = s.account.PrivateKeyID
+	}
+	signed, err := assertion.SignedString(s.key)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to sign fcm token assertion: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {signed},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to request fcm access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("google token endpoint responded with status [ %d ]: %s", resp.StatusCode, string(raw))
+	}
+
+	var res googleTokenResponse
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return "", 0, fmt.Errorf("failed to parse fcm access token response: %w", err)
+	}
+	if res.AccessToken == "" || res.ExpiresIn <= 0 {
+		return "", 0, fmt.Errorf("invalid fcm access token response: %s", string(raw))
+	}
+	return res.AccessToken, time.Duration(res.ExpiresIn) * time.Second, nil
+}
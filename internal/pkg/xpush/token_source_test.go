@@ -0,0 +1,164 @@
+package xpush
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func newECKeyPEM(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestAPNsTokenSource(t *testing.T) {
+	t.Parallel()
+
+	key, keyPEM := newECKeyPEM(t)
+
+	_, err := NewAPNsTokenSource("", "TEAM", keyPEM)
+	require.Error(t, err)
+	_, err = NewAPNsTokenSource("KEY", "TEAM", []byte("invalid"))
+	require.Error(t, err)
+
+	s, err := NewAPNsTokenSource("KEY", "TEAM", keyPEM)
+	require.NoError(t, err)
+	now := time.Unix(1_700_000_000, 0)
+	s.now = func() time.Time { return now }
+
+	token, err := s.Token(t.Context())
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) { return &key.PublicKey, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}), jwt.WithTimeFunc(func() time.Time { return now }))
+	require.NoError(t, err)
+	require.Equal(t, "KEY", parsed.Header["kid"])
+	require.Equal(t, "TEAM", claims["iss"])
+	require.InDelta(t, now.Unix(), claims["iat"], 0)
+
+	// 刷新间隔内复用同一个 token。
+	now = now.Add(apnsTokenRefresh - time.Second)
+	cached, err := s.Token(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, token, cached)
+
+	// 超过刷新间隔后重新签发。
+	now = now.Add(time.Second)
+	refreshed, err := s.Token(t.Context())
+	require.NoError(t, err)
+	require.NotEqual(t, token, refreshed)
+}
+
+// newServiceAccount 生成服务账号密钥文件，token_uri 指向测试服务器。
+func newServiceAccount(t *testing.T, tokenURI string) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	credentials, err := json.Marshal(ServiceAccount{
+		ProjectID:    "hermet",
+		PrivateKeyID: "key-1",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		ClientEmail:  "push@hermet.iam.gserviceaccount.com",
+		TokenURI:     tokenURI,
+	})
+	require.NoError(t, err)
+	return key, credentials
+}
+
+func TestFCMTokenSource(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	var key *rsa.PrivateKey
+	var tokenURI string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		claims := jwt.MapClaims{}
+		if _, err := jwt.ParseWithClaims(r.FormValue("assertion"), claims,
+			func(*jwt.Token) (any, error) { return &key.PublicKey, nil },
+			jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+			jwt.WithAudience(tokenURI),
+			jwt.WithIssuer("push@hermet.iam.gserviceaccount.com"),
+		); err != nil || claims["scope"] != fcmScope {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600,"token_type":"Bearer"}`, n)
+	}))
+	t.Cleanup(srv.Close)
+
+	tokenURI = srv.URL + "/token"
+	key, credentials := newServiceAccount(t, tokenURI)
+
+	_, err := NewFCMTokenSource([]byte("{}"), nil)
+	require.Error(t, err)
+
+	s, err := NewFCMTokenSource(credentials, srv.Client())
+	require.NoError(t, err)
+	require.Equal(t, "hermet", s.ProjectID())
+
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	token, err := s.Token(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+
+	// 过期前复用缓存的 token。
+	now = now.Add(time.Hour - fcmTokenRefreshBefore - time.Second)
+	token, err = s.Token(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+	require.EqualValues(t, 1, calls.Load())
+
+	// 即将过期时重新换取。
+	now = now.Add(2 * time.Second)
+	token, err = s.Token(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "token-2", token)
+}
+
+func TestFCMTokenSource_Error(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	_, credentials := newServiceAccount(t, srv.URL)
+	s, err := NewFCMTokenSource(credentials, srv.Client())
+	require.NoError(t, err)
+
+	_, err = s.Token(t.Context())
+	require.ErrorContains(t, err, "invalid_grant")
+}
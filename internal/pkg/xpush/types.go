@@ -0,0 +1,46 @@
+package xpush
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInvalidToken 设备 token 无效 ( 已卸载 / 已过期 )，调用方应该删除该 token。
+var ErrInvalidToken = errors.New("invalid device token")
+
+// Platform 推送平台。
+type Platform string
+
+const (
+	PlatformAPNs Platform = "apns"
+	PlatformFCM  Platform = "fcm"
+)
+
+// Notification 推送通知。
+type Notification struct {
+	Token string // 设备 token
+
+	Title string
+	Body  string
+	Badge int // 角标数 ( 0 表示不设置 )
+
+	// CollapseKey 相同 key 的通知在设备上会被合并 ( 只展示最新一条 )。
+	CollapseKey string
+
+	Data map[string]string // 自定义数据，客户端点击通知时使用
+}
+
+// Provider 推送提供方 ( APNs / FCM / 本地日志 等 )。
+type Provider interface {
+	// Push 推送通知，token 无效时返回 ErrInvalidToken。
+	Push(ctx context.Context, n Notification) error
+}
+
+// Providers 按平台区分的推送提供方。
+type Providers map[Platform]Provider
+
+// TokenSource 提供推送服务的鉴权 token ( APNs provider token / FCM OAuth2 access token )。
+// token 有过期时间，实现需要在过期前自动刷新 ( 见 APNsTokenSource / FCMTokenSource )。
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
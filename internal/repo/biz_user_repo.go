@@ -17,6 +17,7 @@ type BizUserRepo interface {
 
 	FindByID(ctx context.Context, id uint64) (domain.BizUser, error)
 	FindByEmail(ctx context.Context, email string) (domain.BizUser, error)
+	FindByMobile(ctx context.Context, mobile string) (domain.BizUser, error)
 }
 
 var _ BizUserRepo = (*DefaultBizUserRepo)(nil)
@@ -28,7 +29,7 @@ type DefaultBizUserRepo struct {
 func (r *DefaultBizUserRepo) Save(ctx context.Context, user domain.BizUser) (domain.BizUser, error) {
 	entity, err := r.dao.Save(ctx, r.toEntity(user))
 	if err != nil {
-		return domain.BizUser{}, err
+		return domain.BizUser{}, shardErr(err)
 	}
 	return r.toDomain(entity), nil
 }
@@ -40,7 +41,7 @@ func (r *DefaultBizUserRepo) FindByID(ctx context.Context, id uint64) (domain.Bi
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return domain.BizUser{}, errs.ErrRecordNotFound
 		}
-		return domain.BizUser{}, err
+		return domain.BizUser{}, shardErr(err)
 	}
 	return r.toDomain(entity), nil
 }
@@ -52,7 +53,19 @@ func (r *DefaultBizUserRepo) FindByEmail(ctx context.Context, email string) (dom
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return domain.BizUser{}, errs.ErrRecordNotFound
 		}
-		return domain.BizUser{}, err
+		return domain.BizUser{}, shardErr(err)
+	}
+	return r.toDomain(entity), nil
+}
+
+func (r *DefaultBizUserRepo) FindByMobile(ctx context.Context, mobile string) (domain.BizUser, error) {
+	entity, err := r.dao.FindByMobile(ctx, mobile)
+	if err != nil {
+		// 将数据库层的错误转换为领域层错误。
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.BizUser{}, errs.ErrRecordNotFound
+		}
+		return domain.BizUser{}, shardErr(err)
 	}
 	return r.toDomain(entity), nil
 }
@@ -31,7 +31,7 @@ func (r *DefaultChannelApplicationRepo) Save(
 ) (domain.ChannelApplication, error) {
 	entity, err := r.channelApplicationDao.Save(ctx, r.toEntity(application))
 	if err != nil {
-		return domain.ChannelApplication{}, err
+		return domain.ChannelApplication{}, shardErr(err)
 	}
 	return r.toDomain(entity), nil
 }
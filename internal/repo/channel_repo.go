@@ -1,3 +1,146 @@
 package repo
 
-type ChannelRepo interface{}
+import (
+	"context"
+	"errors"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/repo/dao"
+	"gorm.io/gorm"
+)
+
+type ChannelRepo interface {
+	FindByID(ctx context.Context, id uint64) (domain.Channel, error)
+	UpdateStatus(ctx context.Context, id uint64, from, to domain.ChannelStatus) (bool, error)
+
+	// UpdateLastMessageAt 更新频道最后一条消息的时间，用于判断频道是否活跃 ( 消息归档 )。
+	UpdateLastMessageAt(ctx context.Context, id uint64, lastMessageAt int64) error
+	ListInactive(ctx context.Context, before int64, limit int) ([]domain.Channel, error)
+	MarkMessageArchived(ctx context.Context, id uint64, archivedAt int64) error
+
+	FindMember(ctx context.Context, cid, uid uint64) (domain.ChannelMember, error)
+	ListActiveMembers(ctx context.Context, cid uint64) ([]domain.ChannelMember, error)
+	// FindActiveMembers 查询频道中指定用户且未退出的成员 ( 不存在的用户会被忽略 )。
+	FindActiveMembers(ctx context.Context, cid uint64, uids []uint64) ([]domain.ChannelMember, error)
+}
+
+var _ ChannelRepo = (*DefaultChannelRepo)(nil)
+
+type DefaultChannelRepo struct {
+	channelDao       dao.ChannelDao
+	channelMemberDao dao.ChannelMemberDao
+}
+
+func NewDefaultChannelRepo(channelDao dao.ChannelDao, channelMemberDao dao.ChannelMemberDao) *DefaultChannelRepo {
+	return &DefaultChannelRepo{
+		channelDao:       channelDao,
+		channelMemberDao: channelMemberDao,
+	}
+}
+
+func (r *DefaultChannelRepo) FindByID(ctx context.Context, id uint64) (domain.Channel, error) {
+	entity, err := r.channelDao.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Channel{}, errs.ErrRecordNotFound
+		}
+		return domain.Channel{}, shardErr(err)
+	}
+	return r.toDomain(entity), nil
+}
+
+func (r *DefaultChannelRepo) UpdateStatus(ctx context.Context, id uint64, from, to domain.ChannelStatus) (bool, error) {
+	ok, err := r.channelDao.UpdateStatus(ctx, id, string(from), string(to))
+	return ok, shardErr(err)
+}
+
+func (r *DefaultChannelRepo) UpdateLastMessageAt(ctx context.Context, id uint64, lastMessageAt int64) error {
+	return shardErr(r.channelDao.UpdateLastMessageAt(ctx, id, lastMessageAt))
+}
+
+func (r *DefaultChannelRepo) ListInactive(ctx context.Context, before int64, limit int) ([]domain.Channel, error) {
+	entities, err := r.channelDao.ListInactive(ctx, before, limit)
+	if err != nil {
+		return nil, shardErr(err)
+	}
+
+	channels := make([]domain.Channel, 0, len(entities))
+	for i := range entities {
+		channels = append(channels, r.toDomain(entities[i]))
+	}
+	return channels, nil
+}
+
+func (r *DefaultChannelRepo) MarkMessageArchived(ctx context.Context, id uint64, archivedAt int64) error {
+	return shardErr(r.channelDao.MarkMessageArchived(ctx, id, archivedAt))
+}
+
+func (r *DefaultChannelRepo) FindMember(ctx context.Context, cid, uid uint64) (domain.ChannelMember, error) {
+	entity, err := r.channelMemberDao.FindByChannelIDAndUserID(ctx, cid, uid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.ChannelMember{}, errs.ErrRecordNotFound
+		}
+		return domain.ChannelMember{}, shardErr(err)
+	}
+	return r.toMemberDomain(entity), nil
+}
+
+func (r *DefaultChannelRepo) ListActiveMembers(ctx context.Context, cid uint64) ([]domain.ChannelMember, error) {
+	entities, err := r.channelMemberDao.ListActiveByChannelID(ctx, cid)
+	if err != nil {
+		return nil, shardErr(err)
+	}
+
+	members := make([]domain.ChannelMember, 0, len(entities))
+	for i := range entities {
+		members = append(members, r.toMemberDomain(entities[i]))
+	}
+	return members, nil
+}
+
+func (r *DefaultChannelRepo) FindActiveMembers(ctx context.Context, cid uint64, uids []uint64) ([]domain.ChannelMember, error) {
+	entities, err := r.channelMemberDao.ListActiveByChannelIDAndUserIDs(ctx, cid, uids)
+	if err != nil {
+		return nil, shardErr(err)
+	}
+
+	members := make([]domain.ChannelMember, 0, len(entities))
+	for i := range entities {
+		members = append(members, r.toMemberDomain(entities[i]))
+	}
+	return members, nil
+}
+
+func (r *DefaultChannelRepo) toDomain(entity dao.Channel) domain.Channel {
+	return domain.Channel{
+		ID:          entity.ID,
+		Avatar:      entity.ChannelAvatar,
+		ChannelName: entity.ChannelName,
+		ChannelType: domain.ChannelType(entity.ChannelType),
+
+		ChannelStatus: domain.ChannelStatus(entity.ChannelStatus),
+		MemberCount:   entity.ChannelMemberCount,
+		Creator:       entity.CreatorID,
+
+		LastMessageAt:     entity.LastMessageAt,
+		MessageArchivedAt: entity.MessageArchivedAt,
+
+		CreatedAt: entity.CreatedAt,
+		UpdatedAt: entity.UpdatedAt,
+	}
+}
+
+func (r *DefaultChannelRepo) toMemberDomain(entity dao.ChannelMember) domain.ChannelMember {
+	return domain.ChannelMember{
+		CID: entity.ChannelID,
+		UID: entity.UserID,
+
+		UserRole: domain.ChannelMemberRole(entity.Role),
+		Nickname: entity.Nickname,
+
+		JoinAt: entity.JoinedAt,
+		LeftAt: entity.LeftAt,
+	}
+}
@@ -13,6 +13,7 @@ type ContactApplicationRepo interface {
 	UpdateStatus(ctx context.Context, applicationID uint64, status domain.ApplicationStatus) error
 
 	ListPendingByTargetID(ctx context.Context, targetID uint64) ([]domain.ContactApplication, error)
+	ListByApplicantID(ctx context.Context, applicantID uint64) ([]domain.ContactApplication, error)
 }
 
 var _ ContactApplicationRepo = (*DefaultContactApplicationRepo)(nil)
@@ -30,19 +31,32 @@ func NewDefaultContactApplicationRepo(contactApplicationDao dao.ContactApplicati
 func (r *DefaultContactApplicationRepo) Save(ctx context.Context, application domain.ContactApplication) (domain.ContactApplication, error) {
 	entity, err := r.contactApplicationDao.Save(ctx, r.toEntity(application))
 	if err != nil {
-		return domain.ContactApplication{}, err
+		return domain.ContactApplication{}, shardErr(err)
 	}
 	return r.toDomain(entity), nil
 }
 
 func (r *DefaultContactApplicationRepo) UpdateStatus(ctx context.Context, applicationID uint64, status domain.ApplicationStatus) error {
-	return r.contactApplicationDao.UpdateStatus(ctx, applicationID, status)
+	return shardErr(r.contactApplicationDao.UpdateStatus(ctx, applicationID, status))
 }
 
 func (r *DefaultContactApplicationRepo) ListPendingByTargetID(ctx context.Context, targetID uint64) ([]domain.ContactApplication, error) {
 	entities, err := r.contactApplicationDao.ListPendingByTargetID(ctx, targetID)
 	if err != nil {
-		return nil, err
+		return nil, shardErr(err)
+	}
+
+	domains := make([]domain.ContactApplication, 0, len(entities))
+	for i := range entities {
+		domains = append(domains, r.toDomain(entities[i]))
+	}
+	return domains, nil
+}
+
+func (r *DefaultContactApplicationRepo) ListByApplicantID(ctx context.Context, applicantID uint64) ([]domain.ContactApplication, error) {
+	entities, err := r.contactApplicationDao.ListByApplicantID(ctx, applicantID)
+	if err != nil {
+		return nil, shardErr(err)
 	}
 
 	domains := make([]domain.ContactApplication, 0, len(entities))
@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/repo/dao"
+	"gorm.io/gorm"
+)
+
+type ConversationRepo interface {
+	FindByUIDAndCID(ctx context.Context, uid, cid uint64) (domain.Conversation, error)
+	ListByUID(ctx context.Context, uid uint64, offset, limit int) ([]domain.Conversation, error)
+
+	Close(ctx context.Context, uid, cid uint64, closedAt int64) error
+
+	RecordMention(ctx context.Context, uid, cid, messageID uint64) error
+	ClearMention(ctx context.Context, uid, cid, readMessageID uint64) error
+	RevokeMention(ctx context.Context, uid, cid, messageID uint64) error
+}
+
+var _ ConversationRepo = (*DefaultConversationRepo)(nil)
+
+type DefaultConversationRepo struct {
+	viewDao dao.UserConversationViewDao
+}
+
+func NewDefaultConversationRepo(viewDao dao.UserConversationViewDao) *DefaultConversationRepo {
+	return &DefaultConversationRepo{
+		viewDao: viewDao,
+	}
+}
+
+func (r *DefaultConversationRepo) FindByUIDAndCID(ctx context.Context, uid, cid uint64) (domain.Conversation, error) {
+	entity, err := r.viewDao.FindByUserIDAndChannelID(ctx, uid, cid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Conversation{}, errs.ErrRecordNotFound
+		}
+		return domain.Conversation{}, shardErr(err)
+	}
+	return r.toDomain(entity), nil
+}
+
+func (r *DefaultConversationRepo) ListByUID(ctx context.Context, uid uint64, offset, limit int) ([]domain.Conversation, error) {
+	entities, err := r.viewDao.ListByUserID(ctx, uid, offset, limit)
+	if err != nil {
+		return nil, shardErr(err)
+	}
+
+	conversations := make([]domain.Conversation, 0, len(entities))
+	for i := range entities {
+		conversations = append(conversations, r.toDomain(entities[i]))
+	}
+	return conversations, nil
+}
+
+func (r *DefaultConversationRepo) Close(ctx context.Context, uid, cid uint64, closedAt int64) error {
+	return shardErr(r.viewDao.Close(ctx, uid, cid, closedAt))
+}
+
+func (r *DefaultConversationRepo) RecordMention(ctx context.Context, uid, cid, messageID uint64) error {
+	return shardErr(r.viewDao.RecordMention(ctx, uid, cid, messageID))
+}
+
+func (r *DefaultConversationRepo) ClearMention(ctx context.Context, uid, cid, readMessageID uint64) error {
+	return shardErr(r.viewDao.ClearMention(ctx, uid, cid, readMessageID))
+}
+
+func (r *DefaultConversationRepo) RevokeMention(ctx context.Context, uid, cid, messageID uint64) error {
+	return shardErr(r.viewDao.RevokeMention(ctx, uid, cid, messageID))
+}
+
+func (r *DefaultConversationRepo) toDomain(entity dao.UserConversationView) domain.Conversation {
+	return domain.Conversation{
+		ID: entity.ID,
+
+		UID: entity.UserID,
+		CID: entity.ChannelID,
+
+		ConversationType:   domain.ChannelType(entity.ConversationType),
+		ConversationName:   entity.ConversationName,
+		ConversationAvatar: entity.ConversationAvatar,
+
+		PeerUserID: uint64(entity.PeerUserID.Int64),
+
+		IsMuted:  entity.IsMuted,
+		IsPinned: entity.IsPinned,
+		IsHidden: entity.IsHidden,
+
+		LastMessageID:   entity.LastMessageID,
+		LastMessageTime: entity.LastMessageTime,
+
+		UnreadCount:          entity.UnreadCount,
+		MentionCount:         entity.MentionCount,
+		LastMentionMessageID: entity.LastMentionMessageID,
+
+		OpenedAt: entity.OpenedAt,
+		ClosedAt: entity.ClosedAt,
+	}
+}
@@ -2,14 +2,20 @@ package dao
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
 	"github.com/jrmarcco/jit/xsync"
 	"gorm.io/gorm"
 )
 
+// mobileIndexClaimGrace 手机号索引残留的判定时间。
+// 索引写入后到用户表写入完成前，通过索引查不到用户，必须超过该时间才能认为是写入失败的残留索引。
+const mobileIndexClaimGrace = time.Minute
+
 // BizUser 业务用户表。
 type BizUser struct {
 	ID uint64 `gorm:"column:id"`
@@ -39,33 +45,55 @@ type BizUser struct {
 	UpdatedAt int64 `gorm:"column:updated_at"`
 }
 
+// BizUserMobileIndex 【 二级索引 】手机号索引表。
+// 以 Mobile 为分片键，用于根据手机号查询用户 ( biz_user 以 email 为分片键 )，同时保证手机号全局唯一。
+type BizUserMobileIndex struct {
+	Mobile string `gorm:"column:mobile"`
+	UserID uint64 `gorm:"column:user_id"`
+
+	CreatedAt int64 `gorm:"column:created_at"`
+}
+
 //go:generate mockgen -source=biz_user_dao.go -destination=mock/biz_user_dao.mock.go -package=daomock -typed BizUserDao
 
 type BizUserDao interface {
+	// Save 保存用户，手机号已经被其他用户使用时返回 ErrIndexConflict。
 	Save(ctx context.Context, user BizUser) (BizUser, error)
 
 	FindByID(ctx context.Context, id uint64) (BizUser, error)
 	FindByEmail(ctx context.Context, email string) (BizUser, error)
+	// FindByMobile 通过手机号索引表定位用户，不会广播查询。
+	FindByMobile(ctx context.Context, mobile string) (BizUser, error)
 }
 
 var _ BizUserDao = (*DefaultBizUserDao)(nil)
 
+// DefaultBizUserDao 用户 DAO。
+//
+// 手机号索引与用户表不在同一个分片，无法使用同一个事务，写入顺序为 先占用索引 -> 再写入用户表：
+// - 用户表写入失败时删除索引，删除失败的索引会在下一次占用同一个手机号时被检测并覆盖 ( 见 mobileIndexClaimGrace )；
+// - 查询时通过索引定位到用户后会再校验用户的手机号，不会返回索引残留指向的记录。
 type DefaultBizUserDao struct {
-	dbs         *xsync.Map[string, *gorm.DB]
-	shardHelper *sharding.ShardHelper
+	table       *ShardedTable[BizUser]
+	mobileIndex *ShardedTable[BizUserMobileIndex]
 }
 
-func NewDefaultBizUserDao(dbs *xsync.Map[string, *gorm.DB], shardHelper *sharding.ShardHelper) *DefaultBizUserDao {
+func NewDefaultBizUserDao(
+	dbs *xsync.Map[string, *gorm.DB],
+	shardHelper *sharding.ShardHelper,
+	migration *migrate.Coordinator,
+	mobileIndexShardHelper *sharding.ShardHelper,
+) *DefaultBizUserDao {
 	return &DefaultBizUserDao{
-		dbs:         dbs,
-		shardHelper: shardHelper,
+		table:       NewShardedTable[BizUser](dbs, shardHelper, migration),
+		mobileIndex: NewShardedTable[BizUserMobileIndex](dbs, mobileIndexShardHelper, migration),
 	}
 }
 
 func (d *DefaultBizUserDao) Save(ctx context.Context, user BizUser) (BizUser, error) {
-	id, dst, err := d.shardHelper.NextIDAndShard(sharding.NewStringSharder(user.Email))
+	id, err := d.table.NextID(sharding.NewStringSharder(user.Email))
 	if err != nil {
-		return BizUser{}, fmt.Errorf("failed to get shard destination from email [ %s ]", user.Email)
+		return BizUser{}, err
 	}
 
 	now := time.Now().UnixMilli()
@@ -74,51 +102,104 @@ func (d *DefaultBizUserDao) Save(ctx context.Context, user BizUser) (BizUser, er
 	user.CreatedAt = now
 	user.UpdatedAt = now
 
-	db, ok := d.dbs.Load(dst.DB)
-	if !ok {
-		return BizUser{}, fmt.Errorf("failed to load database [ %s ]", dst.DB)
+	if user.Mobile != "" {
+		if err = d.claimMobile(ctx, user.Mobile, id, now); err != nil {
+			return BizUser{}, err
+		}
 	}
 
-	err = db.WithContext(ctx).Table(dst.TB).Model(&BizUser{}).Create(&user).Error
-	return user, err
+	saved, err := d.table.Create(ctx, id, user)
+	if err != nil {
+		if user.Mobile != "" {
+			err = errors.Join(err, d.releaseMobile(ctx, user.Mobile, id))
+		}
+		return BizUser{}, err
+	}
+	return saved, nil
 }
 
-func (d *DefaultBizUserDao) FindByID(ctx context.Context, id uint64) (BizUser, error) {
-	var user BizUser
+// claimMobile 将手机号索引指向 userID。
+// 索引已经存在时，只有原来指向的用户不存在且索引写入已经超过 mobileIndexClaimGrace ( 索引残留 ) 才会覆盖，
+// 否则返回 ErrIndexConflict。刚写入的索引指向的用户可能还在写入中 ( 并发注册 )，不能覆盖。
+func (d *DefaultBizUserDao) claimMobile(ctx context.Context, mobile string, userID uint64, now int64) error {
+	sharder := sharding.NewStringSharder(mobile)
+
+	inserted, err := d.mobileIndex.Put(ctx, sharder, BizUserMobileIndex{
+		Mobile:    mobile,
+		UserID:    userID,
+		CreatedAt: now,
+	})
+	if err != nil || inserted {
+		return err
+	}
 
-	dst, err := d.shardHelper.DstFromID(id)
+	idx, err := d.mobileIndex.FindByShardKey(ctx, sharder, byMobile(mobile))
 	if err != nil {
-		return BizUser{}, fmt.Errorf("failed to get shard destination from id [ %d ]", id)
+		return err
+	}
+	if now-idx.CreatedAt < mobileIndexClaimGrace.Milliseconds() {
+		return fmt.Errorf("%w: mobile [ %s ]", ErrIndexConflict, mobile)
 	}
 
-	db, ok := d.dbs.Load(dst.DB)
-	if !ok {
-		return BizUser{}, fmt.Errorf("failed to load database [ %s ]", dst.DB)
+	_, err = d.table.FindByID(ctx, idx.UserID, notDeleted, byMobile(mobile))
+	if err == nil {
+		return fmt.Errorf("%w: mobile [ %s ]", ErrIndexConflict, mobile)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
 	}
 
-	err = db.WithContext(ctx).Table(dst.TB).Model(&BizUser{}).
-		Where("id = ?", id).
-		Where("deleted_at = 0").
-		First(&user).Error
-	return user, err
+	// 使用原来的 user_id 和 created_at 作为条件，避免并发占用时覆盖其他请求写入的索引。
+	affected, err := d.mobileIndex.UpdateByShardKey(ctx, sharder,
+		map[string]any{
+			"user_id":    userID,
+			"created_at": now,
+		},
+		byMobile(mobile),
+		func(db *gorm.DB) *gorm.DB {
+			return db.
+				Where("user_id = ?", idx.UserID).
+				Where("created_at = ?", idx.CreatedAt)
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: mobile [ %s ]", ErrIndexConflict, mobile)
+	}
+	return nil
+}
+
+func (d *DefaultBizUserDao) releaseMobile(ctx context.Context, mobile string, userID uint64) error {
+	_, err := d.mobileIndex.DeleteByShardKey(ctx, sharding.NewStringSharder(mobile), byMobile(mobile),
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("user_id = ?", userID)
+		},
+	)
+	return err
+}
+
+func (d *DefaultBizUserDao) FindByID(ctx context.Context, id uint64) (BizUser, error) {
+	return d.table.FindByID(ctx, id, notDeleted)
 }
 
 func (d *DefaultBizUserDao) FindByEmail(ctx context.Context, email string) (BizUser, error) {
-	var user BizUser
+	return d.table.FindByShardKey(ctx, sharding.NewStringSharder(email), notDeleted, func(db *gorm.DB) *gorm.DB {
+		return db.Where("email = ?", email)
+	})
+}
 
-	dst, err := d.shardHelper.DstFromSharder(sharding.NewStringSharder(email))
+func (d *DefaultBizUserDao) FindByMobile(ctx context.Context, mobile string) (BizUser, error) {
+	idx, err := d.mobileIndex.FindByShardKey(ctx, sharding.NewStringSharder(mobile), byMobile(mobile))
 	if err != nil {
-		return BizUser{}, fmt.Errorf("failed to get shard destination from email [ %s ]", email)
+		return BizUser{}, err
 	}
+	return d.table.FindByID(ctx, idx.UserID, notDeleted, byMobile(mobile))
+}
 
-	db, ok := d.dbs.Load(dst.DB)
-	if !ok {
-		return BizUser{}, fmt.Errorf("failed to load database [ %s ]", dst.DB)
+func byMobile(mobile string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("mobile = ?", mobile)
 	}
-
-	err = db.WithContext(ctx).Table(dst.TB).Model(&BizUser{}).
-		Where("email = ?", email).
-		Where("deleted_at = 0").
-		First(&user).Error
-	return user, err
 }
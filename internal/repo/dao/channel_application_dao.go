@@ -2,10 +2,10 @@ package dao
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
 	"github.com/jrmarcco/jit/xsync"
 	"gorm.io/gorm"
 )
@@ -38,34 +38,25 @@ type ChannelApplicationDao interface {
 var _ ChannelApplicationDao = (*DefaultChannelApplicationDao)(nil)
 
 type DefaultChannelApplicationDao struct {
-	dbs         *xsync.Map[string, *gorm.DB]
-	shardHelper *sharding.ShardHelper
+	table *ShardedTable[ChannelApplication]
 }
 
-func NewDefaultChannelApplicationDao(dbs *xsync.Map[string, *gorm.DB], shardHelper *sharding.ShardHelper) *DefaultChannelApplicationDao {
+func NewDefaultChannelApplicationDao(
+	dbs *xsync.Map[string, *gorm.DB],
+	shardHelper *sharding.ShardHelper,
+	migration *migrate.Coordinator,
+) *DefaultChannelApplicationDao {
 	return &DefaultChannelApplicationDao{
-		dbs:         dbs,
-		shardHelper: shardHelper,
+		table: NewShardedTable[ChannelApplication](dbs, shardHelper, migration),
 	}
 }
 
 func (d *DefaultChannelApplicationDao) Save(ctx context.Context, ca ChannelApplication) (ChannelApplication, error) {
-	id, dst, err := d.shardHelper.NextIDAndShard(sharding.NewSingleIDSharder(ca.ChannelID))
-	if err != nil {
-		return ChannelApplication{}, fmt.Errorf("failed to get shard destination from channel id [ %d ]", ca.ChannelID)
-	}
-
 	now := time.Now().UnixMilli()
-
-	ca.ID = id
-	ca.CreatedAt = now
-	ca.UpdatedAt = now
-
-	db, ok := d.dbs.Load(dst.DB)
-	if !ok {
-		return ChannelApplication{}, fmt.Errorf("failed to load database [ %s ]", dst.DB)
-	}
-
-	err = db.WithContext(ctx).Table(dst.TB).Model(&ChannelApplication{}).Create(&ca).Error
-	return ca, err
+	return d.table.Insert(ctx, sharding.NewSingleIDSharder(ca.ChannelID), func(id uint64) ChannelApplication {
+		ca.ID = id
+		ca.CreatedAt = now
+		ca.UpdatedAt = now
+		return ca
+	})
 }
@@ -1,47 +1,147 @@
 package dao
 
 import (
+	"cmp"
 	"context"
-	"database/sql"
+	"time"
 
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
+	"github.com/jrmarcco/jit/xsync"
 	"gorm.io/gorm"
 )
 
+// Channel 【 写入侧 】频道表。
+// ID 由 Creator 计算分片值生成，通过 ID 即可定位分片。
 type Channel struct {
 	ID uint64 `gorm:"column:id"`
 
-	Avatar      sql.NullString `gorm:"column:avatar"`
-	ChannelName sql.NullString `gorm:"column:channel_name"`
-
+	ChannelName   string `gorm:"column:channel_name"`
 	ChannelType   string `gorm:"column:channel_type"`
+	ChannelAvatar string `gorm:"column:channel_avatar"`
 	ChannelStatus string `gorm:"column:channel_status"`
 
-	Creator uint64 `gorm:"column:creator"`
+	ChannelInfoVer     int `gorm:"column:channel_info_ver"`
+	ChannelMemberCount int `gorm:"column:channel_member_count"`
+
+	LastMessageAt     int64 `gorm:"column:last_message_at"`
+	MessageArchivedAt int64 `gorm:"column:message_archived_at"`
 
-	CreateAt int64 `gorm:"column:create_at"`
-	UpdateAt int64 `gorm:"column:update_at"`
+	CreatorID uint64 `gorm:"column:creator_id"`
+
+	CreatedAt int64 `gorm:"column:created_at"`
+	UpdatedAt int64 `gorm:"column:updated_at"`
 }
 
 type ChannelDao interface {
 	Save(ctx context.Context, channel Channel) (Channel, error)
+
+	FindByID(ctx context.Context, id uint64) (Channel, error)
+
+	// UpdateStatus 使用 CAS 的方式更新频道状态，只有当前状态为 from 时才会更新为 to。
+	// 返回值表示是否更新成功。
+	UpdateStatus(ctx context.Context, id uint64, from, to string) (bool, error)
+
+	// UpdateLastMessageAt 更新频道最后一条消息的时间 ( 只会增大，不会回退 )。
+	UpdateLastMessageAt(ctx context.Context, id uint64, lastMessageAt int64) error
+
+	// ListInactive 查询在 before 之前最后一次有消息且尚未归档的频道。
+	// 所有分片并发查询后按照最后一条消息时间全局排序再截断，最久没有消息的频道优先，不会只处理前面的分片。
+	ListInactive(ctx context.Context, before int64, limit int) ([]Channel, error)
+	// MarkMessageArchived 标记频道消息已归档。
+	MarkMessageArchived(ctx context.Context, id uint64, archivedAt int64) error
 }
 
+// broadcastParallelism 广播查询同时查询的最大分片数。
+const broadcastParallelism = 4
+
 var _ ChannelDao = (*DefaultChannelDao)(nil)
 
 type DefaultChannelDao struct {
-	db *gorm.DB
+	table *ShardedTable[Channel]
 }
 
-func NewDefaultChannelDao(db *gorm.DB) *DefaultChannelDao {
-	return &DefaultChannelDao{db: db}
+func NewDefaultChannelDao(
+	dbs *xsync.Map[string, *gorm.DB],
+	shardHelper *sharding.ShardHelper,
+	migration *migrate.Coordinator,
+) *DefaultChannelDao {
+	return &DefaultChannelDao{
+		table: NewShardedTable[Channel](dbs, shardHelper, migration),
+	}
 }
 
-func (d *DefaultChannelDao) Save(_ context.Context, _ Channel) (Channel, error) {
-	// now := time.Now().UnixMilli()
+func (d *DefaultChannelDao) Save(ctx context.Context, channel Channel) (Channel, error) {
+	now := time.Now().UnixMilli()
+	return d.table.Insert(ctx, sharding.NewSingleIDSharder(channel.CreatorID), func(id uint64) Channel {
+		channel.ID = id
+		channel.CreatedAt = now
+		channel.UpdatedAt = now
+		return channel
+	})
+}
+
+func (d *DefaultChannelDao) FindByID(ctx context.Context, id uint64) (Channel, error) {
+	return d.table.FindByID(ctx, id)
+}
+
+func (d *DefaultChannelDao) UpdateStatus(ctx context.Context, id uint64, from, to string) (bool, error) {
+	affected, err := d.table.UpdateByID(ctx, id,
+		map[string]any{
+			"channel_status": to,
+			"updated_at":     time.Now().UnixMilli(),
+		},
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("channel_status = ?", from)
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// UpdateLastMessageAt 只用于判断频道是否活跃，不更新 updated_at。
+func (d *DefaultChannelDao) UpdateLastMessageAt(ctx context.Context, id uint64, lastMessageAt int64) error {
+	_, err := d.table.UpdateByID(ctx, id,
+		map[string]any{"last_message_at": lastMessageAt},
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("last_message_at < ?", lastMessageAt)
+		},
+	)
+	return err
+}
 
-	// channel.CreateAt = now
-	// channel.UpdateAt = now
+func (d *DefaultChannelDao) ListInactive(ctx context.Context, before int64, limit int) ([]Channel, error) {
+	res, err := d.table.Broadcast(ctx,
+		func(db *gorm.DB, limit int) ([]Channel, error) {
+			var channels []Channel
+			err := db.
+				Where("last_message_at > message_archived_at").
+				Where("last_message_at < ?", before).
+				Order("last_message_at").
+				Limit(limit).
+				Find(&channels).Error
+			return channels, err
+		},
+		sharding.ScatterOptions[Channel]{
+			Parallelism: broadcastParallelism,
+			Compare: func(a, b Channel) int {
+				return cmp.Compare(a.LastMessageAt, b.LastMessageAt)
+			},
+			Limit: limit,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
 
-	// TODO: not implemented
-	panic("not implemented")
+func (d *DefaultChannelDao) MarkMessageArchived(ctx context.Context, id uint64, archivedAt int64) error {
+	_, err := d.table.UpdateByID(ctx, id, map[string]any{
+		"message_archived_at": archivedAt,
+		"updated_at":          time.Now().UnixMilli(),
+	})
+	return err
 }
@@ -1,17 +1,87 @@
 package dao
 
-import "gorm.io/gorm"
+import (
+	"context"
 
-type ChannelMember struct{}
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
+	"github.com/jrmarcco/jit/xsync"
+	"gorm.io/gorm"
+)
 
-type ChannelMemberDao interface{}
+// ChannelMember 【 写入侧 】频道成员表。
+// 以 ChannelID 为分片键。
+type ChannelMember struct {
+	ID uint64 `gorm:"column:id"`
+
+	ChannelID uint64 `gorm:"column:channel_id"`
+	UserID    uint64 `gorm:"column:user_id"`
+
+	Role     string `gorm:"column:role"`
+	Nickname string `gorm:"column:nickname"`
+
+	JoinedAt int64 `gorm:"column:joined_at"`
+	LeftAt   int64 `gorm:"column:left_at"`
+
+	CreatedAt int64 `gorm:"column:created_at"`
+	UpdatedAt int64 `gorm:"column:updated_at"`
+}
+
+type ChannelMemberDao interface {
+	FindByChannelIDAndUserID(ctx context.Context, channelID, userID uint64) (ChannelMember, error)
+
+	// ListActiveByChannelID 查询频道中未退出的成员。
+	ListActiveByChannelID(ctx context.Context, channelID uint64) ([]ChannelMember, error)
+	// ListActiveByChannelIDAndUserIDs 查询频道中指定用户且未退出的成员。
+	ListActiveByChannelIDAndUserIDs(ctx context.Context, channelID uint64, userIDs []uint64) ([]ChannelMember, error)
+}
 
 var _ ChannelMemberDao = (*DefaultChannelMemberDao)(nil)
 
 type DefaultChannelMemberDao struct {
-	db *gorm.DB
+	table *ShardedTable[ChannelMember]
 }
 
-func NewDefaultChannelMemberDao(db *gorm.DB) *DefaultChannelMemberDao {
-	return &DefaultChannelMemberDao{db: db}
+func NewDefaultChannelMemberDao(
+	dbs *xsync.Map[string, *gorm.DB],
+	shardHelper *sharding.ShardHelper,
+	migration *migrate.Coordinator,
+) *DefaultChannelMemberDao {
+	return &DefaultChannelMemberDao{
+		table: NewShardedTable[ChannelMember](dbs, shardHelper, migration),
+	}
+}
+
+func (d *DefaultChannelMemberDao) FindByChannelIDAndUserID(ctx context.Context, channelID, userID uint64) (ChannelMember, error) {
+	return d.table.FindByShardKey(ctx, sharding.NewSingleIDSharder(channelID), func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("channel_id = ?", channelID).
+			Where("user_id = ?", userID).
+			Where("left_at = ?", 0)
+	})
+}
+
+func (d *DefaultChannelMemberDao) ListActiveByChannelID(ctx context.Context, channelID uint64) ([]ChannelMember, error) {
+	return d.table.ListByShardKey(ctx, sharding.NewSingleIDSharder(channelID), func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("channel_id = ?", channelID).
+			Where("left_at = ?", 0)
+	})
+}
+
+func (d *DefaultChannelMemberDao) ListActiveByChannelIDAndUserIDs(
+	ctx context.Context,
+	channelID uint64,
+	userIDs []uint64,
+) ([]ChannelMember, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	return d.table.ListByShardKey(ctx, sharding.NewSingleIDSharder(channelID), func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("channel_id = ?", channelID).
+			Where("user_id IN ?", userIDs).
+			Where("left_at = ?", 0)
+	})
 }
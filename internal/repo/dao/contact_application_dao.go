@@ -1,12 +1,15 @@
 package dao
 
 import (
+	"cmp"
 	"context"
-	"fmt"
+	"errors"
+	"slices"
 	"time"
 
 	"github.com/jrmarcco/hermet/internal/domain"
 	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
 	"github.com/jrmarcco/jit/xsync"
 	"gorm.io/gorm"
 )
@@ -34,30 +37,50 @@ type ContactApplication struct {
 	UpdatedAt int64 `gorm:"column:updated_at"`
 }
 
+// ContactApplicantIndex 【 二级索引 】联系人申请的申请人索引表。
+// 以 ApplicantID 为分片键，用于查询 "某个用户发出的申请" ( contact_application 以 TargetID 为分片键 )。
+type ContactApplicantIndex struct {
+	ApplicantID   uint64 `gorm:"column:applicant_id"`
+	ApplicationID uint64 `gorm:"column:application_id"`
+
+	CreatedAt int64 `gorm:"column:created_at"`
+}
+
 type ContactApplicationDao interface {
 	Save(ctx context.Context, ca ContactApplication) (ContactApplication, error)
 
 	UpdateStatus(ctx context.Context, applicationID uint64, status domain.ApplicationStatus) error
 
 	ListPendingByTargetID(ctx context.Context, targetID uint64) ([]ContactApplication, error)
+	// ListByApplicantID 通过申请人索引表查询 applicantID 发出的申请 ( 按照创建时间倒序 )，不会广播查询。
+	ListByApplicantID(ctx context.Context, applicantID uint64) ([]ContactApplication, error)
 }
 
 var _ ContactApplicationDao = (*DefaultContactApplicationDao)(nil)
 
+// DefaultContactApplicationDao 联系人申请 DAO。
+//
+// 申请人索引与申请表不在同一个分片，写入顺序为 先写入索引 -> 再写入申请表，申请表写入失败时删除索引。
+// 查询时通过索引中的申请 ID 按分片批量查询申请表，索引残留指向的申请不存在时会被忽略。
 type DefaultContactApplicationDao struct {
-	dbs         *xsync.Map[string, *gorm.DB]
-	shardHelper *sharding.ShardHelper
+	table          *ShardedTable[ContactApplication]
+	applicantIndex *ShardedTable[ContactApplicantIndex]
 }
 
-func NewDefaultContactApplicationDao(dbs *xsync.Map[string, *gorm.DB], shardHelper *sharding.ShardHelper) *DefaultContactApplicationDao {
+func NewDefaultContactApplicationDao(
+	dbs *xsync.Map[string, *gorm.DB],
+	shardHelper *sharding.ShardHelper,
+	migration *migrate.Coordinator,
+	applicantIndexShardHelper *sharding.ShardHelper,
+) *DefaultContactApplicationDao {
 	return &DefaultContactApplicationDao{
-		dbs:         dbs,
-		shardHelper: shardHelper,
+		table:          NewShardedTable[ContactApplication](dbs, shardHelper, migration),
+		applicantIndex: NewShardedTable[ContactApplicantIndex](dbs, applicantIndexShardHelper, migration),
 	}
 }
 
 func (d *DefaultContactApplicationDao) Save(ctx context.Context, ca ContactApplication) (ContactApplication, error) {
-	id, dst, err := d.shardHelper.NextIDAndShard(sharding.NewSingleIDSharder(ca.TargetID))
+	id, err := d.table.NextID(sharding.NewSingleIDSharder(ca.TargetID))
 	if err != nil {
 		return ContactApplication{}, err
 	}
@@ -68,47 +91,65 @@ func (d *DefaultContactApplicationDao) Save(ctx context.Context, ca ContactAppli
 	ca.CreatedAt = now
 	ca.UpdatedAt = now
 
-	db, ok := d.dbs.Load(dst.DB)
-	if !ok {
-		return ContactApplication{}, fmt.Errorf("failed to load database [ %s ]", dst.DB)
+	applicant := sharding.NewSingleIDSharder(ca.ApplicantID)
+	if _, err = d.applicantIndex.Put(ctx, applicant, ContactApplicantIndex{
+		ApplicantID:   ca.ApplicantID,
+		ApplicationID: id,
+		CreatedAt:     now,
+	}); err != nil {
+		return ContactApplication{}, err
 	}
 
-	err = db.WithContext(ctx).Table(dst.TB).Model(&ContactApplication{}).Create(&ca).Error
-	return ca, err
-}
-
-func (d *DefaultContactApplicationDao) UpdateStatus(ctx context.Context, applicationID uint64, status domain.ApplicationStatus) error {
-	dst, err := d.shardHelper.DstFromID(applicationID)
+	saved, err := d.table.Create(ctx, id, ca)
 	if err != nil {
-		return fmt.Errorf("failed to get shard destination from id [ %d ]", applicationID)
-	}
-
-	db, ok := d.dbs.Load(dst.DB)
-	if !ok {
-		return fmt.Errorf("failed to load database [ %s ]", dst.DB)
+		_, releaseErr := d.applicantIndex.DeleteByShardKey(ctx, applicant, func(db *gorm.DB) *gorm.DB {
+			return db.
+				Where("applicant_id = ?", ca.ApplicantID).
+				Where("application_id = ?", id)
+		})
+		return ContactApplication{}, errors.Join(err, releaseErr)
 	}
+	return saved, nil
+}
 
-	err = db.WithContext(ctx).Table(dst.TB).Model(&ContactApplication{}).
-		Where("id = ?", applicationID).
-		Update("application_status", string(status)).Error
+func (d *DefaultContactApplicationDao) UpdateStatus(ctx context.Context, applicationID uint64, status domain.ApplicationStatus) error {
+	_, err := d.table.UpdateByID(ctx, applicationID, map[string]any{
+		"application_status": string(status),
+	})
 	return err
 }
 
 func (d *DefaultContactApplicationDao) ListPendingByTargetID(ctx context.Context, targetID uint64) ([]ContactApplication, error) {
-	dst, err := d.shardHelper.DstFromSharder(sharding.NewSingleIDSharder(targetID))
+	return d.table.ListByShardKey(ctx, sharding.NewSingleIDSharder(targetID), func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("target_id = ?", targetID).
+			Where("application_status = ?", string(domain.ApplicationStatusPending))
+	})
+}
+
+func (d *DefaultContactApplicationDao) ListByApplicantID(ctx context.Context, applicantID uint64) ([]ContactApplication, error) {
+	db, _, err := d.applicantIndex.DBForShardKey(ctx, sharding.NewSingleIDSharder(applicantID))
 	if err != nil {
 		return nil, err
 	}
 
-	db, ok := d.dbs.Load(dst.DB)
-	if !ok {
-		return nil, fmt.Errorf("failed to load database [ %s ]", dst.DB)
+	var ids []uint64
+	err = db.
+		Where("applicant_id = ?", applicantID).
+		Pluck("application_id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	cas, err := d.table.FindByIDs(ctx, ids, func(db *gorm.DB) *gorm.DB {
+		return db.Where("applicant_id = ?", applicantID)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	var cas []ContactApplication
-	err = db.WithContext(ctx).Table(dst.TB).Model(&ContactApplication{}).
-		Where("target_id = ?", targetID).
-		Where("application_status = ?", string(domain.ApplicationStatusPending)).
-		Find(&cas).Error
-	return cas, err
+	slices.SortFunc(cas, func(a, b ContactApplication) int {
+		return cmp.Compare(b.CreatedAt, a.CreatedAt)
+	})
+	return cas, nil
 }
@@ -0,0 +1,53 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
+	"github.com/jrmarcco/jit/xsync"
+	"gorm.io/gorm"
+)
+
+// ContactReverseIndex 【 反向索引 】联系人反向索引表。
+// 以 ContactUserID 为分片键，用于查询 "谁的联系人中有某个用户"。
+type ContactReverseIndex struct {
+	ContactUserID uint64 `gorm:"column:contact_user_id"`
+	OwnerUserID   uint64 `gorm:"column:owner_user_id"`
+
+	CreatedAt int64 `gorm:"column:created_at"`
+}
+
+type ContactReverseIndexDao interface {
+	// ListOwnerIDs 查询将 contactUserID 添加为联系人的用户 ID。
+	ListOwnerIDs(ctx context.Context, contactUserID uint64) ([]uint64, error)
+}
+
+var _ ContactReverseIndexDao = (*DefaultContactReverseIndexDao)(nil)
+
+type DefaultContactReverseIndexDao struct {
+	table *ShardedTable[ContactReverseIndex]
+}
+
+func NewDefaultContactReverseIndexDao(
+	dbs *xsync.Map[string, *gorm.DB],
+	shardHelper *sharding.ShardHelper,
+	migration *migrate.Coordinator,
+) *DefaultContactReverseIndexDao {
+	return &DefaultContactReverseIndexDao{
+		table: NewShardedTable[ContactReverseIndex](dbs, shardHelper, migration),
+	}
+}
+
+func (d *DefaultContactReverseIndexDao) ListOwnerIDs(ctx context.Context, contactUserID uint64) ([]uint64, error) {
+	db, _, err := d.table.DBForShardKey(ctx, sharding.NewSingleIDSharder(contactUserID))
+	if err != nil {
+		return nil, err
+	}
+
+	var ownerIDs []uint64
+	err = db.
+		Where("contact_user_id = ?", contactUserID).
+		Pluck("owner_user_id", &ownerIDs).Error
+	return ownerIDs, err
+}
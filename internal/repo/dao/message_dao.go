@@ -2,13 +2,28 @@ package dao
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/jrmarcco/hermet/internal/pkg/xmongo"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	messageCollName         = "message"
+	messageArchiveCollName  = "message_archive"  // 冷存储，保存长期不活跃频道的消息
+	messageEditCollName     = "message_edit"     // 消息编辑历史
+	messageReactionCollName = "message_reaction" // 用户对消息的表情回应记录
+
+	// maxReactionUIDs 每个表情在消息上保留的最近回应用户数。
+	maxReactionUIDs = 10
 )
 
 type Message struct {
-	ID uint64 `bson:"id"`
+	ID  uint64 `bson:"id"`
+	Seq uint64 `bson:"seq"`
 
 	CID uint64 `bson:"cid"`
 	SID uint64 `bson:"sid"`
@@ -18,25 +33,176 @@ type Message struct {
 	Content     []byte `bson:"content"`
 	ContentType int32  `bson:"contentType"`
 
+	Mentions   []uint64 `bson:"mentions"`
+	MentionAll bool     `bson:"mentionAll"`
+
 	SendAt int64 `bson:"sendAt"`
+
+	RecalledAt int64 `bson:"recalledAt"`
+	EditVer    int   `bson:"editVer"`
+	EditedAt   int64 `bson:"editedAt"`
+
+	ReplyTo *MessageRef `bson:"replyTo,omitempty"`
+
+	ThreadID           uint64   `bson:"threadId"`
+	ReplyCount         int      `bson:"replyCount"`
+	LastReplyAt        int64    `bson:"lastReplyAt"`
+	ThreadParticipants []uint64 `bson:"threadParticipants,omitempty"` // 话题参与者 ( 根消息发送者 + 所有回复者 )
+
+	Reactions map[string]ReactionSummary `bson:"reactions,omitempty"` // 以表情为 key 的回应汇总
+}
+
+// ReactionSummary 单个表情的回应汇总。
+type ReactionSummary struct {
+	Count int      `bson:"count"`
+	UIDs  []uint64 `bson:"uids"` // 最近回应的用户 ( 最多保留 maxReactionUIDs 个 )
+}
+
+// MessageReaction 用户对消息的表情回应记录。
+// 以 ( messageId, uid, emoji ) 唯一标识一条记录，用于保证回应的幂等。
+type MessageReaction struct {
+	MessageID uint64 `bson:"messageId"`
+	CID       uint64 `bson:"cid"`
+	UID       uint64 `bson:"uid"`
+	Emoji     string `bson:"emoji"`
+
+	ReactedAt int64 `bson:"reactedAt"`
+}
+
+// MessageRef 被回复 / 引用的消息快照。
+type MessageRef struct {
+	ID  uint64 `bson:"id"`
+	SID uint64 `bson:"sid"`
+
+	Content     []byte `bson:"content"`
+	ContentType int32  `bson:"contentType"`
+
+	SendAt     int64 `bson:"sendAt"`
+	RecalledAt int64 `bson:"recalledAt"` // 被引用的消息撤回后快照内容会被清空
+}
+
+// MessageEdit 消息编辑历史，保存每次编辑前的消息内容。
+// 以 ( messageId, ver ) 唯一标识一条记录。
+type MessageEdit struct {
+	MessageID uint64 `bson:"messageId"`
+	CID       uint64 `bson:"cid"`
+	Ver       int    `bson:"ver"`
+
+	Content []byte `bson:"content"`
+
+	EditedAt int64 `bson:"editedAt"`
 }
 
 type MessageDao interface {
+	// EnsureIndexes 创建 ( cid, sid, mid ) 唯一索引，用于客户端重试时去重 ( mid 为空的消息不参与去重 )。
+	// 同时创建 ( cid, replyTo.id ) 索引，用于撤回时清空引用快照，
+	// 以及回应记录的 ( messageId, uid, emoji ) 唯一索引，保证并发回应时只计数一次，
+	// 编辑历史的 ( messageId, ver ) 唯一索引，保证并发写入同一个版本时不会产生重复记录。
+	EnsureIndexes(ctx context.Context) error
+
 	Save(ctx context.Context, message *Message) error
+	// FindByMID 按客户端生成的消息 ID 查询发送者在频道中发送的消息。
+	FindByMID(ctx context.Context, cid, sid uint64, mid string) (Message, error)
+	// FindArchivedByMID 与 FindByMID 相同，但查询的是冷存储中的消息。
+	FindArchivedByMID(ctx context.Context, cid, sid uint64, mid string) (Message, error)
+
+	// ListByCID 按消息 ID 倒序查询频道中 ID 小于 beforeID 的消息 ( beforeID 为 0 时从最新消息开始 )。
+	// 话题中的回复不会出现在频道消息中，需要通过 ListByThreadID 查询。
+	ListByCID(ctx context.Context, cid, beforeID uint64, limit int) ([]Message, error)
+	// ListArchivedByCID 与 ListByCID 相同，但查询的是冷存储中的消息。
+	ListArchivedByCID(ctx context.Context, cid, beforeID uint64, limit int) ([]Message, error)
+
+	// Archive 将频道中的消息按批次迁移到冷存储，返回迁移的消息数量。
+	Archive(ctx context.Context, cid uint64, batchSize int) (int, error)
+
+	FindByID(ctx context.Context, cid, id uint64) (Message, error)
+	// FindArchivedByID 与 FindByID 相同，但查询的是冷存储中的消息。
+	FindArchivedByID(ctx context.Context, cid, id uint64) (Message, error)
+	// FindByIDs 批量查询消息 ( 可以跨频道，不存在的消息会被忽略 )。
+	FindByIDs(ctx context.Context, ids []uint64) ([]Message, error)
+	// FindArchivedByIDs 与 FindByIDs 相同，但查询的是冷存储中的消息。
+	FindArchivedByIDs(ctx context.Context, ids []uint64) ([]Message, error)
+
+	// Recall 撤回消息，撤回后消息内容、编辑历史和引用该消息的快照内容都会被清空。
+	// 返回值表示是否撤回成功 ( 消息已被撤回时返回 false )。
+	Recall(ctx context.Context, cid, id uint64, recalledAt int64) (bool, error)
+	// Edit 使用 CAS 的方式编辑消息，只有当前版本号为 ver 时才会更新，更新后版本号加 1。
+	// 编辑前的内容会写入编辑历史。
+	Edit(ctx context.Context, cid, id uint64, ver int, content []byte, editedAt int64) (bool, error)
+	// ListEdits 按版本号升序查询消息的编辑历史。
+	ListEdits(ctx context.Context, cid, id uint64) ([]MessageEdit, error)
+
+	// ListByThreadID 按消息 ID 升序查询话题中 ID 大于 afterID 的回复。
+	ListByThreadID(ctx context.Context, cid, threadID, afterID uint64, limit int) ([]Message, error)
+	// ListArchivedByThreadID 与 ListByThreadID 相同，但查询的是冷存储中的消息。
+	ListArchivedByThreadID(ctx context.Context, cid, threadID, afterID uint64, limit int) ([]Message, error)
+	// AddThreadReply 更新话题根消息的回复数、最后回复时间和参与者，返回更新后的根消息。
+	// 根消息已经归档时更新冷存储中的根消息。
+	AddThreadReply(ctx context.Context, cid, threadID, sid uint64, repliedAt int64) (Message, error)
+
+	// AddReaction 添加表情回应，返回该表情当前的回应数以及是否发生变更 ( 重复回应返回 false )。
+	// 消息已经归档时更新冷存储中的消息，消息不存在时返回 mongo.ErrNoDocuments。
+	AddReaction(ctx context.Context, cid, id, uid uint64, emoji string, reactedAt int64) (int, bool, error)
+	// RemoveReaction 取消表情回应，返回该表情当前的回应数以及是否发生变更 ( 未回应过返回 false )。
+	RemoveReaction(ctx context.Context, cid, id, uid uint64, emoji string) (int, bool, error)
 }
 
 var _ MessageDao = (*MongoMessageDao)(nil)
 
 type MongoMessageDao struct {
-	coll *mongo.Collection
+	coll         *mongo.Collection
+	archiveColl  *mongo.Collection
+	editColl     *mongo.Collection
+	reactionColl *mongo.Collection
 }
 
 func NewMongoMessageDao(collManager *xmongo.CollManager) *MongoMessageDao {
 	return &MongoMessageDao{
-		coll: collManager.Collection("message"),
+		coll:         collManager.Collection(messageCollName),
+		archiveColl:  collManager.Collection(messageArchiveCollName),
+		editColl:     collManager.Collection(messageEditCollName),
+		reactionColl: collManager.Collection(messageReactionCollName),
 	}
 }
 
+func (d *MongoMessageDao) EnsureIndexes(ctx context.Context) error {
+	_, err := d.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "cid", Value: 1}, {Key: "sid", Value: 1}, {Key: "mid", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"mid": bson.M{"$gt": ""}}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create message mid index: %w", err)
+	}
+
+	_, err = d.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "cid", Value: 1}, {Key: "replyTo.id", Value: 1}},
+		Options: options.Index().
+			SetPartialFilterExpression(bson.M{"replyTo.id": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create message reply index: %w", err)
+	}
+
+	_, err = d.reactionColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "messageId", Value: 1}, {Key: "uid", Value: 1}, {Key: "emoji", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create message reaction index: %w", err)
+	}
+
+	_, err = d.editColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "messageId", Value: 1}, {Key: "ver", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create message edit index: %w", err)
+	}
+	return nil
+}
+
 // Save 保存消息。
 // 注意：message 必须是指针类型，因为 MongoDB 的插入操作会修改 message 的值 ( 如 ID 回传) 。
 // 同时 Content 字段为 []byte，使用指针类型可以有效避免内存拷贝。
@@ -44,3 +210,400 @@ func (d *MongoMessageDao) Save(ctx context.Context, message *Message) error {
 	_, err := d.coll.InsertOne(ctx, message)
 	return err
 }
+
+func (d *MongoMessageDao) FindByMID(ctx context.Context, cid, sid uint64, mid string) (Message, error) {
+	return d.findByMID(ctx, d.coll, cid, sid, mid)
+}
+
+func (d *MongoMessageDao) FindArchivedByMID(ctx context.Context, cid, sid uint64, mid string) (Message, error) {
+	return d.findByMID(ctx, d.archiveColl, cid, sid, mid)
+}
+
+func (d *MongoMessageDao) findByMID(ctx context.Context, coll *mongo.Collection, cid, sid uint64, mid string) (Message, error) {
+	var message Message
+	err := coll.FindOne(ctx, bson.M{"cid": cid, "sid": sid, "mid": mid}).Decode(&message)
+	return message, err
+}
+
+func (d *MongoMessageDao) ListByCID(ctx context.Context, cid, beforeID uint64, limit int) ([]Message, error) {
+	return d.listByCID(ctx, d.coll, cid, beforeID, limit)
+}
+
+func (d *MongoMessageDao) ListArchivedByCID(ctx context.Context, cid, beforeID uint64, limit int) ([]Message, error) {
+	return d.listByCID(ctx, d.archiveColl, cid, beforeID, limit)
+}
+
+func (d *MongoMessageDao) listByCID(
+	ctx context.Context,
+	coll *mongo.Collection,
+	cid, beforeID uint64,
+	limit int,
+) ([]Message, error) {
+	// 历史数据没有 threadId 字段，$in null 可以同时匹配字段不存在的情况。
+	filter := bson.M{"cid": cid, "threadId": bson.M{"$in": bson.A{0, nil}}}
+	if beforeID > 0 {
+		filter["id"] = bson.M{"$lt": beforeID}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "id", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Archive 迁移流程：先写入冷存储，再从热存储删除。
+// 冷存储写入使用 upsert，保证中途失败后重试不会产生重复数据。
+func (d *MongoMessageDao) Archive(ctx context.Context, cid uint64, batchSize int) (int, error) {
+	total := 0
+	for {
+		cursor, err := d.coll.Find(
+			ctx,
+			bson.M{"cid": cid},
+			options.Find().SetSort(bson.D{{Key: "id", Value: 1}}).SetLimit(int64(batchSize)),
+		)
+		if err != nil {
+			return total, err
+		}
+
+		var messages []Message
+		if err := cursor.All(ctx, &messages); err != nil {
+			return total, err
+		}
+		if len(messages) == 0 {
+			return total, nil
+		}
+
+		models := make([]mongo.WriteModel, 0, len(messages))
+		ids := make([]uint64, 0, len(messages))
+		for i := range messages {
+			models = append(models, mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"id": messages[i].ID}).
+				SetReplacement(messages[i]).
+				SetUpsert(true))
+			ids = append(ids, messages[i].ID)
+		}
+
+		if _, err := d.archiveColl.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+			return total, fmt.Errorf("failed to write archived messages of channel [ %d ]: %w", cid, err)
+		}
+
+		if _, err := d.coll.DeleteMany(ctx, bson.M{"cid": cid, "id": bson.M{"$in": ids}}); err != nil {
+			return total, fmt.Errorf("failed to delete archived messages of channel [ %d ]: %w", cid, err)
+		}
+
+		total += len(messages)
+		if len(messages) < batchSize {
+			return total, nil
+		}
+	}
+}
+
+func (d *MongoMessageDao) FindByID(ctx context.Context, cid, id uint64) (Message, error) {
+	return d.findByID(ctx, d.coll, cid, id)
+}
+
+func (d *MongoMessageDao) FindArchivedByID(ctx context.Context, cid, id uint64) (Message, error) {
+	return d.findByID(ctx, d.archiveColl, cid, id)
+}
+
+func (d *MongoMessageDao) findByID(ctx context.Context, coll *mongo.Collection, cid, id uint64) (Message, error) {
+	var message Message
+	err := coll.FindOne(ctx, bson.M{"cid": cid, "id": id}).Decode(&message)
+	return message, err
+}
+
+func (d *MongoMessageDao) FindByIDs(ctx context.Context, ids []uint64) ([]Message, error) {
+	return d.findByIDs(ctx, d.coll, ids)
+}
+
+func (d *MongoMessageDao) FindArchivedByIDs(ctx context.Context, ids []uint64) ([]Message, error) {
+	return d.findByIDs(ctx, d.archiveColl, ids)
+}
+
+func (d *MongoMessageDao) findByIDs(ctx context.Context, coll *mongo.Collection, ids []uint64) ([]Message, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	cursor, err := coll.Find(ctx, bson.M{"id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (d *MongoMessageDao) Recall(ctx context.Context, cid, id uint64, recalledAt int64) (bool, error) {
+	res, err := d.coll.UpdateOne(
+		ctx,
+		bson.M{"cid": cid, "id": id, "recalledAt": 0},
+		bson.M{"$set": bson.M{"recalledAt": recalledAt, "content": nil}},
+	)
+	if err != nil {
+		return false, err
+	}
+	if res.ModifiedCount == 0 {
+		return false, nil
+	}
+
+	// 编辑历史中保存的是撤回前的内容，需要一起删除。
+	if _, err := d.editColl.DeleteMany(ctx, bson.M{"cid": cid, "messageId": id}); err != nil {
+		return true, fmt.Errorf("failed to delete edit history of message [ %d ]: %w", id, err)
+	}
+
+	// 回复 / 引用该消息的快照中也保存了撤回前的内容。
+	// 撤回时间窗口很短，引用该消息的消息不会被归档，只需要更新热存储。
+	_, err = d.coll.UpdateMany(
+		ctx,
+		bson.M{"cid": cid, "replyTo.id": id},
+		bson.M{"$set": bson.M{"replyTo.content": nil, "replyTo.recalledAt": recalledAt}},
+	)
+	if err != nil {
+		return true, fmt.Errorf("failed to redact reply snapshots of message [ %d ]: %w", id, err)
+	}
+	return true, nil
+}
+
+func (d *MongoMessageDao) Edit(
+	ctx context.Context,
+	cid, id uint64,
+	ver int,
+	content []byte,
+	editedAt int64,
+) (bool, error) {
+	old, err := d.FindByID(ctx, cid, id)
+	if err != nil {
+		return false, err
+	}
+	if old.RecalledAt != 0 || old.EditVer != ver {
+		return false, nil
+	}
+
+	// 先写入编辑历史，使用 upsert 保证重试幂等。
+	edit := MessageEdit{
+		MessageID: id,
+		CID:       cid,
+		Ver:       old.EditVer,
+		Content:   old.Content,
+		EditedAt:  editedAt,
+	}
+	_, err = d.editColl.ReplaceOne(
+		ctx,
+		bson.M{"messageId": id, "ver": old.EditVer},
+		edit,
+		options.Replace().SetUpsert(true),
+	)
+	// 并发编辑同一个版本时另一个请求已经写入了编辑历史，由下面的 CAS 决定哪个请求生效。
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return false, fmt.Errorf("failed to save edit history of message [ %d ]: %w", id, err)
+	}
+
+	res, err := d.coll.UpdateOne(
+		ctx,
+		bson.M{"cid": cid, "id": id, "editVer": ver, "recalledAt": 0},
+		bson.M{"$set": bson.M{"content": content, "editVer": ver + 1, "editedAt": editedAt}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+func (d *MongoMessageDao) ListEdits(ctx context.Context, cid, id uint64) ([]MessageEdit, error) {
+	cursor, err := d.editColl.Find(
+		ctx,
+		bson.M{"cid": cid, "messageId": id},
+		options.Find().SetSort(bson.D{{Key: "ver", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var edits []MessageEdit
+	if err := cursor.All(ctx, &edits); err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+func (d *MongoMessageDao) ListByThreadID(
+	ctx context.Context,
+	cid, threadID, afterID uint64,
+	limit int,
+) ([]Message, error) {
+	return d.listByThreadID(ctx, d.coll, cid, threadID, afterID, limit)
+}
+
+func (d *MongoMessageDao) ListArchivedByThreadID(
+	ctx context.Context,
+	cid, threadID, afterID uint64,
+	limit int,
+) ([]Message, error) {
+	return d.listByThreadID(ctx, d.archiveColl, cid, threadID, afterID, limit)
+}
+
+func (d *MongoMessageDao) listByThreadID(
+	ctx context.Context,
+	coll *mongo.Collection,
+	cid, threadID, afterID uint64,
+	limit int,
+) ([]Message, error) {
+	cursor, err := coll.Find(
+		ctx,
+		bson.M{"cid": cid, "threadId": threadID, "id": bson.M{"$gt": afterID}},
+		options.Find().SetSort(bson.D{{Key: "id", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (d *MongoMessageDao) AddThreadReply(
+	ctx context.Context,
+	cid, threadID, sid uint64,
+	repliedAt int64,
+) (Message, error) {
+	root, _, err := d.updateMessage(ctx, cid, threadID, bson.M{
+		"$inc":      bson.M{"replyCount": 1},
+		"$max":      bson.M{"lastReplyAt": repliedAt},
+		"$addToSet": bson.M{"threadParticipants": sid},
+	})
+	return root, err
+}
+
+// updateMessage 更新消息并返回更新后的消息，热存储中不存在时更新冷存储中的消息 ( 消息可能已经归档 )。
+// 同时返回消息所在的集合，两边都不存在时返回 mongo.ErrNoDocuments。
+func (d *MongoMessageDao) updateMessage(
+	ctx context.Context,
+	cid, id uint64,
+	update bson.M,
+) (Message, *mongo.Collection, error) {
+	for _, coll := range []*mongo.Collection{d.coll, d.archiveColl} {
+		var message Message
+		err := coll.FindOneAndUpdate(
+			ctx,
+			bson.M{"cid": cid, "id": id},
+			update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&message)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		return message, coll, err
+	}
+	return Message{}, nil, mongo.ErrNoDocuments
+}
+
+// AddReaction 先写入回应记录 ( 通过 upsert 和唯一索引判断是否重复回应 )，再更新消息上的回应汇总。
+// 汇总更新失败时删除回应记录，保证重试时可以重新计数。
+func (d *MongoMessageDao) AddReaction(
+	ctx context.Context,
+	cid, id, uid uint64,
+	emoji string,
+	reactedAt int64,
+) (int, bool, error) {
+	reaction := MessageReaction{
+		MessageID: id,
+		CID:       cid,
+		UID:       uid,
+		Emoji:     emoji,
+		ReactedAt: reactedAt,
+	}
+	filter := bson.M{"messageId": id, "uid": uid, "emoji": emoji}
+	res, err := d.reactionColl.UpdateOne(
+		ctx,
+		filter,
+		bson.M{"$setOnInsert": reaction},
+		options.UpdateOne().SetUpsert(true),
+	)
+	if err != nil {
+		// 并发回应时另一个请求已经插入了记录。
+		if mongo.IsDuplicateKeyError(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	if res.UpsertedCount == 0 {
+		return 0, false, nil
+	}
+
+	key := "reactions." + emoji
+	message, _, err := d.updateMessage(ctx, cid, id, bson.M{
+		"$inc": bson.M{key + ".count": 1},
+		"$push": bson.M{key + ".uids": bson.M{
+			"$each":  bson.A{uid},
+			"$slice": -maxReactionUIDs,
+		}},
+	})
+	if err != nil {
+		if _, delErr := d.reactionColl.DeleteOne(ctx, filter); delErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to revert reaction record: %w", delErr))
+		}
+		return 0, false, fmt.Errorf("failed to update reaction summary of message [ %d ]: %w", id, err)
+	}
+	return message.Reactions[emoji].Count, true, nil
+}
+
+// RemoveReaction 先删除回应记录，再更新消息上的回应汇总，回应数为 0 时移除该表情。
+// 汇总更新失败时恢复回应记录，保证重试时可以重新计数。
+func (d *MongoMessageDao) RemoveReaction(
+	ctx context.Context,
+	cid, id, uid uint64,
+	emoji string,
+) (int, bool, error) {
+	filter := bson.M{"messageId": id, "uid": uid, "emoji": emoji}
+	var reaction MessageReaction
+	err := d.reactionColl.FindOneAndDelete(ctx, filter).Decode(&reaction)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	key := "reactions." + emoji
+	message, coll, err := d.updateMessage(ctx, cid, id, bson.M{
+		"$inc":  bson.M{key + ".count": -1},
+		"$pull": bson.M{key + ".uids": uid},
+	})
+	if err != nil {
+		if _, insErr := d.reactionColl.InsertOne(ctx, reaction); insErr != nil && !mongo.IsDuplicateKeyError(insErr) {
+			err = errors.Join(err, fmt.Errorf("failed to restore reaction record: %w", insErr))
+		}
+		return 0, false, fmt.Errorf("failed to update reaction summary of message [ %d ]: %w", id, err)
+	}
+
+	cnt := message.Reactions[emoji].Count
+	if cnt <= 0 {
+		_, err = coll.UpdateOne(
+			ctx,
+			bson.M{"cid": cid, "id": id, key + ".count": bson.M{"$lte": 0}},
+			bson.M{"$unset": bson.M{key: ""}},
+		)
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to remove reaction summary of message [ %d ]: %w", id, err)
+		}
+		cnt = 0
+	}
+	return cnt, true, nil
+}
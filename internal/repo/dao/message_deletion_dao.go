@@ -0,0 +1,101 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmongo"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const messageDeletionCollName = "message_deletion"
+
+// MessageDeletion 用户单方面删除的消息 ( 仅对自己不可见 )。
+// 以 ( uid, messageId ) 唯一标识一条记录。
+type MessageDeletion struct {
+	UID       uint64 `bson:"uid"`
+	CID       uint64 `bson:"cid"`
+	MessageID uint64 `bson:"messageId"`
+
+	DeletedAt int64 `bson:"deletedAt"`
+}
+
+type MessageDeletionDao interface {
+	// EnsureIndexes 创建 ( uid, messageId ) 唯一索引，保证同一条消息对同一个用户只有一条删除记录。
+	EnsureIndexes(ctx context.Context) error
+
+	Save(ctx context.Context, deletion MessageDeletion) error
+
+	// FindDeletedIDs 从 messageIDs 中找出已被用户删除的消息 ID。
+	FindDeletedIDs(ctx context.Context, uid, cid uint64, messageIDs []uint64) ([]uint64, error)
+}
+
+var _ MessageDeletionDao = (*MongoMessageDeletionDao)(nil)
+
+type MongoMessageDeletionDao struct {
+	coll *mongo.Collection
+}
+
+func NewMongoMessageDeletionDao(collManager *xmongo.CollManager) *MongoMessageDeletionDao {
+	return &MongoMessageDeletionDao{
+		coll: collManager.Collection(messageDeletionCollName),
+	}
+}
+
+func (d *MongoMessageDeletionDao) EnsureIndexes(ctx context.Context) error {
+	_, err := d.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "uid", Value: 1}, {Key: "messageId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create message deletion index: %w", err)
+	}
+	return nil
+}
+
+// Save 保存删除记录，并发删除同一条消息时只保留一条记录。
+func (d *MongoMessageDeletionDao) Save(ctx context.Context, deletion MessageDeletion) error {
+	_, err := d.coll.ReplaceOne(
+		ctx,
+		bson.M{"uid": deletion.UID, "messageId": deletion.MessageID},
+		deletion,
+		options.Replace().SetUpsert(true),
+	)
+	// 并发删除时另一个请求已经写入了删除记录。
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (d *MongoMessageDeletionDao) FindDeletedIDs(
+	ctx context.Context,
+	uid, cid uint64,
+	messageIDs []uint64,
+) ([]uint64, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+
+	cursor, err := d.coll.Find(
+		ctx,
+		bson.M{"uid": uid, "cid": cid, "messageId": bson.M{"$in": messageIDs}},
+		options.Find().SetProjection(bson.M{"messageId": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var deletions []MessageDeletion
+	if err := cursor.All(ctx, &deletions); err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint64, 0, len(deletions))
+	for i := range deletions {
+		ids = append(ids, deletions[i].MessageID)
+	}
+	return ids, nil
+}
@@ -8,7 +8,12 @@ var DaoFxModule = fx.Module(
 		fx.Annotate(
 			NewDefaultBizUserDao,
 			fx.As(new(BizUserDao)),
-			fx.ParamTags(`name:"db_sharding_clients"`, `name:"biz_user_shard_helper"`),
+			fx.ParamTags(
+				`name:"db_sharding_clients"`,
+				`name:"biz_user_shard_helper"`,
+				``,
+				`name:"biz_user_mobile_index_shard_helper"`,
+			),
 		),
 
 		fx.Annotate(
@@ -19,7 +24,17 @@ var DaoFxModule = fx.Module(
 		fx.Annotate(
 			NewDefaultContactApplicationDao,
 			fx.As(new(ContactApplicationDao)),
-			fx.ParamTags(`name:"db_sharding_clients"`, `name:"contact_application_shard_helper"`),
+			fx.ParamTags(
+				`name:"db_sharding_clients"`,
+				`name:"contact_application_shard_helper"`,
+				``,
+				`name:"contact_applicant_index_shard_helper"`,
+			),
+		),
+		fx.Annotate(
+			NewDefaultContactReverseIndexDao,
+			fx.As(new(ContactReverseIndexDao)),
+			fx.ParamTags(`name:"db_sharding_clients"`, `name:"contact_reverse_index_shard_helper"`),
 		),
 
 		fx.Annotate(
@@ -28,9 +43,61 @@ var DaoFxModule = fx.Module(
 			fx.ParamTags(`name:"db_sharding_clients"`, `name:"channel_application_shard_helper"`),
 		),
 
+		fx.Annotate(
+			NewDefaultChannelDao,
+			fx.As(new(ChannelDao)),
+			fx.ParamTags(`name:"db_sharding_clients"`, `name:"channel_shard_helper"`),
+		),
+		fx.Annotate(
+			NewDefaultChannelMemberDao,
+			fx.As(new(ChannelMemberDao)),
+			fx.ParamTags(`name:"db_sharding_clients"`, `name:"channel_member_shard_helper"`),
+		),
+
+		fx.Annotate(
+			NewDefaultUserConversationViewDao,
+			fx.As(new(UserConversationViewDao)),
+			fx.ParamTags(`name:"db_sharding_clients"`, `name:"user_conversation_view_shard_helper"`),
+		),
+
 		fx.Annotate(
 			NewMongoMessageDao,
 			fx.As(new(MessageDao)),
 		),
+		fx.Annotate(
+			NewMongoMessageDeletionDao,
+			fx.As(new(MessageDeletionDao)),
+		),
+
+		fx.Annotate(
+			NewMongoUserSyncLogDao,
+			fx.As(new(UserSyncLogDao)),
+		),
+
+		fx.Annotate(
+			NewMongoPushDeviceDao,
+			fx.As(new(PushDeviceDao)),
+		),
+		fx.Annotate(
+			NewMongoPushSettingDao,
+			fx.As(new(PushSettingDao)),
+		),
+
+		fx.Annotate(
+			NewRedisUserPresenceDao,
+			fx.As(new(UserPresenceDao)),
+		),
+		fx.Annotate(
+			NewMongoPresenceSettingDao,
+			fx.As(new(PresenceSettingDao)),
+		),
+
+		fx.Annotate(
+			NewRedisUserRouteDao,
+			fx.As(new(UserRouteDao)),
+		),
+
+		// 分片表的版本化迁移，用于启动时检查表结构是否与迁移一致。
+		SchemaMigrations,
 	),
 )
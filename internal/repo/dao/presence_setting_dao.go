@@ -0,0 +1,61 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmongo"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const presenceSettingCollName = "presence_setting"
+
+// PresenceSetting 用户在线状态隐私设置，以 uid 唯一标识一条记录。
+type PresenceSetting struct {
+	UID        uint64 `bson:"uid"`
+	Visibility string `bson:"visibility"`
+
+	UpdatedAt int64 `bson:"updatedAt"`
+}
+
+type PresenceSettingDao interface {
+	Upsert(ctx context.Context, setting PresenceSetting) error
+	// FindByUIDs 批量查询隐私设置，未设置的用户不会返回。
+	FindByUIDs(ctx context.Context, uids []uint64) ([]PresenceSetting, error)
+}
+
+var _ PresenceSettingDao = (*MongoPresenceSettingDao)(nil)
+
+type MongoPresenceSettingDao struct {
+	coll *mongo.Collection
+}
+
+func NewMongoPresenceSettingDao(collManager *xmongo.CollManager) *MongoPresenceSettingDao {
+	return &MongoPresenceSettingDao{
+		coll: collManager.Collection(presenceSettingCollName),
+	}
+}
+
+func (d *MongoPresenceSettingDao) Upsert(ctx context.Context, setting PresenceSetting) error {
+	_, err := d.coll.UpdateOne(
+		ctx,
+		bson.M{"uid": setting.UID},
+		bson.M{"$set": setting},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
+
+func (d *MongoPresenceSettingDao) FindByUIDs(ctx context.Context, uids []uint64) ([]PresenceSetting, error) {
+	cursor, err := d.coll.Find(ctx, bson.M{"uid": bson.M{"$in": uids}})
+	if err != nil {
+		return nil, err
+	}
+
+	var settings []PresenceSetting
+	if err := cursor.All(ctx, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
@@ -0,0 +1,84 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmongo"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const pushDeviceCollName = "push_device"
+
+// PushDevice 推送设备，以会话 ID ( sid ) 唯一标识一条记录。
+type PushDevice struct {
+	SID string `bson:"sid"`
+	UID uint64 `bson:"uid"`
+
+	Platform string `bson:"platform"`
+	Token    string `bson:"token"`
+
+	UpdatedAt int64 `bson:"updatedAt"`
+}
+
+type PushDeviceDao interface {
+	// Upsert 保存会话的设备 token ( 同一个 token 只保留最新的会话 )。
+	Upsert(ctx context.Context, device PushDevice) error
+
+	DeleteBySID(ctx context.Context, sid string) error
+	DeleteByToken(ctx context.Context, token string) error
+
+	ListByUID(ctx context.Context, uid uint64) ([]PushDevice, error)
+}
+
+var _ PushDeviceDao = (*MongoPushDeviceDao)(nil)
+
+type MongoPushDeviceDao struct {
+	coll *mongo.Collection
+}
+
+func NewMongoPushDeviceDao(collManager *xmongo.CollManager) *MongoPushDeviceDao {
+	return &MongoPushDeviceDao{
+		coll: collManager.Collection(pushDeviceCollName),
+	}
+}
+
+func (d *MongoPushDeviceDao) Upsert(ctx context.Context, device PushDevice) error {
+	// 设备切换账号后旧会话的 token 需要失效，避免推送给错误的用户。
+	_, err := d.coll.DeleteMany(ctx, bson.M{"token": device.Token, "sid": bson.M{"$ne": device.SID}})
+	if err != nil {
+		return err
+	}
+
+	_, err = d.coll.UpdateOne(
+		ctx,
+		bson.M{"sid": device.SID},
+		bson.M{"$set": device},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
+
+func (d *MongoPushDeviceDao) DeleteBySID(ctx context.Context, sid string) error {
+	_, err := d.coll.DeleteOne(ctx, bson.M{"sid": sid})
+	return err
+}
+
+func (d *MongoPushDeviceDao) DeleteByToken(ctx context.Context, token string) error {
+	_, err := d.coll.DeleteMany(ctx, bson.M{"token": token})
+	return err
+}
+
+func (d *MongoPushDeviceDao) ListByUID(ctx context.Context, uid uint64) ([]PushDevice, error) {
+	cursor, err := d.coll.Find(ctx, bson.M{"uid": uid})
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []PushDevice
+	if err := cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
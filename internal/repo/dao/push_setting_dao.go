@@ -0,0 +1,57 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmongo"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const pushSettingCollName = "push_setting"
+
+// PushSetting 用户推送设置，以 uid 唯一标识一条记录。
+type PushSetting struct {
+	UID uint64 `bson:"uid"`
+
+	DNDEnabled bool   `bson:"dndEnabled"`
+	DNDStart   int    `bson:"dndStart"`
+	DNDEnd     int    `bson:"dndEnd"`
+	Timezone   string `bson:"timezone"`
+
+	UpdatedAt int64 `bson:"updatedAt"`
+}
+
+type PushSettingDao interface {
+	Upsert(ctx context.Context, setting PushSetting) error
+	FindByUID(ctx context.Context, uid uint64) (PushSetting, error)
+}
+
+var _ PushSettingDao = (*MongoPushSettingDao)(nil)
+
+type MongoPushSettingDao struct {
+	coll *mongo.Collection
+}
+
+func NewMongoPushSettingDao(collManager *xmongo.CollManager) *MongoPushSettingDao {
+	return &MongoPushSettingDao{
+		coll: collManager.Collection(pushSettingCollName),
+	}
+}
+
+func (d *MongoPushSettingDao) Upsert(ctx context.Context, setting PushSetting) error {
+	_, err := d.coll.UpdateOne(
+		ctx,
+		bson.M{"uid": setting.UID},
+		bson.M{"$set": setting},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
+
+func (d *MongoPushSettingDao) FindByUID(ctx context.Context, uid uint64) (PushSetting, error) {
+	var setting PushSetting
+	err := d.coll.FindOne(ctx, bson.M{"uid": uid}).Decode(&setting)
+	return setting, err
+}
@@ -0,0 +1,32 @@
+package dao
+
+import "github.com/jrmarcco/hermet/internal/pkg/sharding/schema"
+
+// SchemaMigrations 分片表的版本化迁移，由 cmd/shardschema 在所有物理表上执行。
+//
+// 新增迁移时在对应逻辑表的列表末尾追加，版本号递增，已经发布的迁移不能修改：
+//
+//	{
+//		Table:       "biz_user",
+//		Version:     2,
+//		Description: "add biz_user.remark",
+//		Up:          schema.Exec("ALTER TABLE {{table}} ADD COLUMN IF NOT EXISTS remark VARCHAR(256) NOT NULL DEFAULT ''"),
+//		Down:        schema.Exec("ALTER TABLE {{table}} DROP COLUMN IF EXISTS remark"),
+//	},
+func SchemaMigrations() []schema.Migration {
+	return []schema.Migration{
+		schema.Baseline("biz_user"),
+		schema.Baseline("biz_user_mobile_index"),
+
+		schema.Baseline("user_contact"),
+		schema.Baseline("contact_application"),
+		schema.Baseline("contact_applicant_index"),
+		schema.Baseline("contact_reverse_index"),
+
+		schema.Baseline("channel_application"),
+		schema.Baseline("channel"),
+		schema.Baseline("channel_member"),
+
+		schema.Baseline("user_conversation_view"),
+	}
+}
@@ -0,0 +1,400 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
+	"github.com/jrmarcco/jit/xsync"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrShardRoute 无法计算分片目标 ( 分片值计算失败或者分片策略返回错误 )。
+	ErrShardRoute = errors.New("failed to route to shard")
+	// ErrShardDBNotFound 分片目标所在的数据库没有配置连接。
+	ErrShardDBNotFound = errors.New("shard database not found")
+	// ErrIndexConflict 唯一二级索引已经被其他记录占用。
+	ErrIndexConflict = errors.New("secondary index conflict")
+)
+
+// Scope 查询条件，与 gorm.DB.Scopes 的参数一致。
+type Scope = func(*gorm.DB) *gorm.DB
+
+// notDeleted 过滤已经删除的记录。
+func notDeleted(db *gorm.DB) *gorm.DB {
+	return db.Where("deleted_at = ?", 0)
+}
+
+// ShardedTable 分库分表的逻辑表，封装了 DAO 中 计算分片目标 -> 加载数据库 -> 指定物理表 的重复逻辑。
+//
+// - 写操作 ( Insert / Create / Put / UpdateByID / UpdateByShardKey / DeleteByShardKey ) 在虚拟桶迁移期间会自动双写到目标分片 ( Transaction 在事务提交之后双写 )；
+// - 读操作返回 gorm 原始的错误 ( 如 gorm.ErrRecordNotFound )，由 repo 转换为业务错误；
+// - 路由失败返回 ErrShardRoute，数据库没有配置返回 ErrShardDBNotFound；
+// - 按时间分表的逻辑表只能按 ID 或时间范围路由，按分片键访问 ( *ByShardKey / Put / Transaction ) 返回 ErrShardRoute。
+type ShardedTable[T any] struct {
+	dbs         *xsync.Map[string, *gorm.DB]
+	shardHelper *sharding.ShardHelper
+	migration   *migrate.Coordinator
+}
+
+// NewShardedTable 创建分库分表的逻辑表。
+// migration 为 nil 时不会双写。
+func NewShardedTable[T any](
+	dbs *xsync.Map[string, *gorm.DB],
+	shardHelper *sharding.ShardHelper,
+	migration *migrate.Coordinator,
+) *ShardedTable[T] {
+	return &ShardedTable[T]{
+		dbs:         dbs,
+		shardHelper: shardHelper,
+		migration:   migration,
+	}
+}
+
+// DBForDst 返回指定分片的物理表。
+func (t *ShardedTable[T]) DBForDst(ctx context.Context, dst sharding.Dst) (*gorm.DB, error) {
+	db, ok := t.dbs.Load(dst.DB)
+	if !ok {
+		return nil, fmt.Errorf("%w: [ %s ]", ErrShardDBNotFound, dst.DB)
+	}
+	return db.WithContext(ctx).Table(dst.TB).Model(new(T)), nil
+}
+
+// DBForShardKey 返回 sharder 所在分片的物理表。
+func (t *ShardedTable[T]) DBForShardKey(ctx context.Context, sharder sharding.Sharder) (*gorm.DB, sharding.Dst, error) {
+	dst, err := t.shardHelper.DstFromSharder(sharder)
+	if err != nil {
+		return nil, sharding.Dst{}, fmt.Errorf("%w: %w", ErrShardRoute, err)
+	}
+
+	db, err := t.DBForDst(ctx, dst)
+	if err != nil {
+		return nil, sharding.Dst{}, err
+	}
+	return db, dst, nil
+}
+
+// DBForID 返回 id 所在分片的物理表。
+func (t *ShardedTable[T]) DBForID(ctx context.Context, id uint64) (*gorm.DB, sharding.Dst, error) {
+	dst, err := t.shardHelper.DstFromID(id)
+	if err != nil {
+		return nil, sharding.Dst{}, fmt.Errorf("%w: id [ %d ]: %w", ErrShardRoute, id, err)
+	}
+
+	db, err := t.DBForDst(ctx, dst)
+	if err != nil {
+		return nil, sharding.Dst{}, err
+	}
+	return db, dst, nil
+}
+
+// NextID 根据 sharder 生成 ID，ID 中包含分片信息。
+// 需要在写入主表之前使用 ID 的场景 ( 如先写入二级索引 ) 使用 NextID + Create，否则直接使用 Insert。
+func (t *ShardedTable[T]) NextID(sharder sharding.Sharder) (uint64, error) {
+	id, err := t.shardHelper.NextID(sharder)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrShardRoute, err)
+	}
+	return id, nil
+}
+
+// Insert 根据 sharder 生成 ID 并写入所在分片。
+// build 根据生成的 ID 构造需要写入的记录 ( 设置 ID 以及创建时间等字段 )。
+func (t *ShardedTable[T]) Insert(ctx context.Context, sharder sharding.Sharder, build func(id uint64) T) (T, error) {
+	id, err := t.NextID(sharder)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return t.Create(ctx, id, build(id))
+}
+
+// Create 将 entity 写入 id 所在的分片，id 需要由 NextID 生成。
+func (t *ShardedTable[T]) Create(ctx context.Context, id uint64, entity T) (T, error) {
+	var zero T
+
+	db, dst, err := t.DBForID(ctx, id)
+	if err != nil {
+		return zero, err
+	}
+
+	if err := db.Create(&entity).Error; err != nil {
+		return zero, err
+	}
+
+	t.mirrorID(ctx, id, dst, func(db *gorm.DB, tb string) error {
+		return db.Table(tb).Model(new(T)).Clauses(clause.OnConflict{UpdateAll: true}).Create(&entity).Error
+	})
+	return entity, nil
+}
+
+// Put 将 entity 写入 sharder 所在的分片，主键已经存在时不写入并返回 false。
+// 用于没有自增 / 雪花 ID 的索引表，主键由业务字段组成。
+func (t *ShardedTable[T]) Put(ctx context.Context, sharder sharding.Sharder, entity T) (bool, error) {
+	shardVal, err := sharder.ShardVal()
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrShardRoute, err)
+	}
+
+	db, dst, err := t.DBForShardKey(ctx, sharder)
+	if err != nil {
+		return false, err
+	}
+
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entity)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+
+	t.mirror(ctx, shardVal, dst, func(db *gorm.DB, tb string) error {
+		return db.Table(tb).Model(new(T)).Clauses(clause.OnConflict{DoNothing: true}).Create(&entity).Error
+	})
+	return true, nil
+}
+
+// FindByID 根据 ID 查询一条记录，ID 中包含分片信息。
+func (t *ShardedTable[T]) FindByID(ctx context.Context, id uint64, scopes ...Scope) (T, error) {
+	var entity T
+
+	db, _, err := t.DBForID(ctx, id)
+	if err != nil {
+		return entity, err
+	}
+
+	err = db.Scopes(scopes...).Where("id = ?", id).First(&entity).Error
+	return entity, err
+}
+
+// FindByIDs 根据 ID 批量查询，按照 ID 所在的分片分组查询，不会广播。
+// 不存在的 ID 会被忽略，返回结果不保证与 ids 的顺序一致。
+func (t *ShardedTable[T]) FindByIDs(ctx context.Context, ids []uint64, scopes ...Scope) ([]T, error) {
+	groups := make(map[sharding.Dst][]uint64)
+	order := make([]sharding.Dst, 0)
+	for _, id := range ids {
+		dst, err := t.shardHelper.DstFromID(id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: id [ %d ]: %w", ErrShardRoute, id, err)
+		}
+		if _, ok := groups[dst]; !ok {
+			order = append(order, dst)
+		}
+		groups[dst] = append(groups[dst], id)
+	}
+
+	entities := make([]T, 0, len(ids))
+	for _, dst := range order {
+		db, err := t.DBForDst(ctx, dst)
+		if err != nil {
+			return nil, err
+		}
+
+		var found []T
+		if err := db.Scopes(scopes...).Where("id IN ?", groups[dst]).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		entities = append(entities, found...)
+	}
+	return entities, nil
+}
+
+// FindByShardKey 在 sharder 所在的分片上查询一条记录。
+func (t *ShardedTable[T]) FindByShardKey(ctx context.Context, sharder sharding.Sharder, scopes ...Scope) (T, error) {
+	var entity T
+
+	db, _, err := t.DBForShardKey(ctx, sharder)
+	if err != nil {
+		return entity, err
+	}
+
+	err = db.Scopes(scopes...).First(&entity).Error
+	return entity, err
+}
+
+// ListByShardKey 在 sharder 所在的分片上查询多条记录。
+func (t *ShardedTable[T]) ListByShardKey(ctx context.Context, sharder sharding.Sharder, scopes ...Scope) ([]T, error) {
+	db, _, err := t.DBForShardKey(ctx, sharder)
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []T
+	err = db.Scopes(scopes...).Find(&entities).Error
+	return entities, err
+}
+
+// UpdateByID 根据 ID 更新记录，返回更新的行数。
+// updates 在源 / 目标分片上共用，更新时间等字段需要在调用前计算好。
+func (t *ShardedTable[T]) UpdateByID(ctx context.Context, id uint64, updates map[string]any, scopes ...Scope) (int64, error) {
+	db, dst, err := t.DBForID(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	update := func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(scopes...).Where("id = ?", id).Updates(updates)
+	}
+
+	res := update(db)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.RowsAffected, res.Error
+	}
+
+	t.mirrorID(ctx, id, dst, func(db *gorm.DB, tb string) error {
+		return update(db.Table(tb).Model(new(T))).Error
+	})
+	return res.RowsAffected, nil
+}
+
+// UpdateByShardKey 在 sharder 所在的分片上更新记录，返回更新的行数。
+// updates 在源 / 目标分片上共用，更新时间等字段需要在调用前计算好。
+func (t *ShardedTable[T]) UpdateByShardKey(
+	ctx context.Context,
+	sharder sharding.Sharder,
+	updates map[string]any,
+	scopes ...Scope,
+) (int64, error) {
+	shardVal, err := sharder.ShardVal()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrShardRoute, err)
+	}
+
+	db, dst, err := t.DBForShardKey(ctx, sharder)
+	if err != nil {
+		return 0, err
+	}
+
+	update := func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(scopes...).Updates(updates)
+	}
+
+	res := update(db)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.RowsAffected, res.Error
+	}
+
+	t.mirror(ctx, shardVal, dst, func(db *gorm.DB, tb string) error {
+		return update(db.Table(tb).Model(new(T))).Error
+	})
+	return res.RowsAffected, nil
+}
+
+// DeleteByShardKey 在 sharder 所在的分片上删除记录，返回删除的行数。
+func (t *ShardedTable[T]) DeleteByShardKey(ctx context.Context, sharder sharding.Sharder, scopes ...Scope) (int64, error) {
+	shardVal, err := sharder.ShardVal()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrShardRoute, err)
+	}
+
+	db, dst, err := t.DBForShardKey(ctx, sharder)
+	if err != nil {
+		return 0, err
+	}
+
+	remove := func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(scopes...).Delete(new(T))
+	}
+
+	res := remove(db)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.RowsAffected, res.Error
+	}
+
+	t.mirror(ctx, shardVal, dst, func(db *gorm.DB, tb string) error {
+		return remove(db.Table(tb).Model(new(T))).Error
+	})
+	return res.RowsAffected, nil
+}
+
+// Broadcast 在所有分片上并发查询并合并结果，query 的 db 已经指定了物理表。
+func (t *ShardedTable[T]) Broadcast(
+	ctx context.Context,
+	query func(db *gorm.DB, limit int) ([]T, error),
+	opts sharding.ScatterOptions[T],
+) (sharding.ScatterResult[T], error) {
+	return t.scatter(ctx, t.shardHelper.Broadcast(), query, opts)
+}
+
+// BroadcastRange 在 [from, to] 时间范围内的分片上并发查询并合并结果，用于按时间分表的逻辑表。
+// 分片策略不支持按时间范围广播时与 Broadcast 相同。
+func (t *ShardedTable[T]) BroadcastRange(
+	ctx context.Context,
+	from, to time.Time,
+	query func(db *gorm.DB, limit int) ([]T, error),
+	opts sharding.ScatterOptions[T],
+) (sharding.ScatterResult[T], error) {
+	return t.scatter(ctx, t.shardHelper.BroadcastRange(from, to), query, opts)
+}
+
+func (t *ShardedTable[T]) scatter(
+	ctx context.Context,
+	dsts []sharding.Dst,
+	query func(db *gorm.DB, limit int) ([]T, error),
+	opts sharding.ScatterOptions[T],
+) (sharding.ScatterResult[T], error) {
+	return sharding.ScatterGather(ctx, dsts,
+		func(ctx context.Context, dst sharding.Dst, limit int) ([]T, error) {
+			db, err := t.DBForDst(ctx, dst)
+			if err != nil {
+				return nil, err
+			}
+			return query(db, limit)
+		},
+		opts,
+	)
+}
+
+// Transaction 在 sharder 所在分片的数据库上执行事务，tx 没有指定物理表，需要使用 tx.Table(dst.TB)。
+// 事务中的每个写操作都需要通过 mirror 登记在目标分片上执行的写操作 ( 与 Mirror 的 write 要求相同 )，
+// 虚拟桶迁移期间事务提交之后按登记顺序双写，事务回滚时不会双写。
+func (t *ShardedTable[T]) Transaction(
+	ctx context.Context,
+	sharder sharding.Sharder,
+	fn func(tx *gorm.DB, dst sharding.Dst, mirror func(write migrate.WriteFunc)) error,
+) error {
+	shardVal, err := sharder.ShardVal()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrShardRoute, err)
+	}
+
+	dst, err := t.shardHelper.DstFromSharder(sharder)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrShardRoute, err)
+	}
+
+	db, ok := t.dbs.Load(dst.DB)
+	if !ok {
+		return fmt.Errorf("%w: [ %s ]", ErrShardDBNotFound, dst.DB)
+	}
+
+	var writes []migrate.WriteFunc
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(tx, dst, func(write migrate.WriteFunc) {
+			writes = append(writes, write)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, write := range writes {
+		t.mirror(ctx, shardVal, dst, write)
+	}
+	return nil
+}
+
+func (t *ShardedTable[T]) mirror(ctx context.Context, shardVal uint64, dst sharding.Dst, write migrate.WriteFunc) {
+	if t.migration != nil {
+		t.migration.Mirror(ctx, shardVal, dst, write)
+	}
+}
+
+func (t *ShardedTable[T]) mirrorID(ctx context.Context, id uint64, dst sharding.Dst, write migrate.WriteFunc) {
+	if t.migration != nil {
+		t.migration.MirrorID(ctx, id, dst, write)
+	}
+}
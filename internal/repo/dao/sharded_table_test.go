@@ -0,0 +1,265 @@
+package dao
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen/snowflake"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
+	"github.com/jrmarcco/jit/xsync"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testUserTable        = "biz_user"
+	testMobileIndexTable = "biz_user_mobile_index"
+)
+
+// newShardedTestDBs 使用 sqlite 代替 postgres，创建 hermet_0 / hermet_1 两个库，每个库两张用户表和两张手机号索引表。
+func newShardedTestDBs(t *testing.T) *xsync.Map[string, *gorm.DB] {
+	t.Helper()
+
+	dbs := &xsync.Map[string, *gorm.DB]{}
+	for i := range 2 {
+		name := fmt.Sprintf("hermet_%d", i)
+		db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name+".db")), &gorm.Config{
+			Logger: logger.Discard,
+		})
+		require.NoError(t, err)
+
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		sqlDB.SetMaxOpenConns(1)
+		t.Cleanup(func() { _ = sqlDB.Close() })
+
+		for j := range 2 {
+			require.NoError(t, db.Table(fmt.Sprintf("%s_%d", testUserTable, j)).Migrator().CreateTable(&BizUser{}))
+			require.NoError(t, db.Exec(fmt.Sprintf(
+				"CREATE TABLE %s_%d (mobile TEXT PRIMARY KEY, user_id INTEGER, created_at INTEGER)",
+				testMobileIndexTable, j,
+			)).Error)
+		}
+		dbs.Store(name, db)
+	}
+	return dbs
+}
+
+func newModuloShardHelper(t *testing.T, tbPrefix string) *sharding.ShardHelper {
+	t.Helper()
+
+	strategy, err := sharding.NewModuloSharding(snowflake.NewExtractor(), "hermet", tbPrefix, 2, 2)
+	require.NoError(t, err)
+	helper, err := sharding.NewShardHelper(snowflake.NewGenerator(), strategy)
+	require.NoError(t, err)
+	return helper
+}
+
+// countRows 返回物理表中 id 对应的行数。
+func countRows(t *testing.T, dbs *xsync.Map[string, *gorm.DB], dst sharding.Dst, id uint64) int64 {
+	t.Helper()
+
+	db, ok := dbs.Load(dst.DB)
+	require.True(t, ok)
+
+	var cnt int64
+	require.NoError(t, db.Table(dst.TB).Where("id = ?", id).Count(&cnt).Error)
+	return cnt
+}
+
+func TestShardedTable_Route(t *testing.T) {
+	t.Parallel()
+
+	dbs := newShardedTestDBs(t)
+	helper := newModuloShardHelper(t, testUserTable)
+	table := NewShardedTable[BizUser](dbs, helper, nil)
+
+	ids := make([]uint64, 0, 8)
+	dsts := make(map[sharding.Dst]struct{})
+	for i := range 8 {
+		email := fmt.Sprintf("user-%d@hermet.io", i)
+		user, err := table.Insert(t.Context(), sharding.NewStringSharder(email), func(id uint64) BizUser {
+			return BizUser{ID: id, Email: email}
+		})
+		require.NoError(t, err)
+
+		// 记录写入 ID 所在的分片，也是分片键所在的分片。
+		dst, err := helper.DstFromID(user.ID)
+		require.NoError(t, err)
+		keyDst, err := helper.DstFromSharder(sharding.NewStringSharder(email))
+		require.NoError(t, err)
+		require.Equal(t, keyDst, dst)
+		require.EqualValues(t, 1, countRows(t, dbs, dst, user.ID))
+
+		found, err := table.FindByShardKey(t.Context(), sharding.NewStringSharder(email), func(db *gorm.DB) *gorm.DB {
+			return db.Where("email = ?", email)
+		})
+		require.NoError(t, err)
+		require.Equal(t, user.ID, found.ID)
+
+		ids = append(ids, user.ID)
+		dsts[dst] = struct{}{}
+	}
+	require.Greater(t, len(dsts), 1, "users should spread across shards")
+
+	// 按 ID 批量查询时按分片分组查询，不存在的 ID 被忽略。
+	missing := ids[0] + 1<<22
+	found, err := table.FindByIDs(t.Context(), append([]uint64{missing}, ids...))
+	require.NoError(t, err)
+	gotIDs := make([]uint64, 0, len(found))
+	for _, user := range found {
+		gotIDs = append(gotIDs, user.ID)
+	}
+	require.ElementsMatch(t, ids, gotIDs)
+
+	_, err = table.FindByID(t.Context(), missing)
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// 分片所在的数据库没有配置连接。
+	partial := &xsync.Map[string, *gorm.DB]{}
+	db, _ := dbs.Load("hermet_0")
+	partial.Store("hermet_0", db)
+	partialTable := NewShardedTable[BizUser](partial, helper, nil)
+	_, err = partialTable.FindByIDs(t.Context(), ids)
+	require.ErrorIs(t, err, ErrShardDBNotFound)
+}
+
+func TestDefaultBizUserDao_MobileIndexConflict(t *testing.T) {
+	t.Parallel()
+
+	dbs := newShardedTestDBs(t)
+	d := NewDefaultBizUserDao(dbs, newModuloShardHelper(t, testUserTable), nil, newModuloShardHelper(t, testMobileIndexTable))
+
+	const mobile = "13800000000"
+	saved, err := d.Save(t.Context(), BizUser{Email: "a@hermet.io", Mobile: mobile})
+	require.NoError(t, err)
+
+	found, err := d.FindByMobile(t.Context(), mobile)
+	require.NoError(t, err)
+	require.Equal(t, saved.ID, found.ID)
+
+	// 手机号已经被其他用户使用。
+	_, err = d.Save(t.Context(), BizUser{Email: "b@hermet.io", Mobile: mobile})
+	require.ErrorIs(t, err, ErrIndexConflict)
+
+	// 刚写入的索引指向的用户不存在时可能还在写入中，不能覆盖。
+	const staleMobile = "13900000000"
+	sharder := sharding.NewStringSharder(staleMobile)
+	inserted, err := d.mobileIndex.Put(t.Context(), sharder, BizUserMobileIndex{
+		Mobile:    staleMobile,
+		UserID:    saved.ID + 1<<22,
+		CreatedAt: time.Now().UnixMilli(),
+	})
+	require.NoError(t, err)
+	require.True(t, inserted)
+	_, err = d.Save(t.Context(), BizUser{Email: "c@hermet.io", Mobile: staleMobile})
+	require.ErrorIs(t, err, ErrIndexConflict)
+
+	// 超过 mobileIndexClaimGrace 的残留索引被覆盖。
+	_, err = d.mobileIndex.UpdateByShardKey(t.Context(), sharder,
+		map[string]any{"created_at": time.Now().Add(-2 * mobileIndexClaimGrace).UnixMilli()},
+		byMobile(staleMobile),
+	)
+	require.NoError(t, err)
+	saved, err = d.Save(t.Context(), BizUser{Email: "c@hermet.io", Mobile: staleMobile})
+	require.NoError(t, err)
+	found, err = d.FindByMobile(t.Context(), staleMobile)
+	require.NoError(t, err)
+	require.Equal(t, saved.ID, found.ID)
+}
+
+func TestShardedTable_Mirror(t *testing.T) {
+	t.Parallel()
+
+	dbs := newShardedTestDBs(t)
+	bucketTable, err := sharding.NewModuloBucketTable(2, 2)
+	require.NoError(t, err)
+	strategy, err := sharding.NewBucketSharding(snowflake.NewExtractor(), "hermet", testUserTable, bucketTable)
+	require.NoError(t, err)
+	helper, err := sharding.NewShardHelper(snowflake.NewGenerator(), strategy)
+	require.NoError(t, err)
+
+	// 虚拟桶 5 在 2 * 2 的取模路由中位于 hermet_1.biz_user_0，正在迁移到 hermet_0.biz_user_1。
+	const bucket = 5
+	source, err := strategy.DstFromBucket(bucket)
+	require.NoError(t, err)
+	target := strategy.DstOf(0, 1)
+
+	store := migrate.NewRedisStore(newTestRedis(t))
+	require.NoError(t, store.Save(t.Context(), migrate.Checkpoint{
+		Task:  migrate.Task{Table: testUserTable, Bucket: bucket, Source: source, Target: target},
+		Phase: migrate.PhaseDualWrite,
+	}))
+	coordinator := migrate.NewCoordinator(store, dbs, snowflake.NewExtractor(), nil)
+	coordinator.Register(testUserTable, strategy)
+	require.NoError(t, coordinator.Refresh(t.Context()))
+
+	table := NewShardedTable[BizUser](dbs, helper, coordinator)
+	sharder := sharding.NewSingleIDSharder(bucket)
+
+	// 写入源分片后同步写入目标分片。
+	user, err := table.Insert(t.Context(), sharder, func(id uint64) BizUser {
+		return BizUser{ID: id, Email: "a@hermet.io"}
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, countRows(t, dbs, source, user.ID))
+	require.EqualValues(t, 1, countRows(t, dbs, target, user.ID))
+
+	affected, err := table.UpdateByID(t.Context(), user.ID, map[string]any{"nickname": "hermet"})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, affected)
+	targetDB, _ := dbs.Load(target.DB)
+	var mirrored BizUser
+	require.NoError(t, targetDB.Table(target.TB).Where("id = ?", user.ID).First(&mirrored).Error)
+	require.Equal(t, "hermet", mirrored.Nickname)
+
+	// 不在迁移中的虚拟桶不会双写。
+	other, err := table.Insert(t.Context(), sharding.NewSingleIDSharder(bucket+2), func(id uint64) BizUser {
+		return BizUser{ID: id, Email: "b@hermet.io"}
+	})
+	require.NoError(t, err)
+	otherDst, err := helper.DstFromID(other.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, countRows(t, dbs, otherDst, other.ID))
+	require.EqualValues(t, 0, countRows(t, dbs, target, other.ID))
+
+	// 事务提交之后双写登记的写操作，回滚时不会双写。
+	errRollback := errors.New("rollback")
+	for _, commit := range []bool{true, false} {
+		id, err := table.NextID(sharder)
+		require.NoError(t, err)
+
+		err = table.Transaction(t.Context(), sharder,
+			func(tx *gorm.DB, dst sharding.Dst, mirror func(write migrate.WriteFunc)) error {
+				row := BizUser{ID: id, Email: "c@hermet.io"}
+				if err := tx.Table(dst.TB).Create(&row).Error; err != nil {
+					return err
+				}
+				mirror(func(db *gorm.DB, tb string) error {
+					return db.Table(tb).Create(&row).Error
+				})
+				if !commit {
+					return errRollback
+				}
+				return nil
+			},
+		)
+
+		want := int64(0)
+		if commit {
+			require.NoError(t, err)
+			want = 1
+		} else {
+			require.ErrorIs(t, err, errRollback)
+		}
+		require.Equal(t, want, countRows(t, dbs, source, id))
+		require.Equal(t, want, countRows(t, dbs, target, id))
+	}
+	require.Zero(t, coordinator.MirrorFailures())
+}
@@ -2,10 +2,9 @@ package dao
 
 import (
 	"context"
-	"fmt"
 
-	"github.com/jrmarcco/hermet/internal/errs"
 	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
 	"github.com/jrmarcco/jit/xsync"
 	"gorm.io/gorm"
 )
@@ -35,42 +34,46 @@ type UserContact struct {
 
 type UserContactDao interface {
 	FindByUserIDAndContactID(ctx context.Context, userID, contactID uint64) (UserContact, error)
+	// FindByUserIDAndContactIDs 批量查询用户的联系人 ( 不是联系人的 contactID 会被忽略 )。
+	FindByUserIDAndContactIDs(ctx context.Context, userID uint64, contactIDs []uint64) ([]UserContact, error)
 }
 
 var _ UserContactDao = (*DefaultUserContactDao)(nil)
 
 type DefaultUserContactDao struct {
-	dbs         *xsync.Map[string, *gorm.DB]
-	shardHelper *sharding.ShardHelper
+	table *ShardedTable[UserContact]
 }
 
-func NewDefaultUserContactDao(dbs *xsync.Map[string, *gorm.DB], shardHelper *sharding.ShardHelper) *DefaultUserContactDao {
+func NewDefaultUserContactDao(
+	dbs *xsync.Map[string, *gorm.DB],
+	shardHelper *sharding.ShardHelper,
+	migration *migrate.Coordinator,
+) *DefaultUserContactDao {
 	return &DefaultUserContactDao{
-		dbs:         dbs,
-		shardHelper: shardHelper,
+		table: NewShardedTable[UserContact](dbs, shardHelper, migration),
 	}
 }
 
-func (d *DefaultUserContactDao) FindByUserIDAndContactID(ctx context.Context, userID, contactID uint64) (UserContact, error) {
-	dst, err := d.shardHelper.DstFromSharder(sharding.NewSingleIDSharder(userID))
-	if err != nil {
-		return UserContact{}, err
-	}
-
-	db, ok := d.dbs.Load(dst.DB)
-	if !ok {
-		return UserContact{}, fmt.Errorf("database not found: %w", errs.ErrRecordNotFound)
+func (d *DefaultUserContactDao) FindByUserIDAndContactIDs(
+	ctx context.Context,
+	userID uint64,
+	contactIDs []uint64,
+) ([]UserContact, error) {
+	if len(contactIDs) == 0 {
+		return nil, nil
 	}
 
-	var uc UserContact
-	err = db.WithContext(ctx).Table(dst.TB).Model(&UserContact{}).
-		Where("user_id = ?", userID).
-		Where("contact_id = ?", contactID).
-		Where("deleted_at = ?", 0).
-		First(&uc).Error
-	if err != nil {
-		return UserContact{}, err
-	}
+	return d.table.ListByShardKey(ctx, sharding.NewSingleIDSharder(userID), notDeleted, func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("user_id = ?", userID).
+			Where("contact_id IN ?", contactIDs)
+	})
+}
 
-	return uc, nil
+func (d *DefaultUserContactDao) FindByUserIDAndContactID(ctx context.Context, userID, contactID uint64) (UserContact, error) {
+	return d.table.FindByShardKey(ctx, sharding.NewSingleIDSharder(userID), notDeleted, func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("user_id = ?", userID).
+			Where("contact_id = ?", contactID)
+	})
 }
@@ -0,0 +1,182 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/migrate"
+	"github.com/jrmarcco/jit/xsync"
+	"gorm.io/gorm"
+)
+
+// UserConversationView 【 读取侧 】用户会话视图表。
+// 以 UserID 为分片键。
+type UserConversationView struct {
+	ID uint64 `gorm:"column:id"`
+
+	UserID    uint64 `gorm:"column:user_id"`
+	ChannelID uint64 `gorm:"column:channel_id"`
+
+	ConversationType    string `gorm:"column:conversation_type"`
+	ConversationName    string `gorm:"column:conversation_name"`
+	ConversationAvatar  string `gorm:"column:conversation_avatar"`
+	ConversationInfoVer int    `gorm:"column:conversation_info_ver"`
+
+	PeerUserID   sql.NullInt64  `gorm:"column:peer_user_id"`
+	PeerNickname sql.NullString `gorm:"column:peer_nickname"`
+	PeerAvatar   sql.NullString `gorm:"column:peer_avatar"`
+	RemarkName   sql.NullString `gorm:"column:remark_name"`
+
+	IsMuted   bool `gorm:"column:is_muted"`
+	IsPinned  bool `gorm:"column:is_pinned"`
+	IsHidden  bool `gorm:"column:is_hidden"`
+	IsStarred bool `gorm:"column:is_starred"`
+
+	LastMessageID         uint64 `gorm:"column:last_message_id"`
+	LastMessageType       string `gorm:"column:last_message_type"`
+	LastMessageContent    string `gorm:"column:last_message_content"`
+	LastMessageSenderID   uint64 `gorm:"column:last_message_sender_id"`
+	LastMessageSenderName string `gorm:"column:last_message_sender_name"`
+	LastMessageTime       int64  `gorm:"column:last_message_time"`
+
+	UnreadCount          int    `gorm:"column:unread_count"`
+	MentionCount         int    `gorm:"column:mention_count"`
+	LastMentionMessageID uint64 `gorm:"column:last_mention_message_id"`
+
+	OpenedAt int64 `gorm:"column:opened_at"`
+	ClosedAt int64 `gorm:"column:closed_at"`
+
+	CreatedAt int64 `gorm:"column:created_at"`
+	UpdatedAt int64 `gorm:"column:updated_at"`
+}
+
+type UserConversationViewDao interface {
+	FindByUserIDAndChannelID(ctx context.Context, userID, channelID uint64) (UserConversationView, error)
+	// ListByUserID 查询用户的会话列表 ( 置顶优先，按最后消息时间倒序，不包含已关闭和隐藏的会话 )。
+	ListByUserID(ctx context.Context, userID uint64, offset, limit int) ([]UserConversationView, error)
+
+	// RecordMention 记录一条 @ 消息，@ 消息数加 1 并更新最后一条 @ 消息 ID。
+	RecordMention(ctx context.Context, userID, channelID, messageID uint64) error
+	// ClearMention 用户已读到 readMessageID 时清除 @ 标记 ( 只有最后一条 @ 消息已读时才会清除 )。
+	ClearMention(ctx context.Context, userID, channelID, readMessageID uint64) error
+	// RevokeMention 撤回一条 @ 消息，@ 消息数减 1 ( 减为 0 时同时清除最后一条 @ 消息 ID )。
+	RevokeMention(ctx context.Context, userID, channelID, messageID uint64) error
+
+	// Close 关闭用户会话 ( 如频道解散 )。
+	Close(ctx context.Context, userID, channelID uint64, closedAt int64) error
+}
+
+var _ UserConversationViewDao = (*DefaultUserConversationViewDao)(nil)
+
+type DefaultUserConversationViewDao struct {
+	table *ShardedTable[UserConversationView]
+}
+
+func NewDefaultUserConversationViewDao(
+	dbs *xsync.Map[string, *gorm.DB],
+	shardHelper *sharding.ShardHelper,
+	migration *migrate.Coordinator,
+) *DefaultUserConversationViewDao {
+	return &DefaultUserConversationViewDao{
+		table: NewShardedTable[UserConversationView](dbs, shardHelper, migration),
+	}
+}
+
+func (d *DefaultUserConversationViewDao) FindByUserIDAndChannelID(
+	ctx context.Context,
+	userID, channelID uint64,
+) (UserConversationView, error) {
+	return d.table.FindByShardKey(ctx, sharding.NewSingleIDSharder(userID), byUserAndChannel(userID, channelID))
+}
+
+func (d *DefaultUserConversationViewDao) Close(ctx context.Context, userID, channelID uint64, closedAt int64) error {
+	_, err := d.table.UpdateByShardKey(ctx, sharding.NewSingleIDSharder(userID),
+		map[string]any{
+			"closed_at":  closedAt,
+			"updated_at": time.Now().UnixMilli(),
+		},
+		byUserAndChannel(userID, channelID),
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("closed_at = ?", 0)
+		},
+	)
+	return err
+}
+
+func (d *DefaultUserConversationViewDao) ListByUserID(
+	ctx context.Context,
+	userID uint64,
+	offset, limit int,
+) ([]UserConversationView, error) {
+	return d.table.ListByShardKey(ctx, sharding.NewSingleIDSharder(userID), func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("user_id = ?", userID).
+			Where("closed_at = ?", 0).
+			Where("is_hidden = ?", false).
+			Order("is_pinned DESC").
+			Order("last_message_time DESC").
+			Offset(offset).
+			Limit(limit)
+	})
+}
+
+func (d *DefaultUserConversationViewDao) RecordMention(ctx context.Context, userID, channelID, messageID uint64) error {
+	_, err := d.table.UpdateByShardKey(ctx, sharding.NewSingleIDSharder(userID),
+		map[string]any{
+			"mention_count":           gorm.Expr("mention_count + 1"),
+			"last_mention_message_id": gorm.Expr("GREATEST(last_mention_message_id, ?)", messageID),
+			"updated_at":              time.Now().UnixMilli(),
+		},
+		byUserAndChannel(userID, channelID),
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("closed_at = ?", 0)
+		},
+	)
+	return err
+}
+
+func (d *DefaultUserConversationViewDao) ClearMention(ctx context.Context, userID, channelID, readMessageID uint64) error {
+	_, err := d.table.UpdateByShardKey(ctx, sharding.NewSingleIDSharder(userID),
+		map[string]any{
+			"mention_count":           0,
+			"last_mention_message_id": 0,
+			"updated_at":              time.Now().UnixMilli(),
+		},
+		byUserAndChannel(userID, channelID),
+		func(db *gorm.DB) *gorm.DB {
+			return db.
+				Where("last_mention_message_id > ?", 0).
+				Where("last_mention_message_id <= ?", readMessageID)
+		},
+	)
+	return err
+}
+
+func (d *DefaultUserConversationViewDao) RevokeMention(ctx context.Context, userID, channelID, messageID uint64) error {
+	_, err := d.table.UpdateByShardKey(ctx, sharding.NewSingleIDSharder(userID),
+		map[string]any{
+			// SET 中的表达式引用的都是更新前的值。
+			"mention_count": gorm.Expr("GREATEST(mention_count - 1, 0)"),
+			"last_mention_message_id": gorm.Expr(
+				"CASE WHEN mention_count <= 1 THEN 0 ELSE last_mention_message_id END",
+			),
+			"updated_at": time.Now().UnixMilli(),
+		},
+		byUserAndChannel(userID, channelID),
+		func(db *gorm.DB) *gorm.DB {
+			// 最后一条 @ 消息早于撤回的消息说明 @ 标记已经清除过 ( 撤回的消息已读 )。
+			return db.Where("last_mention_message_id >= ?", messageID)
+		},
+	)
+	return err
+}
+
+func byUserAndChannel(userID, channelID uint64) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("user_id = ?", userID).
+			Where("channel_id = ?", channelID)
+	}
+}
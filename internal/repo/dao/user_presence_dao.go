@@ -0,0 +1,170 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// heartbeatScript 记录设备心跳，返回心跳前仍然在线的设备数 ( 包括当前设备未过期的记录 )。
+// KEYS[1]: 在线设备 hash ( sid -> 过期时间戳 )
+// ARGV[1]: sid，ARGV[2]: 当前时间戳，ARGV[3]: 过期时间戳，ARGV[4]: ttl ( 毫秒 )
+var heartbeatScript = redis.NewScript(`
+local alive = 0
+local devices = redis.call('HGETALL', KEYS[1])
+for i = 1, #devices, 2 do
+	if tonumber(devices[i + 1]) <= tonumber(ARGV[2]) then
+		redis.call('HDEL', KEYS[1], devices[i])
+	else
+		alive = alive + 1
+	end
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return alive
+`)
+
+// offlineScript 移除设备，返回仍然在线的设备数 ( 为 0 时删除 key )。
+// KEYS[1]: 在线设备 hash ( sid -> 过期时间戳 )
+// ARGV[1]: sid，ARGV[2]: 当前时间戳
+var offlineScript = redis.NewScript(`
+redis.call('HDEL', KEYS[1], ARGV[1])
+local alive = 0
+local devices = redis.call('HGETALL', KEYS[1])
+for i = 1, #devices, 2 do
+	if tonumber(devices[i + 1]) <= tonumber(ARGV[2]) then
+		redis.call('HDEL', KEYS[1], devices[i])
+	else
+		alive = alive + 1
+	end
+end
+if alive == 0 then
+	redis.call('DEL', KEYS[1])
+end
+return alive
+`)
+
+// UserPresenceDao 用户在线状态。
+// 每个用户的在线设备保存在一个 hash 中 ( 会话 ID -> 过期时间 )，设备通过心跳续期，
+// 所有设备都过期后 key 会被 redis 自动删除，用户视为离线。
+type UserPresenceDao interface {
+	// Heartbeat 记录设备心跳，返回 true 表示用户从离线变为在线。
+	Heartbeat(ctx context.Context, uid uint64, sid string, ttl time.Duration) (bool, error)
+	// Offline 设备下线，返回 true 表示用户的所有设备都已下线。
+	Offline(ctx context.Context, uid uint64, sid string) (bool, error)
+
+	// FilterOnline 从 uids 中找出在线的用户。
+	FilterOnline(ctx context.Context, uids []uint64) (map[uint64]bool, error)
+
+	SetLastSeen(ctx context.Context, uid uint64, lastSeen int64) error
+	// GetLastSeen 批量查询最后在线时间，从未在线过的用户不会返回。
+	GetLastSeen(ctx context.Context, uids []uint64) (map[uint64]int64, error)
+
+	// MarkTyping 标记用户正在输入，返回 false 表示 ttl 内已经标记过 ( 用于限流 )。
+	MarkTyping(ctx context.Context, uid, cid uint64, ttl time.Duration) (bool, error)
+}
+
+var _ UserPresenceDao = (*RedisUserPresenceDao)(nil)
+
+type RedisUserPresenceDao struct {
+	rdb redis.Cmdable
+}
+
+func NewRedisUserPresenceDao(rdb redis.Cmdable) *RedisUserPresenceDao {
+	return &RedisUserPresenceDao{
+		rdb: rdb,
+	}
+}
+
+func (d *RedisUserPresenceDao) Heartbeat(ctx context.Context, uid uint64, sid string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	alive, err := heartbeatScript.Run(
+		ctx, d.rdb, []string{d.onlineKey(uid)},
+		sid, now.UnixMilli(), now.Add(ttl).UnixMilli(), ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	return alive == 0, nil
+}
+
+func (d *RedisUserPresenceDao) Offline(ctx context.Context, uid uint64, sid string) (bool, error) {
+	alive, err := offlineScript.Run(
+		ctx, d.rdb, []string{d.onlineKey(uid)},
+		sid, time.Now().UnixMilli(),
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	return alive == 0, nil
+}
+
+func (d *RedisUserPresenceDao) FilterOnline(ctx context.Context, uids []uint64) (map[uint64]bool, error) {
+	if len(uids) == 0 {
+		return map[uint64]bool{}, nil
+	}
+
+	pipe := d.rdb.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(uids))
+	for _, uid := range uids {
+		cmds = append(cmds, pipe.Exists(ctx, d.onlineKey(uid)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	online := make(map[uint64]bool, len(uids))
+	for i, cmd := range cmds {
+		if cmd.Val() > 0 {
+			online[uids[i]] = true
+		}
+	}
+	return online, nil
+}
+
+func (d *RedisUserPresenceDao) SetLastSeen(ctx context.Context, uid uint64, lastSeen int64) error {
+	return d.rdb.Set(ctx, d.lastSeenKey(uid), lastSeen, 0).Err()
+}
+
+func (d *RedisUserPresenceDao) GetLastSeen(ctx context.Context, uids []uint64) (map[uint64]int64, error) {
+	if len(uids) == 0 {
+		return map[uint64]int64{}, nil
+	}
+
+	pipe := d.rdb.Pipeline()
+	cmds := make([]*redis.StringCmd, 0, len(uids))
+	for _, uid := range uids {
+		cmds = append(cmds, pipe.Get(ctx, d.lastSeenKey(uid)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	lastSeen := make(map[uint64]int64, len(uids))
+	for i, cmd := range cmds {
+		val, err := cmd.Int64()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			return nil, err
+		}
+		lastSeen[uids[i]] = val
+	}
+	return lastSeen, nil
+}
+
+func (d *RedisUserPresenceDao) MarkTyping(ctx context.Context, uid, cid uint64, ttl time.Duration) (bool, error) {
+	return d.rdb.SetNX(ctx, fmt.Sprintf("user:typing:%d:%d", cid, uid), 1, ttl).Result()
+}
+
+func (d *RedisUserPresenceDao) onlineKey(uid uint64) string {
+	return fmt.Sprintf("user:online:%d", uid)
+}
+
+func (d *RedisUserPresenceDao) lastSeenKey(uid uint64) string {
+	return fmt.Sprintf("user:last_seen:%d", uid)
+}
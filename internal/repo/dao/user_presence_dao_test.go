@@ -0,0 +1,54 @@
+package dao
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedis(t *testing.T) redis.Cmdable {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return rdb
+}
+
+func TestRedisUserPresenceDao_Heartbeat(t *testing.T) {
+	t.Parallel()
+
+	const uid = uint64(1)
+
+	d := NewRedisUserPresenceDao(newTestRedis(t))
+	heartbeat := func(sid string, ttl time.Duration) bool {
+		t.Helper()
+
+		online, err := d.Heartbeat(t.Context(), uid, sid, ttl)
+		require.NoError(t, err)
+		return online
+	}
+
+	// 第一次心跳从离线变为在线，单设备的后续心跳不再视为上线。
+	require.True(t, heartbeat("s1", time.Minute))
+	require.False(t, heartbeat("s1", time.Minute))
+	require.False(t, heartbeat("s1", time.Minute))
+
+	// 其他设备上线时用户已经在线。
+	require.False(t, heartbeat("s2", time.Minute))
+
+	offline, err := d.Offline(t.Context(), uid, "s1")
+	require.NoError(t, err)
+	require.False(t, offline)
+	offline, err = d.Offline(t.Context(), uid, "s2")
+	require.NoError(t, err)
+	require.True(t, offline)
+
+	require.True(t, heartbeat("s1", time.Millisecond))
+	// 当前设备的记录已经过期，再次心跳视为重新上线。
+	time.Sleep(5 * time.Millisecond)
+	require.True(t, heartbeat("s1", time.Minute))
+}
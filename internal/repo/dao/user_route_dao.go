@@ -0,0 +1,122 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// registerScript 记录会话连接所在的节点并清理已经过期的会话。
+// 每个会话单独记录过期时间 ( 值为 "{过期时间戳}:{节点 ID}" )，key 的过期时间只会延长，所有会话都过期后 key 才会被删除。
+// KEYS[1]: 路由 hash ( sid -> 过期时间戳:节点 ID )
+// ARGV[1]: sid，ARGV[2]: 当前时间戳，ARGV[3]: 过期时间戳，ARGV[4]: 节点 ID，ARGV[5]: ttl ( 毫秒 )
+var registerScript = redis.NewScript(`
+local routes = redis.call('HGETALL', KEYS[1])
+for i = 1, #routes, 2 do
+	local expireAt = tonumber(string.match(routes[i + 1], '^(%d+):'))
+	if expireAt == nil or expireAt <= tonumber(ARGV[2]) then
+		redis.call('HDEL', KEYS[1], routes[i])
+	end
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3] .. ':' .. ARGV[4])
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[5]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[5])
+end
+return 1
+`)
+
+// findNodesScript 返回未过期的会话所在的节点 ( 可能重复 )，并清理已经过期的会话。
+// KEYS[1]: 路由 hash ( sid -> 过期时间戳:节点 ID )
+// ARGV[1]: 当前时间戳
+var findNodesScript = redis.NewScript(`
+local nodes = {}
+local routes = redis.call('HGETALL', KEYS[1])
+for i = 1, #routes, 2 do
+	local expireAt, node = string.match(routes[i + 1], '^(%d+):(.*)$')
+	if expireAt == nil or tonumber(expireAt) <= tonumber(ARGV[1]) then
+		redis.call('HDEL', KEYS[1], routes[i])
+	else
+		table.insert(nodes, node)
+	end
+end
+return nodes
+`)
+
+// UserRouteDao 用户连接路由表，记录用户的每个会话连接在哪个节点上。
+// 每个用户一个 hash ( 会话 ID -> 过期时间以及节点 ID )，由节点定时续期，
+// 每个会话单独过期，节点宕机后该节点上的会话路由会自动失效。
+type UserRouteDao interface {
+	Register(ctx context.Context, uid uint64, sid, nodeID string, ttl time.Duration) error
+	Unregister(ctx context.Context, uid uint64, sid string) error
+
+	// FindNodes 批量查询用户连接所在的节点 ( uid -> 节点 ID 列表 )，没有连接的用户不会返回。
+	FindNodes(ctx context.Context, uids []uint64) (map[uint64][]string, error)
+}
+
+var _ UserRouteDao = (*RedisUserRouteDao)(nil)
+
+type RedisUserRouteDao struct {
+	rdb redis.Cmdable
+}
+
+func NewRedisUserRouteDao(rdb redis.Cmdable) *RedisUserRouteDao {
+	return &RedisUserRouteDao{
+		rdb: rdb,
+	}
+}
+
+func (d *RedisUserRouteDao) Register(ctx context.Context, uid uint64, sid, nodeID string, ttl time.Duration) error {
+	now := time.Now()
+	return registerScript.Run(
+		ctx, d.rdb, []string{d.key(uid)},
+		sid, now.UnixMilli(), now.Add(ttl).UnixMilli(), nodeID, ttl.Milliseconds(),
+	).Err()
+}
+
+func (d *RedisUserRouteDao) Unregister(ctx context.Context, uid uint64, sid string) error {
+	return d.rdb.HDel(ctx, d.key(uid), sid).Err()
+}
+
+func (d *RedisUserRouteDao) FindNodes(ctx context.Context, uids []uint64) (map[uint64][]string, error) {
+	if len(uids) == 0 {
+		return map[uint64][]string{}, nil
+	}
+
+	// 在 pipeline 中不能使用 EVALSHA 失败后重试 EVAL，直接使用 EVAL。
+	now := time.Now().UnixMilli()
+	pipe := d.rdb.Pipeline()
+	cmds := make([]*redis.Cmd, 0, len(uids))
+	for _, uid := range uids {
+		cmds = append(cmds, findNodesScript.Eval(ctx, pipe, []string{d.key(uid)}, now))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint64][]string, len(uids))
+	for i, cmd := range cmds {
+		vals, err := cmd.StringSlice()
+		if err != nil {
+			return nil, err
+		}
+		for _, nodeID := range vals {
+			nodes[uids[i]] = appendUnique(nodes[uids[i]], nodeID)
+		}
+	}
+	return nodes, nil
+}
+
+func (d *RedisUserRouteDao) key(uid uint64) string {
+	return fmt.Sprintf("user:route:%d", uid)
+}
+
+func appendUnique(s []string, v string) []string {
+	for i := range s {
+		if s[i] == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisUserRouteDao_FindNodes(t *testing.T) {
+	t.Parallel()
+
+	rdb := newTestRedis(t)
+	d := NewRedisUserRouteDao(rdb)
+	ctx := t.Context()
+
+	require.NoError(t, d.Register(ctx, 1, "s1", "10.0.0.1:9000", time.Minute))
+	require.NoError(t, d.Register(ctx, 1, "s2", "10.0.0.1:9000", time.Minute))
+	require.NoError(t, d.Register(ctx, 1, "s3", "10.0.0.2:9000", time.Minute))
+	require.NoError(t, d.Register(ctx, 2, "s4", "10.0.0.2:9000", time.Minute))
+
+	nodes, err := d.FindNodes(ctx, []uint64{1, 2, 3})
+	require.NoError(t, err)
+	require.Len(t, nodes, 2)
+	require.ElementsMatch(t, []string{"10.0.0.1:9000", "10.0.0.2:9000"}, nodes[1])
+	require.Equal(t, []string{"10.0.0.2:9000"}, nodes[2])
+
+	require.NoError(t, d.Unregister(ctx, 1, "s3"))
+	nodes, err = d.FindNodes(ctx, []uint64{1})
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1:9000"}, nodes[1])
+
+	nodes, err = d.FindNodes(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, nodes)
+}
+
+func TestRedisUserRouteDao_ExpirePerSession(t *testing.T) {
+	t.Parallel()
+
+	rdb := newTestRedis(t)
+	d := NewRedisUserRouteDao(rdb)
+	ctx := t.Context()
+	key := d.key(1)
+
+	// s1 所在的节点宕机后不再续期，s2 所在的节点持续续期。
+	require.NoError(t, d.Register(ctx, 1, "s1", "node-1", 20*time.Millisecond))
+	require.NoError(t, d.Register(ctx, 1, "s2", "node-2", time.Minute))
+	time.Sleep(30 * time.Millisecond)
+	require.NoError(t, d.Register(ctx, 1, "s2", "node-2", time.Minute))
+
+	// 续期时清理已经过期的会话。
+	fields, err := rdb.HKeys(ctx, key).Result()
+	require.NoError(t, err)
+	require.Equal(t, []string{"s2"}, fields)
+
+	// 查询时过滤并清理已经过期的会话。
+	require.NoError(t, d.Register(ctx, 1, "s3", "node-3", 20*time.Millisecond))
+	time.Sleep(30 * time.Millisecond)
+	nodes, err := d.FindNodes(ctx, []uint64{1})
+	require.NoError(t, err)
+	require.Equal(t, []string{"node-2"}, nodes[1])
+
+	fields, err = rdb.HKeys(ctx, key).Result()
+	require.NoError(t, err)
+	require.Equal(t, []string{"s2"}, fields)
+
+	// 较短 ttl 的会话不会缩短 key 的过期时间。
+	ttl, err := rdb.PTTL(ctx, key).Result()
+	require.NoError(t, err)
+	require.Greater(t, ttl, 30*time.Second)
+}
@@ -0,0 +1,141 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmongo"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	userSyncLogCollName  = "user_sync_log"
+	userSyncLogTTLIndex  = "createdAt_ttl"
+	indexOptionsConflict = 85 // MongoDB IndexOptionsConflict 错误码
+)
+
+// UserSyncLog 用户同步日志，记录用户在每个同步序列号上收到的消息。
+// 以 ( uid, seq ) 唯一标识一条记录，过期数据由 TTL 索引 ( createdAt ) 清理。
+type UserSyncLog struct {
+	UID uint64 `bson:"uid"`
+	Seq uint64 `bson:"seq"`
+
+	CID       uint64 `bson:"cid"`
+	MessageID uint64 `bson:"messageId"`
+
+	CreatedAt time.Time `bson:"createdAt"` // TTL 索引只对日期类型生效
+}
+
+type UserSyncLogDao interface {
+	// EnsureIndexes 创建 ( uid, seq ) 唯一索引以及 createdAt 的 TTL 索引 ( ttl 为 0 时不创建 TTL 索引 )。
+	EnsureIndexes(ctx context.Context, ttl time.Duration) error
+
+	Save(ctx context.Context, log UserSyncLog) error
+
+	// ListAfter 按序列号升序查询用户序列号大于 afterSeq 的同步日志。
+	ListAfter(ctx context.Context, uid, afterSeq uint64, limit int) ([]UserSyncLog, error)
+
+	// MinSeq 查询用户仍保留的最小序列号 ( 没有记录时返回 0 )。
+	MinSeq(ctx context.Context, uid uint64) (uint64, error)
+	// MaxSeq 查询用户已使用的最大序列号 ( 没有记录时返回 0 )。
+	MaxSeq(ctx context.Context, uid uint64) (uint64, error)
+}
+
+var _ UserSyncLogDao = (*MongoUserSyncLogDao)(nil)
+
+type MongoUserSyncLogDao struct {
+	coll *mongo.Collection
+}
+
+func NewMongoUserSyncLogDao(collManager *xmongo.CollManager) *MongoUserSyncLogDao {
+	return &MongoUserSyncLogDao{
+		coll: collManager.Collection(userSyncLogCollName),
+	}
+}
+
+// EnsureIndexes TTL 索引已存在但过期时间不同时，通过 collMod 修改过期时间。
+func (d *MongoUserSyncLogDao) EnsureIndexes(ctx context.Context, ttl time.Duration) error {
+	_, err := d.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "uid", Value: 1}, {Key: "seq", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create sync log index: %w", err)
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	expireAfter := int32(ttl.Seconds())
+	_, err = d.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "createdAt", Value: 1}},
+		Options: options.Index().SetName(userSyncLogTTLIndex).SetExpireAfterSeconds(expireAfter),
+	})
+	if err == nil {
+		return nil
+	}
+
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != indexOptionsConflict {
+		return fmt.Errorf("failed to create sync log ttl index: %w", err)
+	}
+	err = d.coll.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: d.coll.Name()},
+		{Key: "index", Value: bson.M{"name": userSyncLogTTLIndex, "expireAfterSeconds": expireAfter}},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to update sync log ttl index: %w", err)
+	}
+	return nil
+}
+
+func (d *MongoUserSyncLogDao) Save(ctx context.Context, log UserSyncLog) error {
+	_, err := d.coll.InsertOne(ctx, log)
+	return err
+}
+
+func (d *MongoUserSyncLogDao) ListAfter(ctx context.Context, uid, afterSeq uint64, limit int) ([]UserSyncLog, error) {
+	cursor, err := d.coll.Find(
+		ctx,
+		bson.M{"uid": uid, "seq": bson.M{"$gt": afterSeq}},
+		options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []UserSyncLog
+	if err := cursor.All(ctx, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (d *MongoUserSyncLogDao) MinSeq(ctx context.Context, uid uint64) (uint64, error) {
+	return d.edgeSeq(ctx, uid, 1)
+}
+
+func (d *MongoUserSyncLogDao) MaxSeq(ctx context.Context, uid uint64) (uint64, error) {
+	return d.edgeSeq(ctx, uid, -1)
+}
+
+// edgeSeq 按 order 排序后取第一条记录的序列号。
+func (d *MongoUserSyncLogDao) edgeSeq(ctx context.Context, uid uint64, order int) (uint64, error) {
+	var log UserSyncLog
+	err := d.coll.FindOne(
+		ctx,
+		bson.M{"uid": uid},
+		options.FindOne().SetSort(bson.D{{Key: "seq", Value: order}}).SetProjection(bson.M{"seq": 1}),
+	).Decode(&log)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return log.Seq, nil
+}
@@ -0,0 +1,24 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/repo/dao"
+)
+
+// shardErr 将分库分表的 dao 错误转换为领域层错误，保留原始错误信息。
+// 唯一二级索引冲突 ( 如手机号已经被使用 ) 为参数错误，路由失败或者分片数据库没有配置为分片不可用。
+func shardErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, dao.ErrIndexConflict):
+		return fmt.Errorf("%w: %w", errs.ErrInvalidParam, err)
+	case errors.Is(err, dao.ErrShardRoute), errors.Is(err, dao.ErrShardDBNotFound):
+		return fmt.Errorf("%w: %w", errs.ErrShardUnavailable, err)
+	default:
+		return err
+	}
+}
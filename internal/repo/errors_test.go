@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/repo/dao"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardErr(t *testing.T) {
+	t.Parallel()
+
+	mockErr := errors.New("mock error")
+
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{name: "nil", err: nil, wantErr: nil},
+		{
+			name:    "index conflict",
+			err:     fmt.Errorf("%w: mobile already used", dao.ErrIndexConflict),
+			wantErr: errs.ErrInvalidParam,
+		},
+		{
+			name:    "shard route",
+			err:     fmt.Errorf("%w: invalid shard value", dao.ErrShardRoute),
+			wantErr: errs.ErrShardUnavailable,
+		},
+		{
+			name:    "shard db not found",
+			err:     fmt.Errorf("%w: db_3", dao.ErrShardDBNotFound),
+			wantErr: errs.ErrShardUnavailable,
+		},
+		{name: "other", err: mockErr, wantErr: mockErr},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := shardErr(tc.err)
+			if tc.wantErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tc.wantErr)
+			// 保留原始错误，调用方仍然可以判断 dao 层的错误。
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
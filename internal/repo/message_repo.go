@@ -1,15 +1,419 @@
 package repo
 
-import "github.com/jrmarcco/hermet/internal/repo/dao"
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
 
-type MessageRepo interface{}
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/repo/dao"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type MessageRepo interface {
+	// EnsureIndexes 创建消息去重以及编辑历史、回应、删除记录唯一约束所需的索引。
+	EnsureIndexes(ctx context.Context) error
+
+	// Save 保存消息，返回保存的消息。
+	// 发送者在频道中已经保存过 mid 相同的消息 ( 客户端重试 ) 时，返回已保存的消息。
+	Save(ctx context.Context, message domain.Message) (domain.Message, error)
+	// FindByMID 按客户端生成的消息 ID 查询发送者在频道中发送的消息，热存储中不存在时从冷存储中查询。
+	// 客户端重试时原消息可能已经被归档，只查询热存储会导致重复发送。
+	FindByMID(ctx context.Context, cid, sid, mid uint64) (domain.Message, error)
+
+	// ListHistory 按消息 ID 倒序查询频道历史消息。
+	// 热存储中的消息不足 limit 条时，会自动从冷存储 ( 归档 ) 中补齐。
+	ListHistory(ctx context.Context, cid, beforeID uint64, limit int) ([]domain.Message, error)
+
+	// Archive 将频道消息迁移到冷存储。
+	Archive(ctx context.Context, cid uint64, batchSize int) (int, error)
+
+	// FindByID 查询消息，热存储中不存在时从冷存储中查询。
+	FindByID(ctx context.Context, cid, id uint64) (domain.Message, error)
+	// FindByIDs 批量查询消息 ( 可以跨频道，不存在的消息会被忽略 )，热存储中不存在的消息从冷存储中查询。
+	FindByIDs(ctx context.Context, ids []uint64) ([]domain.Message, error)
+
+	// Recall 撤回消息，删除编辑历史并清空引用该消息的快照内容。
+	// 返回 true 和 error 表示消息已经撤回，但是编辑历史删除或快照清空失败。
+	Recall(ctx context.Context, cid, id uint64, recalledAt int64) (bool, error)
+	Edit(ctx context.Context, cid, id uint64, ver int, content []byte, editedAt int64) (bool, error)
+	ListEdits(ctx context.Context, cid, id uint64) ([]domain.MessageEdit, error)
+
+	// DeleteForUser 删除消息 ( 仅对 uid 不可见 )。
+	DeleteForUser(ctx context.Context, uid, cid, id uint64, deletedAt int64) error
+	// FindDeletedIDs 从 ids 中找出已被 uid 删除的消息 ID。
+	FindDeletedIDs(ctx context.Context, uid, cid uint64, ids []uint64) ([]uint64, error)
+
+	// ListThread 按消息 ID 升序查询话题中的回复 ( 包括冷存储中已归档的回复 )。
+	ListThread(ctx context.Context, cid, threadID, afterID uint64, limit int) ([]domain.Message, error)
+	// AddThreadReply 更新话题根消息的回复统计，返回话题参与者 ( 包含根消息发送者 )。
+	AddThreadReply(ctx context.Context, cid, threadID, sid uint64, repliedAt int64) ([]uint64, error)
+
+	// AddReaction 添加表情回应，返回该表情当前的回应数以及是否发生变更。
+	AddReaction(ctx context.Context, cid, id, uid uint64, emoji string, reactedAt int64) (int, bool, error)
+	// RemoveReaction 取消表情回应，返回该表情当前的回应数以及是否发生变更。
+	RemoveReaction(ctx context.Context, cid, id, uid uint64, emoji string) (int, bool, error)
+}
 
 var _ MessageRepo = (*DefaultMessageRepo)(nil)
 
 type DefaultMessageRepo struct {
-	dao dao.MessageDao
+	dao         dao.MessageDao
+	deletionDao dao.MessageDeletionDao
+}
+
+func NewDefaultMessageRepo(dao dao.MessageDao, deletionDao dao.MessageDeletionDao) *DefaultMessageRepo {
+	return &DefaultMessageRepo{
+		dao:         dao,
+		deletionDao: deletionDao,
+	}
+}
+
+func (r *DefaultMessageRepo) EnsureIndexes(ctx context.Context) error {
+	if err := r.dao.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+	return r.deletionDao.EnsureIndexes(ctx)
+}
+
+func (r *DefaultMessageRepo) Save(ctx context.Context, message domain.Message) (domain.Message, error) {
+	entity := r.toEntity(message)
+	err := r.dao.Save(ctx, &entity)
+	if err == nil {
+		return message, nil
+	}
+
+	// 并发重试时只有一条消息能写入成功，其余的返回已写入的消息。
+	if message.MID != 0 && mongo.IsDuplicateKeyError(err) {
+		return r.FindByMID(ctx, message.CID, message.SID, message.MID)
+	}
+	return domain.Message{}, err
+}
+
+func (r *DefaultMessageRepo) FindByMID(ctx context.Context, cid, sid, mid uint64) (domain.Message, error) {
+	entity, err := r.dao.FindByMID(ctx, cid, sid, strconv.FormatUint(mid, 10))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		entity, err = r.dao.FindArchivedByMID(ctx, cid, sid, strconv.FormatUint(mid, 10))
+	}
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return domain.Message{}, errs.ErrRecordNotFound
+		}
+		return domain.Message{}, err
+	}
+	return r.toDomain(entity), nil
+}
+
+func (r *DefaultMessageRepo) ListHistory(ctx context.Context, cid, beforeID uint64, limit int) ([]domain.Message, error) {
+	entities, err := r.dao.ListByCID(ctx, cid, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entities) < limit {
+		// 热存储中的消息不足，从最早的一条继续向冷存储查询。
+		archivedBefore := beforeID
+		if len(entities) > 0 {
+			archivedBefore = entities[len(entities)-1].ID
+		}
+
+		archived, err := r.dao.ListArchivedByCID(ctx, cid, archivedBefore, limit-len(entities))
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, archived...)
+	}
+
+	messages := make([]domain.Message, 0, len(entities))
+	for i := range entities {
+		messages = append(messages, r.toDomain(entities[i]))
+	}
+	return messages, nil
+}
+
+func (r *DefaultMessageRepo) Archive(ctx context.Context, cid uint64, batchSize int) (int, error) {
+	return r.dao.Archive(ctx, cid, batchSize)
+}
+
+func (r *DefaultMessageRepo) FindByID(ctx context.Context, cid, id uint64) (domain.Message, error) {
+	entity, err := r.dao.FindByID(ctx, cid, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		entity, err = r.dao.FindArchivedByID(ctx, cid, id)
+	}
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return domain.Message{}, errs.ErrRecordNotFound
+		}
+		return domain.Message{}, err
+	}
+	return r.toDomain(entity), nil
+}
+
+func (r *DefaultMessageRepo) FindByIDs(ctx context.Context, ids []uint64) ([]domain.Message, error) {
+	entities, err := r.dao.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entities) < len(ids) {
+		found := make(map[uint64]struct{}, len(entities))
+		for i := range entities {
+			found[entities[i].ID] = struct{}{}
+		}
+		missing := slices.DeleteFunc(slices.Clone(ids), func(id uint64) bool {
+			_, ok := found[id]
+			return ok
+		})
+
+		archived, err := r.dao.FindArchivedByIDs(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, archived...)
+	}
+
+	messages := make([]domain.Message, 0, len(entities))
+	for i := range entities {
+		messages = append(messages, r.toDomain(entities[i]))
+	}
+	return messages, nil
+}
+
+func (r *DefaultMessageRepo) Recall(ctx context.Context, cid, id uint64, recalledAt int64) (bool, error) {
+	return r.dao.Recall(ctx, cid, id, recalledAt)
+}
+
+func (r *DefaultMessageRepo) Edit(
+	ctx context.Context,
+	cid, id uint64,
+	ver int,
+	content []byte,
+	editedAt int64,
+) (bool, error) {
+	ok, err := r.dao.Edit(ctx, cid, id, ver, content, editedAt)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, errs.ErrRecordNotFound
+		}
+		return false, err
+	}
+	return ok, nil
+}
+
+func (r *DefaultMessageRepo) ListEdits(ctx context.Context, cid, id uint64) ([]domain.MessageEdit, error) {
+	entities, err := r.dao.ListEdits(ctx, cid, id)
+	if err != nil {
+		return nil, err
+	}
+
+	edits := make([]domain.MessageEdit, 0, len(entities))
+	for i := range entities {
+		edits = append(edits, domain.MessageEdit{
+			MessageID: entities[i].MessageID,
+			Ver:       entities[i].Ver,
+			Content:   entities[i].Content,
+			EditedAt:  entities[i].EditedAt,
+		})
+	}
+	return edits, nil
+}
+
+func (r *DefaultMessageRepo) DeleteForUser(ctx context.Context, uid, cid, id uint64, deletedAt int64) error {
+	return r.deletionDao.Save(ctx, dao.MessageDeletion{
+		UID:       uid,
+		CID:       cid,
+		MessageID: id,
+		DeletedAt: deletedAt,
+	})
 }
 
-func NewDefaultMessageRepo(dao dao.MessageDao) *DefaultMessageRepo {
-	return &DefaultMessageRepo{dao: dao}
+func (r *DefaultMessageRepo) FindDeletedIDs(ctx context.Context, uid, cid uint64, ids []uint64) ([]uint64, error) {
+	return r.deletionDao.FindDeletedIDs(ctx, uid, cid, ids)
+}
+
+func (r *DefaultMessageRepo) ListThread(
+	ctx context.Context,
+	cid, threadID, afterID uint64,
+	limit int,
+) ([]domain.Message, error) {
+	// 频道归档时会迁移全部消息，冷存储中的回复 ID 一定小于热存储中的回复 ID，所以先查询冷存储。
+	entities, err := r.dao.ListArchivedByThreadID(ctx, cid, threadID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entities) < limit {
+		hotAfter := afterID
+		if len(entities) > 0 {
+			hotAfter = entities[len(entities)-1].ID
+		}
+
+		hot, err := r.dao.ListByThreadID(ctx, cid, threadID, hotAfter, limit-len(entities))
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, hot...)
+	}
+
+	messages := make([]domain.Message, 0, len(entities))
+	for i := range entities {
+		messages = append(messages, r.toDomain(entities[i]))
+	}
+	return messages, nil
+}
+
+func (r *DefaultMessageRepo) AddThreadReply(
+	ctx context.Context,
+	cid, threadID, sid uint64,
+	repliedAt int64,
+) ([]uint64, error) {
+	root, err := r.dao.AddThreadReply(ctx, cid, threadID, sid, repliedAt)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errs.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	participants := root.ThreadParticipants
+	if !slices.Contains(participants, root.SID) {
+		participants = append(participants, root.SID)
+	}
+	return participants, nil
+}
+
+func (r *DefaultMessageRepo) AddReaction(
+	ctx context.Context,
+	cid, id, uid uint64,
+	emoji string,
+	reactedAt int64,
+) (int, bool, error) {
+	return r.dao.AddReaction(ctx, cid, id, uid, emoji, reactedAt)
+}
+
+func (r *DefaultMessageRepo) RemoveReaction(ctx context.Context, cid, id, uid uint64, emoji string) (int, bool, error) {
+	return r.dao.RemoveReaction(ctx, cid, id, uid, emoji)
+}
+
+func (r *DefaultMessageRepo) toEntity(message domain.Message) dao.Message {
+	return dao.Message{
+		ID:  message.ID,
+		Seq: message.Seq,
+
+		CID: message.CID,
+		SID: message.SID,
+
+		MID: r.toMIDEntity(message.MID),
+
+		Content:     message.Content,
+		ContentType: int32(message.ContentType),
+
+		Mentions:   message.Mentions,
+		MentionAll: message.MentionAll,
+
+		SendAt: message.SendAt,
+
+		ReplyTo:  r.toRefEntity(message.ReplyTo),
+		ThreadID: message.ThreadID,
+	}
+}
+
+// toMIDEntity mid 为 0 时保存为空字符串，不参与去重。
+func (r *DefaultMessageRepo) toMIDEntity(mid uint64) string {
+	if mid == 0 {
+		return ""
+	}
+	return strconv.FormatUint(mid, 10)
+}
+
+func (r *DefaultMessageRepo) toRefEntity(ref *domain.MessageRef) *dao.MessageRef {
+	if ref == nil {
+		return nil
+	}
+	return &dao.MessageRef{
+		ID:          ref.ID,
+		SID:         ref.SID,
+		Content:     ref.Content,
+		ContentType: int32(ref.ContentType),
+		SendAt:      ref.SendAt,
+		RecalledAt:  ref.RecalledAt,
+	}
+}
+
+func (r *DefaultMessageRepo) toRefDomain(ref *dao.MessageRef) *domain.MessageRef {
+	if ref == nil {
+		return nil
+	}
+	return &domain.MessageRef{
+		ID:          ref.ID,
+		SID:         ref.SID,
+		Content:     ref.Content,
+		ContentType: domain.ContentType(ref.ContentType),
+		SendAt:      ref.SendAt,
+		RecalledAt:  ref.RecalledAt,
+	}
+}
+
+func (r *DefaultMessageRepo) toDomain(entity dao.Message) domain.Message {
+	// 历史数据中 mid 可能为空，解析失败时忽略即可。
+	mid, _ := strconv.ParseUint(entity.MID, 10, 64)
+	return domain.Message{
+		ID:  entity.ID,
+		Seq: entity.Seq,
+
+		CID: entity.CID,
+		SID: entity.SID,
+
+		MID: mid,
+
+		Content:     entity.Content,
+		ContentType: domain.ContentType(entity.ContentType),
+
+		Mentions:   entity.Mentions,
+		MentionAll: entity.MentionAll,
+
+		SendAt: entity.SendAt,
+
+		RecalledAt: entity.RecalledAt,
+		EditVer:    entity.EditVer,
+		EditedAt:   entity.EditedAt,
+
+		ReplyTo: r.toRefDomain(entity.ReplyTo),
+
+		ThreadID:    entity.ThreadID,
+		ReplyCount:  entity.ReplyCount,
+		LastReplyAt: entity.LastReplyAt,
+
+		Reactions: r.toReactionsDomain(entity.Reactions),
+	}
+}
+
+func (r *DefaultMessageRepo) toReactionsDomain(summaries map[string]dao.ReactionSummary) []domain.MessageReaction {
+	if len(summaries) == 0 {
+		return nil
+	}
+
+	reactions := make([]domain.MessageReaction, 0, len(summaries))
+	for emoji, summary := range summaries {
+		if summary.Count <= 0 {
+			continue
+		}
+		reactions = append(reactions, domain.MessageReaction{
+			Emoji: emoji,
+			Count: summary.Count,
+			UIDs:  summary.UIDs,
+		})
+	}
+
+	// map 遍历顺序不固定，按回应数倒序 ( 相同时按表情 ) 排序保证返回结果稳定。
+	slices.SortFunc(reactions, func(a, b domain.MessageReaction) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Emoji, b.Emoji)
+	})
+	return reactions
 }
@@ -0,0 +1,218 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/repo/dao"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// fakeMessageDao 使用两个切片分别模拟热存储和冷存储，只实现测试中用到的方法。
+type fakeMessageDao struct {
+	dao.MessageDao
+
+	hot      []dao.Message
+	archived []dao.Message
+}
+
+func (d *fakeMessageDao) FindByID(_ context.Context, cid, id uint64) (dao.Message, error) {
+	return findMessage(d.hot, cid, id)
+}
+
+func (d *fakeMessageDao) FindArchivedByID(_ context.Context, cid, id uint64) (dao.Message, error) {
+	return findMessage(d.archived, cid, id)
+}
+
+func (d *fakeMessageDao) FindByMID(_ context.Context, cid, sid uint64, mid string) (dao.Message, error) {
+	return findMessageByMID(d.hot, cid, sid, mid)
+}
+
+func (d *fakeMessageDao) FindArchivedByMID(_ context.Context, cid, sid uint64, mid string) (dao.Message, error) {
+	return findMessageByMID(d.archived, cid, sid, mid)
+}
+
+func (d *fakeMessageDao) FindByIDs(_ context.Context, ids []uint64) ([]dao.Message, error) {
+	return findMessages(d.hot, ids), nil
+}
+
+func (d *fakeMessageDao) FindArchivedByIDs(_ context.Context, ids []uint64) ([]dao.Message, error) {
+	return findMessages(d.archived, ids), nil
+}
+
+func (d *fakeMessageDao) ListByThreadID(_ context.Context, cid, threadID, afterID uint64, limit int) ([]dao.Message, error) {
+	return listThread(d.hot, cid, threadID, afterID, limit), nil
+}
+
+func (d *fakeMessageDao) ListArchivedByThreadID(
+	_ context.Context,
+	cid, threadID, afterID uint64,
+	limit int,
+) ([]dao.Message, error) {
+	return listThread(d.archived, cid, threadID, afterID, limit), nil
+}
+
+func findMessage(messages []dao.Message, cid, id uint64) (dao.Message, error) {
+	for _, m := range messages {
+		if m.CID == cid && m.ID == id {
+			return m, nil
+		}
+	}
+	return dao.Message{}, mongo.ErrNoDocuments
+}
+
+func findMessageByMID(messages []dao.Message, cid, sid uint64, mid string) (dao.Message, error) {
+	for _, m := range messages {
+		if m.CID == cid && m.SID == sid && m.MID == mid {
+			return m, nil
+		}
+	}
+	return dao.Message{}, mongo.ErrNoDocuments
+}
+
+func findMessages(messages []dao.Message, ids []uint64) []dao.Message {
+	var res []dao.Message
+	for _, m := range messages {
+		if slices.Contains(ids, m.ID) {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+func listThread(messages []dao.Message, cid, threadID, afterID uint64, limit int) []dao.Message {
+	var res []dao.Message
+	for _, m := range messages {
+		if m.CID == cid && m.ThreadID == threadID && m.ID > afterID {
+			res = append(res, m)
+		}
+	}
+	slices.SortFunc(res, func(a, b dao.Message) int { return cmp.Compare(a.ID, b.ID) })
+	return res[:min(limit, len(res))]
+}
+
+// newArchivedThreadRepo 话题根消息为 1，回复 2 ~ 4 已归档，回复 5 ~ 6 在热存储中。
+// 消息都由用户 1 发送，客户端消息 ID 为消息 ID 加 100。
+func newArchivedThreadRepo() *DefaultMessageRepo {
+	d := &fakeMessageDao{}
+	for id := uint64(1); id <= 6; id++ {
+		m := dao.Message{ID: id, CID: 100, SID: 1, MID: strconv.FormatUint(id+100, 10)}
+		if id > 1 {
+			m.ThreadID = 1
+		}
+		if id <= 4 {
+			d.archived = append(d.archived, m)
+		} else {
+			d.hot = append(d.hot, m)
+		}
+	}
+	return NewDefaultMessageRepo(d, nil)
+}
+
+func TestDefaultMessageRepo_FindByIDFallsBackToArchive(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		name    string
+		id      uint64
+		wantErr error
+	}{
+		{name: "hot", id: 5},
+		{name: "archived", id: 1},
+		{name: "not found", id: 7, wantErr: errs.ErrRecordNotFound},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			message, err := newArchivedThreadRepo().FindByID(t.Context(), 100, tc.id)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.id, message.ID)
+		})
+	}
+}
+
+func TestDefaultMessageRepo_FindByMIDFallsBackToArchive(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		name    string
+		sid     uint64
+		mid     uint64
+		wantID  uint64
+		wantErr error
+	}{
+		{name: "hot", sid: 1, mid: 105, wantID: 5},
+		{name: "archived", sid: 1, mid: 102, wantID: 2},
+		{name: "other sender", sid: 2, mid: 102, wantErr: errs.ErrRecordNotFound},
+		{name: "not found", sid: 1, mid: 107, wantErr: errs.ErrRecordNotFound},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			message, err := newArchivedThreadRepo().FindByMID(t.Context(), 100, tc.sid, tc.mid)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantID, message.ID)
+		})
+	}
+}
+
+func TestDefaultMessageRepo_FindByIDsFallsBackToArchive(t *testing.T) {
+	t.Parallel()
+
+	messages, err := newArchivedThreadRepo().FindByIDs(t.Context(), []uint64{2, 5, 7})
+	require.NoError(t, err)
+
+	ids := make([]uint64, 0, len(messages))
+	for i := range messages {
+		ids = append(ids, messages[i].ID)
+	}
+	require.ElementsMatch(t, []uint64{2, 5}, ids)
+}
+
+func TestDefaultMessageRepo_ListThreadFallsBackToArchive(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		name    string
+		afterID uint64
+		limit   int
+		wantIDs []uint64
+	}{
+		{name: "archived only", afterID: 0, limit: 2, wantIDs: []uint64{2, 3}},
+		{name: "archived and hot", afterID: 2, limit: 3, wantIDs: []uint64{3, 4, 5}},
+		{name: "hot only", afterID: 4, limit: 10, wantIDs: []uint64{5, 6}},
+		{name: "end", afterID: 6, limit: 10, wantIDs: []uint64{}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			messages, err := newArchivedThreadRepo().ListThread(t.Context(), 100, 1, tc.afterID, tc.limit)
+			require.NoError(t, err)
+
+			ids := make([]uint64, 0, len(messages))
+			for i := range messages {
+				ids = append(ids, messages[i].ID)
+			}
+			require.Equal(t, tc.wantIDs, ids)
+		})
+	}
+}
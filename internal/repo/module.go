@@ -30,10 +30,47 @@ var RepoFxModule = fx.Module(
 			fx.As(new(ChannelApplicationRepo)),
 		),
 
+		// channel repo
+		fx.Annotate(
+			NewDefaultChannelRepo,
+			fx.As(new(ChannelRepo)),
+		),
+
+		// conversation repo
+		fx.Annotate(
+			NewDefaultConversationRepo,
+			fx.As(new(ConversationRepo)),
+		),
+
 		// message repo
 		fx.Annotate(
 			NewDefaultMessageRepo,
 			fx.As(new(MessageRepo)),
 		),
+
+		// sync repo
+		fx.Annotate(
+			NewDefaultSyncRepo,
+			fx.ParamTags(`name:"user_sync_seq_generator"`),
+			fx.As(new(SyncRepo)),
+		),
+
+		// push repo
+		fx.Annotate(
+			NewDefaultPushRepo,
+			fx.As(new(PushRepo)),
+		),
+
+		// presence repo
+		fx.Annotate(
+			NewDefaultPresenceRepo,
+			fx.As(new(PresenceRepo)),
+		),
+
+		// route repo
+		fx.Annotate(
+			NewDefaultRouteRepo,
+			fx.As(new(RouteRepo)),
+		),
 	),
 )
@@ -0,0 +1,98 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/repo/dao"
+)
+
+type PresenceRepo interface {
+	// Heartbeat 记录设备心跳，返回 true 表示用户从离线变为在线。
+	Heartbeat(ctx context.Context, uid uint64, sid string, ttl time.Duration) (bool, error)
+	// Offline 设备下线，返回 true 表示用户的所有设备都已下线。
+	Offline(ctx context.Context, uid uint64, sid string) (bool, error)
+
+	// FilterOnline 从 uids 中找出在线的用户。
+	FilterOnline(ctx context.Context, uids []uint64) (map[uint64]bool, error)
+
+	SetLastSeen(ctx context.Context, uid uint64, lastSeen int64) error
+	GetLastSeen(ctx context.Context, uids []uint64) (map[uint64]int64, error)
+
+	// MarkTyping 标记用户正在输入，返回 false 表示 ttl 内已经标记过。
+	MarkTyping(ctx context.Context, uid, cid uint64, ttl time.Duration) (bool, error)
+
+	SaveSetting(ctx context.Context, setting domain.PresenceSetting) error
+	// FindSettings 批量查询隐私设置，未设置的用户使用默认设置 ( 所有人可见 )。
+	FindSettings(ctx context.Context, uids []uint64) (map[uint64]domain.PresenceSetting, error)
+}
+
+var _ PresenceRepo = (*DefaultPresenceRepo)(nil)
+
+type DefaultPresenceRepo struct {
+	presenceDao dao.UserPresenceDao
+	settingDao  dao.PresenceSettingDao
+}
+
+func NewDefaultPresenceRepo(presenceDao dao.UserPresenceDao, settingDao dao.PresenceSettingDao) *DefaultPresenceRepo {
+	return &DefaultPresenceRepo{
+		presenceDao: presenceDao,
+		settingDao:  settingDao,
+	}
+}
+
+func (r *DefaultPresenceRepo) Heartbeat(ctx context.Context, uid uint64, sid string, ttl time.Duration) (bool, error) {
+	return r.presenceDao.Heartbeat(ctx, uid, sid, ttl)
+}
+
+func (r *DefaultPresenceRepo) Offline(ctx context.Context, uid uint64, sid string) (bool, error) {
+	return r.presenceDao.Offline(ctx, uid, sid)
+}
+
+func (r *DefaultPresenceRepo) FilterOnline(ctx context.Context, uids []uint64) (map[uint64]bool, error) {
+	return r.presenceDao.FilterOnline(ctx, uids)
+}
+
+func (r *DefaultPresenceRepo) SetLastSeen(ctx context.Context, uid uint64, lastSeen int64) error {
+	return r.presenceDao.SetLastSeen(ctx, uid, lastSeen)
+}
+
+func (r *DefaultPresenceRepo) GetLastSeen(ctx context.Context, uids []uint64) (map[uint64]int64, error) {
+	return r.presenceDao.GetLastSeen(ctx, uids)
+}
+
+func (r *DefaultPresenceRepo) MarkTyping(ctx context.Context, uid, cid uint64, ttl time.Duration) (bool, error) {
+	return r.presenceDao.MarkTyping(ctx, uid, cid, ttl)
+}
+
+func (r *DefaultPresenceRepo) SaveSetting(ctx context.Context, setting domain.PresenceSetting) error {
+	return r.settingDao.Upsert(ctx, dao.PresenceSetting{
+		UID:        setting.UID,
+		Visibility: string(setting.Visibility),
+		UpdatedAt:  setting.UpdatedAt,
+	})
+}
+
+func (r *DefaultPresenceRepo) FindSettings(ctx context.Context, uids []uint64) (map[uint64]domain.PresenceSetting, error) {
+	entities, err := r.settingDao.FindByUIDs(ctx, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[uint64]domain.PresenceSetting, len(uids))
+	for _, uid := range uids {
+		settings[uid] = domain.PresenceSetting{
+			UID:        uid,
+			Visibility: domain.PresenceVisibilityEveryone,
+		}
+	}
+	for i := range entities {
+		settings[entities[i].UID] = domain.PresenceSetting{
+			UID:        entities[i].UID,
+			Visibility: domain.PresenceVisibility(entities[i].Visibility),
+			UpdatedAt:  entities[i].UpdatedAt,
+		}
+	}
+	return settings, nil
+}
@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/repo/dao"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type PushRepo interface {
+	SaveDevice(ctx context.Context, device domain.PushDevice) error
+	DeleteDeviceBySID(ctx context.Context, sid string) error
+	DeleteDeviceByToken(ctx context.Context, token string) error
+	ListDevices(ctx context.Context, uid uint64) ([]domain.PushDevice, error)
+
+	SaveSetting(ctx context.Context, setting domain.PushSetting) error
+	// FindSetting 查询用户推送设置，未设置时返回 errs.ErrRecordNotFound。
+	FindSetting(ctx context.Context, uid uint64) (domain.PushSetting, error)
+}
+
+var _ PushRepo = (*DefaultPushRepo)(nil)
+
+type DefaultPushRepo struct {
+	deviceDao  dao.PushDeviceDao
+	settingDao dao.PushSettingDao
+}
+
+func NewDefaultPushRepo(deviceDao dao.PushDeviceDao, settingDao dao.PushSettingDao) *DefaultPushRepo {
+	return &DefaultPushRepo{
+		deviceDao:  deviceDao,
+		settingDao: settingDao,
+	}
+}
+
+func (r *DefaultPushRepo) SaveDevice(ctx context.Context, device domain.PushDevice) error {
+	return r.deviceDao.Upsert(ctx, dao.PushDevice{
+		SID:       device.SID,
+		UID:       device.UID,
+		Platform:  string(device.Platform),
+		Token:     device.Token,
+		UpdatedAt: device.UpdatedAt,
+	})
+}
+
+func (r *DefaultPushRepo) DeleteDeviceBySID(ctx context.Context, sid string) error {
+	return r.deviceDao.DeleteBySID(ctx, sid)
+}
+
+func (r *DefaultPushRepo) DeleteDeviceByToken(ctx context.Context, token string) error {
+	return r.deviceDao.DeleteByToken(ctx, token)
+}
+
+func (r *DefaultPushRepo) ListDevices(ctx context.Context, uid uint64) ([]domain.PushDevice, error) {
+	entities, err := r.deviceDao.ListByUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]domain.PushDevice, 0, len(entities))
+	for i := range entities {
+		devices = append(devices, domain.PushDevice{
+			UID:       entities[i].UID,
+			SID:       entities[i].SID,
+			Platform:  domain.PushPlatform(entities[i].Platform),
+			Token:     entities[i].Token,
+			UpdatedAt: entities[i].UpdatedAt,
+		})
+	}
+	return devices, nil
+}
+
+func (r *DefaultPushRepo) SaveSetting(ctx context.Context, setting domain.PushSetting) error {
+	return r.settingDao.Upsert(ctx, dao.PushSetting{
+		UID:        setting.UID,
+		DNDEnabled: setting.DNDEnabled,
+		DNDStart:   setting.DNDStart,
+		DNDEnd:     setting.DNDEnd,
+		Timezone:   setting.Timezone,
+		UpdatedAt:  setting.UpdatedAt,
+	})
+}
+
+func (r *DefaultPushRepo) FindSetting(ctx context.Context, uid uint64) (domain.PushSetting, error) {
+	entity, err := r.settingDao.FindByUID(ctx, uid)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return domain.PushSetting{}, errs.ErrRecordNotFound
+		}
+		return domain.PushSetting{}, err
+	}
+	return domain.PushSetting{
+		UID:        entity.UID,
+		DNDEnabled: entity.DNDEnabled,
+		DNDStart:   entity.DNDStart,
+		DNDEnd:     entity.DNDEnd,
+		Timezone:   entity.Timezone,
+		UpdatedAt:  entity.UpdatedAt,
+	}, nil
+}
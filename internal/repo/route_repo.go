@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/repo/dao"
+)
+
+type RouteRepo interface {
+	// Register 注册 ( 续期 ) 会话连接所在的节点。
+	Register(ctx context.Context, uid uint64, sid, nodeID string, ttl time.Duration) error
+	Unregister(ctx context.Context, uid uint64, sid string) error
+
+	// GroupByNode 按节点对用户分组，返回 节点 ID -> 用户 ID 列表，以及没有任何连接的用户。
+	GroupByNode(ctx context.Context, uids []uint64) (map[string][]uint64, []uint64, error)
+}
+
+var _ RouteRepo = (*DefaultRouteRepo)(nil)
+
+type DefaultRouteRepo struct {
+	routeDao dao.UserRouteDao
+}
+
+func NewDefaultRouteRepo(routeDao dao.UserRouteDao) *DefaultRouteRepo {
+	return &DefaultRouteRepo{
+		routeDao: routeDao,
+	}
+}
+
+func (r *DefaultRouteRepo) Register(ctx context.Context, uid uint64, sid, nodeID string, ttl time.Duration) error {
+	return r.routeDao.Register(ctx, uid, sid, nodeID, ttl)
+}
+
+func (r *DefaultRouteRepo) Unregister(ctx context.Context, uid uint64, sid string) error {
+	return r.routeDao.Unregister(ctx, uid, sid)
+}
+
+func (r *DefaultRouteRepo) GroupByNode(ctx context.Context, uids []uint64) (map[string][]uint64, []uint64, error) {
+	nodes, err := r.routeDao.FindNodes(ctx, uids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	grouped := make(map[string][]uint64)
+	var offline []uint64
+	for _, uid := range uids {
+		nodeIDs, ok := nodes[uid]
+		if !ok {
+			offline = append(offline, uid)
+			continue
+		}
+		// 多设备连接在不同节点时，每个节点都需要投递。
+		for _, nodeID := range nodeIDs {
+			grouped[nodeID] = append(grouped[nodeID], uid)
+		}
+	}
+	return grouped, offline, nil
+}
@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen"
+	"github.com/jrmarcco/hermet/internal/repo/dao"
+)
+
+type SyncRepo interface {
+	// NextSeq 为用户分配下一个同步序列号 ( 由 idgen.SeqGenerator 生成 )。
+	NextSeq(ctx context.Context, uid uint64) (uint64, error)
+	// LatestSeq 查询用户当前最新的同步序列号。
+	LatestSeq(ctx context.Context, uid uint64) (uint64, error)
+	// MinSeq 查询用户仍保留的最小同步序列号 ( 没有记录时返回 0 )。
+	MinSeq(ctx context.Context, uid uint64) (uint64, error)
+
+	// EnsureLogIndexes 创建同步日志索引，同步日志保留 ttl 后自动清理 ( ttl 为 0 时不清理 )。
+	EnsureLogIndexes(ctx context.Context, ttl time.Duration) error
+	Append(ctx context.Context, log domain.SyncLog) error
+	ListAfter(ctx context.Context, uid, afterSeq uint64, limit int) ([]domain.SyncLog, error)
+}
+
+var _ SyncRepo = (*DefaultSyncRepo)(nil)
+
+type DefaultSyncRepo struct {
+	seqGen idgen.SeqGenerator
+	logDao dao.UserSyncLogDao
+}
+
+func NewDefaultSyncRepo(seqGen idgen.SeqGenerator, logDao dao.UserSyncLogDao) *DefaultSyncRepo {
+	return &DefaultSyncRepo{
+		seqGen: seqGen,
+		logDao: logDao,
+	}
+}
+
+// NextSeq 序列号存储中没有用户的序列号时 ( 第一次使用或者存储丢失数据 ) 使用同步日志中的最大序列号初始化，
+// 保证存储丢失数据后序列号仍然单调递增，客户端不会跳过新的同步日志。
+func (r *DefaultSyncRepo) NextSeq(ctx context.Context, uid uint64) (uint64, error) {
+	return r.seqGen.NextSeq(ctx, "user_sync:"+strconv.FormatUint(uid, 10), func(ctx context.Context, _ string) (uint64, error) {
+		return r.logDao.MaxSeq(ctx, uid)
+	})
+}
+
+// LatestSeq 返回已经写入的最大同步序列号，分配后还没有写入 ( 或者写入失败 ) 的序列号不计算在内。
+// 同步日志全部过期后返回 0，客户端会进行全量同步。
+func (r *DefaultSyncRepo) LatestSeq(ctx context.Context, uid uint64) (uint64, error) {
+	return r.logDao.MaxSeq(ctx, uid)
+}
+
+func (r *DefaultSyncRepo) MinSeq(ctx context.Context, uid uint64) (uint64, error) {
+	return r.logDao.MinSeq(ctx, uid)
+}
+
+func (r *DefaultSyncRepo) EnsureLogIndexes(ctx context.Context, ttl time.Duration) error {
+	return r.logDao.EnsureIndexes(ctx, ttl)
+}
+
+func (r *DefaultSyncRepo) Append(ctx context.Context, log domain.SyncLog) error {
+	return r.logDao.Save(ctx, dao.UserSyncLog{
+		UID:       log.UID,
+		Seq:       log.Seq,
+		CID:       log.CID,
+		MessageID: log.MessageID,
+		CreatedAt: time.UnixMilli(log.CreatedAt),
+	})
+}
+
+func (r *DefaultSyncRepo) ListAfter(ctx context.Context, uid, afterSeq uint64, limit int) ([]domain.SyncLog, error) {
+	entities, err := r.logDao.ListAfter(ctx, uid, afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]domain.SyncLog, 0, len(entities))
+	for i := range entities {
+		logs = append(logs, domain.SyncLog{
+			UID:       entities[i].UID,
+			Seq:       entities[i].Seq,
+			CID:       entities[i].CID,
+			MessageID: entities[i].MessageID,
+			CreatedAt: entities[i].CreatedAt.UnixMilli(),
+		})
+	}
+	return logs, nil
+}
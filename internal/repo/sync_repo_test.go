@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen/segment"
+	"github.com/jrmarcco/hermet/internal/repo/dao"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUserSyncLogDao 使用切片模拟同步日志，只实现测试中用到的方法。
+type fakeUserSyncLogDao struct {
+	dao.UserSyncLogDao
+
+	mu   sync.Mutex
+	logs []dao.UserSyncLog
+}
+
+func (d *fakeUserSyncLogDao) Save(_ context.Context, log dao.UserSyncLog) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.logs = append(d.logs, log)
+	return nil
+}
+
+func (d *fakeUserSyncLogDao) MaxSeq(_ context.Context, uid uint64) (uint64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var maxSeq uint64
+	for _, log := range d.logs {
+		if log.UID == uid {
+			maxSeq = max(maxSeq, log.Seq)
+		}
+	}
+	return maxSeq, nil
+}
+
+func TestDefaultSyncRepo_NextSeqAfterDataLoss(t *testing.T) {
+	t.Parallel()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	seqGen, err := segment.NewAllocator(segment.NewRedisStore(rdb), segment.Config{Step: 1}, nil)
+	require.NoError(t, err)
+	logDao := &fakeUserSyncLogDao{}
+	r := NewDefaultSyncRepo(seqGen, logDao)
+
+	const uid = uint64(1)
+	for want := uint64(1); want <= 3; want++ {
+		seq, err := r.NextSeq(t.Context(), uid)
+		require.NoError(t, err)
+		require.Equal(t, want, seq)
+		require.NoError(t, r.Append(t.Context(), domain.SyncLog{UID: uid, Seq: seq, CreatedAt: time.Now().UnixMilli()}))
+	}
+
+	// redis 丢失数据后从同步日志中的最大序列号继续递增，客户端不会跳过新的同步日志。
+	mr.FlushAll()
+	seq, err := r.NextSeq(t.Context(), uid)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), seq)
+
+	// 其他用户没有同步日志，从 1 开始。
+	seq, err = r.NextSeq(t.Context(), uid+1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), seq)
+}
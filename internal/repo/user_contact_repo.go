@@ -12,17 +12,26 @@ import (
 
 type UserContactRepo interface {
 	FindByUserIDAndContactID(ctx context.Context, userID, contactID uint64) (domain.UserContact, error)
+	// FindByUserIDAndContactIDs 批量查询用户的联系人 ( 不是联系人的 contactID 会被忽略 )。
+	FindByUserIDAndContactIDs(ctx context.Context, userID uint64, contactIDs []uint64) ([]domain.UserContact, error)
+	// ListOwnerIDs 查询将 contactID 添加为联系人的用户 ID ( 反向索引 )。
+	ListOwnerIDs(ctx context.Context, contactID uint64) ([]uint64, error)
 }
 
 var _ UserContactRepo = (*DefaultUserContactRepo)(nil)
 
 type DefaultUserContactRepo struct {
-	userContactDao dao.UserContactDao
+	userContactDao  dao.UserContactDao
+	reverseIndexDao dao.ContactReverseIndexDao
 }
 
-func NewDefaultUserContactRepo(userContactDao dao.UserContactDao) *DefaultUserContactRepo {
+func NewDefaultUserContactRepo(
+	userContactDao dao.UserContactDao,
+	reverseIndexDao dao.ContactReverseIndexDao,
+) *DefaultUserContactRepo {
 	return &DefaultUserContactRepo{
-		userContactDao: userContactDao,
+		userContactDao:  userContactDao,
+		reverseIndexDao: reverseIndexDao,
 	}
 }
 
@@ -32,11 +41,33 @@ func (r *DefaultUserContactRepo) FindByUserIDAndContactID(ctx context.Context, u
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return domain.UserContact{}, errs.ErrRecordNotFound
 		}
-		return domain.UserContact{}, err
+		return domain.UserContact{}, shardErr(err)
 	}
 	return r.toDomain(entity), nil
 }
 
+func (r *DefaultUserContactRepo) FindByUserIDAndContactIDs(
+	ctx context.Context,
+	userID uint64,
+	contactIDs []uint64,
+) ([]domain.UserContact, error) {
+	entities, err := r.userContactDao.FindByUserIDAndContactIDs(ctx, userID, contactIDs)
+	if err != nil {
+		return nil, shardErr(err)
+	}
+
+	contacts := make([]domain.UserContact, 0, len(entities))
+	for i := range entities {
+		contacts = append(contacts, r.toDomain(entities[i]))
+	}
+	return contacts, nil
+}
+
+func (r *DefaultUserContactRepo) ListOwnerIDs(ctx context.Context, contactID uint64) ([]uint64, error) {
+	ids, err := r.reverseIndexDao.ListOwnerIDs(ctx, contactID)
+	return ids, shardErr(err)
+}
+
 // func (r *DefaultUserContactRepo) toEntity(uc domain.UserContact) dao.UserContact {
 // 	return dao.UserContact{
 // 		ID:         uc.ID,
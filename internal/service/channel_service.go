@@ -2,16 +2,23 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jrmarcco/hermet/internal/domain"
 	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
 	"github.com/jrmarcco/hermet/internal/repo"
 	"go.uber.org/zap"
 )
 
 type ChannelService interface {
 	CreateGroup(ctx context.Context, event domain.ChannelCreatedEvent) (domain.Channel, error)
+
+	// DissolveGroup 解散群组 ( 仅群主可以操作 )。
+	// 解散后频道变为只读，历史消息仍可查询，成员的会话会被关闭。
+	DissolveGroup(ctx context.Context, cid, operator uint64) error
 }
 
 var _ ChannelService = (*DefaultChannelService)(nil)
@@ -19,14 +26,24 @@ var _ ChannelService = (*DefaultChannelService)(nil)
 type DefaultChannelService struct {
 	asyncThreshold int // 同步成员写入的阈值
 
-	channelRepo repo.ChannelRepo
-	logger      *zap.Logger
+	channelRepo      repo.ChannelRepo
+	conversationRepo repo.ConversationRepo
+
+	producer produce.Producer
+	logger   *zap.Logger
 }
 
-func NewDefaultChannelService(channelRepo repo.ChannelRepo, logger *zap.Logger) *DefaultChannelService {
+func NewDefaultChannelService(
+	channelRepo repo.ChannelRepo,
+	conversationRepo repo.ConversationRepo,
+	producer produce.Producer,
+	logger *zap.Logger,
+) *DefaultChannelService {
 	return &DefaultChannelService{
-		channelRepo: channelRepo,
-		logger:      logger,
+		channelRepo:      channelRepo,
+		conversationRepo: conversationRepo,
+		producer:         producer,
+		logger:           logger,
 	}
 }
 
@@ -54,3 +71,84 @@ func (s *DefaultChannelService) createGroupAsync(_ context.Context, _ domain.Cha
 	// TODO: not implemented
 	panic("not implemented")
 }
+
+func (s *DefaultChannelService) DissolveGroup(ctx context.Context, cid, operator uint64) error {
+	channel, err := s.channelRepo.FindByID(ctx, cid)
+	if err != nil {
+		if errors.Is(err, errs.ErrRecordNotFound) {
+			return fmt.Errorf("%w: channel not found", errs.ErrInvalidParam)
+		}
+		return err
+	}
+
+	if channel.ChannelType != domain.ChannelTypeGroup {
+		return fmt.Errorf("%w: only group channel can be dissolved", errs.ErrInvalidParam)
+	}
+
+	// 校验操作者是否为群主。
+	member, err := s.channelRepo.FindMember(ctx, cid, operator)
+	if err != nil {
+		if errors.Is(err, errs.ErrRecordNotFound) {
+			return fmt.Errorf("%w: not a member of channel", errs.ErrPermissionDenied)
+		}
+		return err
+	}
+	if member.UserRole != domain.ChannelMemberRoleOwner {
+		return fmt.Errorf("%w: only owner can dissolve group", errs.ErrPermissionDenied)
+	}
+
+	// 先加载成员再更新状态：状态更新之后无法再次解散，
+	// 加载失败时频道仍然是活跃状态，调用方可以重试，不会留下没有关闭会话、没有通知成员的已解散频道。
+	members, err := s.channelRepo.ListActiveMembers(ctx, cid)
+	if err != nil {
+		return fmt.Errorf("failed to list channel members: %w", err)
+	}
+
+	// 使用 CAS 更新状态，避免重复解散。
+	ok, err := s.channelRepo.UpdateStatus(ctx, cid, domain.ChannelStatusActive, domain.ChannelStatusArchived)
+	if err != nil {
+		return fmt.Errorf("failed to archive channel: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: channel is not active", errs.ErrInvalidParam)
+	}
+
+	now := time.Now().UnixMilli()
+	memberIDs := make([]uint64, 0, len(members))
+	for i := range members {
+		memberIDs = append(memberIDs, members[i].UID)
+
+		// 关闭成员会话，失败不中断流程 ( 频道已是只读状态，会话可由客户端同步时修正 )。
+		if err := s.conversationRepo.Close(ctx, members[i].UID, cid, now); err != nil {
+			s.logger.Error(
+				"[hermet-channel-service] failed to close conversation",
+				zap.Uint64("cid", cid),
+				zap.Uint64("uid", members[i].UID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	go func() {
+		// 异步通知成员，避免阻塞主流程。
+		// 这里必须使用 context.Background()，ctx 会在请求结束后取消。
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		event := domain.ChannelDissolvedEvent{
+			CID:         cid,
+			MemberIDs:   memberIDs,
+			Operator:    operator,
+			DissolvedAt: now,
+		}
+		err := publishEvent(ctx, s.producer, channelEventTopic, cid, string(domain.ChannelEventTypeDissolved), event)
+		if err != nil {
+			s.logger.Error(
+				"[hermet-channel-service] failed to publish channel dissolved event",
+				zap.Uint64("cid", cid),
+				zap.Error(err),
+			)
+		}
+	}()
+	return nil
+}
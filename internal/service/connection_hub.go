@@ -0,0 +1,92 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+)
+
+const connectionBufferSize = 256
+
+// ConnectionHub 本节点上的用户连接 ( 一个会话对应一个连接 )。
+// 投递是尽力而为的，连接的缓冲区满时丢弃投递，客户端通过离线同步补齐。
+type ConnectionHub struct {
+	mu    sync.RWMutex
+	conns map[uint64]map[string]chan domain.Delivery
+}
+
+func NewConnectionHub() *ConnectionHub {
+	return &ConnectionHub{
+		conns: make(map[uint64]map[string]chan domain.Delivery),
+	}
+}
+
+// Attach 建立会话连接，同一会话重复连接时旧连接会被关闭。
+// 返回投递通道以及断开连接的函数。
+func (h *ConnectionHub) Attach(uid uint64, sid string) (<-chan domain.Delivery, func()) {
+	ch := make(chan domain.Delivery, connectionBufferSize)
+
+	h.mu.Lock()
+	sessions, ok := h.conns[uid]
+	if !ok {
+		sessions = make(map[string]chan domain.Delivery)
+		h.conns[uid] = sessions
+	}
+	if old, ok := sessions[sid]; ok {
+		close(old)
+	}
+	sessions[sid] = ch
+	h.mu.Unlock()
+
+	detach := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		// 连接可能已经被同一会话的新连接替换。
+		if sessions, ok := h.conns[uid]; ok && sessions[sid] == ch {
+			close(ch)
+			delete(sessions, sid)
+			if len(sessions) == 0 {
+				delete(h.conns, uid)
+			}
+		}
+	}
+	return ch, detach
+}
+
+// Deliver 投递到用户在本节点上的所有连接，返回成功投递的连接数。
+func (h *ConnectionHub) Deliver(uid uint64, delivery domain.Delivery) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	delivered := 0
+	for _, ch := range h.conns[uid] {
+		select {
+		case ch <- delivery:
+			delivered++
+		default:
+		}
+	}
+	return delivered
+}
+
+// FilterLocal 从 uids 中找出在本节点上有连接的用户。
+func (h *ConnectionHub) FilterLocal(uids []uint64) []uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	local := make([]uint64, 0, len(uids))
+	for _, uid := range uids {
+		if _, ok := h.conns[uid]; ok {
+			local = append(local, uid)
+		}
+	}
+	return local
+}
+
+// Empty 判断本节点上是否没有任何连接。
+func (h *ConnectionHub) Empty() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.conns) == 0
+}
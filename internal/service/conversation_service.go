@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/repo"
+)
+
+const maxConversationLimit = 100
+
+type ConversationService interface {
+	// List 查询用户的会话列表 ( 置顶优先，按最后消息时间倒序 )。
+	List(ctx context.Context, uid uint64, offset, limit int) ([]domain.Conversation, error)
+
+	// MarkRead 标记会话已读到 messageID，已读到最后一条 @ 消息时清除 @ 标记。
+	MarkRead(ctx context.Context, uid, cid, messageID uint64) error
+}
+
+var _ ConversationService = (*DefaultConversationService)(nil)
+
+type DefaultConversationService struct {
+	conversationRepo repo.ConversationRepo
+}
+
+func NewDefaultConversationService(conversationRepo repo.ConversationRepo) *DefaultConversationService {
+	return &DefaultConversationService{
+		conversationRepo: conversationRepo,
+	}
+}
+
+func (s *DefaultConversationService) List(ctx context.Context, uid uint64, offset, limit int) ([]domain.Conversation, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", errs.ErrInvalidParam)
+	}
+	if limit <= 0 || limit > maxConversationLimit {
+		return nil, fmt.Errorf("%w: limit must be in (0, %d]", errs.ErrInvalidParam, maxConversationLimit)
+	}
+	return s.conversationRepo.ListByUID(ctx, uid, offset, limit)
+}
+
+func (s *DefaultConversationService) MarkRead(ctx context.Context, uid, cid, messageID uint64) error {
+	if messageID == 0 {
+		return fmt.Errorf("%w: message id is empty", errs.ErrInvalidParam)
+	}
+	return s.conversationRepo.ClearMention(ctx, uid, cid, messageID)
+}
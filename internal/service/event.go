@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
+)
+
+const (
+	// channelEventTopic 频道事件 topic，以 channel id 作为 key 保证同一频道的事件有序。
+	channelEventTopic = "channel-events"
+	// messageEventTopic 消息事件 topic ( 撤回 / 编辑等 )，以 channel id 作为 key 保证同一频道的事件有序。
+	messageEventTopic = "message-events"
+	// messageSentTopic 消息发送 topic，以 channel id 作为 key。
+	// 发送消息的量远大于其他消息事件，单独使用一个 topic，下游 ( 如离线推送 ) 不需要按事件类型过滤。
+	messageSentTopic = "message-sent"
+	// presenceEventTopic 在线状态事件 topic ( 在线状态变更 / 正在输入 )，事件只用于实时通知，不会持久化。
+	presenceEventTopic = "presence-events"
+
+	headerEventType     = "event_type"
+	headerTimestamp     = "timestamp"
+	headerSchemaVersion = "schema_version"
+
+	// eventSchemaVersion 事件结构版本，事件结构发生不兼容的变更时递增，下游按照版本解析。
+	eventSchemaVersion = "1"
+)
+
+// publishEvent 将事件序列化后发送到 kafka。
+// key 一般为 channel id，用于保证同一频道内事件的顺序。
+func publishEvent(
+	ctx context.Context,
+	producer produce.Producer,
+	topic string,
+	key uint64,
+	eventType string,
+	event any,
+) error {
+	msg, err := newEventMessage(topic, key, eventType, event)
+	if err != nil {
+		return err
+	}
+	return producer.Produce(ctx, msg)
+}
+
+// publishEventAsync 将事件放入异步发送缓冲区后立即返回，不等待 kafka 写入。
+// 缓冲区已满时返回错误，发送失败时调用 onError。
+func publishEventAsync(
+	producer produce.AsyncProducer,
+	topic string,
+	key uint64,
+	eventType string,
+	event any,
+	onError func(err error),
+) error {
+	msg, err := newEventMessage(topic, key, eventType, event)
+	if err != nil {
+		return err
+	}
+	return producer.ProduceAsync(msg, func(_ *xmq.Message, err error) {
+		if err != nil {
+			onError(err)
+		}
+	})
+}
+
+func newEventMessage(topic string, key uint64, eventType string, event any) (*xmq.Message, error) {
+	val, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event [ %s ]: %w", eventType, err)
+	}
+
+	return &xmq.Message{
+		Headers: xmq.Headers{
+			headerEventType:     eventType,
+			headerTimestamp:     strconv.FormatInt(time.Now().UnixMilli(), 10),
+			headerSchemaVersion: eventSchemaVersion,
+		},
+		Topic: topic,
+		Key:   []byte(strconv.FormatUint(key, 10)),
+		Val:   val,
+	}, nil
+}
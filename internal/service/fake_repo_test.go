@@ -0,0 +1,725 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
+	"github.com/jrmarcco/hermet/internal/repo"
+)
+
+// 测试使用的内存 repo，只实现测试中用到的方法 ( 未实现的方法调用时 panic )。
+
+type fakeChannelRepo struct {
+	repo.ChannelRepo
+
+	mu       sync.Mutex
+	channels map[uint64]domain.Channel
+	members  map[uint64][]domain.ChannelMember
+}
+
+func newFakeChannelRepo() *fakeChannelRepo {
+	return &fakeChannelRepo{
+		channels: make(map[uint64]domain.Channel),
+		members:  make(map[uint64][]domain.ChannelMember),
+	}
+}
+
+func (r *fakeChannelRepo) addChannel(channel domain.Channel, members ...domain.ChannelMember) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.channels[channel.ID] = channel
+	for i := range members {
+		members[i].CID = channel.ID
+	}
+	r.members[channel.ID] = append(r.members[channel.ID], members...)
+}
+
+func (r *fakeChannelRepo) setStatus(cid uint64, status domain.ChannelStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	channel := r.channels[cid]
+	channel.ChannelStatus = status
+	r.channels[cid] = channel
+}
+
+func (r *fakeChannelRepo) removeMember(cid, uid uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.members[cid] {
+		if r.members[cid][i].UID == uid {
+			r.members[cid][i].LeftAt = 1
+		}
+	}
+}
+
+func (r *fakeChannelRepo) FindByID(_ context.Context, id uint64) (domain.Channel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	channel, ok := r.channels[id]
+	if !ok {
+		return domain.Channel{}, errs.ErrRecordNotFound
+	}
+	return channel, nil
+}
+
+func (r *fakeChannelRepo) UpdateLastMessageAt(_ context.Context, id uint64, lastMessageAt int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	channel := r.channels[id]
+	channel.LastMessageAt = max(channel.LastMessageAt, lastMessageAt)
+	r.channels[id] = channel
+	return nil
+}
+
+func (r *fakeChannelRepo) ListInactive(_ context.Context, before int64, limit int) ([]domain.Channel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var channels []domain.Channel
+	for _, channel := range r.channels {
+		if channel.LastMessageAt > channel.MessageArchivedAt && channel.LastMessageAt < before {
+			channels = append(channels, channel)
+		}
+	}
+	slices.SortFunc(channels, func(a, b domain.Channel) int { return cmp.Compare(a.LastMessageAt, b.LastMessageAt) })
+	return channels[:min(limit, len(channels))], nil
+}
+
+func (r *fakeChannelRepo) MarkMessageArchived(_ context.Context, id uint64, archivedAt int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	channel := r.channels[id]
+	channel.MessageArchivedAt = archivedAt
+	r.channels[id] = channel
+	return nil
+}
+
+func (r *fakeChannelRepo) FindMember(_ context.Context, cid, uid uint64) (domain.ChannelMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, member := range r.members[cid] {
+		if member.UID == uid && member.LeftAt == 0 {
+			return member, nil
+		}
+	}
+	return domain.ChannelMember{}, errs.ErrRecordNotFound
+}
+
+func (r *fakeChannelRepo) ListActiveMembers(_ context.Context, cid uint64) ([]domain.ChannelMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var members []domain.ChannelMember
+	for _, member := range r.members[cid] {
+		if member.LeftAt == 0 {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func (r *fakeChannelRepo) FindActiveMembers(ctx context.Context, cid uint64, uids []uint64) ([]domain.ChannelMember, error) {
+	members, _ := r.ListActiveMembers(ctx, cid)
+	return slices.DeleteFunc(members, func(m domain.ChannelMember) bool {
+		return !slices.Contains(uids, m.UID)
+	}), nil
+}
+
+type fakeMessageRepo struct {
+	repo.MessageRepo
+
+	mu       sync.Mutex
+	messages map[uint64]domain.Message // 按消息 ID 保存
+	archived map[uint64]domain.Message // 冷存储
+	edits    map[uint64][]domain.MessageEdit
+	deleted  map[uint64][]uint64 // uid -> 删除的消息 ID
+}
+
+func newFakeMessageRepo() *fakeMessageRepo {
+	return &fakeMessageRepo{
+		messages: make(map[uint64]domain.Message),
+		archived: make(map[uint64]domain.Message),
+		edits:    make(map[uint64][]domain.MessageEdit),
+		deleted:  make(map[uint64][]uint64),
+	}
+}
+
+func (r *fakeMessageRepo) Save(_ context.Context, message domain.Message) (domain.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if saved, ok := r.findByMID(message.CID, message.SID, message.MID); ok {
+		return saved, nil
+	}
+	r.messages[message.ID] = message
+	return message, nil
+}
+
+func (r *fakeMessageRepo) FindByMID(_ context.Context, cid, sid, mid uint64) (domain.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if saved, ok := r.findByMID(cid, sid, mid); ok {
+		return saved, nil
+	}
+	return domain.Message{}, errs.ErrRecordNotFound
+}
+
+func (r *fakeMessageRepo) findByMID(cid, sid, mid uint64) (domain.Message, bool) {
+	if mid == 0 {
+		return domain.Message{}, false
+	}
+	for _, m := range r.messages {
+		if m.CID == cid && m.SID == sid && m.MID == mid {
+			return m, true
+		}
+	}
+	return domain.Message{}, false
+}
+
+func (r *fakeMessageRepo) ListHistory(_ context.Context, cid, beforeID uint64, limit int) ([]domain.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var messages []domain.Message
+	for _, m := range r.messages {
+		if m.CID == cid && !m.IsInThread() && (beforeID == 0 || m.ID < beforeID) {
+			messages = append(messages, m)
+		}
+	}
+	slices.SortFunc(messages, func(a, b domain.Message) int { return cmp.Compare(b.ID, a.ID) })
+	return messages[:min(limit, len(messages))], nil
+}
+
+func (r *fakeMessageRepo) ListThread(_ context.Context, cid, threadID, afterID uint64, limit int) ([]domain.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var messages []domain.Message
+	for _, m := range r.messages {
+		if m.CID == cid && m.ThreadID == threadID && m.ID > afterID {
+			messages = append(messages, m)
+		}
+	}
+	slices.SortFunc(messages, func(a, b domain.Message) int { return cmp.Compare(a.ID, b.ID) })
+	return messages[:min(limit, len(messages))], nil
+}
+
+func (r *fakeMessageRepo) Archive(_ context.Context, cid uint64, _ int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cnt := 0
+	for id, m := range r.messages {
+		if m.CID == cid {
+			r.archived[id] = m
+			delete(r.messages, id)
+			cnt++
+		}
+	}
+	return cnt, nil
+}
+
+func (r *fakeMessageRepo) FindByIDs(_ context.Context, ids []uint64) ([]domain.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var messages []domain.Message
+	for _, id := range ids {
+		if m, ok := r.messages[id]; ok {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (r *fakeMessageRepo) FindByID(_ context.Context, cid, id uint64) (domain.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	message, ok := r.messages[id]
+	if !ok || message.CID != cid {
+		return domain.Message{}, errs.ErrRecordNotFound
+	}
+	return message, nil
+}
+
+// Recall 与旧版本的实现一样不删除编辑历史，用于验证 service 层不会返回撤回消息的编辑历史。
+func (r *fakeMessageRepo) Recall(_ context.Context, _, id uint64, recalledAt int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	message := r.messages[id]
+	if message.IsRecalled() {
+		return false, nil
+	}
+	message.RecalledAt = recalledAt
+	message.Content = nil
+	r.messages[id] = message
+	return true, nil
+}
+
+func (r *fakeMessageRepo) Edit(_ context.Context, _, id uint64, ver int, content []byte, editedAt int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	message := r.messages[id]
+	if message.IsRecalled() || message.EditVer != ver {
+		return false, nil
+	}
+	r.edits[id] = append(r.edits[id], domain.MessageEdit{
+		MessageID: id,
+		Ver:       ver,
+		Content:   message.Content,
+		EditedAt:  editedAt,
+	})
+	message.Content = content
+	message.EditVer = ver + 1
+	message.EditedAt = editedAt
+	r.messages[id] = message
+	return true, nil
+}
+
+func (r *fakeMessageRepo) ListEdits(_ context.Context, _, id uint64) ([]domain.MessageEdit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.edits[id]), nil
+}
+
+func (r *fakeMessageRepo) DeleteForUser(_ context.Context, uid, _, id uint64, _ int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deleted[uid] = append(r.deleted[uid], id)
+	return nil
+}
+
+func (r *fakeMessageRepo) FindDeletedIDs(_ context.Context, uid, _ uint64, ids []uint64) ([]uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted []uint64
+	for _, id := range ids {
+		if slices.Contains(r.deleted[uid], id) {
+			deleted = append(deleted, id)
+		}
+	}
+	return deleted, nil
+}
+
+// fakePresenceRepo 心跳结果和在线用户由测试指定，隐私设置默认为所有人可见。
+type fakePresenceRepo struct {
+	repo.PresenceRepo
+
+	becameOnline bool
+	online       map[uint64]bool
+	settings     map[uint64]domain.PresenceSetting
+}
+
+func (r *fakePresenceRepo) Heartbeat(context.Context, uint64, string, time.Duration) (bool, error) {
+	return r.becameOnline, nil
+}
+
+func (r *fakePresenceRepo) SetLastSeen(context.Context, uint64, int64) error {
+	return nil
+}
+
+func (r *fakePresenceRepo) FilterOnline(_ context.Context, uids []uint64) (map[uint64]bool, error) {
+	online := make(map[uint64]bool, len(uids))
+	for _, uid := range uids {
+		if r.online[uid] {
+			online[uid] = true
+		}
+	}
+	return online, nil
+}
+
+func (r *fakePresenceRepo) FindSettings(_ context.Context, uids []uint64) (map[uint64]domain.PresenceSetting, error) {
+	settings := make(map[uint64]domain.PresenceSetting, len(uids))
+	for _, uid := range uids {
+		setting, ok := r.settings[uid]
+		if !ok {
+			setting = domain.PresenceSetting{UID: uid, Visibility: domain.PresenceVisibilityEveryone}
+		}
+		settings[uid] = setting
+	}
+	return settings, nil
+}
+
+// fakeUserContactRepo 以 ( userID, contactID ) 为 key 保存联系人。
+type fakeUserContactRepo struct {
+	repo.UserContactRepo
+
+	contacts map[[2]uint64]domain.UserContact
+}
+
+func newFakeUserContactRepo(contacts ...domain.UserContact) *fakeUserContactRepo {
+	r := &fakeUserContactRepo{contacts: make(map[[2]uint64]domain.UserContact)}
+	for _, contact := range contacts {
+		r.contacts[[2]uint64{contact.UserID, contact.ContactID}] = contact
+	}
+	return r
+}
+
+func (r *fakeUserContactRepo) FindByUserIDAndContactID(_ context.Context, userID, contactID uint64) (domain.UserContact, error) {
+	contact, ok := r.contacts[[2]uint64{userID, contactID}]
+	if !ok {
+		return domain.UserContact{}, errs.ErrRecordNotFound
+	}
+	return contact, nil
+}
+
+func (r *fakeUserContactRepo) FindByUserIDAndContactIDs(
+	_ context.Context,
+	userID uint64,
+	contactIDs []uint64,
+) ([]domain.UserContact, error) {
+	var contacts []domain.UserContact
+	for _, contactID := range contactIDs {
+		if contact, ok := r.contacts[[2]uint64{userID, contactID}]; ok {
+			contacts = append(contacts, contact)
+		}
+	}
+	return contacts, nil
+}
+
+func (r *fakeUserContactRepo) ListOwnerIDs(_ context.Context, contactID uint64) ([]uint64, error) {
+	var ownerIDs []uint64
+	for key := range r.contacts {
+		if key[1] == contactID {
+			ownerIDs = append(ownerIDs, key[0])
+		}
+	}
+	slices.Sort(ownerIDs)
+	return ownerIDs, nil
+}
+
+// fakeConversationRepo 只维护会话的 @ 标记 ( 与 UserConversationViewDao 的 SQL 语义一致 )。
+type fakeConversationRepo struct {
+	repo.ConversationRepo
+
+	mu            sync.Mutex
+	conversations map[[2]uint64]domain.Conversation
+}
+
+func newFakeConversationRepo() *fakeConversationRepo {
+	return &fakeConversationRepo{conversations: make(map[[2]uint64]domain.Conversation)}
+}
+
+func (r *fakeConversationRepo) FindByUIDAndCID(_ context.Context, uid, cid uint64) (domain.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conversation := r.conversations[[2]uint64{uid, cid}]
+	conversation.UID, conversation.CID = uid, cid
+	return conversation, nil
+}
+
+func (r *fakeConversationRepo) ListByUID(_ context.Context, uid uint64, offset, limit int) ([]domain.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var conversations []domain.Conversation
+	for key, conversation := range r.conversations {
+		if key[0] == uid {
+			conversation.UID, conversation.CID = key[0], key[1]
+			conversations = append(conversations, conversation)
+		}
+	}
+	slices.SortFunc(conversations, func(a, b domain.Conversation) int { return cmp.Compare(a.CID, b.CID) })
+	conversations = conversations[min(offset, len(conversations)):]
+	return conversations[:min(limit, len(conversations))], nil
+}
+
+func (r *fakeConversationRepo) RecordMention(_ context.Context, uid, cid, messageID uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]uint64{uid, cid}
+	conversation := r.conversations[key]
+	conversation.MentionCount++
+	conversation.LastMentionMessageID = max(conversation.LastMentionMessageID, messageID)
+	r.conversations[key] = conversation
+	return nil
+}
+
+func (r *fakeConversationRepo) RevokeMention(_ context.Context, uid, cid, messageID uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]uint64{uid, cid}
+	conversation := r.conversations[key]
+	if conversation.LastMentionMessageID < messageID {
+		return nil
+	}
+	if conversation.MentionCount <= 1 {
+		conversation.LastMentionMessageID = 0
+	}
+	conversation.MentionCount = max(conversation.MentionCount-1, 0)
+	r.conversations[key] = conversation
+	return nil
+}
+
+// fakeRouteRepo 保存 uid -> sid -> 节点 的路由，不处理过期。
+type fakeRouteRepo struct {
+	repo.RouteRepo
+
+	mu     sync.Mutex
+	routes map[uint64]map[string]string
+}
+
+func newFakeRouteRepo() *fakeRouteRepo {
+	return &fakeRouteRepo{routes: make(map[uint64]map[string]string)}
+}
+
+func (r *fakeRouteRepo) Register(_ context.Context, uid uint64, sid, nodeID string, _ time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.routes[uid] == nil {
+		r.routes[uid] = make(map[string]string)
+	}
+	r.routes[uid][sid] = nodeID
+	return nil
+}
+
+func (r *fakeRouteRepo) Unregister(_ context.Context, uid uint64, sid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.routes[uid], sid)
+	return nil
+}
+
+func (r *fakeRouteRepo) GroupByNode(_ context.Context, uids []uint64) (map[string][]uint64, []uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	grouped := make(map[string][]uint64)
+	var offline []uint64
+	for _, uid := range uids {
+		if len(r.routes[uid]) == 0 {
+			offline = append(offline, uid)
+			continue
+		}
+
+		nodes := make(map[string]struct{})
+		for _, nodeID := range r.routes[uid] {
+			if _, ok := nodes[nodeID]; !ok {
+				nodes[nodeID] = struct{}{}
+				grouped[nodeID] = append(grouped[nodeID], uid)
+			}
+		}
+	}
+	return grouped, offline, nil
+}
+
+// fakePushRepo 只保存推送设备，没有用户设置推送偏好。
+type fakePushRepo struct {
+	repo.PushRepo
+
+	mu      sync.Mutex
+	devices map[uint64][]domain.PushDevice
+}
+
+func newFakePushRepo(devices ...domain.PushDevice) *fakePushRepo {
+	r := &fakePushRepo{devices: make(map[uint64][]domain.PushDevice)}
+	for _, device := range devices {
+		r.devices[device.UID] = append(r.devices[device.UID], device)
+	}
+	return r
+}
+
+func (r *fakePushRepo) ListDevices(_ context.Context, uid uint64) ([]domain.PushDevice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.devices[uid]), nil
+}
+
+func (r *fakePushRepo) DeleteDeviceByToken(_ context.Context, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for uid, devices := range r.devices {
+		r.devices[uid] = slices.DeleteFunc(devices, func(device domain.PushDevice) bool {
+			return device.Token == token
+		})
+	}
+	return nil
+}
+
+func (r *fakePushRepo) FindSetting(context.Context, uint64) (domain.PushSetting, error) {
+	return domain.PushSetting{}, errs.ErrRecordNotFound
+}
+
+type fakeSyncRepo struct {
+	repo.SyncRepo
+
+	mu   sync.Mutex
+	seqs map[uint64]uint64
+	logs map[uint64][]domain.SyncLog
+}
+
+func newFakeSyncRepo() *fakeSyncRepo {
+	return &fakeSyncRepo{
+		seqs: make(map[uint64]uint64),
+		logs: make(map[uint64][]domain.SyncLog),
+	}
+}
+
+func (r *fakeSyncRepo) NextSeq(_ context.Context, uid uint64) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seqs[uid]++
+	return r.seqs[uid], nil
+}
+
+func (r *fakeSyncRepo) Append(_ context.Context, log domain.SyncLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.logs[log.UID] = append(r.logs[log.UID], log)
+	return nil
+}
+
+func (r *fakeSyncRepo) LatestSeq(_ context.Context, uid uint64) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.seqs[uid], nil
+}
+
+func (r *fakeSyncRepo) MinSeq(_ context.Context, uid uint64) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.logs[uid]) == 0 {
+		return 0, nil
+	}
+	return slices.MinFunc(r.logs[uid], func(a, b domain.SyncLog) int { return cmp.Compare(a.Seq, b.Seq) }).Seq, nil
+}
+
+func (r *fakeSyncRepo) ListAfter(_ context.Context, uid, afterSeq uint64, limit int) ([]domain.SyncLog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var logs []domain.SyncLog
+	for _, log := range r.logs[uid] {
+		if log.Seq > afterSeq {
+			logs = append(logs, log)
+		}
+	}
+	slices.SortFunc(logs, func(a, b domain.SyncLog) int { return cmp.Compare(a.Seq, b.Seq) })
+	return logs[:min(limit, len(logs))], nil
+}
+
+func (r *fakeSyncRepo) userLogs(uid uint64) []domain.SyncLog {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.logs[uid])
+}
+
+// fakeIDGen 按顺序生成 ID ( 忽略分片值 )。
+type fakeIDGen struct {
+	next atomic.Uint64
+}
+
+func (g *fakeIDGen) NextID(_ uint64) (uint64, error) {
+	return g.next.Add(1), nil
+}
+
+// fakeSeqGen 每个 key 的序列号从 floor 的下一个序列号 ( 没有 floor 时为 1 ) 开始递增。
+type fakeSeqGen struct {
+	mu   sync.Mutex
+	seqs map[string]uint64
+}
+
+func (g *fakeSeqGen) NextSeq(ctx context.Context, key string, floor idgen.SeqFloorFunc) (uint64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.seqs == nil {
+		g.seqs = make(map[string]uint64)
+	}
+	if _, ok := g.seqs[key]; !ok && floor != nil {
+		seq, err := floor(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		g.seqs[key] = seq
+	}
+	g.seqs[key]++
+	return g.seqs[key], nil
+}
+
+// fakeProducer 只记录发送的消息。
+type fakeProducer struct {
+	mu   sync.Mutex
+	msgs []*xmq.Message
+}
+
+var _ produce.AsyncProducer = (*fakeProducer)(nil)
+
+func (p *fakeProducer) ProduceAsync(msg *xmq.Message, cb produce.DeliveryCallback) error {
+	p.mu.Lock()
+	p.msgs = append(p.msgs, msg)
+	p.mu.Unlock()
+
+	if cb != nil {
+		cb(msg, nil)
+	}
+	return nil
+}
+
+var _ produce.Producer = (*fakeProducer)(nil)
+
+func (p *fakeProducer) Produce(_ context.Context, msg *xmq.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.msgs = append(p.msgs, msg)
+	return nil
+}
+
+func (p *fakeProducer) ProduceBatch(ctx context.Context, msgs []*xmq.Message) error {
+	for _, msg := range msgs {
+		_ = p.Produce(ctx, msg)
+	}
+	return nil
+}
+
+func (p *fakeProducer) Close(_ context.Context) error {
+	return nil
+}
+
+// eventTypes 返回发送的事件类型。
+func (p *fakeProducer) eventTypes() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	types := make([]string, 0, len(p.msgs))
+	for _, msg := range p.msgs {
+		types = append(types, msg.Headers[headerEventType])
+	}
+	return types
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/worker"
+	"github.com/jrmarcco/hermet/internal/repo"
+	"go.uber.org/zap"
+)
+
+// FanoutDispatcherConfig 消息扇出配置。
+type FanoutDispatcherConfig struct {
+	GroupID     string `mapstructure:"group_id"`    // kafka 消费者组，所有节点共享
+	Concurrency int    `mapstructure:"concurrency"` // 处理协程数
+
+	Retry *worker.RetryPolicy `mapstructure:"retry"` // 处理失败时的重试策略，为空时不重试
+}
+
+// FanoutDispatcher 消息扇出任务。
+// 消费消息发送事件，将消息实时投递给频道成员 ( 包括发送者的其他设备 )。
+// 没有连接的成员不做处理，消息已写入同步日志，由离线同步和离线推送兜底。
+type FanoutDispatcher struct {
+	cfg FanoutDispatcherConfig
+
+	router      *MessageRouter
+	channelRepo repo.ChannelRepo
+	logger      *zap.Logger
+}
+
+func NewFanoutDispatcher(
+	cfg FanoutDispatcherConfig,
+	router *MessageRouter,
+	channelRepo repo.ChannelRepo,
+	logger *zap.Logger,
+) *FanoutDispatcher {
+	return &FanoutDispatcher{
+		cfg:         cfg,
+		router:      router,
+		channelRepo: channelRepo,
+		logger:      logger,
+	}
+}
+
+// Handle 处理消息发送事件。
+func (d *FanoutDispatcher) Handle(ctx context.Context, msg *xmq.Message) error {
+	var event domain.MessageSentEvent
+	if err := json.Unmarshal(msg.Val, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal message sent event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, routeDeliveryTimeout)
+	defer cancel()
+
+	members, err := d.channelRepo.ListActiveMembers(ctx, event.CID)
+	if err != nil {
+		return fmt.Errorf("failed to list members of channel [ %d ]: %w", event.CID, err)
+	}
+
+	uids := make([]uint64, 0, len(members))
+	for i := range members {
+		uids = append(uids, members[i].UID)
+	}
+
+	offline, err := d.router.Route(ctx, domain.Delivery{
+		EventType: string(domain.MessageEventTypeSent),
+		CID:       event.CID,
+		UIDs:      uids,
+		Payload:   msg.Val,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to route message [ %d ]: %w", event.ID, err)
+	}
+
+	if len(offline) > 0 {
+		d.logger.Debug(
+			"[hermet-fanout-dispatcher] recipients offline, fallback to offline sync",
+			zap.Uint64("cid", event.CID),
+			zap.Uint64("message_id", event.ID),
+			zap.Int("offline", len(offline)),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/repo"
+	"go.uber.org/zap"
+)
+
+// MessageArchiverConfig 消息归档配置。
+type MessageArchiverConfig struct {
+	Interval      time.Duration `mapstructure:"interval"`       // 归档任务执行间隔
+	InactiveAfter time.Duration `mapstructure:"inactive_after"` // 最后一条消息超过该时长的频道视为不活跃
+	ChannelLimit  int           `mapstructure:"channel_limit"`  // 每次任务最多处理的频道数量
+	BatchSize     int           `mapstructure:"batch_size"`     // 每批迁移的消息数量
+}
+
+// MessageArchiver 消息归档后台任务。
+// 定期将长期不活跃频道 ( 依据 channel.last_message_at ) 的消息迁移到冷存储，
+// 历史消息查询会自动回落到冷存储，对调用方透明。
+type MessageArchiver struct {
+	cfg MessageArchiverConfig
+
+	channelRepo repo.ChannelRepo
+	messageRepo repo.MessageRepo
+
+	logger *zap.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewMessageArchiver(
+	cfg MessageArchiverConfig,
+	channelRepo repo.ChannelRepo,
+	messageRepo repo.MessageRepo,
+	logger *zap.Logger,
+) *MessageArchiver {
+	return &MessageArchiver{
+		cfg:         cfg,
+		channelRepo: channelRepo,
+		messageRepo: messageRepo,
+		logger:      logger,
+	}
+}
+
+func (a *MessageArchiver) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(a.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.archive(ctx)
+			}
+		}
+	}()
+}
+
+func (a *MessageArchiver) Stop(ctx context.Context) error {
+	if a.cancel != nil {
+		a.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *MessageArchiver) archive(ctx context.Context) {
+	before := time.Now().Add(-a.cfg.InactiveAfter).UnixMilli()
+
+	channels, err := a.channelRepo.ListInactive(ctx, before, a.cfg.ChannelLimit)
+	if err != nil {
+		a.logger.Error("[hermet-message-archiver] failed to list inactive channels", zap.Error(err))
+		return
+	}
+
+	for i := range channels {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// 先记录时间，迁移期间若有新消息写入，last_message_at 会大于归档时间，下一轮会再次归档。
+		archivedAt := time.Now().UnixMilli()
+
+		cnt, err := a.messageRepo.Archive(ctx, channels[i].ID, a.cfg.BatchSize)
+		if err != nil {
+			a.logger.Error(
+				"[hermet-message-archiver] failed to archive messages",
+				zap.Uint64("cid", channels[i].ID),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		if err := a.channelRepo.MarkMessageArchived(ctx, channels[i].ID, archivedAt); err != nil {
+			a.logger.Error(
+				"[hermet-message-archiver] failed to mark channel archived",
+				zap.Uint64("cid", channels[i].ID),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		a.logger.Info(
+			"[hermet-message-archiver] archived channel messages",
+			zap.Uint64("cid", channels[i].ID),
+			zap.Int("count", cnt),
+		)
+	}
+}
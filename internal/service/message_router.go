@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
+	"github.com/jrmarcco/hermet/internal/repo"
+	"go.uber.org/zap"
+)
+
+const (
+	// nodeTopicPrefix 节点投递 topic 前缀，每个节点消费自己的 topic ( hermet-node-{node_id} )。
+	nodeTopicPrefix = "hermet-node-"
+	// broadcastTopic 广播投递 topic，每个节点使用独立的消费者组，所有节点都会收到全部广播。
+	broadcastTopic = "hermet-broadcast"
+
+	routeDeliveryTimeout = 5 * time.Second
+)
+
+// MessageRouterConfig 跨节点投递配置。
+type MessageRouterConfig struct {
+	NodeID   string        `mapstructure:"node_id"`   // 节点 ID，多实例部署时必须唯一
+	RouteTTL time.Duration `mapstructure:"route_ttl"` // 连接路由的过期时间，连接需要在过期前续期
+
+	// BroadcastThreshold 接收者超过该数量时使用广播投递。
+	// 广播投递不查询路由表，由每个节点根据频道成员筛选本地连接，避免大群按成员逐个查询路由。
+	BroadcastThreshold int `mapstructure:"broadcast_threshold"`
+
+	Concurrency int `mapstructure:"concurrency"` // 节点投递的处理协程数
+}
+
+// MessageRouter 跨节点实时投递。
+// 通过 redis 路由表 ( uid -> 节点 ) 找到接收者连接所在的节点，再通过节点 topic 投递到对应节点，
+// 本节点的连接直接投递，不经过 kafka。
+// 投递是尽力而为的，没有连接或投递失败的用户通过离线同步和离线推送获取消息。
+type MessageRouter struct {
+	cfg MessageRouterConfig
+
+	hub         *ConnectionHub
+	routeRepo   repo.RouteRepo
+	channelRepo repo.ChannelRepo
+
+	producer produce.Producer
+	logger   *zap.Logger
+}
+
+func NewMessageRouter(
+	cfg MessageRouterConfig,
+	hub *ConnectionHub,
+	routeRepo repo.RouteRepo,
+	channelRepo repo.ChannelRepo,
+	producer produce.Producer,
+	logger *zap.Logger,
+) *MessageRouter {
+	return &MessageRouter{
+		cfg:         cfg,
+		hub:         hub,
+		routeRepo:   routeRepo,
+		channelRepo: channelRepo,
+		producer:    producer,
+		logger:      logger,
+	}
+}
+
+// Connect 在本节点建立会话连接并注册路由，返回投递通道和断开连接的函数。
+func (r *MessageRouter) Connect(ctx context.Context, uid uint64, sid string) (<-chan domain.Delivery, func(), error) {
+	if err := r.routeRepo.Register(ctx, uid, sid, r.cfg.NodeID, r.cfg.RouteTTL); err != nil {
+		return nil, nil, fmt.Errorf("failed to register route: %w", err)
+	}
+
+	ch, detach := r.hub.Attach(uid, sid)
+	disconnect := func() {
+		detach()
+
+		// 这里必须使用 context.Background()，连接断开时 ctx 通常已经取消。
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := r.routeRepo.Unregister(ctx, uid, sid); err != nil {
+			r.logger.Error(
+				"[hermet-message-router] failed to unregister route",
+				zap.Uint64("uid", uid),
+				zap.String("sid", sid),
+				zap.Error(err),
+			)
+		}
+	}
+	return ch, disconnect, nil
+}
+
+// KeepAlive 续期会话连接的路由。
+func (r *MessageRouter) KeepAlive(ctx context.Context, uid uint64, sid string) error {
+	return r.routeRepo.Register(ctx, uid, sid, r.cfg.NodeID, r.cfg.RouteTTL)
+}
+
+// RouteTTL 返回路由过期时间，连接需要在过期前调用 KeepAlive。
+func (r *MessageRouter) RouteTTL() time.Duration {
+	return r.cfg.RouteTTL
+}
+
+// Route 将投递发送到接收者连接所在的节点，返回没有任何连接的用户。
+// 接收者超过广播阈值时使用广播投递，此时不返回离线用户。
+func (r *MessageRouter) Route(ctx context.Context, delivery domain.Delivery) ([]uint64, error) {
+	if len(delivery.UIDs) > r.cfg.BroadcastThreshold {
+		delivery.UIDs = nil
+		return nil, r.produce(ctx, broadcastTopic, delivery)
+	}
+
+	grouped, offline, err := r.routeRepo.GroupByNode(ctx, delivery.UIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for nodeID, uids := range grouped {
+		nodeDelivery := delivery
+		nodeDelivery.UIDs = uids
+
+		if nodeID == r.cfg.NodeID {
+			r.deliverLocal(nodeDelivery)
+			continue
+		}
+		if err := r.produce(ctx, nodeTopicPrefix+nodeID, nodeDelivery); err != nil {
+			r.logger.Error(
+				"[hermet-message-router] failed to route delivery to node",
+				zap.String("node_id", nodeID),
+				zap.Uint64("cid", delivery.CID),
+				zap.Error(err),
+			)
+		}
+	}
+	return offline, nil
+}
+
+func (r *MessageRouter) produce(ctx context.Context, topic string, delivery domain.Delivery) error {
+	val, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to marshal delivery: %w", err)
+	}
+	return r.producer.Produce(ctx, &xmq.Message{
+		Topic: topic,
+		Key:   []byte(strconv.FormatUint(delivery.CID, 10)),
+		Val:   val,
+	})
+}
+
+// NodeTopic 本节点的投递 topic。
+func (r *MessageRouter) NodeTopic() string {
+	return nodeTopicPrefix + r.cfg.NodeID
+}
+
+// BroadcastGroupID 本节点消费广播 topic 使用的消费者组 ( 每个节点独立 )。
+func (r *MessageRouter) BroadcastGroupID() string {
+	return broadcastTopic + "-" + r.cfg.NodeID
+}
+
+// Handle 处理投递到本节点的消息 ( 节点 topic 和广播 topic )。
+func (r *MessageRouter) Handle(ctx context.Context, msg *xmq.Message) error {
+	var delivery domain.Delivery
+	if err := json.Unmarshal(msg.Val, &delivery); err != nil {
+		return fmt.Errorf("failed to unmarshal delivery: %w", err)
+	}
+
+	if !delivery.IsBroadcast() {
+		r.deliverLocal(delivery)
+		return nil
+	}
+
+	// 本节点没有任何连接时不需要查询频道成员。
+	if r.hub.Empty() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, routeDeliveryTimeout)
+	defer cancel()
+
+	members, err := r.channelRepo.ListActiveMembers(ctx, delivery.CID)
+	if err != nil {
+		return fmt.Errorf("failed to list members of channel [ %d ]: %w", delivery.CID, err)
+	}
+
+	uids := make([]uint64, 0, len(members))
+	for i := range members {
+		uids = append(uids, members[i].UID)
+	}
+	delivery.UIDs = r.hub.FilterLocal(uids)
+	r.deliverLocal(delivery)
+	return nil
+}
+
+func (r *MessageRouter) deliverLocal(delivery domain.Delivery) {
+	for _, uid := range delivery.UIDs {
+		if r.hub.Deliver(uid, delivery) == 0 {
+			r.logger.Debug(
+				"[hermet-message-router] delivery dropped, no active connection",
+				zap.Uint64("uid", uid),
+				zap.Uint64("cid", delivery.CID),
+			)
+		}
+	}
+}
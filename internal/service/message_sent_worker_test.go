@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/consumer"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/memory"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/worker"
+	"github.com/jrmarcco/hermet/internal/pkg/xpush"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakePushProvider 记录推送的通知。
+type fakePushProvider struct {
+	mu            sync.Mutex
+	notifications []xpush.Notification
+}
+
+func (p *fakePushProvider) Push(_ context.Context, n xpush.Notification) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.notifications = append(p.notifications, n)
+	return nil
+}
+
+func (p *fakePushProvider) pushed() []xpush.Notification {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]xpush.Notification(nil), p.notifications...)
+}
+
+// TestMessageSentWorkers 使用内存消息队列运行扇出和离线推送任务 ( 与 module.go 中的注册一致 )，
+// 验证消息发送事件经过 worker 运行时投递给在线成员并推送给离线成员。
+func TestMessageSentWorkers(t *testing.T) {
+	t.Parallel()
+
+	const (
+		cid     = uint64(100)
+		sender  = uint64(1)
+		local   = uint64(2) // 连接在本节点
+		remote  = uint64(3) // 连接在其他节点
+		offline = uint64(4) // 没有连接，有推送设备
+
+		nodeID      = "node-a"
+		remoteTopic = nodeTopicPrefix + "node-b"
+	)
+
+	broker := memory.NewBroker(memory.Config{Partitions: 2})
+	t.Cleanup(broker.Close)
+	producer := memory.NewProducer(broker)
+	factory := memory.NewConsumerFactory(broker, consumer.AckModeManual)
+
+	channelRepo := newFakeChannelRepo()
+	channelRepo.addChannel(
+		domain.Channel{ID: cid, ChannelType: domain.ChannelTypeGroup, ChannelStatus: domain.ChannelStatusActive},
+		domain.ChannelMember{UID: sender, UserRole: domain.ChannelMemberRoleOwner},
+		domain.ChannelMember{UID: local, UserRole: domain.ChannelMemberRoleMember},
+		domain.ChannelMember{UID: remote, UserRole: domain.ChannelMemberRoleMember},
+		domain.ChannelMember{UID: offline, UserRole: domain.ChannelMemberRoleMember},
+	)
+	routeRepo := newFakeRouteRepo()
+	require.NoError(t, routeRepo.Register(t.Context(), remote, "sid-remote", "node-b", time.Minute))
+	pushRepo := newFakePushRepo(domain.PushDevice{UID: offline, SID: "sid-offline", Platform: domain.PushPlatformFCM, Token: "token"})
+	presenceRepo := &fakePresenceRepo{online: map[uint64]bool{sender: true, local: true, remote: true}}
+	provider := &fakePushProvider{}
+
+	router := NewMessageRouter(
+		MessageRouterConfig{NodeID: nodeID, RouteTTL: time.Minute, BroadcastThreshold: 100},
+		NewConnectionHub(),
+		routeRepo,
+		channelRepo,
+		producer,
+		zap.NewNop(),
+	)
+	deliveries, disconnect, err := router.Connect(t.Context(), local, "sid-local")
+	require.NoError(t, err)
+	t.Cleanup(disconnect)
+
+	fanout := NewFanoutDispatcher(FanoutDispatcherConfig{}, router, channelRepo, zap.NewNop())
+	push := NewPushDispatcher(
+		PushDispatcherConfig{AggregateWindow: 10 * time.Millisecond},
+		channelRepo,
+		newFakeConversationRepo(),
+		presenceRepo,
+		pushRepo,
+		xpush.Providers{xpush.PlatformFCM: provider},
+		zap.NewNop(),
+	)
+
+	runtime := worker.NewRuntime(factory, producer, nil)
+	require.NoError(t, runtime.Register(worker.Registration{
+		Name:     "hermet-fanout-dispatcher",
+		Topic:    messageSentTopic,
+		GroupID:  "hermet-fanout",
+		Handler:  worker.HandlerFunc(fanout.Handle),
+		Affinity: worker.AffinityKey,
+		Retry:    &worker.RetryPolicy{},
+	}))
+	require.NoError(t, runtime.Register(worker.Registration{
+		Name:     "hermet-push-dispatcher",
+		Topic:    messageSentTopic,
+		GroupID:  "hermet-push",
+		Handler:  worker.HandlerFunc(push.Handle),
+		Affinity: worker.AffinityKey,
+		Retry:    &worker.RetryPolicy{},
+	}))
+	require.NoError(t, runtime.Start())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		_ = runtime.Stop(ctx)
+		_ = push.Stop(ctx)
+	})
+
+	content, err := json.Marshal(domain.TextContent{Text: "hello"})
+	require.NoError(t, err)
+	event := domain.MessageSentEvent{
+		CID:         cid,
+		ID:          1,
+		SID:         sender,
+		Content:     content,
+		ContentType: domain.ContentTypeText,
+	}
+	require.NoError(t, publishEvent(t.Context(), producer, messageSentTopic, cid, string(domain.MessageEventTypeSent), event))
+
+	// 本节点的连接直接投递。
+	select {
+	case delivery := <-deliveries:
+		require.Equal(t, cid, delivery.CID)
+		require.Equal(t, []uint64{local}, delivery.UIDs)
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for local delivery")
+	}
+
+	// 其他节点的连接通过节点 topic 投递。
+	c, err := factory.NewConsumer(remoteTopic, "node-b")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Second)
+	defer cancel()
+	msg, err := c.Consume(ctx)
+	require.NoError(t, err)
+	var delivery domain.Delivery
+	require.NoError(t, json.Unmarshal(msg.Val, &delivery))
+	require.Equal(t, []uint64{remote}, delivery.UIDs)
+
+	// 离线成员在聚合窗口结束后收到推送。
+	require.Eventually(t, func() bool {
+		return len(provider.pushed()) == 1
+	}, 3*time.Second, 5*time.Millisecond)
+	n := provider.pushed()[0]
+	require.Equal(t, "token", n.Token)
+	require.Contains(t, n.Body, "hello")
+
+	// 两个消费者组分别提交了 offset。
+	for _, groupID := range []string{"hermet-fanout", "hermet-push"} {
+		require.Eventually(t, func() bool {
+			var committed int64
+			for _, offset := range broker.Offsets(messageSentTopic, groupID) {
+				committed += offset
+			}
+			return committed == 1
+		}, 3*time.Second, 5*time.Millisecond)
+	}
+}
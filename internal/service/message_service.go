@@ -0,0 +1,807 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
+	"github.com/jrmarcco/hermet/internal/repo"
+	commonv1 "github.com/jrmarcco/synp-api/api/go/common/v1"
+	"go.uber.org/zap"
+)
+
+const (
+	maxHistoryLimit = 100
+	maxMentions     = 50 // 单条消息最多 @ 的用户数
+	maxEmojiLen     = 32 // 表情的最大字节数
+
+	// lastMessageAtPrecision 频道最后消息时间的精度。
+	// 最后消息时间只用于判断频道是否活跃，不需要每条消息都更新，减少热点频道的写入。
+	lastMessageAtPrecision = time.Minute
+
+	recordMentionTimeout = 10 * time.Second
+)
+
+// channelMessageSeqKey 频道消息序列号的 key。
+func channelMessageSeqKey(cid uint64) string {
+	return "channel_message:" + strconv.FormatUint(cid, 10)
+}
+
+// MessageServiceConfig 消息服务配置。
+type MessageServiceConfig struct {
+	RecallWindow time.Duration `mapstructure:"recall_window"` // 消息发送后允许撤回的时间窗口
+	EditWindow   time.Duration `mapstructure:"edit_window"`   // 消息发送后允许编辑的时间窗口
+}
+
+type MessageService interface {
+	// Send 发送消息。
+	// 消息内容会按照 serializeType 解码并校验，未知的内容类型或格式错误的内容会被拒绝。
+	// 校验通过后统一使用 json 编码存储。
+	// 只有群聊可以 @，被 @ 的用户必须是频道成员，@所有人 仅群主 / 管理员可用。
+	// message.ReplyTo 不为 nil 时表示回复 / 引用消息 ( 只需要设置 ReplyTo.ID，快照由服务端生成 )。
+	// message.ThreadID 不为 0 时表示在话题中回复 ( 仅群聊 )，话题回复不会出现在频道历史消息中。
+	// message.MID 不为 0 时用于客户端重试去重，发送者在频道中已经发送过 MID 相同的消息时直接返回该消息。
+	Send(ctx context.Context, message domain.Message, serializeType commonv1.SerializeType) (domain.Message, error)
+
+	// ListHistory 查询频道历史消息 ( 按消息 ID 倒序 )。
+	// 频道解散 ( 归档 ) 后历史消息仍然可以查询。
+	// 当前用户删除的消息不会返回，撤回的消息只返回撤回状态不返回内容。
+	ListHistory(ctx context.Context, uid, cid, beforeID uint64, limit int) ([]domain.Message, error)
+	// ListThread 按消息 ID 升序查询话题中的回复 ( afterID 为 0 时从第一条回复开始 )。
+	ListThread(ctx context.Context, uid, cid, threadID, afterID uint64, limit int) ([]domain.Message, error)
+
+	// Recall 撤回消息 ( 仅发送者可以在撤回窗口内撤回，频道不可写或已退出频道时不能撤回 )。
+	Recall(ctx context.Context, uid, cid, id uint64) error
+	// Edit 编辑文本消息 ( 仅发送者可以在编辑窗口内编辑，频道不可写或已退出频道时不能编辑 )。
+	// ver 为客户端看到的消息版本号，版本号不一致时编辑失败。
+	Edit(ctx context.Context, uid, cid, id uint64, ver int, content []byte) (domain.Message, error)
+	// ListEdits 查询消息的编辑历史，撤回的消息不返回编辑历史。
+	ListEdits(ctx context.Context, uid, cid, id uint64) ([]domain.MessageEdit, error)
+	// DeleteForMe 删除消息 ( 仅对自己不可见 )。
+	DeleteForMe(ctx context.Context, uid, cid, id uint64) error
+
+	// AddReaction 添加表情回应 ( 重复回应是幂等的 )。
+	AddReaction(ctx context.Context, uid, cid, id uint64, emoji string) error
+	// RemoveReaction 取消表情回应 ( 未回应过时直接返回 )。
+	RemoveReaction(ctx context.Context, uid, cid, id uint64, emoji string) error
+}
+
+var _ MessageService = (*DefaultMessageService)(nil)
+
+type DefaultMessageService struct {
+	cfg MessageServiceConfig
+
+	channelRepo      repo.ChannelRepo
+	messageRepo      repo.MessageRepo
+	conversationRepo repo.ConversationRepo
+	syncLogAppender  *SyncLogAppender
+
+	idGen    idgen.Generator
+	seqGen   idgen.SeqGenerator
+	producer produce.AsyncProducer // 消息发送是热点路径，事件使用异步发送
+	logger   *zap.Logger
+}
+
+func NewDefaultMessageService(
+	cfg MessageServiceConfig,
+	channelRepo repo.ChannelRepo,
+	messageRepo repo.MessageRepo,
+	conversationRepo repo.ConversationRepo,
+	syncLogAppender *SyncLogAppender,
+	idGen idgen.Generator,
+	seqGen idgen.SeqGenerator,
+	producer produce.AsyncProducer,
+	logger *zap.Logger,
+) *DefaultMessageService {
+	return &DefaultMessageService{
+		cfg:              cfg,
+		channelRepo:      channelRepo,
+		messageRepo:      messageRepo,
+		conversationRepo: conversationRepo,
+		syncLogAppender:  syncLogAppender,
+		idGen:            idGen,
+		seqGen:           seqGen,
+		producer:         producer,
+		logger:           logger,
+	}
+}
+
+func (s *DefaultMessageService) Send(
+	ctx context.Context,
+	message domain.Message,
+	serializeType commonv1.SerializeType,
+) (domain.Message, error) {
+	// 系统消息只能由服务端发送。
+	if message.ContentType == domain.ContentTypeSystem {
+		return domain.Message{}, fmt.Errorf("%w: system message can not be sent by user", errs.ErrPermissionDenied)
+	}
+
+	content, err := domain.DecodeContent(message.ContentType, serializeType, message.Content)
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	channel, sender, err := s.findWritableMember(ctx, message.SID, message.CID)
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	// 客户端重试时直接返回已保存的消息。
+	if message.MID != 0 {
+		saved, err := s.messageRepo.FindByMID(ctx, message.CID, message.SID, message.MID)
+		if err == nil {
+			return saved, nil
+		}
+		if !errors.Is(err, errs.ErrRecordNotFound) {
+			return domain.Message{}, err
+		}
+	}
+
+	if err := s.checkMentions(ctx, channel, sender, &message); err != nil {
+		return domain.Message{}, err
+	}
+
+	if err := s.attachReply(ctx, channel, &message); err != nil {
+		return domain.Message{}, err
+	}
+
+	// 统一使用 json 编码存储，读取时不需要关心客户端的序列化方式。
+	message.Content, err = domain.EncodeContent(content, commonv1.SerializeType_SERIALIZE_TYPE_JSON)
+	if err != nil {
+		return domain.Message{}, fmt.Errorf("failed to encode message content: %w", err)
+	}
+
+	// 使用 cid 作为分片值，同一频道的消息 ID 携带相同的分片信息。
+	message.ID, err = s.idGen.NextID(message.CID)
+	if err != nil {
+		return domain.Message{}, fmt.Errorf("failed to generate message id: %w", err)
+	}
+	// 话题中的回复也使用频道的序列号，客户端按序列号检测缺失的消息时不需要区分话题。
+	// 频道消息序列号使用持久化的号段存储 ( 见 sharding_idgen.segment.channel_message )，不需要从消息中恢复。
+	message.Seq, err = s.seqGen.NextSeq(ctx, channelMessageSeqKey(message.CID), nil)
+	if err != nil {
+		return domain.Message{}, fmt.Errorf("failed to generate message seq: %w", err)
+	}
+	message.SendAt = time.Now().UnixMilli()
+
+	saved, err := s.messageRepo.Save(ctx, message)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	if saved.ID != message.ID {
+		// 并发重试，消息已经由另一个请求发送。
+		return saved, nil
+	}
+
+	s.touchChannel(ctx, channel, message)
+	s.syncLogAppender.Append(ctx, message)
+	s.publishMessageSent(message)
+
+	if message.MentionAll || len(message.Mentions) > 0 {
+		s.recordMentions(message)
+	}
+
+	if message.IsInThread() {
+		s.addThreadReply(ctx, message)
+	}
+	return message, nil
+}
+
+// touchChannel 更新频道最后一条消息的时间，消息归档任务依据该时间判断频道是否活跃。
+// 频道消息归档后的第一条消息总是会更新，保证频道会被再次归档。
+// 消息已经保存成功，这里失败只记录日志。
+func (s *DefaultMessageService) touchChannel(ctx context.Context, channel domain.Channel, message domain.Message) {
+	if channel.LastMessageAt > channel.MessageArchivedAt &&
+		message.SendAt-channel.LastMessageAt < lastMessageAtPrecision.Milliseconds() {
+		return
+	}
+
+	if err := s.channelRepo.UpdateLastMessageAt(ctx, channel.ID, message.SendAt); err != nil {
+		s.logger.Error(
+			"[hermet-message-service] failed to update channel last message time",
+			zap.Uint64("cid", channel.ID),
+			zap.Uint64("message_id", message.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+// attachReply 校验话题和父消息，并生成父消息快照。
+func (s *DefaultMessageService) attachReply(ctx context.Context, channel domain.Channel, message *domain.Message) error {
+	if message.IsInThread() {
+		if channel.ChannelType != domain.ChannelTypeGroup {
+			return fmt.Errorf("%w: thread is only supported in group channel", errs.ErrInvalidParam)
+		}
+
+		root, err := s.findMessage(ctx, message.CID, message.ThreadID)
+		if err != nil {
+			return err
+		}
+		if root.IsInThread() {
+			return fmt.Errorf("%w: thread root can not be a thread reply", errs.ErrInvalidParam)
+		}
+		if root.IsRecalled() {
+			return fmt.Errorf("%w: thread root already recalled", errs.ErrInvalidParam)
+		}
+	}
+
+	if message.ReplyTo == nil {
+		return nil
+	}
+
+	parent, err := s.findMessage(ctx, message.CID, message.ReplyTo.ID)
+	if err != nil {
+		return err
+	}
+	if parent.IsRecalled() {
+		return fmt.Errorf("%w: parent message already recalled", errs.ErrInvalidParam)
+	}
+
+	// 话题中只能回复同一话题中的消息，频道中只能引用频道中的消息。
+	if message.IsInThread() {
+		if parent.ID != message.ThreadID && parent.ThreadID != message.ThreadID {
+			return fmt.Errorf("%w: parent message is not in the thread", errs.ErrInvalidParam)
+		}
+	} else if parent.IsInThread() {
+		return fmt.Errorf("%w: parent message is in a thread", errs.ErrInvalidParam)
+	}
+
+	message.ReplyTo = &domain.MessageRef{
+		ID:          parent.ID,
+		SID:         parent.SID,
+		Content:     parent.Content,
+		ContentType: parent.ContentType,
+		SendAt:      parent.SendAt,
+	}
+	return nil
+}
+
+// addThreadReply 更新话题根消息的回复统计，并异步通知话题参与者。
+// 消息已经保存成功，这里失败只记录日志。
+func (s *DefaultMessageService) addThreadReply(ctx context.Context, message domain.Message) {
+	participants, err := s.messageRepo.AddThreadReply(ctx, message.CID, message.ThreadID, message.SID, message.SendAt)
+	if err != nil {
+		s.logger.Error(
+			"[hermet-message-service] failed to update thread reply count",
+			zap.Uint64("cid", message.CID),
+			zap.Uint64("thread_id", message.ThreadID),
+			zap.Uint64("message_id", message.ID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	participants = slices.DeleteFunc(participants, func(uid uint64) bool {
+		return uid == message.SID
+	})
+	if len(participants) == 0 {
+		return
+	}
+
+	s.publishMessageEvent(message.CID, domain.MessageEventTypeThreadReplied, domain.MessageThreadRepliedEvent{
+		CID:            message.CID,
+		ThreadID:       message.ThreadID,
+		ID:             message.ID,
+		SID:            message.SID,
+		ParticipantIDs: participants,
+		RepliedAt:      message.SendAt,
+	})
+}
+
+// checkMentions 校验并规范化消息的 @ 列表 ( 去重并忽略 @ 自己 )。
+func (s *DefaultMessageService) checkMentions(
+	ctx context.Context,
+	channel domain.Channel,
+	sender domain.ChannelMember,
+	message *domain.Message,
+) error {
+	mentions := make([]uint64, 0, len(message.Mentions))
+	for _, uid := range message.Mentions {
+		if uid != message.SID && !slices.Contains(mentions, uid) {
+			mentions = append(mentions, uid)
+		}
+	}
+	message.Mentions = mentions
+
+	if !message.MentionAll && len(mentions) == 0 {
+		return nil
+	}
+
+	if channel.ChannelType != domain.ChannelTypeGroup {
+		return fmt.Errorf("%w: only group message can mention users", errs.ErrInvalidParam)
+	}
+	if len(mentions) > maxMentions {
+		return fmt.Errorf("%w: mention at most %d users", errs.ErrInvalidParam, maxMentions)
+	}
+
+	if message.MentionAll &&
+		sender.UserRole != domain.ChannelMemberRoleOwner &&
+		sender.UserRole != domain.ChannelMemberRoleAdmin {
+		return fmt.Errorf("%w: only owner or admin can mention all", errs.ErrPermissionDenied)
+	}
+
+	if len(mentions) > 0 {
+		members, err := s.channelRepo.FindActiveMembers(ctx, message.CID, mentions)
+		if err != nil {
+			return err
+		}
+		if len(members) != len(mentions) {
+			return fmt.Errorf("%w: mentioned user is not a member of channel", errs.ErrInvalidParam)
+		}
+	}
+	return nil
+}
+
+// recordMentions 异步更新被 @ 用户的会话视图 ( 会话列表展示 "[@me]" )。
+// 失败只记录日志，不影响消息发送。
+func (s *DefaultMessageService) recordMentions(message domain.Message) {
+	s.updateMentions(message, "record", s.conversationRepo.RecordMention)
+}
+
+// revokeMentions 异步撤回被 @ 用户会话视图中的 @ 标记和 @ 消息数。
+// 失败只记录日志，不影响消息撤回。
+func (s *DefaultMessageService) revokeMentions(message domain.Message) {
+	s.updateMentions(message, "revoke", s.conversationRepo.RevokeMention)
+}
+
+func (s *DefaultMessageService) updateMentions(
+	message domain.Message,
+	action string,
+	update func(ctx context.Context, uid, cid, messageID uint64) error,
+) {
+	go func() {
+		// 这里必须使用 context.Background()，ctx 会在请求结束后取消。
+		ctx, cancel := context.WithTimeout(context.Background(), recordMentionTimeout)
+		defer cancel()
+
+		uids := message.Mentions
+		if message.MentionAll {
+			members, err := s.channelRepo.ListActiveMembers(ctx, message.CID)
+			if err != nil {
+				s.logger.Error(
+					"[hermet-message-service] failed to list channel members for mention",
+					zap.String("action", action),
+					zap.Uint64("cid", message.CID),
+					zap.Uint64("message_id", message.ID),
+					zap.Error(err),
+				)
+				return
+			}
+
+			uids = make([]uint64, 0, len(members))
+			for i := range members {
+				if members[i].UID != message.SID {
+					uids = append(uids, members[i].UID)
+				}
+			}
+		}
+
+		for _, uid := range uids {
+			if err := update(ctx, uid, message.CID, message.ID); err != nil {
+				s.logger.Error(
+					"[hermet-message-service] failed to "+action+" mention",
+					zap.Uint64("cid", message.CID),
+					zap.Uint64("uid", uid),
+					zap.Uint64("message_id", message.ID),
+					zap.Error(err),
+				)
+			}
+		}
+	}()
+}
+
+func (s *DefaultMessageService) ListHistory(
+	ctx context.Context,
+	uid, cid, beforeID uint64,
+	limit int,
+) ([]domain.Message, error) {
+	if limit <= 0 || limit > maxHistoryLimit {
+		return nil, fmt.Errorf("%w: limit must be in (0, %d]", errs.ErrInvalidParam, maxHistoryLimit)
+	}
+
+	if err := s.checkMember(ctx, uid, cid); err != nil {
+		return nil, err
+	}
+
+	return s.listVisible(ctx, uid, cid, beforeID, limit, func(cursor uint64, n int) ([]domain.Message, error) {
+		return s.messageRepo.ListHistory(ctx, cid, cursor, n)
+	})
+}
+
+func (s *DefaultMessageService) ListThread(
+	ctx context.Context,
+	uid, cid, threadID, afterID uint64,
+	limit int,
+) ([]domain.Message, error) {
+	if limit <= 0 || limit > maxHistoryLimit {
+		return nil, fmt.Errorf("%w: limit must be in (0, %d]", errs.ErrInvalidParam, maxHistoryLimit)
+	}
+
+	if err := s.checkMember(ctx, uid, cid); err != nil {
+		return nil, err
+	}
+
+	return s.listVisible(ctx, uid, cid, afterID, limit, func(cursor uint64, n int) ([]domain.Message, error) {
+		return s.messageRepo.ListThread(ctx, cid, threadID, cursor, n)
+	})
+}
+
+// listVisible 从 cursor 开始分页查询消息并过滤掉 uid 删除的消息。
+// 过滤后不足 limit 条时从本次查询的最后一条消息继续查询，直到凑满 limit 条或没有更多消息，
+// 避免用户删除的消息较多时返回的分页过短 ( 甚至为空 ) 导致客户端误以为没有更多消息。
+func (s *DefaultMessageService) listVisible(
+	ctx context.Context,
+	uid, cid, cursor uint64,
+	limit int,
+	list func(cursor uint64, n int) ([]domain.Message, error),
+) ([]domain.Message, error) {
+	page := make([]domain.Message, 0, limit)
+	for {
+		n := limit - len(page)
+		messages, err := list(cursor, n)
+		if err != nil {
+			return nil, err
+		}
+		if len(messages) == 0 {
+			return page, nil
+		}
+		cursor = messages[len(messages)-1].ID
+
+		visible, err := s.visibleMessages(ctx, uid, cid, messages)
+		if err != nil {
+			return nil, err
+		}
+		page = append(page, visible...)
+
+		if len(messages) < n || len(page) == limit {
+			return page, nil
+		}
+	}
+}
+
+// visibleMessages 过滤掉 uid 删除的消息，并清空撤回消息的内容。
+func (s *DefaultMessageService) visibleMessages(
+	ctx context.Context,
+	uid, cid uint64,
+	messages []domain.Message,
+) ([]domain.Message, error) {
+	ids := make([]uint64, 0, len(messages))
+	for i := range messages {
+		ids = append(ids, messages[i].ID)
+	}
+
+	deletedIDs, err := s.messageRepo.FindDeletedIDs(ctx, uid, cid, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(deletedIDs) > 0 {
+		messages = slices.DeleteFunc(messages, func(m domain.Message) bool {
+			return slices.Contains(deletedIDs, m.ID)
+		})
+	}
+
+	for i := range messages {
+		// 撤回的消息不返回内容。
+		if messages[i].IsRecalled() {
+			messages[i].Content = nil
+		}
+	}
+	return messages, nil
+}
+
+func (s *DefaultMessageService) Recall(ctx context.Context, uid, cid, id uint64) error {
+	message, err := s.findOwnMessage(ctx, uid, cid, id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if now.Sub(time.UnixMilli(message.SendAt)) > s.cfg.RecallWindow {
+		return fmt.Errorf("%w: recall window exceeded", errs.ErrInvalidParam)
+	}
+
+	ok, err := s.messageRepo.Recall(ctx, cid, id, now.UnixMilli())
+	if err != nil {
+		if !ok {
+			return err
+		}
+		// 消息已经撤回，编辑历史删除或引用快照清空失败只记录日志 ( ListEdits 不会返回撤回消息的编辑历史 )。
+		s.logger.Error(
+			"[hermet-message-service] failed to clean up recalled message",
+			zap.Uint64("cid", cid),
+			zap.Uint64("message_id", id),
+			zap.Error(err),
+		)
+	}
+	if !ok {
+		return fmt.Errorf("%w: message already recalled", errs.ErrInvalidParam)
+	}
+
+	if message.MentionAll || len(message.Mentions) > 0 {
+		s.revokeMentions(message)
+	}
+
+	s.publishMessageEvent(cid, domain.MessageEventTypeRecalled, domain.MessageRecalledEvent{
+		CID:        cid,
+		ID:         id,
+		Operator:   uid,
+		RecalledAt: now.UnixMilli(),
+	})
+	return nil
+}
+
+func (s *DefaultMessageService) Edit(
+	ctx context.Context,
+	uid, cid, id uint64,
+	ver int,
+	content []byte,
+) (domain.Message, error) {
+	message, err := s.findOwnMessage(ctx, uid, cid, id)
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	if message.ContentType != domain.ContentTypeText {
+		return domain.Message{}, fmt.Errorf("%w: only text message can be edited", errs.ErrInvalidParam)
+	}
+	if message.IsRecalled() {
+		return domain.Message{}, fmt.Errorf("%w: message already recalled", errs.ErrInvalidParam)
+	}
+
+	now := time.Now()
+	if now.Sub(time.UnixMilli(message.SendAt)) > s.cfg.EditWindow {
+		return domain.Message{}, fmt.Errorf("%w: edit window exceeded", errs.ErrInvalidParam)
+	}
+
+	text, err := domain.DecodeContent(domain.ContentTypeText, commonv1.SerializeType_SERIALIZE_TYPE_JSON, content)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	if content, err = domain.EncodeContent(text, commonv1.SerializeType_SERIALIZE_TYPE_JSON); err != nil {
+		return domain.Message{}, fmt.Errorf("failed to encode message content: %w", err)
+	}
+
+	ok, err := s.messageRepo.Edit(ctx, cid, id, ver, content, now.UnixMilli())
+	if err != nil {
+		return domain.Message{}, err
+	}
+	if !ok {
+		return domain.Message{}, fmt.Errorf("%w: message version conflict", errs.ErrInvalidParam)
+	}
+
+	message.Content = content
+	message.EditVer = ver + 1
+	message.EditedAt = now.UnixMilli()
+
+	s.publishMessageEvent(cid, domain.MessageEventTypeEdited, domain.MessageEditedEvent{
+		CID:      cid,
+		ID:       id,
+		Operator: uid,
+		Content:  content,
+		EditVer:  message.EditVer,
+		EditedAt: message.EditedAt,
+	})
+	return message, nil
+}
+
+func (s *DefaultMessageService) ListEdits(ctx context.Context, uid, cid, id uint64) ([]domain.MessageEdit, error) {
+	if err := s.checkMember(ctx, uid, cid); err != nil {
+		return nil, err
+	}
+
+	message, err := s.findMessage(ctx, cid, id)
+	if err != nil {
+		return nil, err
+	}
+	// 编辑历史中保存的是撤回前的内容，撤回后即使编辑历史没有删除成功也不能返回。
+	if message.IsRecalled() {
+		return []domain.MessageEdit{}, nil
+	}
+	return s.messageRepo.ListEdits(ctx, cid, id)
+}
+
+func (s *DefaultMessageService) DeleteForMe(ctx context.Context, uid, cid, id uint64) error {
+	if err := s.checkMember(ctx, uid, cid); err != nil {
+		return err
+	}
+	// 消息必须属于该频道，避免为不存在或者其他频道的消息写入删除记录。
+	if _, err := s.findMessage(ctx, cid, id); err != nil {
+		return err
+	}
+	return s.messageRepo.DeleteForUser(ctx, uid, cid, id, time.Now().UnixMilli())
+}
+
+func (s *DefaultMessageService) AddReaction(ctx context.Context, uid, cid, id uint64, emoji string) error {
+	if err := s.checkReaction(ctx, uid, cid, id, emoji); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	cnt, changed, err := s.messageRepo.AddReaction(ctx, cid, id, uid, emoji, now)
+	if err != nil {
+		return err
+	}
+	if changed {
+		s.publishMessageEvent(cid, domain.MessageEventTypeReactionChanged, domain.MessageReactionChangedEvent{
+			CID:       cid,
+			ID:        id,
+			UID:       uid,
+			Emoji:     emoji,
+			Added:     true,
+			Count:     cnt,
+			ChangedAt: now,
+		})
+	}
+	return nil
+}
+
+func (s *DefaultMessageService) RemoveReaction(ctx context.Context, uid, cid, id uint64, emoji string) error {
+	if err := s.checkReaction(ctx, uid, cid, id, emoji); err != nil {
+		return err
+	}
+
+	cnt, changed, err := s.messageRepo.RemoveReaction(ctx, cid, id, uid, emoji)
+	if err != nil {
+		return err
+	}
+	if changed {
+		s.publishMessageEvent(cid, domain.MessageEventTypeReactionChanged, domain.MessageReactionChangedEvent{
+			CID:       cid,
+			ID:        id,
+			UID:       uid,
+			Emoji:     emoji,
+			Added:     false,
+			Count:     cnt,
+			ChangedAt: time.Now().UnixMilli(),
+		})
+	}
+	return nil
+}
+
+// checkReaction 校验表情以及用户是否可以回应消息 ( 频道可写、用户为成员、消息未撤回 )。
+func (s *DefaultMessageService) checkReaction(ctx context.Context, uid, cid, id uint64, emoji string) error {
+	// 表情会作为 MongoDB 文档的 key，不能包含 "." 或以 "$" 开头。
+	if emoji == "" || len(emoji) > maxEmojiLen || !utf8.ValidString(emoji) ||
+		strings.Contains(emoji, ".") || strings.HasPrefix(emoji, "$") {
+		return fmt.Errorf("%w: invalid emoji", errs.ErrInvalidParam)
+	}
+
+	if _, _, err := s.findWritableMember(ctx, uid, cid); err != nil {
+		return err
+	}
+
+	message, err := s.findMessage(ctx, cid, id)
+	if err != nil {
+		return err
+	}
+	if message.IsRecalled() {
+		return fmt.Errorf("%w: message already recalled", errs.ErrInvalidParam)
+	}
+	return nil
+}
+
+// findWritableMember 查询频道以及用户在频道中的成员信息，频道不可写或用户不是频道成员时返回错误。
+func (s *DefaultMessageService) findWritableMember(
+	ctx context.Context,
+	uid, cid uint64,
+) (domain.Channel, domain.ChannelMember, error) {
+	channel, err := s.channelRepo.FindByID(ctx, cid)
+	if err != nil {
+		if errors.Is(err, errs.ErrRecordNotFound) {
+			return domain.Channel{}, domain.ChannelMember{}, fmt.Errorf("%w: channel not found", errs.ErrInvalidParam)
+		}
+		return domain.Channel{}, domain.ChannelMember{}, err
+	}
+	if !channel.ChannelStatus.IsWritable() {
+		return domain.Channel{}, domain.ChannelMember{}, fmt.Errorf("%w: channel is not writable", errs.ErrInvalidParam)
+	}
+
+	member, err := s.findMember(ctx, uid, cid)
+	if err != nil {
+		return domain.Channel{}, domain.ChannelMember{}, err
+	}
+	return channel, member, nil
+}
+
+// checkMember 校验用户是否为频道成员。
+func (s *DefaultMessageService) checkMember(ctx context.Context, uid, cid uint64) error {
+	_, err := s.findMember(ctx, uid, cid)
+	return err
+}
+
+// findMember 查询用户在频道中的成员信息，用户不是频道成员时返回 ErrPermissionDenied。
+func (s *DefaultMessageService) findMember(ctx context.Context, uid, cid uint64) (domain.ChannelMember, error) {
+	member, err := s.channelRepo.FindMember(ctx, cid, uid)
+	if err != nil {
+		if errors.Is(err, errs.ErrRecordNotFound) {
+			return domain.ChannelMember{}, fmt.Errorf("%w: not a member of channel", errs.ErrPermissionDenied)
+		}
+		return domain.ChannelMember{}, err
+	}
+	return member, nil
+}
+
+// findMessage 查询消息，消息不存在时返回 ErrInvalidParam。
+func (s *DefaultMessageService) findMessage(ctx context.Context, cid, id uint64) (domain.Message, error) {
+	message, err := s.messageRepo.FindByID(ctx, cid, id)
+	if err != nil {
+		if errors.Is(err, errs.ErrRecordNotFound) {
+			return domain.Message{}, fmt.Errorf("%w: message not found", errs.ErrInvalidParam)
+		}
+		return domain.Message{}, err
+	}
+	return message, nil
+}
+
+// findOwnMessage 查询用户自己发送的消息，与发送消息一样要求频道可写并且用户仍是频道成员。
+func (s *DefaultMessageService) findOwnMessage(ctx context.Context, uid, cid, id uint64) (domain.Message, error) {
+	if _, _, err := s.findWritableMember(ctx, uid, cid); err != nil {
+		return domain.Message{}, err
+	}
+
+	message, err := s.findMessage(ctx, cid, id)
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	if message.SID != uid {
+		return domain.Message{}, fmt.Errorf("%w: not the sender of message", errs.ErrPermissionDenied)
+	}
+	return message, nil
+}
+
+// publishMessageSent 异步发送消息发送事件，由离线推送等下游消费。
+func (s *DefaultMessageService) publishMessageSent(message domain.Message) {
+	event := domain.MessageSentEvent{
+		CID:         message.CID,
+		ID:          message.ID,
+		Seq:         message.Seq,
+		SID:         message.SID,
+		Content:     message.Content,
+		ContentType: message.ContentType,
+		Mentions:    message.Mentions,
+		MentionAll:  message.MentionAll,
+		ThreadID:    message.ThreadID,
+		SendAt:      message.SendAt,
+	}
+	err := publishEventAsync(s.producer, messageSentTopic, message.CID, string(domain.MessageEventTypeSent), event, func(err error) {
+		s.logger.Error(
+			"[hermet-message-service] failed to publish message sent event",
+			zap.Uint64("cid", message.CID),
+			zap.Uint64("message_id", message.ID),
+			zap.Error(err),
+		)
+	})
+	if err != nil {
+		s.logger.Error(
+			"[hermet-message-service] failed to publish message sent event",
+			zap.Uint64("cid", message.CID),
+			zap.Uint64("message_id", message.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+// publishMessageEvent 异步发送消息事件，由网关推送给在线成员。
+func (s *DefaultMessageService) publishMessageEvent(cid uint64, eventType domain.MessageEventType, event any) {
+	onError := func(err error) {
+		s.logger.Error(
+			"[hermet-message-service] failed to publish message event",
+			zap.Uint64("cid", cid),
+			zap.String("event_type", string(eventType)),
+			zap.Error(err),
+		)
+	}
+	if err := publishEventAsync(s.producer, messageEventTopic, cid, string(eventType), event, onError); err != nil {
+		onError(err)
+	}
+}
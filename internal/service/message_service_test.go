@@ -0,0 +1,316 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	commonv1 "github.com/jrmarcco/synp-api/api/go/common/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	testCID    = uint64(100)
+	testSender = uint64(1)
+	testPeer   = uint64(2)
+)
+
+type messageServiceFixture struct {
+	svc         *DefaultMessageService
+	channelRepo *fakeChannelRepo
+	messageRepo *fakeMessageRepo
+	convRepo    *fakeConversationRepo
+	syncRepo    *fakeSyncRepo
+	producer    *fakeProducer
+}
+
+func newMessageServiceFixture() *messageServiceFixture {
+	f := &messageServiceFixture{
+		channelRepo: newFakeChannelRepo(),
+		messageRepo: newFakeMessageRepo(),
+		convRepo:    newFakeConversationRepo(),
+		syncRepo:    newFakeSyncRepo(),
+		producer:    &fakeProducer{},
+	}
+	f.channelRepo.addChannel(
+		domain.Channel{ID: testCID, ChannelType: domain.ChannelTypeGroup, ChannelStatus: domain.ChannelStatusActive},
+		domain.ChannelMember{UID: testSender, UserRole: domain.ChannelMemberRoleOwner},
+		domain.ChannelMember{UID: testPeer, UserRole: domain.ChannelMemberRoleMember},
+	)
+
+	appender := NewSyncLogAppender(SyncLogAppenderConfig{Lanes: 2}, f.channelRepo, f.syncRepo, zap.NewNop())
+	f.svc = NewDefaultMessageService(
+		MessageServiceConfig{RecallWindow: time.Minute, EditWindow: time.Minute},
+		f.channelRepo,
+		f.messageRepo,
+		f.convRepo,
+		appender,
+		&fakeIDGen{},
+		&fakeSeqGen{},
+		f.producer,
+		zap.NewNop(),
+	)
+	return f
+}
+
+// saveText 直接保存一条文本消息 ( 不经过 Send )。
+func (f *messageServiceFixture) saveText(t *testing.T, id, sid uint64, text string) {
+	t.Helper()
+
+	_, err := f.messageRepo.Save(t.Context(), domain.Message{
+		ID:          id,
+		CID:         testCID,
+		SID:         sid,
+		Content:     textContent(text),
+		ContentType: domain.ContentTypeText,
+		SendAt:      time.Now().UnixMilli(),
+	})
+	require.NoError(t, err)
+}
+
+func textContent(text string) []byte {
+	return []byte(`{"text":"` + text + `"}`)
+}
+
+func TestMessageService_ListEditsAfterRecall(t *testing.T) {
+	t.Parallel()
+
+	f := newMessageServiceFixture()
+	f.saveText(t, 1, testSender, "v0")
+
+	_, err := f.svc.Edit(t.Context(), testSender, testCID, 1, 0, textContent("v1"))
+	require.NoError(t, err)
+
+	edits, err := f.svc.ListEdits(t.Context(), testPeer, testCID, 1)
+	require.NoError(t, err)
+	require.Len(t, edits, 1)
+
+	require.NoError(t, f.svc.Recall(t.Context(), testSender, testCID, 1))
+
+	// 撤回后不能再通过编辑历史看到撤回前的内容。
+	edits, err = f.svc.ListEdits(t.Context(), testPeer, testCID, 1)
+	require.NoError(t, err)
+	require.Empty(t, edits)
+}
+
+func TestMessageService_RecallAndEditRequireWritableMember(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		name    string
+		setup   func(f *messageServiceFixture)
+		wantErr error
+	}{
+		{
+			name:  "writable member",
+			setup: func(*messageServiceFixture) {},
+		}, {
+			name: "removed member",
+			setup: func(f *messageServiceFixture) {
+				f.channelRepo.removeMember(testCID, testSender)
+			},
+			wantErr: errs.ErrPermissionDenied,
+		}, {
+			name: "dissolved channel",
+			setup: func(f *messageServiceFixture) {
+				f.channelRepo.setStatus(testCID, domain.ChannelStatusArchived)
+			},
+			wantErr: errs.ErrInvalidParam,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := newMessageServiceFixture()
+			f.saveText(t, 1, testSender, "edit")
+			f.saveText(t, 2, testSender, "recall")
+			tc.setup(f)
+
+			_, err := f.svc.Edit(t.Context(), testSender, testCID, 1, 0, textContent("edited"))
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			err = f.svc.Recall(t.Context(), testSender, testCID, 2)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMessageService_ListHistorySkipsDeleted(t *testing.T) {
+	t.Parallel()
+
+	f := newMessageServiceFixture()
+	for id := uint64(1); id <= 10; id++ {
+		f.saveText(t, id, testSender, "hello")
+	}
+	// 最新的 4 条消息被 testPeer 删除。
+	for id := uint64(7); id <= 10; id++ {
+		require.NoError(t, f.svc.DeleteForMe(t.Context(), testPeer, testCID, id))
+	}
+	// 不存在的消息不能删除。
+	require.ErrorIs(t, f.svc.DeleteForMe(t.Context(), testPeer, testCID, 11), errs.ErrInvalidParam)
+
+	messages, err := f.svc.ListHistory(t.Context(), testPeer, testCID, 0, 3)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{6, 5, 4}, messageIDs(messages))
+
+	messages, err = f.svc.ListHistory(t.Context(), testPeer, testCID, 4, 5)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3, 2, 1}, messageIDs(messages))
+
+	// 其他成员不受影响。
+	messages, err = f.svc.ListHistory(t.Context(), testSender, testCID, 0, 3)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{10, 9, 8}, messageIDs(messages))
+}
+
+func messageIDs(messages []domain.Message) []uint64 {
+	ids := make([]uint64, 0, len(messages))
+	for i := range messages {
+		ids = append(ids, messages[i].ID)
+	}
+	return ids
+}
+
+func TestMessageArchiver_ArchivesChannelAfterSend(t *testing.T) {
+	t.Parallel()
+
+	f := newMessageServiceFixture()
+	sent, err := f.svc.Send(t.Context(), domain.Message{
+		CID:         testCID,
+		SID:         testSender,
+		Content:     textContent("hello"),
+		ContentType: domain.ContentTypeText,
+	}, commonv1.SerializeType_SERIALIZE_TYPE_JSON)
+	require.NoError(t, err)
+
+	channel, err := f.channelRepo.FindByID(t.Context(), testCID)
+	require.NoError(t, err)
+	require.Equal(t, sent.SendAt, channel.LastMessageAt)
+
+	// InactiveAfter 为负数时刚发送过消息的频道也视为不活跃。
+	archiver := NewMessageArchiver(MessageArchiverConfig{
+		InactiveAfter: -time.Minute,
+		ChannelLimit:  10,
+		BatchSize:     10,
+	}, f.channelRepo, f.messageRepo, zap.NewNop())
+	archiver.archive(t.Context())
+
+	channel, err = f.channelRepo.FindByID(t.Context(), testCID)
+	require.NoError(t, err)
+	require.NotZero(t, channel.MessageArchivedAt)
+	require.Contains(t, f.messageRepo.archived, sent.ID)
+
+	// 归档后的第一条消息会再次更新最后消息时间，频道会被再次归档。
+	time.Sleep(2 * time.Millisecond)
+	sent, err = f.svc.Send(t.Context(), domain.Message{
+		CID:         testCID,
+		SID:         testSender,
+		Content:     textContent("again"),
+		ContentType: domain.ContentTypeText,
+	}, commonv1.SerializeType_SERIALIZE_TYPE_JSON)
+	require.NoError(t, err)
+
+	channels, err := f.channelRepo.ListInactive(t.Context(), time.Now().Add(time.Minute).UnixMilli(), 10)
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+	require.Equal(t, sent.SendAt, channels[0].LastMessageAt)
+}
+
+func TestMessageService_SendDedupesByMID(t *testing.T) {
+	t.Parallel()
+
+	f := newMessageServiceFixture()
+	send := func(mid uint64, text string) domain.Message {
+		t.Helper()
+
+		sent, err := f.svc.Send(t.Context(), domain.Message{
+			CID:         testCID,
+			SID:         testSender,
+			MID:         mid,
+			Content:     textContent(text),
+			ContentType: domain.ContentTypeText,
+		}, commonv1.SerializeType_SERIALIZE_TYPE_JSON)
+		require.NoError(t, err)
+		return sent
+	}
+
+	first := send(7, "hello")
+	retry := send(7, "hello")
+	require.Equal(t, first.ID, retry.ID)
+	require.Equal(t, first.Seq, retry.Seq)
+	// 重试不会再次发送事件。
+	require.Len(t, f.producer.eventTypes(), 1)
+
+	// 不同的 mid 和没有 mid 的消息不去重，频道序列号继续递增。
+	other := send(8, "hello")
+	require.NotEqual(t, first.ID, other.ID)
+	require.Greater(t, other.Seq, first.Seq)
+	require.NotEqual(t, send(0, "hello").ID, send(0, "hello").ID)
+}
+
+func TestMessageService_RecallRevokesMentions(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		name       string
+		mentions   []uint64
+		mentionAll bool
+	}{
+		{name: "mention", mentions: []uint64{testPeer}},
+		{name: "mention all", mentionAll: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := newMessageServiceFixture()
+			send := func(text string) domain.Message {
+				t.Helper()
+
+				sent, err := f.svc.Send(t.Context(), domain.Message{
+					CID:         testCID,
+					SID:         testSender,
+					Content:     textContent(text),
+					ContentType: domain.ContentTypeText,
+					Mentions:    tc.mentions,
+					MentionAll:  tc.mentionAll,
+				}, commonv1.SerializeType_SERIALIZE_TYPE_JSON)
+				require.NoError(t, err)
+				return sent
+			}
+			requireMention := func(count int, lastID uint64) {
+				t.Helper()
+
+				require.Eventually(t, func() bool {
+					conversation, err := f.convRepo.FindByUIDAndCID(t.Context(), testPeer, testCID)
+					require.NoError(t, err)
+					return conversation.MentionCount == count && conversation.LastMentionMessageID == lastID
+				}, time.Second, time.Millisecond)
+			}
+
+			first := send("first")
+			requireMention(1, first.ID)
+			second := send("second")
+			requireMention(2, second.ID)
+
+			// 撤回一条后 @ 消息数减 1，全部撤回后 @ 标记清除。
+			require.NoError(t, f.svc.Recall(t.Context(), testSender, testCID, second.ID))
+			requireMention(1, second.ID)
+			require.NoError(t, f.svc.Recall(t.Context(), testSender, testCID, first.ID))
+			requireMention(0, 0)
+		})
+	}
+}
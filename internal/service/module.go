@@ -1,10 +1,19 @@
 package service
 
 import (
+	"context"
+	"os"
+
+	"github.com/jrmarcco/hermet/internal/pkg/sharding/idgen"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/worker"
+	"github.com/jrmarcco/hermet/internal/pkg/xpush"
 	"github.com/jrmarcco/hermet/internal/repo"
 	"github.com/jrmarcco/jit/xjwt"
 	authv1 "github.com/jrmarcco/synp-api/api/go/auth/v1"
+	"github.com/spf13/viper"
 	"go.uber.org/fx"
+	"go.uber.org/zap"
 )
 
 var ServiceFxModule = fx.Module(
@@ -21,7 +30,35 @@ var ServiceFxModule = fx.Module(
 			NewDefaultApplicationService,
 			fx.As(new(ApplicationService)),
 		),
+
+		fx.Annotate(
+			NewDefaultChannelService,
+			fx.As(new(ChannelService)),
+		),
+
+		fx.Annotate(
+			NewDefaultConversationService,
+			fx.As(new(ConversationService)),
+		),
+
+		newMessageService,
+		newSyncService,
+		newSyncLogAppender,
+		newPresenceService,
+
+		fx.Annotate(
+			NewDefaultPushService,
+			fx.As(new(PushService)),
+		),
+
+		NewConnectionHub,
+		newMessageRouter,
+
+		newMessageArchiver,
+		newPushDispatcher,
+		newFanoutDispatcher,
 	),
+	fx.Invoke(func(*MessageArchiver) {}),
 )
 
 type authServiceFxParams struct {
@@ -41,3 +78,279 @@ func newAuthService(p authServiceFxParams) AuthService {
 		p.RtManager,
 	)
 }
+
+type messageServiceFxParams struct {
+	fx.In
+
+	ChannelRepo      repo.ChannelRepo
+	MessageRepo      repo.MessageRepo
+	ConversationRepo repo.ConversationRepo
+	SyncLogAppender  *SyncLogAppender
+
+	IDGen  idgen.Generator
+	SeqGen idgen.SeqGenerator `name:"channel_message_seq_generator"`
+
+	Producer  produce.AsyncProducer
+	Logger    *zap.Logger
+	Lifecycle fx.Lifecycle
+}
+
+// newMessageService 加载消息服务配置并创建消息服务，启动时创建消息去重索引。
+func newMessageService(p messageServiceFxParams) (MessageService, error) {
+	cfg := MessageServiceConfig{}
+	if err := viper.UnmarshalKey("hermet.message", &cfg); err != nil {
+		return nil, err
+	}
+
+	p.Lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return p.MessageRepo.EnsureIndexes(ctx)
+		},
+	})
+	return NewDefaultMessageService(
+		cfg,
+		p.ChannelRepo,
+		p.MessageRepo,
+		p.ConversationRepo,
+		p.SyncLogAppender,
+		p.IDGen,
+		p.SeqGen,
+		p.Producer,
+		p.Logger,
+	), nil
+}
+
+// newSyncService 加载离线同步配置并创建同步服务，启动时创建同步日志的索引。
+func newSyncService(
+	syncRepo repo.SyncRepo,
+	messageRepo repo.MessageRepo,
+	lifecycle fx.Lifecycle,
+) (SyncService, error) {
+	cfg := SyncServiceConfig{}
+	if err := viper.UnmarshalKey("hermet.sync", &cfg); err != nil {
+		return nil, err
+	}
+
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return syncRepo.EnsureLogIndexes(ctx, cfg.LogTTL)
+		},
+	})
+	return NewDefaultSyncService(cfg, syncRepo, messageRepo), nil
+}
+
+// newSyncLogAppender 创建同步日志写入任务，并注册到 fx 生命周期中。
+func newSyncLogAppender(
+	channelRepo repo.ChannelRepo,
+	syncRepo repo.SyncRepo,
+	logger *zap.Logger,
+	lifecycle fx.Lifecycle,
+) (*SyncLogAppender, error) {
+	cfg := SyncLogAppenderConfig{}
+	if err := viper.UnmarshalKey("hermet.sync.appender", &cfg); err != nil {
+		return nil, err
+	}
+
+	appender := NewSyncLogAppender(cfg, channelRepo, syncRepo, logger)
+	lifecycle.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			appender.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return appender.Stop(ctx)
+		},
+	})
+	return appender, nil
+}
+
+// newPresenceService 加载在线状态配置并创建在线状态服务。
+func newPresenceService(
+	presenceRepo repo.PresenceRepo,
+	userContactRepo repo.UserContactRepo,
+	channelRepo repo.ChannelRepo,
+	conversationRepo repo.ConversationRepo,
+	producer produce.Producer,
+	logger *zap.Logger,
+) (PresenceService, error) {
+	cfg := PresenceServiceConfig{}
+	if err := viper.UnmarshalKey("hermet.presence", &cfg); err != nil {
+		return nil, err
+	}
+	return NewDefaultPresenceService(cfg, presenceRepo, userContactRepo, channelRepo, conversationRepo, producer, logger), nil
+}
+
+// newMessageArchiver 创建消息归档任务，并注册到 fx 生命周期中。
+func newMessageArchiver(
+	channelRepo repo.ChannelRepo,
+	messageRepo repo.MessageRepo,
+	logger *zap.Logger,
+	lifecycle fx.Lifecycle,
+) (*MessageArchiver, error) {
+	cfg := MessageArchiverConfig{}
+	if err := viper.UnmarshalKey("hermet.archiver", &cfg); err != nil {
+		return nil, err
+	}
+
+	archiver := NewMessageArchiver(cfg, channelRepo, messageRepo, logger)
+	lifecycle.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			archiver.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return archiver.Stop(ctx)
+		},
+	})
+	return archiver, nil
+}
+
+type pushDispatcherFxParams struct {
+	fx.In
+
+	ChannelRepo      repo.ChannelRepo
+	ConversationRepo repo.ConversationRepo
+	PresenceRepo     repo.PresenceRepo
+	PushRepo         repo.PushRepo
+
+	Providers xpush.Providers
+	Logger    *zap.Logger
+
+	Lifecycle fx.Lifecycle
+}
+
+type pushDispatcherFxResult struct {
+	fx.Out
+
+	Dispatcher   *PushDispatcher
+	Registration worker.Registration `group:"xmq_workers"`
+}
+
+// newPushDispatcher 创建离线推送分发任务，并注册到 xmq worker。
+// 消费者由 worker 运行时管理，这里只在停止时推送聚合窗口中剩余的通知。
+func newPushDispatcher(p pushDispatcherFxParams) (pushDispatcherFxResult, error) {
+	cfg := PushDispatcherConfig{}
+	if err := viper.UnmarshalKey("hermet.push", &cfg); err != nil {
+		return pushDispatcherFxResult{}, err
+	}
+
+	dispatcher := NewPushDispatcher(
+		cfg,
+		p.ChannelRepo,
+		p.ConversationRepo,
+		p.PresenceRepo,
+		p.PushRepo,
+		p.Providers,
+		p.Logger,
+	)
+	p.Lifecycle.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			return dispatcher.Stop(ctx)
+		},
+	})
+	return pushDispatcherFxResult{
+		Dispatcher: dispatcher,
+		Registration: worker.Registration{
+			Name:        "hermet-push-dispatcher",
+			Topic:       messageSentTopic,
+			GroupID:     cfg.GroupID,
+			Handler:     worker.HandlerFunc(dispatcher.Handle),
+			Concurrency: cfg.Concurrency,
+			Affinity:    worker.AffinityKey,
+			Retry:       cfg.Retry,
+		},
+	}, nil
+}
+
+type messageRouterFxParams struct {
+	fx.In
+
+	Hub         *ConnectionHub
+	RouteRepo   repo.RouteRepo
+	ChannelRepo repo.ChannelRepo
+
+	Producer produce.Producer
+	Logger   *zap.Logger
+}
+
+type messageRouterFxResult struct {
+	fx.Out
+
+	Router        *MessageRouter
+	Registrations []worker.Registration `group:"xmq_workers,flatten"`
+}
+
+// newMessageRouter 创建跨节点投递路由，并将本节点 topic 和广播 topic 注册到 xmq worker。
+// 没有配置节点 ID 时使用主机名。
+// 投递给在线连接的消息过期后没有意义，使用空的重试策略，处理失败时直接丢弃。
+func newMessageRouter(p messageRouterFxParams) (messageRouterFxResult, error) {
+	cfg := MessageRouterConfig{}
+	if err := viper.UnmarshalKey("hermet.router", &cfg); err != nil {
+		return messageRouterFxResult{}, err
+	}
+	if cfg.NodeID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return messageRouterFxResult{}, err
+		}
+		cfg.NodeID = hostname
+	}
+
+	router := NewMessageRouter(cfg, p.Hub, p.RouteRepo, p.ChannelRepo, p.Producer, p.Logger)
+	return messageRouterFxResult{
+		Router: router,
+		Registrations: []worker.Registration{
+			{
+				Name:        "hermet-message-router",
+				Topic:       router.NodeTopic(),
+				GroupID:     router.NodeTopic(),
+				Handler:     worker.HandlerFunc(router.Handle),
+				Concurrency: cfg.Concurrency,
+				Affinity:    worker.AffinityKey,
+				Retry:       &worker.RetryPolicy{},
+			},
+			{
+				Name:        "hermet-message-router-broadcast",
+				Topic:       broadcastTopic,
+				GroupID:     router.BroadcastGroupID(),
+				Handler:     worker.HandlerFunc(router.Handle),
+				Concurrency: cfg.Concurrency,
+				Affinity:    worker.AffinityKey,
+				Retry:       &worker.RetryPolicy{},
+			},
+		},
+	}, nil
+}
+
+type fanoutDispatcherFxResult struct {
+	fx.Out
+
+	Dispatcher   *FanoutDispatcher
+	Registration worker.Registration `group:"xmq_workers"`
+}
+
+// newFanoutDispatcher 创建消息扇出任务，并注册到 xmq worker。
+func newFanoutDispatcher(
+	router *MessageRouter,
+	channelRepo repo.ChannelRepo,
+	logger *zap.Logger,
+) (fanoutDispatcherFxResult, error) {
+	cfg := FanoutDispatcherConfig{}
+	if err := viper.UnmarshalKey("hermet.fanout", &cfg); err != nil {
+		return fanoutDispatcherFxResult{}, err
+	}
+
+	dispatcher := NewFanoutDispatcher(cfg, router, channelRepo, logger)
+	return fanoutDispatcherFxResult{
+		Dispatcher: dispatcher,
+		Registration: worker.Registration{
+			Name:        "hermet-fanout-dispatcher",
+			Topic:       messageSentTopic,
+			GroupID:     cfg.GroupID,
+			Handler:     worker.HandlerFunc(dispatcher.Handle),
+			Concurrency: cfg.Concurrency,
+			Affinity:    worker.AffinityKey,
+			Retry:       cfg.Retry,
+		},
+	}, nil
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/produce"
+	"github.com/jrmarcco/hermet/internal/repo"
+	"go.uber.org/zap"
+)
+
+const (
+	maxPresenceQuery = 200 // 单次最多查询的用户数
+
+	broadcastPresenceTimeout = 10 * time.Second
+)
+
+// PresenceServiceConfig 在线状态配置。
+type PresenceServiceConfig struct {
+	HeartbeatTTL time.Duration `mapstructure:"heartbeat_ttl"` // 心跳过期时间，超过该时间没有心跳的设备视为离线
+	TypingTTL    time.Duration `mapstructure:"typing_ttl"`    // 正在输入状态的有效期，有效期内重复上报不会重复广播
+
+	// BroadcastChannelLimit 在线状态变更时最多通知的频道数 ( 按会话最后消息时间倒序 )。
+	BroadcastChannelLimit int `mapstructure:"broadcast_channel_limit"`
+}
+
+type PresenceService interface {
+	// Heartbeat 上报设备心跳，用户从离线变为在线时广播在线状态。
+	Heartbeat(ctx context.Context, uid uint64, sid string) error
+	// Offline 设备主动下线，用户所有设备都下线时广播离线状态。
+	// 心跳过期导致的离线不会广播，客户端以查询到的在线状态为准。
+	Offline(ctx context.Context, uid uint64, sid string) error
+
+	// Query 批量查询用户在线状态，不可见的用户只返回 Hidden = true。
+	Query(ctx context.Context, viewer uint64, uids []uint64) ([]domain.Presence, error)
+
+	GetSetting(ctx context.Context, uid uint64) (domain.PresenceSetting, error)
+	UpdateSetting(ctx context.Context, setting domain.PresenceSetting) error
+
+	// Typing 上报正在输入状态。
+	// 正在输入是临时事件，只通过 kafka 转发给网关，不会持久化。
+	Typing(ctx context.Context, uid, cid uint64) error
+}
+
+var _ PresenceService = (*DefaultPresenceService)(nil)
+
+type DefaultPresenceService struct {
+	cfg PresenceServiceConfig
+
+	presenceRepo     repo.PresenceRepo
+	userContactRepo  repo.UserContactRepo
+	channelRepo      repo.ChannelRepo
+	conversationRepo repo.ConversationRepo
+
+	producer produce.Producer
+	logger   *zap.Logger
+}
+
+func NewDefaultPresenceService(
+	cfg PresenceServiceConfig,
+	presenceRepo repo.PresenceRepo,
+	userContactRepo repo.UserContactRepo,
+	channelRepo repo.ChannelRepo,
+	conversationRepo repo.ConversationRepo,
+	producer produce.Producer,
+	logger *zap.Logger,
+) *DefaultPresenceService {
+	return &DefaultPresenceService{
+		cfg:              cfg,
+		presenceRepo:     presenceRepo,
+		userContactRepo:  userContactRepo,
+		channelRepo:      channelRepo,
+		conversationRepo: conversationRepo,
+		producer:         producer,
+		logger:           logger,
+	}
+}
+
+func (s *DefaultPresenceService) Heartbeat(ctx context.Context, uid uint64, sid string) error {
+	becameOnline, err := s.presenceRepo.Heartbeat(ctx, uid, sid, s.cfg.HeartbeatTTL)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	if err := s.presenceRepo.SetLastSeen(ctx, uid, now); err != nil {
+		return err
+	}
+
+	if becameOnline {
+		s.broadcast(uid, true, now)
+	}
+	return nil
+}
+
+func (s *DefaultPresenceService) Offline(ctx context.Context, uid uint64, sid string) error {
+	becameOffline, err := s.presenceRepo.Offline(ctx, uid, sid)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	if err := s.presenceRepo.SetLastSeen(ctx, uid, now); err != nil {
+		return err
+	}
+
+	if becameOffline {
+		s.broadcast(uid, false, now)
+	}
+	return nil
+}
+
+func (s *DefaultPresenceService) Query(ctx context.Context, viewer uint64, uids []uint64) ([]domain.Presence, error) {
+	if len(uids) == 0 || len(uids) > maxPresenceQuery {
+		return nil, fmt.Errorf("%w: uids length must be in (0, %d]", errs.ErrInvalidParam, maxPresenceQuery)
+	}
+
+	settings, err := s.presenceRepo.FindSettings(ctx, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]uint64, 0, len(uids))
+	for _, uid := range uids {
+		ok, err := s.isVisible(ctx, viewer, settings[uid])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			visible = append(visible, uid)
+		}
+	}
+
+	online, err := s.presenceRepo.FilterOnline(ctx, visible)
+	if err != nil {
+		return nil, err
+	}
+	lastSeen, err := s.presenceRepo.GetLastSeen(ctx, visible)
+	if err != nil {
+		return nil, err
+	}
+
+	visibleSet := make(map[uint64]bool, len(visible))
+	for _, uid := range visible {
+		visibleSet[uid] = true
+	}
+
+	presences := make([]domain.Presence, 0, len(uids))
+	for _, uid := range uids {
+		if !visibleSet[uid] {
+			presences = append(presences, domain.Presence{UID: uid, Hidden: true})
+			continue
+		}
+		presences = append(presences, domain.Presence{
+			UID:      uid,
+			Online:   online[uid],
+			LastSeen: lastSeen[uid],
+		})
+	}
+	return presences, nil
+}
+
+// isVisible 判断 viewer 是否可以查看用户的在线状态 ( 用户本人总是可见 )。
+func (s *DefaultPresenceService) isVisible(ctx context.Context, viewer uint64, setting domain.PresenceSetting) (bool, error) {
+	if setting.UID == viewer {
+		return true, nil
+	}
+
+	switch setting.Visibility {
+	case domain.PresenceVisibilityEveryone:
+		return true, nil
+	case domain.PresenceVisibilityContacts:
+		contact, err := s.userContactRepo.FindByUserIDAndContactID(ctx, setting.UID, viewer)
+		if err != nil {
+			if errors.Is(err, errs.ErrRecordNotFound) {
+				return false, nil
+			}
+			return false, err
+		}
+		return !contact.IsBlocked, nil
+	default:
+		return false, nil
+	}
+}
+
+// filterVisible 批量判断 viewers 是否可以查看用户的在线状态，规则与 isVisible 相同。
+func (s *DefaultPresenceService) filterVisible(
+	ctx context.Context,
+	setting domain.PresenceSetting,
+	viewers []uint64,
+) ([]uint64, error) {
+	switch setting.Visibility {
+	case domain.PresenceVisibilityEveryone:
+		return viewers, nil
+	case domain.PresenceVisibilityContacts:
+		visible := make([]uint64, 0, len(viewers))
+		for chunk := range slices.Chunk(viewers, maxPresenceQuery) {
+			contacts, err := s.userContactRepo.FindByUserIDAndContactIDs(ctx, setting.UID, chunk)
+			if err != nil {
+				return nil, err
+			}
+			for i := range contacts {
+				if !contacts[i].IsBlocked {
+					visible = append(visible, contacts[i].ContactID)
+				}
+			}
+		}
+		return visible, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (s *DefaultPresenceService) GetSetting(ctx context.Context, uid uint64) (domain.PresenceSetting, error) {
+	settings, err := s.presenceRepo.FindSettings(ctx, []uint64{uid})
+	if err != nil {
+		return domain.PresenceSetting{}, err
+	}
+	return settings[uid], nil
+}
+
+func (s *DefaultPresenceService) UpdateSetting(ctx context.Context, setting domain.PresenceSetting) error {
+	if !setting.Visibility.IsValid() {
+		return fmt.Errorf("%w: unknown visibility [ %s ]", errs.ErrInvalidParam, setting.Visibility)
+	}
+
+	setting.UpdatedAt = time.Now().UnixMilli()
+	return s.presenceRepo.SaveSetting(ctx, setting)
+}
+
+func (s *DefaultPresenceService) Typing(ctx context.Context, uid, cid uint64) error {
+	channel, err := s.channelRepo.FindByID(ctx, cid)
+	if err != nil {
+		if errors.Is(err, errs.ErrRecordNotFound) {
+			return fmt.Errorf("%w: channel not found", errs.ErrInvalidParam)
+		}
+		return err
+	}
+	if !channel.ChannelStatus.IsWritable() {
+		return fmt.Errorf("%w: channel is not writable", errs.ErrInvalidParam)
+	}
+
+	if _, err := s.channelRepo.FindMember(ctx, cid, uid); err != nil {
+		if errors.Is(err, errs.ErrRecordNotFound) {
+			return fmt.Errorf("%w: not a member of channel", errs.ErrPermissionDenied)
+		}
+		return err
+	}
+
+	// 有效期内重复上报直接忽略，避免客户端每次按键都产生一个事件。
+	ok, err := s.presenceRepo.MarkTyping(ctx, uid, cid, s.cfg.TypingTTL)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	go func() {
+		// 这里必须使用 context.Background()，ctx 会在请求结束后取消。
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		event := domain.TypingEvent{
+			CID:      cid,
+			UID:      uid,
+			TypingAt: time.Now().UnixMilli(),
+		}
+		err := publishEvent(ctx, s.producer, presenceEventTopic, cid, string(domain.PresenceEventTypeTyping), event)
+		if err != nil {
+			s.logger.Error(
+				"[hermet-presence-service] failed to publish typing event",
+				zap.Uint64("cid", cid),
+				zap.Uint64("uid", uid),
+				zap.Error(err),
+			)
+		}
+	}()
+	return nil
+}
+
+// broadcast 异步广播在线状态变更。
+// 按照隐私设置确定通知范围: 所有人可见时通知联系人和最近的频道，仅联系人可见时只通知联系人。
+func (s *DefaultPresenceService) broadcast(uid uint64, online bool, lastSeen int64) {
+	go func() {
+		// 这里必须使用 context.Background()，ctx 会在请求结束后取消。
+		ctx, cancel := context.WithTimeout(context.Background(), broadcastPresenceTimeout)
+		defer cancel()
+
+		setting, err := s.GetSetting(ctx, uid)
+		if err != nil {
+			s.logger.Error(
+				"[hermet-presence-service] failed to get presence setting",
+				zap.Uint64("uid", uid),
+				zap.Error(err),
+			)
+			return
+		}
+		if setting.Visibility == domain.PresenceVisibilityNobody {
+			return
+		}
+
+		ownerIDs, err := s.userContactRepo.ListOwnerIDs(ctx, uid)
+		if err != nil {
+			s.logger.Error(
+				"[hermet-presence-service] failed to list contact owners",
+				zap.Uint64("uid", uid),
+				zap.Error(err),
+			)
+			return
+		}
+
+		// 添加了 uid 的用户不一定能查看 uid 的在线状态，与 Query 使用相同的可见规则过滤。
+		contactIDs, err := s.filterVisible(ctx, setting, ownerIDs)
+		if err != nil {
+			s.logger.Error(
+				"[hermet-presence-service] failed to filter presence viewers",
+				zap.Uint64("uid", uid),
+				zap.Error(err),
+			)
+			return
+		}
+
+		var cids []uint64
+		if setting.Visibility == domain.PresenceVisibilityEveryone {
+			conversations, err := s.conversationRepo.ListByUID(ctx, uid, 0, s.cfg.BroadcastChannelLimit)
+			if err != nil {
+				s.logger.Error(
+					"[hermet-presence-service] failed to list conversations",
+					zap.Uint64("uid", uid),
+					zap.Error(err),
+				)
+				return
+			}
+
+			cids = make([]uint64, 0, len(conversations))
+			for i := range conversations {
+				if !conversations[i].IsClosed() {
+					cids = append(cids, conversations[i].CID)
+				}
+			}
+		}
+
+		event := domain.PresenceChangedEvent{
+			UID:        uid,
+			Online:     online,
+			LastSeen:   lastSeen,
+			ContactIDs: contactIDs,
+			CIDs:       cids,
+			ChangedAt:  time.Now().UnixMilli(),
+		}
+		err = publishEvent(ctx, s.producer, presenceEventTopic, uid, string(domain.PresenceEventTypeChanged), event)
+		if err != nil {
+			s.logger.Error(
+				"[hermet-presence-service] failed to publish presence changed event",
+				zap.Uint64("uid", uid),
+				zap.Error(err),
+			)
+		}
+	}()
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPresenceService_BroadcastOnlyToVisibleViewers(t *testing.T) {
+	t.Parallel()
+
+	const uid = uint64(1)
+
+	// 用户 2、3、4 都添加了 uid 为联系人：
+	// - uid 也添加了 2 ( 联系人可见 )；
+	// - uid 添加了 3 但是拉黑了 3；
+	// - uid 没有添加 4。
+	contacts := []domain.UserContact{
+		{UserID: 2, ContactID: uid},
+		{UserID: 3, ContactID: uid},
+		{UserID: 4, ContactID: uid},
+		{UserID: uid, ContactID: 2},
+		{UserID: uid, ContactID: 3, IsBlocked: true},
+	}
+
+	tcs := []struct {
+		name       string
+		visibility domain.PresenceVisibility
+		wantEvent  bool
+		wantIDs    []uint64
+	}{
+		{name: "everyone", visibility: domain.PresenceVisibilityEveryone, wantEvent: true, wantIDs: []uint64{2, 3, 4}},
+		{name: "contacts", visibility: domain.PresenceVisibilityContacts, wantEvent: true, wantIDs: []uint64{2}},
+		{name: "nobody", visibility: domain.PresenceVisibilityNobody},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			producer := &fakeProducer{}
+			svc := NewDefaultPresenceService(
+				PresenceServiceConfig{HeartbeatTTL: time.Minute},
+				&fakePresenceRepo{
+					becameOnline: true,
+					settings: map[uint64]domain.PresenceSetting{
+						uid: {UID: uid, Visibility: tc.visibility},
+					},
+				},
+				newFakeUserContactRepo(contacts...),
+				newFakeChannelRepo(),
+				newFakeConversationRepo(),
+				producer,
+				zap.NewNop(),
+			)
+			require.NoError(t, svc.Heartbeat(t.Context(), uid, "s1"))
+
+			if !tc.wantEvent {
+				// 广播是异步的，等待一段时间确认没有发送事件。
+				time.Sleep(20 * time.Millisecond)
+				require.Empty(t, producer.eventTypes())
+				return
+			}
+
+			require.Eventually(t, func() bool {
+				return len(producer.eventTypes()) == 1
+			}, time.Second, time.Millisecond)
+
+			var event domain.PresenceChangedEvent
+			require.NoError(t, json.Unmarshal(producer.msgs[0].Val, &event))
+			require.True(t, event.Online)
+			require.ElementsMatch(t, tc.wantIDs, event.ContactIDs)
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq"
+	"github.com/jrmarcco/hermet/internal/pkg/xmq/worker"
+	"github.com/jrmarcco/hermet/internal/pkg/xpush"
+	"github.com/jrmarcco/hermet/internal/repo"
+	"go.uber.org/zap"
+)
+
+const (
+	maxPushPreviewLen = 100 // 通知内容预览的最大字符数
+
+	dispatchPushTimeout = 10 * time.Second
+)
+
+// PushDispatcherConfig 离线推送配置。
+type PushDispatcherConfig struct {
+	GroupID         string        `mapstructure:"group_id"`         // kafka 消费者组
+	Concurrency     int           `mapstructure:"concurrency"`      // 处理协程数
+	AggregateWindow time.Duration `mapstructure:"aggregate_window"` // 聚合窗口，窗口内同一会话的多条消息合并为一条通知
+
+	Retry *worker.RetryPolicy `mapstructure:"retry"` // 处理失败时的重试策略，为空时不重试
+}
+
+// PushDispatcher 离线推送分发任务。
+// 消费消息发送事件，为离线的频道成员生成推送通知，并交给对应平台的 xpush.Provider 发送。
+//
+// 以下情况不会推送:
+//   - 用户在线 ( 由网关直接下发 )；
+//   - 会话已关闭，或会话开启了免打扰 ( 被 @ 时仍然推送 )；
+//   - 用户处于免打扰时间段；
+//   - 话题回复 ( 仅推送给被 @ 的用户 )。
+//
+// 同一用户同一会话在聚合窗口内的多条消息只推送一条通知 ( 突发消息合并 )。
+type PushDispatcher struct {
+	cfg PushDispatcherConfig
+
+	channelRepo      repo.ChannelRepo
+	conversationRepo repo.ConversationRepo
+	presenceRepo     repo.PresenceRepo
+	pushRepo         repo.PushRepo
+
+	providers xpush.Providers
+	logger    *zap.Logger
+
+	wg sync.WaitGroup
+
+	mu      sync.Mutex
+	pending map[pushKey]*pushBatch
+}
+
+type pushKey struct {
+	uid uint64
+	cid uint64
+}
+
+// pushBatch 聚合窗口内待推送的消息。
+type pushBatch struct {
+	count     int
+	mentioned bool
+	last      domain.MessageSentEvent
+
+	timer *time.Timer
+}
+
+func NewPushDispatcher(
+	cfg PushDispatcherConfig,
+	channelRepo repo.ChannelRepo,
+	conversationRepo repo.ConversationRepo,
+	presenceRepo repo.PresenceRepo,
+	pushRepo repo.PushRepo,
+	providers xpush.Providers,
+	logger *zap.Logger,
+) *PushDispatcher {
+	return &PushDispatcher{
+		cfg:              cfg,
+		channelRepo:      channelRepo,
+		conversationRepo: conversationRepo,
+		presenceRepo:     presenceRepo,
+		pushRepo:         pushRepo,
+		providers:        providers,
+		logger:           logger,
+		pending:          make(map[pushKey]*pushBatch),
+	}
+}
+
+// Stop 立即推送聚合窗口中尚未推送的通知。
+// 需要在消费者停止之后调用。
+func (d *PushDispatcher) Stop(ctx context.Context) error {
+	// 定时器已经触发的批次由定时器自己推送，这里只处理尚未触发的批次。
+	d.mu.Lock()
+	keys := make([]pushKey, 0, len(d.pending))
+	for key, batch := range d.pending {
+		if batch.timer.Stop() {
+			keys = append(keys, key)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, key := range keys {
+		d.flush(key)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Handle 处理消息发送事件。
+func (d *PushDispatcher) Handle(ctx context.Context, msg *xmq.Message) error {
+	var event domain.MessageSentEvent
+	if err := json.Unmarshal(msg.Val, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal message sent event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dispatchPushTimeout)
+	defer cancel()
+
+	recipients, err := d.recipients(ctx, event)
+	if err != nil {
+		return fmt.Errorf("failed to resolve push recipients of message [ %d ]: %w", event.ID, err)
+	}
+
+	now := time.Now()
+	for _, uid := range recipients {
+		mentioned := event.MentionAll || slices.Contains(event.Mentions, uid)
+
+		ok, err := d.shouldPush(ctx, uid, event.CID, mentioned, now)
+		if err != nil {
+			d.logger.Error(
+				"[hermet-push-dispatcher] failed to check push preference",
+				zap.Uint64("cid", event.CID),
+				zap.Uint64("uid", uid),
+				zap.Error(err),
+			)
+			continue
+		}
+		if ok {
+			d.enqueue(uid, event, mentioned)
+		}
+	}
+	return nil
+}
+
+// recipients 返回需要推送的离线用户 ( 不包含发送者 )。
+func (d *PushDispatcher) recipients(ctx context.Context, event domain.MessageSentEvent) ([]uint64, error) {
+	var uids []uint64
+	if event.ThreadID != 0 {
+		// 话题回复只推送给被 @ 的用户，避免话题讨论打扰整个群。
+		if !event.MentionAll {
+			uids = slices.Clone(event.Mentions)
+		}
+	}
+
+	if uids == nil {
+		members, err := d.channelRepo.ListActiveMembers(ctx, event.CID)
+		if err != nil {
+			return nil, err
+		}
+
+		uids = make([]uint64, 0, len(members))
+		for i := range members {
+			uids = append(uids, members[i].UID)
+		}
+	}
+
+	uids = slices.DeleteFunc(uids, func(uid uint64) bool {
+		return uid == event.SID
+	})
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	online, err := d.presenceRepo.FilterOnline(ctx, uids)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(uids, func(uid uint64) bool {
+		return online[uid]
+	}), nil
+}
+
+// shouldPush 根据会话免打扰和用户免打扰时间段判断是否推送。
+func (d *PushDispatcher) shouldPush(ctx context.Context, uid, cid uint64, mentioned bool, now time.Time) (bool, error) {
+	conversation, err := d.conversationRepo.FindByUIDAndCID(ctx, uid, cid)
+	switch {
+	case err == nil:
+		if conversation.IsClosed() {
+			return false, nil
+		}
+		if conversation.IsMuted && !mentioned {
+			return false, nil
+		}
+	case errors.Is(err, errs.ErrRecordNotFound):
+		// 会话视图尚未创建 ( 如第一条消息 )，按未免打扰处理。
+	default:
+		return false, err
+	}
+
+	setting, err := d.pushRepo.FindSetting(ctx, uid)
+	if err != nil {
+		if errors.Is(err, errs.ErrRecordNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+	return !setting.InDND(now), nil
+}
+
+// enqueue 将消息加入聚合窗口，窗口内的第一条消息会启动定时器。
+func (d *PushDispatcher) enqueue(uid uint64, event domain.MessageSentEvent, mentioned bool) {
+	key := pushKey{uid: uid, cid: event.CID}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if batch, ok := d.pending[key]; ok {
+		batch.count++
+		batch.mentioned = batch.mentioned || mentioned
+		batch.last = event
+		return
+	}
+
+	d.wg.Add(1)
+	d.pending[key] = &pushBatch{
+		count:     1,
+		mentioned: mentioned,
+		last:      event,
+		timer: time.AfterFunc(d.cfg.AggregateWindow, func() {
+			d.flush(key)
+		}),
+	}
+}
+
+// flush 推送聚合窗口中的通知到用户的所有设备。
+func (d *PushDispatcher) flush(key pushKey) {
+	d.mu.Lock()
+	batch, ok := d.pending[key]
+	delete(d.pending, key)
+	d.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	defer d.wg.Done()
+
+	// 这里必须使用 context.Background()，Stop 时仍需要推送剩余的通知。
+	ctx, cancel := context.WithTimeout(context.Background(), dispatchPushTimeout)
+	defer cancel()
+
+	devices, err := d.pushRepo.ListDevices(ctx, key.uid)
+	if err != nil {
+		d.logger.Error(
+			"[hermet-push-dispatcher] failed to list push devices",
+			zap.Uint64("uid", key.uid),
+			zap.Error(err),
+		)
+		return
+	}
+
+	for i := range devices {
+		provider, ok := d.providers[xpush.Platform(devices[i].Platform)]
+		if !ok {
+			d.logger.Warn(
+				"[hermet-push-dispatcher] push provider not configured",
+				zap.String("platform", string(devices[i].Platform)),
+			)
+			continue
+		}
+
+		n := d.buildNotification(batch)
+		n.Token = devices[i].Token
+
+		err := provider.Push(ctx, n)
+		if err == nil {
+			continue
+		}
+
+		if errors.Is(err, xpush.ErrInvalidToken) {
+			// token 已失效，删除后不再推送。
+			if err := d.pushRepo.DeleteDeviceByToken(ctx, devices[i].Token); err != nil {
+				d.logger.Error(
+					"[hermet-push-dispatcher] failed to delete invalid push token",
+					zap.Uint64("uid", key.uid),
+					zap.Error(err),
+				)
+			}
+			continue
+		}
+		d.logger.Error(
+			"[hermet-push-dispatcher] failed to push notification",
+			zap.Uint64("uid", key.uid),
+			zap.Uint64("cid", key.cid),
+			zap.String("platform", string(devices[i].Platform)),
+			zap.Error(err),
+		)
+	}
+}
+
+func (d *PushDispatcher) buildNotification(batch *pushBatch) xpush.Notification {
+	body := pushPreview(batch.last.ContentType, batch.last.Content)
+	if batch.count > 1 {
+		body = fmt.Sprintf("[%d 条] %s", batch.count, body)
+	}
+	if batch.mentioned {
+		body = "[有人@我] " + body
+	}
+
+	cid := strconv.FormatUint(batch.last.CID, 10)
+	return xpush.Notification{
+		Title:       "新消息",
+		Body:        body,
+		CollapseKey: cid,
+		Data: map[string]string{
+			"cid":       cid,
+			"messageId": strconv.FormatUint(batch.last.ID, 10),
+		},
+	}
+}
+
+// pushPreview 生成通知内容预览，非文本消息只展示消息类型。
+func pushPreview(ct domain.ContentType, content []byte) string {
+	switch ct {
+	case domain.ContentTypeText:
+		var text domain.TextContent
+		if err := json.Unmarshal(content, &text); err != nil {
+			return "[新消息]"
+		}
+		if utf8.RuneCountInString(text.Text) > maxPushPreviewLen {
+			return string([]rune(text.Text)[:maxPushPreviewLen]) + "..."
+		}
+		return text.Text
+	case domain.ContentTypeImage:
+		return "[图片]"
+	case domain.ContentTypeFile:
+		return "[文件]"
+	case domain.ContentTypeVoice:
+		return "[语音]"
+	case domain.ContentTypeVideo:
+		return "[视频]"
+	case domain.ContentTypeLocation:
+		return "[位置]"
+	case domain.ContentTypeCard:
+		return "[名片]"
+	default:
+		return "[新消息]"
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/repo"
+)
+
+const maxPushTokenLen = 4096
+
+type PushService interface {
+	// RegisterDevice 注册会话的推送设备 token ( 重复注册会覆盖旧的 token )。
+	RegisterDevice(ctx context.Context, device domain.PushDevice) error
+	// UnregisterDevice 注销会话的推送设备 ( 退出登录时调用 )。
+	UnregisterDevice(ctx context.Context, sid string) error
+
+	// GetSetting 查询用户推送设置，未设置时返回默认设置 ( 不开启免打扰 )。
+	GetSetting(ctx context.Context, uid uint64) (domain.PushSetting, error)
+	UpdateSetting(ctx context.Context, setting domain.PushSetting) error
+}
+
+var _ PushService = (*DefaultPushService)(nil)
+
+type DefaultPushService struct {
+	pushRepo repo.PushRepo
+}
+
+func NewDefaultPushService(pushRepo repo.PushRepo) *DefaultPushService {
+	return &DefaultPushService{
+		pushRepo: pushRepo,
+	}
+}
+
+func (s *DefaultPushService) RegisterDevice(ctx context.Context, device domain.PushDevice) error {
+	if device.SID == "" {
+		return fmt.Errorf("%w: sid is empty", errs.ErrInvalidParam)
+	}
+	if !device.Platform.IsValid() {
+		return fmt.Errorf("%w: unknown platform [ %s ]", errs.ErrInvalidParam, device.Platform)
+	}
+	if device.Token == "" || len(device.Token) > maxPushTokenLen {
+		return fmt.Errorf("%w: token length must be in (0, %d]", errs.ErrInvalidParam, maxPushTokenLen)
+	}
+
+	device.UpdatedAt = time.Now().UnixMilli()
+	return s.pushRepo.SaveDevice(ctx, device)
+}
+
+func (s *DefaultPushService) UnregisterDevice(ctx context.Context, sid string) error {
+	return s.pushRepo.DeleteDeviceBySID(ctx, sid)
+}
+
+func (s *DefaultPushService) GetSetting(ctx context.Context, uid uint64) (domain.PushSetting, error) {
+	setting, err := s.pushRepo.FindSetting(ctx, uid)
+	if err != nil {
+		if errors.Is(err, errs.ErrRecordNotFound) {
+			return domain.PushSetting{UID: uid}, nil
+		}
+		return domain.PushSetting{}, err
+	}
+	return setting, nil
+}
+
+func (s *DefaultPushService) UpdateSetting(ctx context.Context, setting domain.PushSetting) error {
+	if err := setting.Validate(); err != nil {
+		return fmt.Errorf("%w: %s", errs.ErrInvalidParam, err.Error())
+	}
+
+	setting.UpdatedAt = time.Now().UnixMilli()
+	return s.pushRepo.SaveSetting(ctx, setting)
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/repo"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSyncLogLanes     = 16
+	defaultSyncLogQueueSize = 1024
+
+	appendSyncLogTimeout = 10 * time.Second
+)
+
+// SyncLogAppenderConfig 同步日志写入配置。
+type SyncLogAppenderConfig struct {
+	Lanes     int `mapstructure:"lanes"`      // 写入协程数 ( 查询频道成员和写入同步日志各 Lanes 个 )
+	QueueSize int `mapstructure:"queue_size"` // 每个写入协程的队列长度，队列满时发送消息会等待
+}
+
+// SyncLogAppender 为频道成员 ( 包括发送者，用于多设备同步 ) 分配同步序列号并写入同步日志。
+//
+// 写入分为两级，都使用固定数量的协程，不会为每条消息创建协程：
+// - 按 cid 分配到协程查询频道成员，同一频道的消息按发送顺序处理；
+// - 按 uid 分配到协程分配序列号并写入同步日志，同一用户的序列号 N 一定先于 N+1 写入。
+//
+// 写入失败只记录日志，序列号会出现空洞，同步时会跳过 ( 见 SyncServiceConfig.GapWait )。
+type SyncLogAppender struct {
+	cfg SyncLogAppenderConfig
+
+	channelRepo repo.ChannelRepo
+	syncRepo    repo.SyncRepo
+	logger      *zap.Logger
+
+	messages []chan domain.Message
+	logs     []chan domain.SyncLog
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewSyncLogAppender(
+	cfg SyncLogAppenderConfig,
+	channelRepo repo.ChannelRepo,
+	syncRepo repo.SyncRepo,
+	logger *zap.Logger,
+) *SyncLogAppender {
+	if cfg.Lanes <= 0 {
+		cfg.Lanes = defaultSyncLogLanes
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultSyncLogQueueSize
+	}
+
+	a := &SyncLogAppender{
+		cfg:         cfg,
+		channelRepo: channelRepo,
+		syncRepo:    syncRepo,
+		logger:      logger,
+		messages:    make([]chan domain.Message, cfg.Lanes),
+		logs:        make([]chan domain.SyncLog, cfg.Lanes),
+	}
+	for i := range cfg.Lanes {
+		a.messages[i] = make(chan domain.Message, cfg.QueueSize)
+		a.logs[i] = make(chan domain.SyncLog, cfg.QueueSize)
+	}
+	// Start 之前写入的消息会在队列中等待。
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	return a
+}
+
+func (a *SyncLogAppender) Start() {
+	for i := range a.cfg.Lanes {
+		a.wg.Add(2) //nolint:mnd // 每个 lane 两个协程
+		go a.runMessageLane(a.messages[i])
+		go a.runLogLane(a.logs[i])
+	}
+}
+
+// Stop 停止写入协程，队列中尚未写入的同步日志会被丢弃 ( 客户端可以通过全量同步修正 )。
+func (a *SyncLogAppender) Stop(ctx context.Context) error {
+	a.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Append 将消息放入写入队列，队列满时等待直到 ctx 结束。
+func (a *SyncLogAppender) Append(ctx context.Context, message domain.Message) {
+	select {
+	case a.messages[message.CID%uint64(a.cfg.Lanes)] <- message: //nolint:gosec // Lanes 为正数
+	case <-ctx.Done():
+		a.logger.Error(
+			"[hermet-sync-log-appender] failed to enqueue message",
+			zap.Uint64("cid", message.CID),
+			zap.Uint64("message_id", message.ID),
+			zap.Error(ctx.Err()),
+		)
+	case <-a.ctx.Done():
+		a.logger.Error(
+			"[hermet-sync-log-appender] appender stopped, message dropped",
+			zap.Uint64("cid", message.CID),
+			zap.Uint64("message_id", message.ID),
+		)
+	}
+}
+
+// runMessageLane 查询频道成员，并将每个成员的同步日志放入对应用户的写入队列。
+func (a *SyncLogAppender) runMessageLane(messages <-chan domain.Message) {
+	defer a.wg.Done()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case message := <-messages:
+			a.fanout(message)
+		}
+	}
+}
+
+func (a *SyncLogAppender) fanout(message domain.Message) {
+	ctx, cancel := context.WithTimeout(a.ctx, appendSyncLogTimeout)
+	members, err := a.channelRepo.ListActiveMembers(ctx, message.CID)
+	cancel()
+	if err != nil {
+		a.logger.Error(
+			"[hermet-sync-log-appender] failed to list channel members for sync",
+			zap.Uint64("cid", message.CID),
+			zap.Uint64("message_id", message.ID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	for i := range members {
+		log := domain.SyncLog{
+			UID:       members[i].UID,
+			CID:       message.CID,
+			MessageID: message.ID,
+		}
+		select {
+		case a.logs[log.UID%uint64(a.cfg.Lanes)] <- log: //nolint:gosec // Lanes 为正数
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+// runLogLane 按顺序为用户分配序列号并写入同步日志。
+func (a *SyncLogAppender) runLogLane(logs <-chan domain.SyncLog) {
+	defer a.wg.Done()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case log := <-logs:
+			a.append(log)
+		}
+	}
+}
+
+func (a *SyncLogAppender) append(log domain.SyncLog) {
+	ctx, cancel := context.WithTimeout(a.ctx, appendSyncLogTimeout)
+	defer cancel()
+
+	seq, err := a.syncRepo.NextSeq(ctx, log.UID)
+	if err == nil {
+		log.Seq = seq
+		// 使用写入时间而不是消息发送时间，同步时依据该时间判断序列号空洞是否还在写入中。
+		log.CreatedAt = time.Now().UnixMilli()
+		err = a.syncRepo.Append(ctx, log)
+	}
+	if err != nil {
+		a.logger.Error(
+			"[hermet-sync-log-appender] failed to append sync log",
+			zap.Uint64("cid", log.CID),
+			zap.Uint64("uid", log.UID),
+			zap.Uint64("message_id", log.MessageID),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSyncLogAppender_Append(t *testing.T) {
+	t.Parallel()
+
+	channelRepo := newFakeChannelRepo()
+	for cid := uint64(1); cid <= 3; cid++ {
+		channelRepo.addChannel(
+			domain.Channel{ID: cid, ChannelStatus: domain.ChannelStatusActive},
+			domain.ChannelMember{UID: 10},
+			domain.ChannelMember{UID: 10 + cid},
+		)
+	}
+	syncRepo := newFakeSyncRepo()
+
+	appender := NewSyncLogAppender(SyncLogAppenderConfig{Lanes: 2, QueueSize: 4}, channelRepo, syncRepo, zap.NewNop())
+	appender.Start()
+	defer func() {
+		require.NoError(t, appender.Stop(context.Background()))
+	}()
+
+	const perChannel = 20
+	id := uint64(0)
+	for range perChannel {
+		for cid := uint64(1); cid <= 3; cid++ {
+			id++
+			appender.Append(t.Context(), domain.Message{ID: id, CID: cid})
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		return len(syncRepo.userLogs(10)) == 3*perChannel
+	}, time.Second, time.Millisecond)
+
+	// 同一用户的同步日志按序列号顺序写入，同一频道的消息按发送顺序分配序列号。
+	lastIDs := make(map[uint64]uint64)
+	for i, log := range syncRepo.userLogs(10) {
+		require.Equal(t, uint64(i+1), log.Seq)
+		require.Greater(t, log.MessageID, lastIDs[log.CID])
+		lastIDs[log.CID] = log.MessageID
+	}
+
+	require.Eventually(t, func() bool {
+		return len(syncRepo.userLogs(13)) == perChannel
+	}, time.Second, time.Millisecond)
+}
+
+func TestSyncLogAppender_AppendAfterStop(t *testing.T) {
+	t.Parallel()
+
+	appender := NewSyncLogAppender(SyncLogAppenderConfig{Lanes: 1, QueueSize: 1}, newFakeChannelRepo(), newFakeSyncRepo(), zap.NewNop())
+	appender.Start()
+	require.NoError(t, appender.Stop(t.Context()))
+
+	// 停止后队列满时不会阻塞。
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 3 {
+			appender.Append(context.Background(), domain.Message{ID: uint64(i + 1), CID: 1})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("append blocked after stop")
+	}
+}
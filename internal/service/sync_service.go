@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/jrmarcco/hermet/internal/errs"
+	"github.com/jrmarcco/hermet/internal/repo"
+)
+
+const maxSyncLimit = 500
+
+// SyncServiceConfig 离线同步配置。
+type SyncServiceConfig struct {
+	MaxDiff uint64 `mapstructure:"max_diff"` // 允许增量同步的最大序列号差值，超过时需要全量同步
+	// GapWait 序列号出现空洞时的等待时长。
+	// 多个实例并发写入时序列号 N+1 可能先于 N 写入，空洞之后的日志写入时间在 GapWait 以内时，
+	// 本次同步在空洞之前结束，避免客户端的同步进度越过尚未写入的 N。
+	// 超过 GapWait 的空洞视为写入失败直接跳过。
+	GapWait time.Duration `mapstructure:"gap_wait"`
+	// LogTTL 同步日志的保留时长，过期的同步日志由 TTL 索引清理，客户端落后超过该时长时需要全量同步。
+	LogTTL time.Duration `mapstructure:"log_ttl"`
+}
+
+type SyncService interface {
+	// Sync 查询用户序列号大于 sinceSeq 的消息 ( 跨所有频道，按序列号升序 )。
+	// 客户端 ( 每个设备 ) 各自保存上一次同步得到的 NextSeq，重连后从该序列号继续同步。
+	Sync(ctx context.Context, uid, sinceSeq uint64, limit int) (domain.SyncResult, error)
+}
+
+var _ SyncService = (*DefaultSyncService)(nil)
+
+type DefaultSyncService struct {
+	cfg SyncServiceConfig
+
+	syncRepo    repo.SyncRepo
+	messageRepo repo.MessageRepo
+}
+
+func NewDefaultSyncService(cfg SyncServiceConfig, syncRepo repo.SyncRepo, messageRepo repo.MessageRepo) *DefaultSyncService {
+	return &DefaultSyncService{
+		cfg:         cfg,
+		syncRepo:    syncRepo,
+		messageRepo: messageRepo,
+	}
+}
+
+func (s *DefaultSyncService) Sync(ctx context.Context, uid, sinceSeq uint64, limit int) (domain.SyncResult, error) {
+	if limit <= 0 || limit > maxSyncLimit {
+		return domain.SyncResult{}, fmt.Errorf("%w: limit must be in (0, %d]", errs.ErrInvalidParam, maxSyncLimit)
+	}
+
+	latestSeq, err := s.syncRepo.LatestSeq(ctx, uid)
+	if err != nil {
+		return domain.SyncResult{}, err
+	}
+
+	res := domain.SyncResult{
+		NextSeq:   sinceSeq,
+		LatestSeq: latestSeq,
+	}
+	if sinceSeq == latestSeq {
+		return res, nil
+	}
+
+	// 客户端序列号比服务端大 ( 服务端数据异常 ) 或者落后太多时，都需要全量同步。
+	if sinceSeq > latestSeq || latestSeq-sinceSeq > s.cfg.MaxDiff {
+		res.FullResync = true
+		return res, nil
+	}
+
+	// 同步日志已经过期清理，无法增量同步。
+	minSeq, err := s.syncRepo.MinSeq(ctx, uid)
+	if err != nil {
+		return domain.SyncResult{}, err
+	}
+	if minSeq == 0 || sinceSeq+1 < minSeq {
+		res.FullResync = true
+		return res, nil
+	}
+
+	logs, err := s.syncRepo.ListAfter(ctx, uid, sinceSeq, limit)
+	if err != nil {
+		return domain.SyncResult{}, err
+	}
+
+	logs, gap := s.untilGap(sinceSeq, logs)
+	if len(logs) == 0 {
+		return res, nil
+	}
+
+	res.NextSeq = logs[len(logs)-1].Seq
+	// 停在空洞前时不提示客户端立即继续同步，等待下一次同步时再检查。
+	res.HasMore = !gap && res.NextSeq < latestSeq
+
+	res.Entries, err = s.entries(ctx, uid, logs)
+	if err != nil {
+		return domain.SyncResult{}, err
+	}
+	return res, nil
+}
+
+// untilGap 返回 logs 中第一个仍在等待写入的序列号空洞之前的部分，并返回是否停在空洞前。
+func (s *DefaultSyncService) untilGap(sinceSeq uint64, logs []domain.SyncLog) ([]domain.SyncLog, bool) {
+	waitAfter := time.Now().Add(-s.cfg.GapWait).UnixMilli()
+
+	prev := sinceSeq
+	for i := range logs {
+		if logs[i].Seq != prev+1 && logs[i].CreatedAt > waitAfter {
+			return logs[:i], true
+		}
+		prev = logs[i].Seq
+	}
+	return logs, false
+}
+
+// entries 根据同步日志加载消息，跳过已归档或被用户删除的消息，并清空撤回消息的内容。
+func (s *DefaultSyncService) entries(ctx context.Context, uid uint64, logs []domain.SyncLog) ([]domain.SyncEntry, error) {
+	ids := make([]uint64, 0, len(logs))
+	idsByCID := make(map[uint64][]uint64)
+	for i := range logs {
+		ids = append(ids, logs[i].MessageID)
+		idsByCID[logs[i].CID] = append(idsByCID[logs[i].CID], logs[i].MessageID)
+	}
+
+	messages, err := s.messageRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	messageByID := make(map[uint64]domain.Message, len(messages))
+	for i := range messages {
+		messageByID[messages[i].ID] = messages[i]
+	}
+
+	for cid, cidIDs := range idsByCID {
+		deletedIDs, err := s.messageRepo.FindDeletedIDs(ctx, uid, cid, cidIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range deletedIDs {
+			delete(messageByID, id)
+		}
+	}
+
+	entries := make([]domain.SyncEntry, 0, len(logs))
+	for i := range logs {
+		message, ok := messageByID[logs[i].MessageID]
+		if !ok {
+			continue
+		}
+		if message.IsRecalled() {
+			message.Content = nil
+		}
+		entries = append(entries, domain.SyncEntry{
+			Seq:     logs[i].Seq,
+			Message: message,
+		})
+	}
+	return entries, nil
+}
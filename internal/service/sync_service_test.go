@@ -0,0 +1,79 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jrmarcco/hermet/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncService_SyncStopsAtGap(t *testing.T) {
+	t.Parallel()
+
+	const uid = uint64(1)
+	now := time.Now()
+
+	tcs := []struct {
+		name        string
+		gapAt       time.Time // 空洞之后的日志的写入时间
+		wantSeqs    []uint64
+		wantNextSeq uint64
+		wantHasMore bool
+	}{
+		{
+			name:        "recent gap",
+			gapAt:       now,
+			wantSeqs:    []uint64{1, 2},
+			wantNextSeq: 2,
+		}, {
+			name:        "expired gap",
+			gapAt:       now.Add(-time.Minute),
+			wantSeqs:    []uint64{1, 2, 4, 5},
+			wantNextSeq: 5,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			syncRepo := newFakeSyncRepo()
+			messageRepo := newFakeMessageRepo()
+			// 序列号 3 已经分配但是尚未写入。
+			for seq := uint64(1); seq <= 5; seq++ {
+				_, err := syncRepo.NextSeq(t.Context(), uid)
+				require.NoError(t, err)
+				if seq == 3 {
+					continue
+				}
+
+				createdAt := now.Add(-time.Minute)
+				if seq > 3 {
+					createdAt = tc.gapAt
+				}
+				_, err = messageRepo.Save(t.Context(), domain.Message{ID: seq, CID: 1})
+				require.NoError(t, err)
+				require.NoError(t, syncRepo.Append(t.Context(), domain.SyncLog{
+					UID:       uid,
+					Seq:       seq,
+					CID:       1,
+					MessageID: seq,
+					CreatedAt: createdAt.UnixMilli(),
+				}))
+			}
+
+			svc := NewDefaultSyncService(SyncServiceConfig{MaxDiff: 100, GapWait: 10 * time.Second}, syncRepo, messageRepo)
+			res, err := svc.Sync(t.Context(), uid, 0, 10)
+			require.NoError(t, err)
+
+			seqs := make([]uint64, 0, len(res.Entries))
+			for _, entry := range res.Entries {
+				seqs = append(seqs, entry.Seq)
+			}
+			require.Equal(t, tc.wantSeqs, seqs)
+			require.Equal(t, tc.wantNextSeq, res.NextSeq)
+			require.Equal(t, tc.wantHasMore, res.HasMore)
+		})
+	}
+}
@@ -18,6 +18,11 @@
 --   - conversation_reverse_index: 会话反向索引 ( 谁的会话中有某个用户 )
 --   - contact_reverse_index: 联系人反向索引 ( 谁的联系人中有某个用户 )
 --
+-- 【 二级索引 Secondary Index 】非分片键查询 ( 索引表以索引字段为分片键，避免广播查询 )
+--   - biz_user_mobile_index: 手机号 -> 用户 ID ( 同时保证手机号全局唯一 )
+--   - contact_applicant_index: 申请人 ID -> 联系人申请 ID
+--   - 写入顺序: 先写入索引表，再写入主表 ( 主表写入失败时删除索引 )；查询时通过主表校验，忽略残留的索引
+--
 -- 【 数据同步 】
 --   - 方式1: 应用层双写 ( 写入时同时更新读取侧 )
 --   - 方式2: CDC + Kafka ( **推荐** 通过 Debezium 捕获变更 )
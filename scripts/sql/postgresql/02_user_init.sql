@@ -68,6 +68,21 @@ CREATE INDEX idx_biz_user_nickname ON biz_user(nickname) WHERE deleted_at = 0;
 -- 3. 状态查询
 CREATE INDEX idx_biz_user_status ON biz_user(user_status, deleted_at);
 
+-- 用户手机号索引表 ( 二级索引 - 以 mobile 为分片键 )
+-- biz_user 以 email 为分片键，根据手机号查询用户时先通过索引表找到 user_id，避免广播查询。
+DROP TABLE IF EXISTS biz_user_mobile_index;
+CREATE TABLE biz_user_mobile_index (
+    mobile VARCHAR(16) PRIMARY KEY,                    -- 手机号 ( 全局唯一 )
+    user_id BIGINT NOT NULL,                           -- 用户 ID
+
+    created_at BIGINT NOT NULL
+);
+
+COMMENT ON TABLE biz_user_mobile_index IS '【 二级索引 】用户手机号索引表 ( 根据手机号定位用户，同时保证手机号全局唯一 )';
+COMMENT ON COLUMN biz_user_mobile_index.mobile IS '手机号';
+COMMENT ON COLUMN biz_user_mobile_index.user_id IS '用户 ID';
+COMMENT ON COLUMN biz_user_mobile_index.created_at IS '创建时间戳 ( Unix 毫秒值 )';
+
 -- ============================================================================
 -- 测试数据
 -- ============================================================================
@@ -142,3 +157,15 @@ INSERT INTO biz_user_2 (
     EXTRACT(EPOCH FROM NOW()) * 1000,
     EXTRACT(EPOCH FROM NOW()) * 1000
 );
+
+-- hermet_0
+-- 以 13100131000 为 sharder 插入手机号索引到分表
+INSERT INTO biz_user_mobile_index_0 (
+    mobile,
+    user_id,
+    created_at
+) VALUES (
+    '13100131000',
+    134605228232032256,
+    EXTRACT(EPOCH FROM NOW()) * 1000
+);
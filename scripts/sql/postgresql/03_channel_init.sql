@@ -26,6 +26,9 @@ CREATE TABLE channel (
     -- 最后消息时间 ( 用于判断活跃度 )
     last_message_at BIGINT NOT NULL DEFAULT 0,
 
+    -- 消息归档时间 ( 长期不活跃频道的消息会被迁移到冷存储 )
+    message_archived_at BIGINT NOT NULL DEFAULT 0,
+
     creator_id BIGINT NOT NULL,
 
     created_at BIGINT NOT NULL,
@@ -41,6 +44,7 @@ COMMENT ON COLUMN channel.channel_status IS '频道状态 ( creating=创建中 /
 COMMENT ON COLUMN channel.channel_info_ver IS '【 CQRS 关键字段 】频道信息版本号 ( 用于同步检测 )';
 COMMENT ON COLUMN channel.channel_member_count IS '频道成员数量';
 COMMENT ON COLUMN channel.last_message_at IS '最后消息时间戳 ( Unix 毫秒值 )';
+COMMENT ON COLUMN channel.message_archived_at IS '消息归档时间戳 ( Unix 毫秒值，0 表示未归档 )';
 COMMENT ON COLUMN channel.creator_id IS '创建者 ID';
 COMMENT ON COLUMN channel.created_at IS '创建时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN channel.updated_at IS '更新时间戳 ( Unix 毫秒值 )';
@@ -49,6 +53,7 @@ COMMENT ON COLUMN channel.updated_at IS '更新时间戳 ( Unix 毫秒值 )';
 CREATE INDEX idx_channel_type ON channel(channel_type);
 CREATE INDEX idx_channel_creator ON channel(creator_id);
 CREATE INDEX idx_channel_status ON channel(channel_status);
+CREATE INDEX idx_channel_last_message ON channel(last_message_at) WHERE last_message_at > message_archived_at;
 
 
 
@@ -226,6 +231,7 @@ CREATE TABLE user_conversation_view (
     -- 未读数（冗余，实时更新）
     unread_count INT NOT NULL DEFAULT 0,
     mention_count INT NOT NULL DEFAULT 0,                       -- @我的消息数
+    last_mention_message_id BIGINT NOT NULL DEFAULT 0,          -- 最后一条 @我的消息 ID ( 读到该消息后清零 )
 
     -- 会话状态
     opened_at BIGINT NOT NULL,                                  -- 会话创建时间
@@ -269,6 +275,7 @@ COMMENT ON COLUMN user_conversation_view.last_message_sender_name IS '最后消
 COMMENT ON COLUMN user_conversation_view.last_message_time IS '最后消息时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view.unread_count IS '未读消息数';
 COMMENT ON COLUMN user_conversation_view.mention_count IS '@我的消息数';
+COMMENT ON COLUMN user_conversation_view.last_mention_message_id IS '最后一条 @我的消息 ID ( 0 表示没有未读的 @ 消息 )';
 COMMENT ON COLUMN user_conversation_view.opened_at IS '会话创建时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view.closed_at IS '会话删除时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view.created_at IS '创建时间戳 ( Unix 毫秒值 )';
@@ -495,6 +502,23 @@ CREATE INDEX idx_contact_application_target ON contact_application(target_id);
 CREATE INDEX idx_contact_application_pending ON contact_application(target_id, application_status) WHERE application_status = 'pending';
 
 
+-- 联系人申请的申请人索引表 ( 二级索引 - 以 ApplicantID 为分片键 )
+-- contact_application 以 target_id 为分片键，查询用户发出的申请时先通过索引表找到申请 ID，避免广播查询。
+DROP TABLE IF EXISTS contact_applicant_index;
+CREATE TABLE contact_applicant_index (
+    applicant_id BIGINT NOT NULL,                           -- 申请人 ID
+    application_id BIGINT NOT NULL,                         -- 申请 ID ( 包含 contact_application 的分片信息 )
+
+    created_at BIGINT NOT NULL,
+
+    PRIMARY KEY(applicant_id, application_id)
+);
+
+COMMENT ON TABLE contact_applicant_index IS '【 二级索引 】联系人申请的申请人索引表 ( 用于查询 "某个用户发出的申请" )';
+COMMENT ON COLUMN contact_applicant_index.applicant_id IS '申请人 ID';
+COMMENT ON COLUMN contact_applicant_index.application_id IS '联系人申请 ID';
+COMMENT ON COLUMN contact_applicant_index.created_at IS '创建时间戳 ( Unix 毫秒值 )';
+
 
 -- 频道申请表 ( 入群申请 - 以 ChannelID 为分片键 )
 DROP TABLE IF EXISTS channel_application;
@@ -2,7 +2,7 @@
 -- 分库分表SQL脚本
 -- 数据库: hermet_0
 -- 分表数量: 4
--- 生成时间: 2026-01-09 19:22:17
+-- 生成时间: 2026-10-19 04:30:25
 -- 原始文件: ./03_channel_init.sql
 -- ============================================
 
@@ -45,6 +45,9 @@ CREATE TABLE channel_0 (
     -- 最后消息时间 ( 用于判断活跃度 )
     last_message_at BIGINT NOT NULL DEFAULT 0,
 
+    -- 消息归档时间 ( 长期不活跃频道的消息会被迁移到冷存储 )
+    message_archived_at BIGINT NOT NULL DEFAULT 0,
+
     creator_id BIGINT NOT NULL,
 
     created_at BIGINT NOT NULL,
@@ -60,6 +63,7 @@ COMMENT ON COLUMN channel_0.channel_status IS '频道状态 ( creating=创建中
 COMMENT ON COLUMN channel_0.channel_info_ver IS '【 CQRS 关键字段 】频道信息版本号 ( 用于同步检测 )';
 COMMENT ON COLUMN channel_0.channel_member_count IS '频道成员数量';
 COMMENT ON COLUMN channel_0.last_message_at IS '最后消息时间戳 ( Unix 毫秒值 )';
+COMMENT ON COLUMN channel_0.message_archived_at IS '消息归档时间戳 ( Unix 毫秒值，0 表示未归档 )';
 COMMENT ON COLUMN channel_0.creator_id IS '创建者 ID';
 COMMENT ON COLUMN channel_0.created_at IS '创建时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN channel_0.updated_at IS '更新时间戳 ( Unix 毫秒值 )';
@@ -71,6 +75,8 @@ CREATE INDEX idx_channel_creator_0 ON channel_0(creator_id);
 
 CREATE INDEX idx_channel_status_0 ON channel_0(channel_status);
 
+CREATE INDEX idx_channel_last_message_0 ON channel_0(last_message_at) WHERE last_message_at > message_archived_at;
+
 -- 分表 1: channel_1
 DROP TABLE IF EXISTS channel_1;
 CREATE TABLE channel_1 (
@@ -87,6 +93,9 @@ CREATE TABLE channel_1 (
     -- 最后消息时间 ( 用于判断活跃度 )
     last_message_at BIGINT NOT NULL DEFAULT 0,
 
+    -- 消息归档时间 ( 长期不活跃频道的消息会被迁移到冷存储 )
+    message_archived_at BIGINT NOT NULL DEFAULT 0,
+
     creator_id BIGINT NOT NULL,
 
     created_at BIGINT NOT NULL,
@@ -102,6 +111,7 @@ COMMENT ON COLUMN channel_1.channel_status IS '频道状态 ( creating=创建中
 COMMENT ON COLUMN channel_1.channel_info_ver IS '【 CQRS 关键字段 】频道信息版本号 ( 用于同步检测 )';
 COMMENT ON COLUMN channel_1.channel_member_count IS '频道成员数量';
 COMMENT ON COLUMN channel_1.last_message_at IS '最后消息时间戳 ( Unix 毫秒值 )';
+COMMENT ON COLUMN channel_1.message_archived_at IS '消息归档时间戳 ( Unix 毫秒值，0 表示未归档 )';
 COMMENT ON COLUMN channel_1.creator_id IS '创建者 ID';
 COMMENT ON COLUMN channel_1.created_at IS '创建时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN channel_1.updated_at IS '更新时间戳 ( Unix 毫秒值 )';
@@ -113,6 +123,8 @@ CREATE INDEX idx_channel_creator_1 ON channel_1(creator_id);
 
 CREATE INDEX idx_channel_status_1 ON channel_1(channel_status);
 
+CREATE INDEX idx_channel_last_message_1 ON channel_1(last_message_at) WHERE last_message_at > message_archived_at;
+
 -- 分表 2: channel_2
 DROP TABLE IF EXISTS channel_2;
 CREATE TABLE channel_2 (
@@ -129,6 +141,9 @@ CREATE TABLE channel_2 (
     -- 最后消息时间 ( 用于判断活跃度 )
     last_message_at BIGINT NOT NULL DEFAULT 0,
 
+    -- 消息归档时间 ( 长期不活跃频道的消息会被迁移到冷存储 )
+    message_archived_at BIGINT NOT NULL DEFAULT 0,
+
     creator_id BIGINT NOT NULL,
 
     created_at BIGINT NOT NULL,
@@ -144,6 +159,7 @@ COMMENT ON COLUMN channel_2.channel_status IS '频道状态 ( creating=创建中
 COMMENT ON COLUMN channel_2.channel_info_ver IS '【 CQRS 关键字段 】频道信息版本号 ( 用于同步检测 )';
 COMMENT ON COLUMN channel_2.channel_member_count IS '频道成员数量';
 COMMENT ON COLUMN channel_2.last_message_at IS '最后消息时间戳 ( Unix 毫秒值 )';
+COMMENT ON COLUMN channel_2.message_archived_at IS '消息归档时间戳 ( Unix 毫秒值，0 表示未归档 )';
 COMMENT ON COLUMN channel_2.creator_id IS '创建者 ID';
 COMMENT ON COLUMN channel_2.created_at IS '创建时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN channel_2.updated_at IS '更新时间戳 ( Unix 毫秒值 )';
@@ -155,6 +171,8 @@ CREATE INDEX idx_channel_creator_2 ON channel_2(creator_id);
 
 CREATE INDEX idx_channel_status_2 ON channel_2(channel_status);
 
+CREATE INDEX idx_channel_last_message_2 ON channel_2(last_message_at) WHERE last_message_at > message_archived_at;
+
 -- 分表 3: channel_3
 DROP TABLE IF EXISTS channel_3;
 CREATE TABLE channel_3 (
@@ -171,6 +189,9 @@ CREATE TABLE channel_3 (
     -- 最后消息时间 ( 用于判断活跃度 )
     last_message_at BIGINT NOT NULL DEFAULT 0,
 
+    -- 消息归档时间 ( 长期不活跃频道的消息会被迁移到冷存储 )
+    message_archived_at BIGINT NOT NULL DEFAULT 0,
+
     creator_id BIGINT NOT NULL,
 
     created_at BIGINT NOT NULL,
@@ -186,6 +207,7 @@ COMMENT ON COLUMN channel_3.channel_status IS '频道状态 ( creating=创建中
 COMMENT ON COLUMN channel_3.channel_info_ver IS '【 CQRS 关键字段 】频道信息版本号 ( 用于同步检测 )';
 COMMENT ON COLUMN channel_3.channel_member_count IS '频道成员数量';
 COMMENT ON COLUMN channel_3.last_message_at IS '最后消息时间戳 ( Unix 毫秒值 )';
+COMMENT ON COLUMN channel_3.message_archived_at IS '消息归档时间戳 ( Unix 毫秒值，0 表示未归档 )';
 COMMENT ON COLUMN channel_3.creator_id IS '创建者 ID';
 COMMENT ON COLUMN channel_3.created_at IS '创建时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN channel_3.updated_at IS '更新时间戳 ( Unix 毫秒值 )';
@@ -197,6 +219,8 @@ CREATE INDEX idx_channel_creator_3 ON channel_3(creator_id);
 
 CREATE INDEX idx_channel_status_3 ON channel_3(channel_status);
 
+CREATE INDEX idx_channel_last_message_3 ON channel_3(last_message_at) WHERE last_message_at > message_archived_at;
+
 -- ============================================
 -- 表: channel_application (分表数: 4)
 -- ============================================
@@ -641,6 +665,78 @@ COMMENT ON COLUMN channel_read_record_3.updated_at IS '更新时间戳 ( Unix 
 -- 创建索引
 CREATE INDEX idx_channel_read_user_channel_3 ON channel_read_record_3(user_id, channel_id);
 
+-- ============================================
+-- 表: contact_applicant_index (分表数: 4)
+-- ============================================
+
+-- 分表 0: contact_applicant_index_0
+DROP TABLE IF EXISTS contact_applicant_index_0;
+CREATE TABLE contact_applicant_index_0 (
+    applicant_id BIGINT NOT NULL,                           -- 申请人 ID
+    application_id BIGINT NOT NULL,                         -- 申请 ID ( 包含 contact_application 的分片信息 )
+
+    created_at BIGINT NOT NULL,
+
+    PRIMARY KEY(applicant_id, application_id)
+);
+
+COMMENT ON TABLE contact_applicant_index_0 IS '【 二级索引 】联系人申请的申请人索引表 ( 用于查询 "某个用户发出的申请" )';
+COMMENT ON COLUMN contact_applicant_index_0.applicant_id IS '申请人 ID';
+COMMENT ON COLUMN contact_applicant_index_0.application_id IS '联系人申请 ID';
+COMMENT ON COLUMN contact_applicant_index_0.created_at IS '创建时间戳 ( Unix 毫秒值 )';
+
+
+-- 分表 1: contact_applicant_index_1
+DROP TABLE IF EXISTS contact_applicant_index_1;
+CREATE TABLE contact_applicant_index_1 (
+    applicant_id BIGINT NOT NULL,                           -- 申请人 ID
+    application_id BIGINT NOT NULL,                         -- 申请 ID ( 包含 contact_application 的分片信息 )
+
+    created_at BIGINT NOT NULL,
+
+    PRIMARY KEY(applicant_id, application_id)
+);
+
+COMMENT ON TABLE contact_applicant_index_1 IS '【 二级索引 】联系人申请的申请人索引表 ( 用于查询 "某个用户发出的申请" )';
+COMMENT ON COLUMN contact_applicant_index_1.applicant_id IS '申请人 ID';
+COMMENT ON COLUMN contact_applicant_index_1.application_id IS '联系人申请 ID';
+COMMENT ON COLUMN contact_applicant_index_1.created_at IS '创建时间戳 ( Unix 毫秒值 )';
+
+
+-- 分表 2: contact_applicant_index_2
+DROP TABLE IF EXISTS contact_applicant_index_2;
+CREATE TABLE contact_applicant_index_2 (
+    applicant_id BIGINT NOT NULL,                           -- 申请人 ID
+    application_id BIGINT NOT NULL,                         -- 申请 ID ( 包含 contact_application 的分片信息 )
+
+    created_at BIGINT NOT NULL,
+
+    PRIMARY KEY(applicant_id, application_id)
+);
+
+COMMENT ON TABLE contact_applicant_index_2 IS '【 二级索引 】联系人申请的申请人索引表 ( 用于查询 "某个用户发出的申请" )';
+COMMENT ON COLUMN contact_applicant_index_2.applicant_id IS '申请人 ID';
+COMMENT ON COLUMN contact_applicant_index_2.application_id IS '联系人申请 ID';
+COMMENT ON COLUMN contact_applicant_index_2.created_at IS '创建时间戳 ( Unix 毫秒值 )';
+
+
+-- 分表 3: contact_applicant_index_3
+DROP TABLE IF EXISTS contact_applicant_index_3;
+CREATE TABLE contact_applicant_index_3 (
+    applicant_id BIGINT NOT NULL,                           -- 申请人 ID
+    application_id BIGINT NOT NULL,                         -- 申请 ID ( 包含 contact_application 的分片信息 )
+
+    created_at BIGINT NOT NULL,
+
+    PRIMARY KEY(applicant_id, application_id)
+);
+
+COMMENT ON TABLE contact_applicant_index_3 IS '【 二级索引 】联系人申请的申请人索引表 ( 用于查询 "某个用户发出的申请" )';
+COMMENT ON COLUMN contact_applicant_index_3.applicant_id IS '申请人 ID';
+COMMENT ON COLUMN contact_applicant_index_3.application_id IS '联系人申请 ID';
+COMMENT ON COLUMN contact_applicant_index_3.created_at IS '创建时间戳 ( Unix 毫秒值 )';
+
+
 -- ============================================
 -- 表: contact_application (分表数: 4)
 -- ============================================
@@ -1565,6 +1661,7 @@ CREATE TABLE user_conversation_view_0 (
     -- 未读数（冗余，实时更新）
     unread_count INT NOT NULL DEFAULT 0,
     mention_count INT NOT NULL DEFAULT 0,                       -- @我的消息数
+    last_mention_message_id BIGINT NOT NULL DEFAULT 0,          -- 最后一条 @我的消息 ID ( 读到该消息后清零 )
 
     -- 会话状态
     opened_at BIGINT NOT NULL,                                  -- 会话创建时间
@@ -1608,6 +1705,7 @@ COMMENT ON COLUMN user_conversation_view_0.last_message_sender_name IS '最后
 COMMENT ON COLUMN user_conversation_view_0.last_message_time IS '最后消息时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view_0.unread_count IS '未读消息数';
 COMMENT ON COLUMN user_conversation_view_0.mention_count IS '@我的消息数';
+COMMENT ON COLUMN user_conversation_view_0.last_mention_message_id IS '最后一条 @我的消息 ID ( 0 表示没有未读的 @ 消息 )';
 COMMENT ON COLUMN user_conversation_view_0.opened_at IS '会话创建时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view_0.closed_at IS '会话删除时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view_0.created_at IS '创建时间戳 ( Unix 毫秒值 )';
@@ -1676,6 +1774,7 @@ CREATE TABLE user_conversation_view_1 (
     -- 未读数（冗余，实时更新）
     unread_count INT NOT NULL DEFAULT 0,
     mention_count INT NOT NULL DEFAULT 0,                       -- @我的消息数
+    last_mention_message_id BIGINT NOT NULL DEFAULT 0,          -- 最后一条 @我的消息 ID ( 读到该消息后清零 )
 
     -- 会话状态
     opened_at BIGINT NOT NULL,                                  -- 会话创建时间
@@ -1719,6 +1818,7 @@ COMMENT ON COLUMN user_conversation_view_1.last_message_sender_name IS '最后
 COMMENT ON COLUMN user_conversation_view_1.last_message_time IS '最后消息时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view_1.unread_count IS '未读消息数';
 COMMENT ON COLUMN user_conversation_view_1.mention_count IS '@我的消息数';
+COMMENT ON COLUMN user_conversation_view_1.last_mention_message_id IS '最后一条 @我的消息 ID ( 0 表示没有未读的 @ 消息 )';
 COMMENT ON COLUMN user_conversation_view_1.opened_at IS '会话创建时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view_1.closed_at IS '会话删除时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view_1.created_at IS '创建时间戳 ( Unix 毫秒值 )';
@@ -1787,6 +1887,7 @@ CREATE TABLE user_conversation_view_2 (
     -- 未读数（冗余，实时更新）
     unread_count INT NOT NULL DEFAULT 0,
     mention_count INT NOT NULL DEFAULT 0,                       -- @我的消息数
+    last_mention_message_id BIGINT NOT NULL DEFAULT 0,          -- 最后一条 @我的消息 ID ( 读到该消息后清零 )
 
     -- 会话状态
     opened_at BIGINT NOT NULL,                                  -- 会话创建时间
@@ -1830,6 +1931,7 @@ COMMENT ON COLUMN user_conversation_view_2.last_message_sender_name IS '最后
 COMMENT ON COLUMN user_conversation_view_2.last_message_time IS '最后消息时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view_2.unread_count IS '未读消息数';
 COMMENT ON COLUMN user_conversation_view_2.mention_count IS '@我的消息数';
+COMMENT ON COLUMN user_conversation_view_2.last_mention_message_id IS '最后一条 @我的消息 ID ( 0 表示没有未读的 @ 消息 )';
 COMMENT ON COLUMN user_conversation_view_2.opened_at IS '会话创建时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view_2.closed_at IS '会话删除时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view_2.created_at IS '创建时间戳 ( Unix 毫秒值 )';
@@ -1898,6 +2000,7 @@ CREATE TABLE user_conversation_view_3 (
     -- 未读数（冗余，实时更新）
     unread_count INT NOT NULL DEFAULT 0,
     mention_count INT NOT NULL DEFAULT 0,                       -- @我的消息数
+    last_mention_message_id BIGINT NOT NULL DEFAULT 0,          -- 最后一条 @我的消息 ID ( 读到该消息后清零 )
 
     -- 会话状态
     opened_at BIGINT NOT NULL,                                  -- 会话创建时间
@@ -1941,6 +2044,7 @@ COMMENT ON COLUMN user_conversation_view_3.last_message_sender_name IS '最后
 COMMENT ON COLUMN user_conversation_view_3.last_message_time IS '最后消息时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view_3.unread_count IS '未读消息数';
 COMMENT ON COLUMN user_conversation_view_3.mention_count IS '@我的消息数';
+COMMENT ON COLUMN user_conversation_view_3.last_mention_message_id IS '最后一条 @我的消息 ID ( 0 表示没有未读的 @ 消息 )';
 COMMENT ON COLUMN user_conversation_view_3.opened_at IS '会话创建时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view_3.closed_at IS '会话删除时间戳 ( Unix 毫秒值 )';
 COMMENT ON COLUMN user_conversation_view_3.created_at IS '创建时间戳 ( Unix 毫秒值 )';
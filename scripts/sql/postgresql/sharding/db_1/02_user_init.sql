@@ -2,7 +2,7 @@
 -- 分库分表SQL脚本
 -- 数据库: hermet_1
 -- 分表数量: 4
--- 生成时间: 2026-01-09 19:22:17
+-- 生成时间: 2026-10-19 04:30:25
 -- 原始文件: ./02_user_init.sql
 -- ============================================
 
@@ -244,3 +244,67 @@ CREATE INDEX idx_biz_user_nickname_3 ON biz_user_3(nickname) WHERE deleted_at =
 
 CREATE INDEX idx_biz_user_status_3 ON biz_user_3(user_status, deleted_at);
 
+-- ============================================
+-- 表: biz_user_mobile_index (分表数: 4)
+-- ============================================
+
+-- 分表 0: biz_user_mobile_index_0
+DROP TABLE IF EXISTS biz_user_mobile_index_0;
+CREATE TABLE biz_user_mobile_index_0 (
+    mobile VARCHAR(16) PRIMARY KEY,                    -- 手机号 ( 全局唯一 )
+    user_id BIGINT NOT NULL,                           -- 用户 ID
+
+    created_at BIGINT NOT NULL
+);
+
+COMMENT ON TABLE biz_user_mobile_index_0 IS '【 二级索引 】用户手机号索引表 ( 根据手机号定位用户，同时保证手机号全局唯一 )';
+COMMENT ON COLUMN biz_user_mobile_index_0.mobile IS '手机号';
+COMMENT ON COLUMN biz_user_mobile_index_0.user_id IS '用户 ID';
+COMMENT ON COLUMN biz_user_mobile_index_0.created_at IS '创建时间戳 ( Unix 毫秒值 )';
+
+
+-- 分表 1: biz_user_mobile_index_1
+DROP TABLE IF EXISTS biz_user_mobile_index_1;
+CREATE TABLE biz_user_mobile_index_1 (
+    mobile VARCHAR(16) PRIMARY KEY,                    -- 手机号 ( 全局唯一 )
+    user_id BIGINT NOT NULL,                           -- 用户 ID
+
+    created_at BIGINT NOT NULL
+);
+
+COMMENT ON TABLE biz_user_mobile_index_1 IS '【 二级索引 】用户手机号索引表 ( 根据手机号定位用户，同时保证手机号全局唯一 )';
+COMMENT ON COLUMN biz_user_mobile_index_1.mobile IS '手机号';
+COMMENT ON COLUMN biz_user_mobile_index_1.user_id IS '用户 ID';
+COMMENT ON COLUMN biz_user_mobile_index_1.created_at IS '创建时间戳 ( Unix 毫秒值 )';
+
+
+-- 分表 2: biz_user_mobile_index_2
+DROP TABLE IF EXISTS biz_user_mobile_index_2;
+CREATE TABLE biz_user_mobile_index_2 (
+    mobile VARCHAR(16) PRIMARY KEY,                    -- 手机号 ( 全局唯一 )
+    user_id BIGINT NOT NULL,                           -- 用户 ID
+
+    created_at BIGINT NOT NULL
+);
+
+COMMENT ON TABLE biz_user_mobile_index_2 IS '【 二级索引 】用户手机号索引表 ( 根据手机号定位用户，同时保证手机号全局唯一 )';
+COMMENT ON COLUMN biz_user_mobile_index_2.mobile IS '手机号';
+COMMENT ON COLUMN biz_user_mobile_index_2.user_id IS '用户 ID';
+COMMENT ON COLUMN biz_user_mobile_index_2.created_at IS '创建时间戳 ( Unix 毫秒值 )';
+
+
+-- 分表 3: biz_user_mobile_index_3
+DROP TABLE IF EXISTS biz_user_mobile_index_3;
+CREATE TABLE biz_user_mobile_index_3 (
+    mobile VARCHAR(16) PRIMARY KEY,                    -- 手机号 ( 全局唯一 )
+    user_id BIGINT NOT NULL,                           -- 用户 ID
+
+    created_at BIGINT NOT NULL
+);
+
+COMMENT ON TABLE biz_user_mobile_index_3 IS '【 二级索引 】用户手机号索引表 ( 根据手机号定位用户，同时保证手机号全局唯一 )';
+COMMENT ON COLUMN biz_user_mobile_index_3.mobile IS '手机号';
+COMMENT ON COLUMN biz_user_mobile_index_3.user_id IS '用户 ID';
+COMMENT ON COLUMN biz_user_mobile_index_3.created_at IS '创建时间戳 ( Unix 毫秒值 )';
+
+